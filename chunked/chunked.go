@@ -0,0 +1,130 @@
+// Package chunked downloads the big remote files reading chunks of them in
+// parallel: over a high latency link reading one piece at a time leaves the
+// connection idle most of the time.
+package chunked
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+)
+
+const (
+	// Files with fewer bytes left to copy are read one piece at a time
+	Threshold = 8 * 1024 * 1024
+	// Bytes read by each request of a worker, sftp splits it in packets
+	// sent together
+	chunkSize = 1024 * 1024
+	// Chunks read at the same time
+	workers = 4
+	// Extension of the local files being downloaded, it's dropped once they
+	// are complete
+	PartExt = ".part"
+)
+
+// Get the path where the local file is written while it's downloaded
+func PartPath(localPath string) string {
+	return localPath + PartExt
+}
+
+// Copy the remote file into the local one from the offset, the bytes before
+// it are already there. The copied bytes are written to the counter.
+//
+// The chunks are written at their offset as soon as they're read, when the
+// copy fails the local file is truncated after the last chunk of the
+// uninterrupted part so it can be resumed from its size. The streamed files
+// are copied in order.
+func Copy(srcFile remotefs.File, destFile *os.File, offset int64, counter io.Writer) error {
+	fileInfo, err := srcFile.Stat()
+	if err != nil {
+		return err
+	}
+	size := fileInfo.Size()
+	streamed, ok := srcFile.(remotefs.Streamed)
+	if !fileInfo.Mode().IsRegular() || size-offset < Threshold || ok && streamed.Streamed() {
+		if _, err := srcFile.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := destFile.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		_, err = io.Copy(destFile, io.TeeReader(srcFile, counter))
+		return err
+	}
+
+	var (
+		mu       sync.Mutex // guards counter, copied and firstErr
+		copied   = make(map[int64]bool)
+		firstErr error
+		stopOnce sync.Once
+		wg       sync.WaitGroup
+	)
+	chunks := make(chan int64)
+	stop := make(chan struct{})
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		mu.Unlock()
+		stopOnce.Do(func() { close(stop) })
+	}
+
+	go func() {
+		defer close(chunks)
+		for chunk := offset; chunk < size; chunk += chunkSize {
+			select {
+			case chunks <- chunk:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, chunkSize)
+			for chunk := range chunks {
+				n := int64(chunkSize)
+				if size-chunk < n {
+					n = size - chunk
+				}
+				read, err := srcFile.ReadAt(buf[:n], chunk)
+				if int64(read) < n {
+					if err == nil || err == io.EOF {
+						err = io.ErrUnexpectedEOF
+					}
+					fail(err)
+					return
+				}
+				if _, err := destFile.WriteAt(buf[:n], chunk); err != nil {
+					fail(err)
+					return
+				}
+				mu.Lock()
+				counter.Write(buf[:n])
+				copied[chunk] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		// Keep only the chunks copied without a gap before them
+		end := offset
+		for copied[end] {
+			end += chunkSize
+		}
+		if end > size {
+			end = size
+		}
+		destFile.Truncate(end)
+		return firstErr
+	}
+	_, err = destFile.Seek(size, io.SeekStart)
+	return err
+}
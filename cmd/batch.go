@@ -0,0 +1,450 @@
+/*
+Copyright © 2022 Guglielmo Bartelloni bartelloni.guglielmo@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/guglielmobartelloni/sftp-tui/conflict"
+	"github.com/guglielmobartelloni/sftp-tui/localpath"
+	"github.com/guglielmobartelloni/sftp-tui/mirror"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+	"github.com/guglielmobartelloni/sftp-tui/throttle"
+	"github.com/pkg/sftp"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	batchFile            string
+	batchContinueOnError bool
+)
+
+// A line of the batch script
+type batchCommand struct {
+	line        int
+	text        string   // the line as written
+	args        []string // the command and its arguments
+	ignoreError bool     // whether its failure doesn't count, the line starts with -
+}
+
+// The commands of the batch scripts, with their number of arguments
+var batchCommands = map[string]struct {
+	minArgs, maxArgs int
+	run              func(b *batch, args []string) error
+}{
+	"cd":    {1, 1, (*batch).cd},
+	"lcd":   {1, 1, (*batch).lcd},
+	"get":   {1, 2, (*batch).get},
+	"put":   {1, 2, (*batch).put},
+	"rm":    {1, 2, (*batch).rm},
+	"mkdir": {1, 2, (*batch).mkdir},
+	"chmod": {2, 2, (*batch).chmod},
+	"sync":  {3, 4, (*batch).sync},
+}
+
+// The outcome of a command of the batch
+type batchResult struct {
+	Line    int    `json:"line"`
+	Command string `json:"command"`
+	Status  string `json:"status"` // ok, failed, ignored or skipped
+	Error   string `json:"error,omitempty"`
+}
+
+// The summary printed once the batch is over
+type batchSummary struct {
+	DryRun    bool          `json:"dry_run"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Ignored   int           `json:"ignored"`
+	Skipped   int           `json:"skipped"`
+	Results   []batchResult `json:"results"`
+}
+
+// The state of the running batch: the directories the relative paths start
+// from, changed by cd and lcd
+type batch struct {
+	client    *sftp.Client
+	remoteDir string
+	localDir  string
+	limiter   *throttle.Limiter
+	policy    conflict.Policy
+	dryRun    bool
+}
+
+// Run the commands of the script, - for stdin, on the host and print the
+// summary on stdout. The batch stops at the first failure, unless asked to
+// go on; it exits with 1 when a command failed.
+func runBatch(cmd *cobra.Command, script, host string) {
+	file := os.Stdin
+	if script != "-" {
+		var err error
+		file, err = os.Open(script)
+		cobra.CheckErr(err)
+		defer file.Close()
+	}
+	// The whole script is checked before connecting
+	commands, err := parseBatch(file)
+	cobra.CheckErr(err)
+
+	remote, _ := parseRemotePath(host + ":")
+	if isSFTPURL(host) {
+		remote, err = parseSFTPURL(host)
+		cobra.CheckErr(err)
+	}
+	client, close, err := connectTo(remote)
+	cobra.CheckErr(err)
+	defer close()
+
+	b := &batch{
+		client:  client,
+		limiter: transferLimiter(),
+		policy:  conflictPolicy(),
+		dryRun:  viper.GetBool("DryRun"),
+	}
+	if b.remoteDir, err = client.Getwd(); err != nil {
+		close()
+		cobra.CheckErr(fmt.Errorf("reading the remote directory failed %v", err))
+	}
+	if b.localDir, err = os.Getwd(); err != nil {
+		close()
+		cobra.CheckErr(fmt.Errorf("reading the local directory failed %v", err))
+	}
+	// The script starts from the directories of the flags or the config
+	// file, the path of the url wins over the config file
+	remoteDir := viper.GetString("RemoteDir")
+	if isSFTPURL(host) && remote.path != "" && !cmd.Flags().Changed("remote-dir") {
+		remoteDir = remote.path
+	}
+	if remoteDir != "" {
+		b.remoteDir = b.remotePath(remoteDir)
+	}
+	if dir := viper.GetString("LocalDir"); dir != "" {
+		b.localDir = b.localPath(dir)
+	}
+
+	summary := batchSummary{DryRun: b.dryRun, Results: []batchResult{}}
+	stopped := false
+	for _, command := range commands {
+		result := batchResult{Line: command.line, Command: command.text, Status: "ok"}
+		if stopped {
+			result.Status = "skipped"
+			summary.Skipped++
+			summary.Results = append(summary.Results, result)
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "> %s\n", command.text)
+		err := batchCommands[command.args[0]].run(b, command.args[1:])
+		switch {
+		case err == nil:
+			summary.Succeeded++
+		case command.ignoreError:
+			result.Status, result.Error = "ignored", err.Error()
+			summary.Ignored++
+			fmt.Fprintf(os.Stderr, "Ignored: %v\n", err)
+		default:
+			result.Status, result.Error = "failed", err.Error()
+			summary.Failed++
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			stopped = !batchContinueOnError
+		}
+		summary.Results = append(summary.Results, result)
+	}
+
+	printJSON(summary)
+	if summary.Failed > 0 {
+		close()
+		cobra.CheckErr(fmt.Errorf("%d of the batch commands failed", summary.Failed))
+	}
+}
+
+// Read the commands of the script, one per line. The empty lines and the
+// ones starting with # are left out, a - before the command ignores its
+// failure.
+func parseBatch(r io.Reader) ([]batchCommand, error) {
+	var commands []batchCommand
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		command := batchCommand{line: line, text: text}
+		if strings.HasPrefix(text, "-") {
+			command.ignoreError = true
+			text = text[1:]
+		}
+		args, err := splitArgs(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("line %d: no command", line)
+		}
+		spec, ok := batchCommands[args[0]]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown command %s, use cd, lcd, get, put, rm, mkdir, chmod or sync", line, args[0])
+		}
+		if len(args)-1 < spec.minArgs || len(args)-1 > spec.maxArgs {
+			return nil, fmt.Errorf("line %d: wrong number of arguments for %s", line, args[0])
+		}
+		command.args = args
+		commands = append(commands, command)
+	}
+	return commands, scanner.Err()
+}
+
+// Split the line in its arguments like a shell does: the quotes keep the
+// spaces, a backslash escapes the next character outside single quotes
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg, escaped := false, false
+	var quote rune
+	for _, r := range line {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("missing closing %c", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("nothing to escape after the backslash")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// Get the remote path relative to the current remote directory
+func (b *batch) remotePath(remote string) string {
+	if path.IsAbs(remote) {
+		return path.Clean(remote)
+	}
+	return b.client.Join(b.remoteDir, remote)
+}
+
+// Get the local path relative to the current local directory
+func (b *batch) localPath(local string) string {
+	local = localpath.ExpandHome(local)
+	if filepath.IsAbs(local) {
+		return filepath.Clean(local)
+	}
+	return filepath.Join(b.localDir, local)
+}
+
+// Change the remote directory
+func (b *batch) cd(args []string) error {
+	dir := b.remotePath(args[0])
+	info, err := b.client.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	b.remoteDir = dir
+	return nil
+}
+
+// Change the local directory
+func (b *batch) lcd(args []string) error {
+	dir := b.localPath(args[0])
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	b.localDir = dir
+	return nil
+}
+
+// Download the remote file into the local directory, or to the local path
+func (b *batch) get(args []string) error {
+	remote := b.remotePath(args[0])
+	local := b.localDir
+	if len(args) > 1 {
+		local = b.localPath(args[1])
+	}
+	if info, err := os.Stat(local); err == nil && info.IsDir() {
+		local = filepath.Join(local, localpath.Name(path.Base(remote)))
+	}
+
+	local, resume, ok := resolveGet(b.client, b.policy, remote, local)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Skipped %s, %s exists\n", remote, local)
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "download\t%s\t%s\n", remote, local)
+	if b.dryRun {
+		return nil
+	}
+	if err := getFile(b.client, remote, local, resume, b.limiter); err != nil {
+		return fmt.Errorf("downloading %s failed: %v", remote, err)
+	}
+	return nil
+}
+
+// Upload the local file into the remote directory, or to the remote path
+func (b *batch) put(args []string) error {
+	local := b.localPath(args[0])
+	remote := b.remoteDir
+	if len(args) > 1 {
+		remote = b.remotePath(args[1])
+	}
+	if info, err := b.client.Stat(remote); err == nil && info.IsDir() {
+		remote = b.client.Join(remote, filepath.Base(local))
+	}
+
+	remote, resume, ok := resolvePut(b.client, b.policy, local, remote)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Skipped %s, %s exists\n", local, remote)
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "upload\t%s\t%s\n", local, remote)
+	if b.dryRun {
+		return nil
+	}
+	if err := putFile(b.client, local, remote, resume, b.limiter); err != nil {
+		return fmt.Errorf("uploading %s failed: %v", local, err)
+	}
+	return nil
+}
+
+// Delete the remote file or empty directory, with -r a directory with its
+// content
+func (b *batch) rm(args []string) error {
+	recursive := args[0] == "-r"
+	if recursive == (len(args) == 1) {
+		return fmt.Errorf("use rm [-r] <path>")
+	}
+	remote := b.remotePath(args[len(args)-1])
+	fmt.Fprintf(os.Stderr, "delete\t%s\n", remote)
+	if b.dryRun {
+		return nil
+	}
+	if recursive {
+		return remotefs.RemoveAll(remotefs.NewSFTP(b.client), remote)
+	}
+	return b.client.Remove(remote)
+}
+
+// Create the remote directory, with -p its parents too and no error when
+// it exists
+func (b *batch) mkdir(args []string) error {
+	parents := args[0] == "-p"
+	if parents == (len(args) == 1) {
+		return fmt.Errorf("use mkdir [-p] <dir>")
+	}
+	dir := b.remotePath(args[len(args)-1])
+	fmt.Fprintf(os.Stderr, "mkdir\t%s\n", dir)
+	if b.dryRun {
+		return nil
+	}
+	if parents {
+		return b.client.MkdirAll(dir)
+	}
+	return b.client.Mkdir(dir)
+}
+
+// Change the permissions of the remote path to the octal mode
+func (b *batch) chmod(args []string) error {
+	mode, err := strconv.ParseUint(args[0], 8, 32)
+	if err != nil || mode > 07777 {
+		return fmt.Errorf("%s is not an octal mode like 644", args[0])
+	}
+	remote := b.remotePath(args[1])
+	fmt.Fprintf(os.Stderr, "chmod\t%04o\t%s\n", mode, remote)
+	if b.dryRun {
+		return nil
+	}
+	return b.client.Chmod(remote, os.FileMode(mode))
+}
+
+// Mirror a directory: sync push [--delete] <local dir> <remote dir>, or
+// sync pull [--delete] <remote dir> <local dir>
+func (b *batch) sync(args []string) error {
+	direction, args := args[0], args[1:]
+	deleteExtras := args[0] == "--delete"
+	if deleteExtras {
+		args = args[1:]
+	}
+	if len(args) != 2 || (direction != "push" && direction != "pull") {
+		return fmt.Errorf("use sync push|pull [--delete] <source> <destination>")
+	}
+
+	remote := remotefs.NewSFTP(b.client)
+	var actions []mirror.Action
+	var err error
+	apply := mirror.ApplyPush
+	if direction == "push" {
+		actions, err = mirror.PlanPush(remote, b.localPath(args[0]), b.remotePath(args[1]), deleteExtras)
+	} else {
+		actions, err = mirror.PlanPull(remote, b.remotePath(args[0]), b.localPath(args[1]), deleteExtras)
+		apply = mirror.ApplyPull
+	}
+	if err != nil {
+		return err
+	}
+	for _, action := range actions {
+		fmt.Fprintf(os.Stderr, "%s\t%s\n", action.Kind, action.Path)
+		if b.dryRun {
+			continue
+		}
+		if err := apply(remote, action, b.limiter.Writer(io.Discard)); err != nil {
+			return fmt.Errorf("%s of %s failed: %v", action.Kind, action.Path, err)
+		}
+	}
+	return nil
+}
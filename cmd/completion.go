@@ -0,0 +1,208 @@
+/*
+Copyright © 2022 Guglielmo Bartelloni bartelloni.guglielmo@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/guglielmobartelloni/sftp-tui/config"
+	"github.com/guglielmobartelloni/sftp-tui/ssh"
+	"github.com/spf13/cobra"
+)
+
+// completionCmd prints the script completing the commands, the flags and
+// the hosts in the shell
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|fish",
+	Short: "Generate the completion script of the shell",
+	Long: `Print the script completing the subcommands, the flags, the saved
+profiles and the hosts of ~/.ssh/config in the shell.
+
+Bash, needs the bash-completion package:
+  source <(sftp-tui completion bash)
+or, for all the sessions:
+  sftp-tui completion bash > /etc/bash_completion.d/sftp-tui
+
+Zsh, with compinit enabled:
+  sftp-tui completion zsh > "${fpath[1]}/_sftp-tui"
+
+Fish:
+  sftp-tui completion fish > ~/.config/fish/completions/sftp-tui.fish`,
+	Args:                  cobra.ExactValidArgs(1),
+	ValidArgs:             []string{"bash", "zsh", "fish"},
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		switch args[0] {
+		case "bash":
+			err = rootCmd.GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			err = rootCmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			err = rootCmd.GenFishCompletion(os.Stdout, true)
+		}
+		cobra.CheckErr(err)
+	},
+}
+
+// Get the hosts to complete: the aliases of the ssh config and the hosts of
+// the saved profiles
+func knownHosts() []string {
+	seen := map[string]bool{}
+	var hosts []string
+	add := func(host string) {
+		if host != "" && !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+	for _, host := range ssh.Hosts() {
+		add(host)
+	}
+	// Without a readable config file there are no profiles to add
+	profiles, _ := config.Profiles()
+	for _, profile := range profiles {
+		add(profile.Host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// Get the names of the saved profiles
+func profileNames() []string {
+	profiles, _ := config.Profiles()
+	var names []string
+	for _, profile := range profiles {
+		names = append(names, profile.Name)
+	}
+	return names
+}
+
+// Complete the remote path being typed with the hosts followed by ":", the
+// user@ typed is kept. The paths on the server aren't completed, connecting
+// would be too slow.
+func remoteCompletions(toComplete string) []string {
+	if strings.Contains(toComplete, ":") {
+		return nil
+	}
+	user := ""
+	if at := strings.LastIndex(toComplete, "@"); at >= 0 {
+		user, toComplete = toComplete[:at+1], toComplete[at+1:]
+	}
+	var completions []string
+	for _, host := range knownHosts() {
+		if strings.HasPrefix(host, toComplete) {
+			completions = append(completions, user+host+":")
+		}
+	}
+	return completions
+}
+
+// Complete an argument that can only be a remote path
+func completeRemote(toComplete string) ([]string, cobra.ShellCompDirective) {
+	return remoteCompletions(toComplete), cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+}
+
+// Complete an argument that can be a remote or a local path: the hosts
+// matching what's typed, the local files otherwise
+func completeRemoteOrLocal(toComplete string) ([]string, cobra.ShellCompDirective) {
+	if toComplete == "" {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	if completions := remoteCompletions(toComplete); len(completions) > 0 {
+		return completions, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+	}
+	return nil, cobra.ShellCompDirectiveDefault
+}
+
+// Complete the host of the tui and the local files to upload after it, or
+// the directory browsed with --local
+func completeRootArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	// The local directory to browse takes the place of the host
+	if browseLocal {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	return knownHosts(), cobra.ShellCompDirectiveNoFileComp
+}
+
+// Complete the single remote path of ls and stat
+func completeRemoteArg(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeRemote(toComplete)
+}
+
+// Complete the remote files of get, the last argument can be local
+func completeGetArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completeRemote(toComplete)
+	}
+	return completeRemoteOrLocal(toComplete)
+}
+
+// Complete the local files of put, the last argument is remote
+func completePutArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	return completeRemoteOrLocal(toComplete)
+}
+
+// Complete the local and the remote directory of sync push
+func completeSyncPushArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	case 1:
+		return completeRemote(toComplete)
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+// Complete the remote and the local directory of sync pull
+func completeSyncPullArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	switch len(args) {
+	case 0:
+		return completeRemote(toComplete)
+	case 1:
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+// Complete the names of the saved profiles
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return profileNames(), cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+}
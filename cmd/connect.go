@@ -0,0 +1,43 @@
+/*
+Copyright © 2022 Guglielmo Bartelloni bartelloni.guglielmo@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// connectCmd starts the tui like the root command, for the scripts and the
+// aliases that rather name what they do
+var connectCmd = &cobra.Command{
+	Use:   "connect [host] [local files...]",
+	Short: "Connect to the host and start the TUI",
+	Long: `Connect to the host and start the TUI, the same as running sftp-tui
+without a subcommand: see sftp-tui --help for the host, the profiles and
+the files uploaded once connected.`,
+	Args:              cobra.ArbitraryArgs,
+	ValidArgsFunction: completeRootArgs,
+	Run:               runTUI,
+}
+
+func init() {
+	rootCmd.AddCommand(connectCmd)
+}
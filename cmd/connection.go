@@ -0,0 +1,375 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/guglielmobartelloni/sftp-tui/config"
+	"github.com/guglielmobartelloni/sftp-tui/conflict"
+	"github.com/guglielmobartelloni/sftp-tui/ssh"
+	"github.com/guglielmobartelloni/sftp-tui/throttle"
+	"github.com/pkg/sftp"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// Get the connection settings of the config file, overridden by the
+// profile, when given, or by the ssh config of the host
+func resolveConnection(profileName, host string) (config.Profile, error) {
+	connection := config.Profile{
+		Host:            viper.GetString("Host"),
+		Port:            viper.GetString("Port"),
+		Username:        viper.GetString("Username"),
+		PrivateKeyPath:  viper.GetString("PrivateKeyPath"),
+		CertificatePath: viper.GetString("CertificatePath"),
+		KnownHostsPath:  viper.GetString("KnownHostsPath"),
+		ProxyJump:       viper.GetString("ProxyJump"),
+		Proxy:           viper.GetString("Proxy"),
+		LocalDir:        viper.GetString("LocalDir"),
+		Legacy:          viper.GetBool("Legacy"),
+		RemoteDir:       viper.GetString("RemoteDir"),
+	}
+
+	switch {
+	case profileName != "":
+		profile, err := config.FindProfile(profileName)
+		if err != nil {
+			return config.Profile{}, err
+		}
+		applyProfile(&connection, profile)
+	case host != "":
+		hostConfig := ssh.ResolveHost(host)
+		applyProfile(&connection, config.Profile{
+			Host:            hostConfig.HostName,
+			Port:            hostConfig.Port,
+			Username:        hostConfig.User,
+			PrivateKeyPath:  hostConfig.IdentityFile,
+			CertificatePath: hostConfig.CertificateFile,
+			ProxyJump:       hostConfig.ProxyJump,
+		})
+	}
+
+	// The flags win over the profile and the ssh config
+	if jumpHosts != "" {
+		connection.ProxyJump = jumpHosts
+	}
+	if proxyURL != "" {
+		connection.Proxy = proxyURL
+	}
+	return connection, nil
+}
+
+// Override the connection settings with the ones set in the profile
+func applyProfile(connection *config.Profile, profile config.Profile) {
+	if profile.Name != "" {
+		connection.Name = profile.Name
+	}
+	if profile.Host != "" {
+		connection.Host = profile.Host
+	}
+	if profile.Port != "" {
+		connection.Port = profile.Port
+	}
+	if profile.Username != "" {
+		connection.Username = profile.Username
+	}
+	if profile.PrivateKeyPath != "" {
+		connection.PrivateKeyPath = profile.PrivateKeyPath
+	}
+	if profile.CertificatePath != "" {
+		connection.CertificatePath = profile.CertificatePath
+	}
+	if profile.KnownHostsPath != "" {
+		connection.KnownHostsPath = profile.KnownHostsPath
+	}
+	if profile.ProxyJump != "" {
+		connection.ProxyJump = profile.ProxyJump
+	}
+	if profile.Proxy != "" {
+		connection.Proxy = profile.Proxy
+	}
+	if profile.LocalDir != "" {
+		connection.LocalDir = profile.LocalDir
+	}
+	if profile.Legacy {
+		connection.Legacy = true
+	}
+	if profile.RemoteDir != "" {
+		connection.RemoteDir = profile.RemoteDir
+	}
+}
+
+// Environment variables holding the secrets, for the scripts that can't
+// answer the prompts and mustn't show them on the command line
+const (
+	passwordEnv   = "SSSFTP_PASSWORD"
+	passphraseEnv = "SSSFTP_PASSPHRASE"
+)
+
+// Password read from stdin with --password-stdin
+var stdinPassword string
+
+// Read the password from the first line of stdin
+func readStdinPassword() (string, error) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", errors.New("--password-stdin reads the password piped on stdin, like echo \"$PASSWORD\" | sftp-tui --password-stdin ...")
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("reading the password from stdin failed %v", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("no password on stdin")
+	}
+	return password, nil
+}
+
+// Get the password of the user on the server, from stdin or the environment
+func loginPassword() string {
+	if stdinPassword != "" {
+		return stdinPassword
+	}
+	return os.Getenv(passwordEnv)
+}
+
+// Get the passphrase of the private key, from the environment or the config
+// file
+func keyPassphrase() string {
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		return passphrase
+	}
+	return viper.GetString("Password")
+}
+
+// Get the settings of the ssh connection to the host of the profile
+func sshOptions(connection config.Profile) ssh.Options {
+	options := ssh.Options{
+		Username:           connection.Username,
+		PrivateKeyPath:     connection.PrivateKeyPath,
+		PrivateKeyPassword: keyPassphrase(),
+		Password:           loginPassword(),
+		CertificatePath:    connection.CertificatePath,
+		Host:               connection.Host,
+		Port:               connection.Port,
+		KnownHostsPath:     connection.KnownHostsPath,
+		ProxyJump:          connection.ProxyJump,
+		Proxy:              connection.Proxy,
+		MaxPacket:          viper.GetInt("MaxPacket"),
+		MaxRequests:        viper.GetInt("MaxRequests"),
+		Timeout:            viper.GetDuration("ConnectTimeout"),
+		HostKeyAlgorithms:  viper.GetStringSlice("HostKeyAlgorithms"),
+		KeyExchanges:       viper.GetStringSlice("KeyExchanges"),
+		Ciphers:            viper.GetStringSlice("Ciphers"),
+		MACs:               viper.GetStringSlice("MACs"),
+		Legacy:             connection.Legacy,
+	}
+	if viper.GetBool("Keyring") && connection.Name != "" {
+		useKeyring(&options, connection.Name)
+	}
+	return options
+}
+
+// Fill in the secrets of the profile kept in the keyring, the ones from the
+// environment or stdin win, and keep the new ones once logged in
+func useKeyring(options *ssh.Options, profile string) {
+	password, err := config.Secret(profile, config.PasswordSecret)
+	if err != nil {
+		slog.Warn("keyring unavailable", "err", err)
+		return
+	}
+	passphrase, err := config.Secret(profile, config.PassphraseSecret)
+	if err != nil {
+		slog.Warn("keyring unavailable", "err", err)
+		return
+	}
+	if options.Password == "" {
+		options.Password = password
+	}
+	if os.Getenv(passphraseEnv) == "" && passphrase != "" {
+		options.PrivateKeyPassword = passphrase
+	}
+
+	options.Remember = func(newPassword, newPassphrase string) {
+		secrets := []struct{ kind, old, new string }{
+			{config.PasswordSecret, password, newPassword},
+			{config.PassphraseSecret, passphrase, newPassphrase},
+		}
+		for _, secret := range secrets {
+			if secret.new == "" || secret.new == secret.old {
+				continue
+			}
+			if err := config.SetSecret(profile, secret.kind, secret.new); err != nil {
+				slog.Warn("keeping the secret failed", "err", err)
+				continue
+			}
+			slog.Info("secret saved in the keyring", "profile", profile, "kind", secret.kind)
+		}
+	}
+}
+
+// A path on the server in the [user@]host:path form, or the
+// sftp://[user@]host[:port]/path one
+type remotePath struct {
+	user string
+	host string
+	port string
+	path string
+}
+
+// Whether the argument is an sftp:// url
+func isSFTPURL(arg string) bool {
+	scheme, _, ok := strings.Cut(arg, "://")
+	return ok && strings.EqualFold(scheme, "sftp")
+}
+
+// Parse the sftp://[user@]host[:port][/path] url. The path is absolute,
+// /~/path is relative to the home; it's empty without one.
+func parseSFTPURL(arg string) (remotePath, error) {
+	u, err := url.Parse(arg)
+	if err != nil {
+		return remotePath{}, err
+	}
+	if u.Hostname() == "" {
+		return remotePath{}, fmt.Errorf("no host in %s", arg)
+	}
+	if _, ok := u.User.Password(); ok {
+		return remotePath{}, fmt.Errorf("the password can't be in the url, use --password-stdin or %s", passwordEnv)
+	}
+	remote := remotePath{user: u.User.Username(), host: u.Hostname(), port: u.Port(), path: u.Path}
+	switch {
+	case remote.path == "/~" || remote.path == "/~/":
+		remote.path = "."
+	case strings.HasPrefix(remote.path, "/~/"):
+		remote.path = remote.path[len("/~/"):]
+	}
+	return remote, nil
+}
+
+// Override the connection settings with the ones given with the host
+func applyRemote(connection *config.Profile, remote remotePath) {
+	if remote.user != "" {
+		connection.Username = remote.user
+	}
+	if remote.port != "" {
+		connection.Port = remote.port
+	}
+	if remote.path != "" {
+		connection.RemoteDir = remote.path
+	}
+}
+
+// Parse the argument as a remote path, returns false if it's a local one
+func parseRemotePath(arg string) (remotePath, bool) {
+	if isSFTPURL(arg) {
+		remote, err := parseSFTPURL(arg)
+		if remote.path == "" {
+			remote.path = "."
+		}
+		return remote, err == nil
+	}
+	separator := strings.Index(arg, ":")
+	if separator < 0 || strings.Contains(arg[:separator], "/") {
+		return remotePath{}, false
+	}
+	remote := remotePath{host: arg[:separator], path: arg[separator+1:]}
+	if at := strings.LastIndex(remote.host, "@"); at >= 0 {
+		remote.user, remote.host = remote.host[:at], remote.host[at+1:]
+	}
+	if remote.path == "" {
+		remote.path = "."
+	}
+	return remote, true
+}
+
+// Get the connection settings of a new tab, the host can be the name of a
+// saved profile or an sftp:// url
+func tabOptions(host string) (ssh.Options, error) {
+	profile := ""
+	var remote remotePath
+	if _, err := config.FindProfile(host); err == nil {
+		profile = host
+	} else if isSFTPURL(host) {
+		if remote, err = parseSFTPURL(host); err != nil {
+			return ssh.Options{}, err
+		}
+		host = remote.host
+	}
+	connection, err := resolveConnection(profile, host)
+	if err != nil {
+		return ssh.Options{}, err
+	}
+	applyRemote(&connection, remote)
+	if connection.Host == "" {
+		return ssh.Options{}, fmt.Errorf("no host to connect to")
+	}
+	return sshOptions(connection), nil
+}
+
+// Open an sftp session to the host of the remote path, the caller has to
+// call close when done
+func connectTo(remote remotePath) (client *sftp.Client, close func(), err error) {
+	connection, err := resolveConnection(profileName, remote.host)
+	if err != nil {
+		return nil, nil, err
+	}
+	applyRemote(&connection, remotePath{user: remote.user, port: remote.port})
+	if connection.Host == "" {
+		return nil, nil, fmt.Errorf("no host to connect to, pass one as host:path or set Host in the config file")
+	}
+
+	sshClient, err := ssh.ConnectSSH(sshOptions(connection))
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err = ssh.NewSFTP(sshClient, sshOptions(connection))
+	if err != nil {
+		sshClient.Close()
+		return nil, nil, fmt.Errorf("starting the sftp session failed %v", err)
+	}
+	return client, func() {
+		client.Close()
+		sshClient.Close()
+	}, nil
+}
+
+// Parse the arguments as remote paths, all on the same host
+func parseRemotePaths(args []string) ([]remotePath, error) {
+	var remotes []remotePath
+	for _, arg := range args {
+		remote, ok := parseRemotePath(arg)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a remote path, use [user@]host:path or sftp://[user@]host[:port]/path", arg)
+		}
+		if len(remotes) > 0 && (remote.host != remotes[0].host || remote.user != remotes[0].user || remote.port != remotes[0].port) {
+			return nil, fmt.Errorf("%s is not on %s", arg, remotes[0].host)
+		}
+		remotes = append(remotes, remote)
+	}
+	return remotes, nil
+}
+
+// Get the policy of get and put for the files whose destination exists,
+// asking isn't possible: they are overwritten like cp does
+func conflictPolicy() conflict.Policy {
+	policy, err := conflict.ParsePolicy(viper.GetString("OnConflict"))
+	cobra.CheckErr(err)
+	if policy == conflict.Ask {
+		return conflict.Overwrite
+	}
+	return policy
+}
+
+// Get the limiter capping the speed of the transfers at --limit-rate
+func transferLimiter() *throttle.Limiter {
+	rate, err := throttle.ParseRate(viper.GetString("LimitRate"))
+	cobra.CheckErr(err)
+	return throttle.NewLimiter(rate)
+}
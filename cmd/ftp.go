@@ -0,0 +1,117 @@
+/*
+Copyright © 2022 Guglielmo Bartelloni bartelloni.guglielmo@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/guglielmobartelloni/sftp-tui/config"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+	"github.com/guglielmobartelloni/sftp-tui/ssh"
+	"github.com/guglielmobartelloni/sftp-tui/tui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Protection and default port of the connections of the ftp url schemes:
+// ftps is TLS from the start, ftpes upgrades the plain connection to it
+var ftpSchemes = map[string]struct {
+	security remotefs.FTPSecurity
+	port     int
+}{
+	"ftp":   {remotefs.FTPPlain, 21},
+	"ftps":  {remotefs.FTPImplicitTLS, 990},
+	"ftpes": {remotefs.FTPExplicitTLS, 21},
+}
+
+// Whether the host is the url of an ftp server
+func isFTPURL(host string) bool {
+	scheme, _, ok := strings.Cut(host, "://")
+	_, known := ftpSchemes[strings.ToLower(scheme)]
+	return ok && known
+}
+
+// Get the options of the connection to the ftp server of the url, and the
+// directory in its path. Without a user the login is anonymous, the
+// password comes from the url, stdin, the environment or the user.
+func ftpOptions(rawURL string) (remotefs.FTPOptions, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return remotefs.FTPOptions{}, "", err
+	}
+	scheme := ftpSchemes[strings.ToLower(u.Scheme)]
+	options := remotefs.FTPOptions{
+		Host:     u.Hostname(),
+		Port:     scheme.port,
+		User:     "anonymous",
+		Password: "anonymous",
+		Security: scheme.security,
+		Timeout:  viper.GetDuration("ConnectTimeout"),
+	}
+	if options.Host == "" {
+		return remotefs.FTPOptions{}, "", fmt.Errorf("no host in %s", rawURL)
+	}
+	if port := u.Port(); port != "" {
+		if options.Port, err = strconv.Atoi(port); err != nil {
+			return remotefs.FTPOptions{}, "", fmt.Errorf("invalid port %s", port)
+		}
+	}
+	if u.User != nil {
+		options.User = u.User.Username()
+		password, ok := u.User.Password()
+		if !ok {
+			password = loginPassword()
+		}
+		if !ok && password == "" {
+			answers, ok := tui.AskChallenge("", "", []string{fmt.Sprintf("Password for %s@%s:", options.User, options.Host)}, []bool{false})
+			if !ok {
+				return remotefs.FTPOptions{}, "", fmt.Errorf("login cancelled")
+			}
+			password = answers[0]
+		}
+		options.Password = password
+	}
+	return options, u.Path, nil
+}
+
+// Start the tui browsing the ftp server of the url, the local paths
+// following it are uploaded to the start directory
+func runFTP(rawURL string, localPaths []string) {
+	if profileName != "" || batchFile != "" || saveProfileName != "" {
+		cobra.CheckErr(fmt.Errorf("the ftp servers can't be used with --profile, --save-profile or --batch"))
+	}
+	uploads := uploadPaths(localPaths)
+	options, remoteDir, err := ftpOptions(rawURL)
+	cobra.CheckErr(err)
+
+	connection := config.Profile{LocalDir: viper.GetString("LocalDir"), RemoteDir: viper.GetString("RemoteDir")}
+	if remoteDir != "" {
+		connection.RemoteDir = remoteDir
+	}
+	settings := tuiSettings(connection)
+	settings.Upload = uploads
+	settings.FTP = &options
+	cobra.CheckErr(tui.StartProgram(ssh.Options{}, settings))
+}
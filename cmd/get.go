@@ -0,0 +1,175 @@
+/*
+Copyright © 2022 Guglielmo Bartelloni bartelloni.guglielmo@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/guglielmobartelloni/sftp-tui/chunked"
+	"github.com/guglielmobartelloni/sftp-tui/conflict"
+	"github.com/guglielmobartelloni/sftp-tui/localpath"
+	"github.com/guglielmobartelloni/sftp-tui/throttle"
+	"github.com/pkg/sftp"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// getCmd downloads remote files without starting the tui
+var getCmd = &cobra.Command{
+	Use:   "get [user@]host:path... [local path]",
+	Short: "Download files without starting the TUI",
+	Long: `Download the remote files into the local path, the current directory
+by default. With a single file the local path can be the new name of the
+file. All the files have to be on the same host. The existing local files
+are overwritten, --on-conflict chooses otherwise. Each file is written
+with the .part extension and renamed once complete, the .part file of an
+interrupted download is continued.
+With --dry-run each copy is printed on a line instead.`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeGetArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		destination := "."
+		if _, ok := parseRemotePath(args[len(args)-1]); !ok {
+			destination = args[len(args)-1]
+			args = args[:len(args)-1]
+		}
+		sources, err := parseRemotePaths(args)
+		cobra.CheckErr(err)
+
+		limiter, policy, dryRun := transferLimiter(), conflictPolicy(), viper.GetBool("DryRun")
+		client, close, err := connectTo(sources[0])
+		cobra.CheckErr(err)
+		defer close()
+
+		destInfo, err := os.Stat(destination)
+		isDir := err == nil && destInfo.IsDir()
+		if len(sources) > 1 && !isDir {
+			cobra.CheckErr(fmt.Errorf("%s is not a directory", destination))
+		}
+
+		for _, source := range sources {
+			localPath := destination
+			if isDir {
+				localPath = filepath.Join(destination, localpath.Name(path.Base(source.path)))
+			}
+			localPath, resume, ok := resolveGet(client, policy, source.path, localPath)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "Skipped %s, %s exists\n", source.path, localPath)
+				continue
+			}
+			if dryRun {
+				fmt.Printf("download\t%s\t%s\n", source.path, localPath)
+				continue
+			}
+			if err := getFile(client, source.path, localPath, resume, limiter); err != nil {
+				close()
+				cobra.CheckErr(fmt.Errorf("downloading %s failed: %v", source.path, err))
+			}
+		}
+	},
+}
+
+// Decide where to download the remote file when the local one exists,
+// following the policy: returns the local path, whether the local file is
+// continued, and false when the file is skipped
+func resolveGet(client *sftp.Client, policy conflict.Policy, remotePath, localPath string) (string, bool, bool) {
+	localInfo, err := os.Stat(localPath)
+	if err != nil {
+		// The download interrupted before goes on
+		partInfo, err := os.Stat(chunked.PartPath(localPath))
+		if err != nil || !partInfo.Mode().IsRegular() {
+			return localPath, false, true
+		}
+		remoteInfo, err := client.Stat(remotePath)
+		return localPath, err == nil && partInfo.Size() <= remoteInfo.Size(), true
+	}
+	// Without the remote file the download fails with its error
+	remoteInfo, err := client.Stat(remotePath)
+	if err != nil {
+		return localPath, false, true
+	}
+	switch policy.Decide(remoteInfo.Size(), remoteInfo.ModTime(), localInfo) {
+	case conflict.SkipFile:
+		return localPath, false, false
+	case conflict.RenameFile:
+		dir := filepath.Dir(localPath)
+		return filepath.Join(dir, conflict.UniqueName(filepath.Base(localPath), func(name string) bool {
+			_, err := os.Stat(filepath.Join(dir, name))
+			return !os.IsNotExist(err)
+		})), false, true
+	case conflict.ResumeFile:
+		return localPath, true, true
+	}
+	return localPath, false, true
+}
+
+// Copy the remote file to the local path, throttled by the limiter. It's
+// written to the .part file and renamed once complete. When resuming the
+// copy continues from the end of the .part file, or of the local one.
+func getFile(client *sftp.Client, remotePath, localPath string, resume bool, limiter *throttle.Limiter) error {
+	srcFile, err := client.Open(remotePath)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	partPath := chunked.PartPath(localPath)
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if resume {
+		flags = os.O_WRONLY
+		if _, err := os.Stat(partPath); os.IsNotExist(err) {
+			if err := os.Rename(localPath, partPath); err != nil {
+				return err
+			}
+		}
+	}
+	destFile, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return err
+	}
+	if resume {
+		offset, err := destFile.Seek(0, io.SeekEnd)
+		if err == nil {
+			_, err = srcFile.Seek(offset, io.SeekStart)
+		}
+		if err != nil {
+			destFile.Close()
+			return err
+		}
+	}
+	if _, err := io.Copy(limiter.Writer(destFile), srcFile); err != nil {
+		destFile.Close()
+		return err
+	}
+	if err := destFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(partPath, localPath)
+}
+
+func init() {
+	rootCmd.AddCommand(getCmd)
+}
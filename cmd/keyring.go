@@ -0,0 +1,69 @@
+/*
+Copyright © 2022 Guglielmo Bartelloni bartelloni.guglielmo@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/guglielmobartelloni/sftp-tui/config"
+	"github.com/spf13/cobra"
+)
+
+// keyringCmd groups the commands managing the secrets kept in the keyring
+var keyringCmd = &cobra.Command{
+	Use:   "keyring",
+	Short: "Manage the passwords and the passphrases kept in the keyring",
+	Long: `With --keyring the password and the passphrase of the private key of a
+profile are kept in the keyring of the system (the macOS Keychain, the
+Secret Service on Linux, the Credential Manager on Windows) once logged
+in, and used for the next connections to the profile.`,
+}
+
+// keyringClearCmd deletes the secrets of the profiles from the keyring
+var keyringClearCmd = &cobra.Command{
+	Use:   "clear [profile...]",
+	Short: "Delete the secrets of the profiles from the keyring",
+	Long: `Delete the password and the passphrase of the profiles from the keyring,
+of all the saved profiles when none is given.`,
+	ValidArgsFunction: completeProfiles,
+	Run: func(cmd *cobra.Command, args []string) {
+		names := args
+		if len(names) == 0 {
+			profiles, err := config.Profiles()
+			cobra.CheckErr(err)
+			for _, profile := range profiles {
+				names = append(names, profile.Name)
+			}
+		}
+
+		for _, name := range names {
+			cleared, err := config.ClearSecrets(name)
+			cobra.CheckErr(err)
+			fmt.Printf("%s\t%d secrets deleted\n", name, cleared)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(keyringCmd)
+	keyringCmd.AddCommand(keyringClearCmd)
+}
@@ -0,0 +1,63 @@
+/*
+Copyright © 2022 Guglielmo Bartelloni bartelloni.guglielmo@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/guglielmobartelloni/sftp-tui/config"
+	"github.com/guglielmobartelloni/sftp-tui/ssh"
+	"github.com/guglielmobartelloni/sftp-tui/tui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Whether the tui browses a local directory instead of a server
+var browseLocal bool
+
+// Start the tui browsing the local directory, the current one without
+// arguments
+func runLocal(args []string) {
+	if len(args) > 1 {
+		cobra.CheckErr(fmt.Errorf("--local browses a single directory, %d given", len(args)))
+	}
+	if profileName != "" || batchFile != "" {
+		cobra.CheckErr(fmt.Errorf("--local connects to no server, it can't be used with --profile or --batch"))
+	}
+	dir := "."
+	if len(args) == 1 {
+		dir = args[0]
+	}
+	dir, err := filepath.Abs(dir)
+	cobra.CheckErr(err)
+	info, err := os.Stat(dir)
+	cobra.CheckErr(err)
+	if !info.IsDir() {
+		cobra.CheckErr(fmt.Errorf("%s is not a directory", dir))
+	}
+
+	settings := tuiSettings(config.Profile{LocalDir: viper.GetString("LocalDir")})
+	settings.Browse = dir
+	cobra.CheckErr(tui.StartProgram(ssh.Options{}, settings))
+}
@@ -0,0 +1,89 @@
+/*
+Copyright © 2022 Guglielmo Bartelloni bartelloni.guglielmo@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// lsCmd lists a remote directory without starting the tui
+var lsCmd = &cobra.Command{
+	Use:   "ls [user@]host:path",
+	Short: "List a remote directory without starting the TUI",
+	Long: `List the remote directory, or show the remote file. Each line has the
+mode, the size in bytes, the modification time in RFC 3339 and the name,
+separated by tabs. With --json an array of the entries is printed instead,
+each with its name, type, size, mode, octal permissions, modification time
+and owner and group ids.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeRemoteArg,
+	Run: func(cmd *cobra.Command, args []string) {
+		remote, ok := parseRemotePath(args[0])
+		if !ok {
+			cobra.CheckErr(fmt.Errorf("%s is not a remote path, use [user@]host:path", args[0]))
+		}
+
+		client, close, err := connectTo(remote)
+		cobra.CheckErr(err)
+		defer close()
+
+		info, err := client.Stat(remote.path)
+		if err != nil {
+			close()
+			cobra.CheckErr(err)
+		}
+		entries := []os.FileInfo{info}
+		if info.IsDir() {
+			entries, err = client.ReadDir(remote.path)
+			if err != nil {
+				close()
+				cobra.CheckErr(err)
+			}
+		}
+
+		if jsonOutput {
+			list := []jsonEntry{}
+			for _, entry := range entries {
+				list = append(list, newJSONEntry(entry))
+			}
+			printJSON(list)
+			return
+		}
+		for _, entry := range entries {
+			fmt.Printf("%s\t%d\t%s\t%s\n",
+				entry.Mode(),
+				entry.Size(),
+				entry.ModTime().UTC().Format(time.RFC3339),
+				entry.Name(),
+			)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lsCmd)
+	addJSONFlag(lsCmd)
+}
@@ -0,0 +1,90 @@
+/*
+Copyright © 2022 Guglielmo Bartelloni bartelloni.guglielmo@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/spf13/cobra"
+)
+
+// Whether the subcommands print JSON instead of the lines made for people
+var jsonOutput bool
+
+// A remote file as printed by ls and stat with --json
+type jsonEntry struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path,omitempty"`
+	Type    string    `json:"type"` // file, dir, symlink or other
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"` // like -rw-r--r--
+	Perm    string    `json:"perm"` // the octal permissions, like 0644
+	ModTime time.Time `json:"mtime"`
+	UID     *uint32   `json:"uid,omitempty"`
+	GID     *uint32   `json:"gid,omitempty"`
+	Target  string    `json:"target,omitempty"` // where the symlink points
+}
+
+// Describe the file for the JSON output
+func newJSONEntry(info os.FileInfo) jsonEntry {
+	entry := jsonEntry{
+		Name:    info.Name(),
+		Type:    "other",
+		Size:    info.Size(),
+		Mode:    info.Mode().String(),
+		Perm:    fmt.Sprintf("%04o", info.Mode().Perm()),
+		ModTime: info.ModTime().UTC(),
+	}
+	switch {
+	case info.Mode().IsRegular():
+		entry.Type = "file"
+	case info.IsDir():
+		entry.Type = "dir"
+	case info.Mode()&os.ModeSymlink != 0:
+		entry.Type = "symlink"
+	}
+	if stat, ok := info.Sys().(*sftp.FileStat); ok {
+		entry.UID, entry.GID = &stat.UID, &stat.GID
+	}
+	return entry
+}
+
+// Print the value on stdout as indented JSON
+func printJSON(value any) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	cobra.CheckErr(encoder.Encode(value))
+}
+
+// Add the --json flag to the subcommand
+func addJSONFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(
+		&jsonOutput,
+		"json",
+		false,
+		"print JSON instead of tab separated lines, for jq and other tools",
+	)
+}
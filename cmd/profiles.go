@@ -0,0 +1,87 @@
+/*
+Copyright © 2022 Guglielmo Bartelloni bartelloni.guglielmo@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/guglielmobartelloni/sftp-tui/config"
+	"github.com/spf13/cobra"
+)
+
+// The details of a profile printed with --json, the secrets stay in the
+// keyring
+type jsonProfile struct {
+	Name      string `json:"name"`
+	Host      string `json:"host"`
+	Port      string `json:"port,omitempty"`
+	Username  string `json:"username,omitempty"`
+	ProxyJump string `json:"proxyjump,omitempty"`
+	LocalDir  string `json:"localdir,omitempty"`
+	RemoteDir string `json:"remotedir,omitempty"`
+}
+
+// profilesCmd lists the saved profiles
+var profilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "List the saved profiles",
+	Long: `List the profiles saved in the config file with --save-profile, one per
+line with the name and the [user@]host[:port] to connect to, separated by
+a tab. Connect to one with sftp-tui --profile <name>.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		profiles, err := config.Profiles()
+		cobra.CheckErr(err)
+
+		if jsonOutput {
+			list := []jsonProfile{}
+			for _, profile := range profiles {
+				list = append(list, jsonProfile{
+					Name:      profile.Name,
+					Host:      profile.Host,
+					Port:      profile.Port,
+					Username:  profile.Username,
+					ProxyJump: profile.ProxyJump,
+					LocalDir:  profile.LocalDir,
+					RemoteDir: profile.RemoteDir,
+				})
+			}
+			printJSON(list)
+			return
+		}
+		for _, profile := range profiles {
+			address := profile.Host
+			if profile.Username != "" {
+				address = profile.Username + "@" + address
+			}
+			if profile.Port != "" {
+				address += ":" + profile.Port
+			}
+			fmt.Printf("%s\t%s\n", profile.Name, address)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(profilesCmd)
+	addJSONFlag(profilesCmd)
+}
@@ -0,0 +1,153 @@
+/*
+Copyright © 2022 Guglielmo Bartelloni bartelloni.guglielmo@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/guglielmobartelloni/sftp-tui/conflict"
+	"github.com/guglielmobartelloni/sftp-tui/throttle"
+	"github.com/pkg/sftp"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// putCmd uploads local files without starting the tui
+var putCmd = &cobra.Command{
+	Use:   "put local path... [user@]host:path",
+	Short: "Upload files without starting the TUI",
+	Long: `Upload the local files into the remote path. With a single file the
+remote path can be the new name of the file. The existing remote files are
+overwritten, --on-conflict chooses otherwise.
+With --dry-run each copy is printed on a line instead.`,
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: completePutArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		destination, ok := parseRemotePath(args[len(args)-1])
+		if !ok {
+			cobra.CheckErr(fmt.Errorf("%s is not a remote path, use [user@]host:path", args[len(args)-1]))
+		}
+		sources := args[:len(args)-1]
+
+		limiter, policy, dryRun := transferLimiter(), conflictPolicy(), viper.GetBool("DryRun")
+		client, close, err := connectTo(destination)
+		cobra.CheckErr(err)
+		defer close()
+
+		destInfo, err := client.Stat(destination.path)
+		isDir := err == nil && destInfo.IsDir()
+		if len(sources) > 1 && !isDir {
+			close()
+			cobra.CheckErr(fmt.Errorf("%s is not a directory", destination.path))
+		}
+
+		for _, source := range sources {
+			remotePath := destination.path
+			if isDir {
+				remotePath = client.Join(destination.path, filepath.Base(source))
+			}
+			remotePath, resume, ok := resolvePut(client, policy, source, remotePath)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "Skipped %s, %s exists\n", source, remotePath)
+				continue
+			}
+			if dryRun {
+				fmt.Printf("upload\t%s\t%s\n", source, remotePath)
+				continue
+			}
+			if err := putFile(client, source, remotePath, resume, limiter); err != nil {
+				close()
+				cobra.CheckErr(fmt.Errorf("uploading %s failed: %v", source, err))
+			}
+		}
+	},
+}
+
+// Decide where to upload the local file when the remote one exists,
+// following the policy: returns the remote path, whether the remote file is
+// continued, and false when the file is skipped
+func resolvePut(client *sftp.Client, policy conflict.Policy, localPath, remotePath string) (string, bool, bool) {
+	remoteInfo, err := client.Stat(remotePath)
+	if err != nil {
+		return remotePath, false, true
+	}
+	// Without the local file the upload fails with its error
+	localInfo, err := os.Stat(localPath)
+	if err != nil {
+		return remotePath, false, true
+	}
+	switch policy.Decide(localInfo.Size(), localInfo.ModTime(), remoteInfo) {
+	case conflict.SkipFile:
+		return remotePath, false, false
+	case conflict.RenameFile:
+		dir := path.Dir(remotePath)
+		return client.Join(dir, conflict.UniqueName(path.Base(remotePath), func(name string) bool {
+			_, err := client.Stat(client.Join(dir, name))
+			return err == nil
+		})), false, true
+	case conflict.ResumeFile:
+		return remotePath, true, true
+	}
+	return remotePath, false, true
+}
+
+// Copy the local file to the remote path, throttled by the limiter. When
+// resuming the copy continues from the end of the remote file.
+func putFile(client *sftp.Client, localPath, remotePath string, resume bool, limiter *throttle.Limiter) error {
+	srcFile, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if resume {
+		flags = os.O_WRONLY
+	}
+	destFile, err := client.OpenFile(remotePath, flags)
+	if err != nil {
+		return err
+	}
+	if resume {
+		offset, err := destFile.Seek(0, io.SeekEnd)
+		if err == nil {
+			_, err = srcFile.Seek(offset, io.SeekStart)
+		}
+		if err != nil {
+			destFile.Close()
+			return err
+		}
+	}
+	if _, err := io.Copy(limiter.Writer(destFile), srcFile); err != nil {
+		destFile.Close()
+		return err
+	}
+	return destFile.Close()
+}
+
+func init() {
+	rootCmd.AddCommand(putCmd)
+}
@@ -22,36 +22,232 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/guglielmobartelloni/sftp-tui/config"
+	"github.com/guglielmobartelloni/sftp-tui/localpath"
+	"github.com/guglielmobartelloni/sftp-tui/logging"
+	"github.com/guglielmobartelloni/sftp-tui/ssh"
+	"github.com/guglielmobartelloni/sftp-tui/throttle"
 	"github.com/guglielmobartelloni/sftp-tui/tui"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-var cfgFile string
+var (
+	cfgFile         string
+	profileName     string
+	saveProfileName string
+	jumpHosts       string
+	proxyURL        string
+	passwordStdin   bool
+	logFile         io.Closer
+)
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "sftp-tui",
+	Use:   "sftp-tui [host] [local files...]",
 	Short: "A TUI client for SFTP",
-	Run: func(cmd *cobra.Command, args []string) {
-		var (
-			username       = viper.GetString("Username")
-			password       = viper.GetString("Password")
-			host           = viper.GetString("Host")
-			knownHostsPath = viper.GetString("KnownHostsPath")
-			privateKeyPath = viper.GetString("PrivateKeyPath")
-			port           = viper.GetString("Port")
-		)
-		tui.StartProgram(username, privateKeyPath, password, host, port, knownHostsPath)
+	Long: `A TUI client for SFTP.
+
+The host can be an alias defined in ~/.ssh/config, in that case its
+HostName, User, Port and IdentityFile are used for the connection.
+It can also be an url, sftp://user@host:port/dir, giving the user,
+the port and the start directory.
+Without a host, and no Host in the config file, the saved profiles
+are listed to pick the one to connect to. Without a host, --resume
+reopens the host and the directories of the last session.
+
+The local files and directories following the host are uploaded to
+the start directory, the home or --remote-dir, once connected.
+
+With --batch the commands of the script are run without the tui, one
+per line: cd, lcd, get, put, rm [-r], mkdir [-p], chmod and
+sync push|pull [--delete]. The batch stops at the first failed
+command, unless --continue-on-error is given or the line starts with
+"-", and prints a JSON summary of the results on stdout.
+
+With --local the tui browses the local directory given in place of
+the host, the current one by default, like the files of a server:
+the downloads copy the files to --local-dir. Confirming the connection
+form without a host browses the current directory too. Other tabs can
+connect to servers, to copy the files between them.
+
+The host can be the url of an ftp server, ftp://user@host:port/dir,
+with ftps:// for TLS from the start or ftpes:// to upgrade the
+connection to TLS. Without a user the login is anonymous.
+
+The host can also be an s3 bucket, s3://bucket/prefix, on the
+service of --s3-endpoint. The credentials come from the environment
+(AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY), ~/.aws/credentials
+or the instance metadata.`,
+	Args:              cobra.ArbitraryArgs,
+	ValidArgsFunction: completeRootArgs,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) (err error) {
+		logFile, err = logging.Setup(logPath(), viper.GetBool("Verbose"))
+		if err != nil {
+			return err
+		}
+		localpath.Transliterate = viper.GetBool("Transliterate")
+		if passwordStdin {
+			stdinPassword, err = readStdinPassword()
+		}
+		return err
 	},
+	Run: runTUI,
+}
+
+// Connect to the host, or run the batch on it, and start the tui; the
+// command of the root and of connect
+func runTUI(cmd *cobra.Command, args []string) {
+	host := ""
+	if len(args) > 0 {
+		host = args[0]
+	}
+	if browseLocal {
+		runLocal(args)
+		return
+	}
+	if isFTPURL(host) {
+		runFTP(host, args[1:])
+		return
+	}
+	if isS3URL(host) {
+		runS3(host, args[1:])
+		return
+	}
+	if batchFile != "" {
+		if len(args) > 1 {
+			cobra.CheckErr(fmt.Errorf("no local files to upload with --batch, use put in the script"))
+		}
+		runBatch(cmd, batchFile, host)
+		return
+	}
+
+	// Check the files to upload before connecting
+	var uploads []string
+	if len(args) > 1 {
+		uploads = uploadPaths(args[1:])
+	}
+	// An sftp:// url gives the user, the port and the start directory too
+	var remote remotePath
+	if isSFTPURL(host) {
+		var err error
+		remote, err = parseSFTPURL(host)
+		cobra.CheckErr(err)
+		host = remote.host
+	}
+	connection, err := resolveConnection(profileName, host)
+	cobra.CheckErr(err)
+	applyRemote(&connection, remote)
+
+	// Pick up where the last session was left, unless told where to
+	// connect
+	var session *config.Session
+	if viper.GetBool("Resume") && host == "" && profileName == "" {
+		last, ok, err := config.LastSession()
+		cobra.CheckErr(err)
+		if ok {
+			session = &last
+			applyProfile(&connection, last.Connection)
+			connection.LocalDir = last.LocalDir
+			connection.RemoteDir = last.RemoteDir
+		}
+	}
+
+	// Nothing to connect to, let the user pick a saved profile or fill
+	// in the connection form
+	if connection.Host == "" {
+		profiles, err := config.Profiles()
+		cobra.CheckErr(err)
+		if len(profiles) > 0 {
+			profile, ok := tui.PickProfile(profiles)
+			if !ok {
+				return
+			}
+			applyProfile(&connection, profile)
+		}
+	}
+
+	// The flags win over the directories of the profile
+	if cmd.Flags().Changed("local-dir") {
+		connection.LocalDir = viper.GetString("LocalDir")
+	}
+	if cmd.Flags().Changed("remote-dir") {
+		connection.RemoteDir = viper.GetString("RemoteDir")
+	}
+
+	if saveProfileName != "" {
+		connection.Name = saveProfileName
+		cobra.CheckErr(config.SaveProfile(connection))
+	}
+
+	settings := tuiSettings(connection)
+	settings.Upload = uploads
+	settings.Session = session
+	cobra.CheckErr(tui.StartProgram(sshOptions(connection), settings))
+}
+
+// Get the absolute paths of the local files to upload, which have to exist
+func uploadPaths(localPaths []string) []string {
+	var uploads []string
+	for _, localPath := range localPaths {
+		absPath, err := filepath.Abs(localPath)
+		cobra.CheckErr(err)
+		_, err = os.Stat(absPath)
+		cobra.CheckErr(err)
+		uploads = append(uploads, absPath)
+	}
+	return uploads
+}
+
+// Get the settings of the tui from the flags and the config, the
+// directories from the connection
+func tuiSettings(connection config.Profile) tui.Settings {
+	limitRate, err := throttle.ParseRate(viper.GetString("LimitRate"))
+	cobra.CheckErr(err)
+	previewSize, err := throttle.ParseRate(viper.GetString("PreviewSize"))
+	if err != nil {
+		cobra.CheckErr(fmt.Errorf("invalid preview size %q, use a number of bytes like 64K or 1M", viper.GetString("PreviewSize")))
+	}
+
+	return tui.Settings{
+		Concurrency:     viper.GetInt("Concurrency"),
+		LocalDir:        connection.LocalDir,
+		ShowHidden:      viper.GetBool("ShowHidden"),
+		Columns:         viper.GetBool("Columns"),
+		LimitRate:       limitRate,
+		Verify:          viper.GetBool("Verify"),
+		OnConflict:      viper.GetString("OnConflict"),
+		OpenDownloads:   viper.GetBool("OpenDownloads"),
+		Keys:            viper.GetStringMapStringSlice("Keys"),
+		Theme:           viper.GetString("Theme"),
+		Colors:          viper.GetStringMapString("Colors"),
+		NoIcons:         viper.GetBool("NoIcons"),
+		NoMouse:         viper.GetBool("NoMouse"),
+		PreviewSize:     previewSize,
+		Trash:           viper.GetString("Trash"),
+		DryRun:          viper.GetBool("DryRun"),
+		RefreshInterval: viper.GetDuration("RefreshInterval"),
+		CacheTTL:        viper.GetDuration("CacheTTL"),
+		Retries:         viper.GetInt("Retries"),
+		RetryBackoff:    viper.GetDuration("RetryBackoff"),
+		RemoteDir:       connection.RemoteDir,
+		ResolveHost:     tabOptions,
+	}
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	err := rootCmd.Execute()
+	if logFile != nil {
+		logFile.Close()
+	}
 	if err != nil {
 		os.Exit(1)
 	}
@@ -60,6 +256,11 @@ func Execute() {
 func init() {
 	cobra.OnInitialize(initConfig)
 
+	// Ask the passphrase of the encrypted keys when the password doesn't work
+	ssh.AskPassphrase = tui.AskPassphrase
+	// and the one time passwords of the servers with two factors
+	ssh.AskChallenge = tui.AskChallenge
+
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
@@ -71,10 +272,260 @@ func init() {
 		"config file (default is $HOME/.sftp-tui.yaml)",
 	)
 
+	rootCmd.PersistentFlags().StringVarP(
+		&profileName,
+		"profile",
+		"p",
+		"",
+		"connect using the saved profile",
+	)
+	cobra.CheckErr(rootCmd.RegisterFlagCompletionFunc("profile", completeProfiles))
+	rootCmd.PersistentFlags().StringVarP(
+		&jumpHosts,
+		"jump",
+		"J",
+		"",
+		"connect through the jump hosts, a comma separated list of [user@]host[:port]",
+	)
+	cobra.CheckErr(viper.BindPFlag("ProxyJump", rootCmd.PersistentFlags().Lookup("jump")))
+	rootCmd.PersistentFlags().StringVar(
+		&proxyURL,
+		"proxy",
+		"",
+		"dial through the SOCKS5 or HTTP proxy, socks5://host:port or http://host:port",
+	)
+	cobra.CheckErr(viper.BindPFlag("Proxy", rootCmd.PersistentFlags().Lookup("proxy")))
+	rootCmd.PersistentFlags().Duration(
+		"connect-timeout",
+		ssh.DefaultTimeout,
+		"give up connecting to a host that doesn't answer after the timeout",
+	)
+	cobra.CheckErr(viper.BindPFlag("ConnectTimeout", rootCmd.PersistentFlags().Lookup("connect-timeout")))
+	rootCmd.PersistentFlags().Bool(
+		"legacy",
+		false,
+		"offer the old algorithms too (ssh-rsa, diffie-hellman-group14-sha1, aes128-cbc...), for old servers and network appliances",
+	)
+	cobra.CheckErr(viper.BindPFlag("Legacy", rootCmd.PersistentFlags().Lookup("legacy")))
+	rootCmd.PersistentFlags().StringSlice(
+		"host-key-algorithms",
+		nil,
+		"host key algorithms offered to the server, in order of preference",
+	)
+	cobra.CheckErr(viper.BindPFlag("HostKeyAlgorithms", rootCmd.PersistentFlags().Lookup("host-key-algorithms")))
+	rootCmd.PersistentFlags().StringSlice(
+		"kex",
+		nil,
+		"key exchange algorithms offered to the server, in order of preference",
+	)
+	cobra.CheckErr(viper.BindPFlag("KeyExchanges", rootCmd.PersistentFlags().Lookup("kex")))
+	rootCmd.PersistentFlags().StringSlice(
+		"ciphers",
+		nil,
+		"ciphers offered to the server, in order of preference",
+	)
+	cobra.CheckErr(viper.BindPFlag("Ciphers", rootCmd.PersistentFlags().Lookup("ciphers")))
+	rootCmd.PersistentFlags().StringSlice(
+		"macs",
+		nil,
+		"MAC algorithms offered to the server, in order of preference",
+	)
+	cobra.CheckErr(viper.BindPFlag("MACs", rootCmd.PersistentFlags().Lookup("macs")))
+	rootCmd.PersistentFlags().String(
+		"limit-rate",
+		"",
+		"cap the speed of all the transfers together, in bytes per second like 500K or 2M",
+	)
+	cobra.CheckErr(viper.BindPFlag("LimitRate", rootCmd.PersistentFlags().Lookup("limit-rate")))
+	rootCmd.PersistentFlags().String(
+		"on-conflict",
+		"",
+		"what to do with the files whose destination exists: ask, overwrite, skip, rename, newer or resume (ask overwrites in get and put)",
+	)
+	cobra.CheckErr(viper.BindPFlag("OnConflict", rootCmd.PersistentFlags().Lookup("on-conflict")))
+	rootCmd.PersistentFlags().Bool(
+		"dry-run",
+		false,
+		"only tell what the deletes, renames, permission changes, syncs and transfers would do",
+	)
+	cobra.CheckErr(viper.BindPFlag("DryRun", rootCmd.PersistentFlags().Lookup("dry-run")))
+	rootCmd.PersistentFlags().Bool(
+		"transliterate",
+		false,
+		"save the downloads with ASCII names, for the local filesystems that can't hold the others",
+	)
+	cobra.CheckErr(viper.BindPFlag("Transliterate", rootCmd.PersistentFlags().Lookup("transliterate")))
+	rootCmd.PersistentFlags().Int(
+		"max-packet",
+		0,
+		"payload of the sftp packets in bytes (default 32768), bigger is faster on fast links but some servers refuse it",
+	)
+	cobra.CheckErr(viper.BindPFlag("MaxPacket", rootCmd.PersistentFlags().Lookup("max-packet")))
+	rootCmd.PersistentFlags().Int(
+		"max-requests",
+		0,
+		"sftp requests in flight for each file (default 64), more help on high latency links",
+	)
+	cobra.CheckErr(viper.BindPFlag("MaxRequests", rootCmd.PersistentFlags().Lookup("max-requests")))
+	rootCmd.PersistentFlags().String(
+		"log-file",
+		"",
+		"log the connections, transfers, remote commands and errors to the file",
+	)
+	cobra.CheckErr(viper.BindPFlag("LogFile", rootCmd.PersistentFlags().Lookup("log-file")))
+	rootCmd.PersistentFlags().BoolP(
+		"verbose",
+		"v",
+		false,
+		"log the debug messages too, to $HOME/.sftp-tui.log without --log-file",
+	)
+	cobra.CheckErr(viper.BindPFlag("Verbose", rootCmd.PersistentFlags().Lookup("verbose")))
+	rootCmd.PersistentFlags().Bool(
+		"keyring",
+		false,
+		"keep the password and the passphrase of the profile in the keyring of the system",
+	)
+	cobra.CheckErr(viper.BindPFlag("Keyring", rootCmd.PersistentFlags().Lookup("keyring")))
+	rootCmd.PersistentFlags().BoolVar(
+		&passwordStdin,
+		"password-stdin",
+		false,
+		"read the password of the user on the server from the first line of stdin",
+	)
+
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	rootCmd.Flags().StringVar(
+		&saveProfileName,
+		"save-profile",
+		"",
+		"save the connection settings as a profile with the given name",
+	)
+	rootCmd.Flags().StringVarP(
+		&batchFile,
+		"batch",
+		"b",
+		"",
+		"run the commands of the script, - for stdin, instead of the tui and print a JSON summary",
+	)
+	rootCmd.Flags().BoolVar(
+		&batchContinueOnError,
+		"continue-on-error",
+		false,
+		"go on with the batch after a failed command instead of stopping",
+	)
+	rootCmd.Flags().BoolVar(
+		&browseLocal,
+		"local",
+		false,
+		"browse the local directory given in place of the host, the current one by default, instead of a server",
+	)
+	rootCmd.Flags().String(
+		"s3-endpoint",
+		"https://s3.amazonaws.com",
+		"service of the s3:// buckets, like http://localhost:9000 for a MinIO server",
+	)
+	cobra.CheckErr(viper.BindPFlag("S3Endpoint", rootCmd.Flags().Lookup("s3-endpoint")))
+	rootCmd.Flags().Int(
+		"concurrency",
+		4,
+		"number of transfers running at the same time",
+	)
+	cobra.CheckErr(viper.BindPFlag("Concurrency", rootCmd.Flags().Lookup("concurrency")))
+	rootCmd.Flags().String(
+		"local-dir",
+		"",
+		"local directory of the downloads and uploads (default is the current directory)",
+	)
+	cobra.CheckErr(viper.BindPFlag("LocalDir", rootCmd.Flags().Lookup("local-dir")))
+	rootCmd.Flags().String(
+		"remote-dir",
+		"",
+		"remote directory where the session starts, relative to the home (default is the home)",
+	)
+	cobra.CheckErr(viper.BindPFlag("RemoteDir", rootCmd.Flags().Lookup("remote-dir")))
+	rootCmd.Flags().Bool(
+		"resume",
+		false,
+		"reopen the host, the directories and the view of the last session",
+	)
+	cobra.CheckErr(viper.BindPFlag("Resume", rootCmd.Flags().Lookup("resume")))
+	rootCmd.Flags().Bool(
+		"verify",
+		false,
+		"compare the sha256 of the files after the transfers",
+	)
+	cobra.CheckErr(viper.BindPFlag("Verify", rootCmd.Flags().Lookup("verify")))
+	rootCmd.Flags().Bool(
+		"open",
+		false,
+		"open the downloaded files with the default application",
+	)
+	cobra.CheckErr(viper.BindPFlag("OpenDownloads", rootCmd.Flags().Lookup("open")))
+	rootCmd.Flags().String(
+		"theme",
+		"",
+		"colors of the tui: default, dark, light or solarized",
+	)
+	cobra.CheckErr(viper.BindPFlag("Theme", rootCmd.Flags().Lookup("theme")))
+	rootCmd.Flags().Bool(
+		"no-icons",
+		false,
+		"leave out the file icons, for the terminals without a nerd font",
+	)
+	cobra.CheckErr(viper.BindPFlag("NoIcons", rootCmd.Flags().Lookup("no-icons")))
+	rootCmd.Flags().Bool(
+		"no-mouse",
+		false,
+		"leave the mouse to the terminal, to select the text without holding shift",
+	)
+	cobra.CheckErr(viper.BindPFlag("NoMouse", rootCmd.Flags().Lookup("no-mouse")))
+	rootCmd.Flags().String(
+		"preview-size",
+		"64K",
+		"bytes of the files read for the preview, K, M and G are powers of 1024",
+	)
+	cobra.CheckErr(viper.BindPFlag("PreviewSize", rootCmd.Flags().Lookup("preview-size")))
+	rootCmd.Flags().Duration(
+		"refresh",
+		5*time.Second,
+		"how often the current directory is checked for changes, 0 to never check",
+	)
+	cobra.CheckErr(viper.BindPFlag("RefreshInterval", rootCmd.Flags().Lookup("refresh")))
+	rootCmd.Flags().Duration(
+		"cache-ttl",
+		time.Minute,
+		"how long the listings of the directories visited are reused, 0 to always read them",
+	)
+	cobra.CheckErr(viper.BindPFlag("CacheTTL", rootCmd.Flags().Lookup("cache-ttl")))
+	rootCmd.Flags().Int(
+		"retries",
+		3,
+		"times a failed transfer is tried again, resuming where it stopped when it can",
+	)
+	cobra.CheckErr(viper.BindPFlag("Retries", rootCmd.Flags().Lookup("retries")))
+	rootCmd.Flags().Duration(
+		"retry-backoff",
+		time.Second,
+		"wait before retrying a failed transfer, doubled at each retry up to a minute",
+	)
+	cobra.CheckErr(viper.BindPFlag("RetryBackoff", rootCmd.Flags().Lookup("retry-backoff")))
+
+	// connect is the root command spelled out, it shares its flags
+	connectCmd.Flags().AddFlagSet(rootCmd.Flags())
+}
 
+// Get the path of the log file, empty when not logging
+func logPath() string {
+	path := viper.GetString("LogFile")
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	if path == "" && viper.GetBool("Verbose") {
+		return filepath.Join(home, ".sftp-tui.log")
+	}
+	return localpath.ExpandHome(path)
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -104,6 +555,8 @@ func initConfig() {
 	//	viper.SetDefault("Username", "root")
 	//	viper.SetDefault("Password", "")
 	//	viper.SetDefault("KnownHostsPath", "~/.ssh/known_hosts")
-	//	viper.SetDefault("Port", "22")
+	viper.SetDefault("Port", "22")
+	viper.SetDefault("ShowHidden", true)
+	viper.SetDefault("Trash", "~/.sssftp-trash")
 
 }
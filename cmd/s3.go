@@ -0,0 +1,88 @@
+/*
+Copyright © 2022 Guglielmo Bartelloni bartelloni.guglielmo@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/guglielmobartelloni/sftp-tui/config"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+	"github.com/guglielmobartelloni/sftp-tui/ssh"
+	"github.com/guglielmobartelloni/sftp-tui/tui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// Whether the host is the url of an s3 bucket
+func isS3URL(host string) bool {
+	scheme, _, ok := strings.Cut(host, "://")
+	return ok && strings.EqualFold(scheme, "s3")
+}
+
+// Get the options of the bucket of the url on the service of --s3-endpoint,
+// and the directory of the prefix in its path
+func s3Options(rawURL string) (remotefs.S3Options, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return remotefs.S3Options{}, "", err
+	}
+	if u.Host == "" {
+		return remotefs.S3Options{}, "", fmt.Errorf("no bucket in %s", rawURL)
+	}
+	endpoint, err := url.Parse(viper.GetString("S3Endpoint"))
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return remotefs.S3Options{}, "", fmt.Errorf("invalid s3 endpoint %q, use a url like https://s3.amazonaws.com", viper.GetString("S3Endpoint"))
+	}
+	options := remotefs.S3Options{
+		Endpoint: endpoint.Host,
+		Secure:   endpoint.Scheme == "https",
+		Bucket:   u.Host,
+		Region:   os.Getenv("AWS_REGION"),
+	}
+	if options.Region == "" {
+		options.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	return options, u.Path, nil
+}
+
+// Start the tui browsing the bucket of the url, the local paths following
+// it are uploaded to the start directory
+func runS3(rawURL string, localPaths []string) {
+	if profileName != "" || batchFile != "" || saveProfileName != "" {
+		cobra.CheckErr(fmt.Errorf("the s3 buckets can't be used with --profile, --save-profile or --batch"))
+	}
+	uploads := uploadPaths(localPaths)
+	options, remoteDir, err := s3Options(rawURL)
+	cobra.CheckErr(err)
+
+	connection := config.Profile{LocalDir: viper.GetString("LocalDir"), RemoteDir: viper.GetString("RemoteDir")}
+	if remoteDir != "" {
+		connection.RemoteDir = remoteDir
+	}
+	settings := tuiSettings(connection)
+	settings.Upload = uploads
+	settings.S3 = &options
+	cobra.CheckErr(tui.StartProgram(ssh.Options{}, settings))
+}
@@ -0,0 +1,93 @@
+/*
+Copyright © 2022 Guglielmo Bartelloni bartelloni.guglielmo@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// statCmd shows the details of a remote path without starting the tui
+var statCmd = &cobra.Command{
+	Use:   "stat [user@]host:path",
+	Short: "Show the details of a remote file without starting the TUI",
+	Long: `Show the details of the remote file or directory, a symlink itself
+and not what it points to: its path, type, size in bytes, mode, octal
+permissions, modification time in RFC 3339, owner and group ids and, for a
+symlink, its target. Each is printed on a line as a name and a value
+separated by a tab.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeRemoteArg,
+	Run: func(cmd *cobra.Command, args []string) {
+		remote, ok := parseRemotePath(args[0])
+		if !ok {
+			cobra.CheckErr(fmt.Errorf("%s is not a remote path, use [user@]host:path", args[0]))
+		}
+
+		client, close, err := connectTo(remote)
+		cobra.CheckErr(err)
+		defer close()
+
+		info, err := client.Lstat(remote.path)
+		if err != nil {
+			close()
+			cobra.CheckErr(err)
+		}
+		entry := newJSONEntry(info)
+		// The directory is resolved, not the symlink
+		entry.Path = remote.path
+		if dir, err := client.RealPath(path.Dir(remote.path)); err == nil {
+			entry.Path = client.Join(dir, path.Base(remote.path))
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			// The target is only missing when the server can't read it
+			entry.Target, _ = client.ReadLink(remote.path)
+		}
+
+		if jsonOutput {
+			printJSON(entry)
+			return
+		}
+		fmt.Printf("path\t%s\n", entry.Path)
+		fmt.Printf("type\t%s\n", entry.Type)
+		fmt.Printf("size\t%d\n", entry.Size)
+		fmt.Printf("mode\t%s\n", entry.Mode)
+		fmt.Printf("perm\t%s\n", entry.Perm)
+		fmt.Printf("mtime\t%s\n", entry.ModTime.Format(time.RFC3339))
+		if entry.UID != nil {
+			fmt.Printf("uid\t%d\n", *entry.UID)
+			fmt.Printf("gid\t%d\n", *entry.GID)
+		}
+		if entry.Target != "" {
+			fmt.Printf("target\t%s\n", entry.Target)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statCmd)
+	addJSONFlag(statCmd)
+}
@@ -0,0 +1,218 @@
+/*
+Copyright © 2022 Guglielmo Bartelloni bartelloni.guglielmo@gmail.com
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/guglielmobartelloni/sftp-tui/mirror"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+var syncDelete bool
+
+// A change of the sync as printed with --json
+type jsonAction struct {
+	Kind        string     `json:"kind"` // mkdir, copy or delete
+	Path        string     `json:"path"`
+	Source      string     `json:"source,omitempty"`
+	Destination string     `json:"destination"`
+	Size        int64      `json:"size"`
+	ModTime     *time.Time `json:"mtime,omitempty"`
+}
+
+// The changes of the sync as printed with --json
+type jsonSync struct {
+	DryRun  bool         `json:"dry_run"`
+	Actions []jsonAction `json:"actions"`
+}
+
+// syncCmd groups the commands mirroring a directory
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Mirror a directory between the local machine and the server",
+	Long: `Mirror a directory between the local machine and the server, copying
+only the files whose size or modification time changed.`,
+}
+
+// syncPushCmd mirrors a local directory to the server
+var syncPushCmd = &cobra.Command{
+	Use:   "push <local dir> [user@]host:<remote dir>",
+	Short: "Mirror a local directory to the server",
+	Long: `Mirror the local directory to the remote one: the missing directories
+are created and the new or changed files uploaded. With --delete the remote
+files missing locally are deleted. Each change is printed on a line, with
+--json they are printed as a JSON object once the sync is over.`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeSyncPushArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		remote, ok := parseRemotePath(args[1])
+		if !ok {
+			cobra.CheckErr(fmt.Errorf("%s is not a remote path, use [user@]host:path", args[1]))
+		}
+		runSync(remote, func(client remotefs.FileSystem) ([]mirror.Action, error) {
+			return mirror.PlanPush(client, args[0], remote.path, syncDelete)
+		}, mirror.ApplyPush)
+	},
+}
+
+// syncPullCmd mirrors a remote directory to the local machine
+var syncPullCmd = &cobra.Command{
+	Use:   "pull [user@]host:<remote dir> <local dir>",
+	Short: "Mirror a remote directory to the local machine",
+	Long: `Mirror the remote directory to the local one: the missing directories
+are created and the new or changed files downloaded. With --delete the local
+files missing on the remote are deleted. Each change is printed on a line,
+with --json they are printed as a JSON object once the sync is over.`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeSyncPullArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		remote, ok := parseRemotePath(args[0])
+		if !ok {
+			cobra.CheckErr(fmt.Errorf("%s is not a remote path, use [user@]host:path", args[0]))
+		}
+		runSync(remote, func(client remotefs.FileSystem) ([]mirror.Action, error) {
+			return mirror.PlanPull(client, remote.path, args[1], syncDelete)
+		}, mirror.ApplyPull)
+	},
+}
+
+// Plan the sync and apply its actions one after the other, printing them.
+// With --dry-run the actions are only printed. With --json they are printed
+// all together once the sync is over.
+func runSync(
+	remote remotePath,
+	plan func(client remotefs.FileSystem) ([]mirror.Action, error),
+	apply func(client remotefs.FileSystem, action mirror.Action, counter io.Writer) error,
+) {
+	limiter := transferLimiter()
+	sftpClient, close, err := connectTo(remote)
+	cobra.CheckErr(err)
+	defer close()
+	client := remotefs.NewSFTP(sftpClient)
+
+	actions, err := plan(client)
+	if err != nil {
+		close()
+		cobra.CheckErr(err)
+	}
+
+	dryRun := viper.GetBool("DryRun")
+	var total, copied int64
+	for _, action := range actions {
+		total += action.Size
+	}
+	summary := jsonSync{DryRun: dryRun, Actions: []jsonAction{}}
+	for i, action := range actions {
+		if jsonOutput {
+			summary.Actions = append(summary.Actions, newJSONAction(action))
+		} else {
+			fmt.Printf("%s\t%s\n", action.Kind, action.Path)
+		}
+		if dryRun {
+			continue
+		}
+
+		var counter io.Writer = io.Discard
+		showProgress := action.Kind == mirror.Copy && term.IsTerminal(int(os.Stderr.Fd()))
+		if showProgress {
+			counter = &syncProgress{action: action, index: i + 1, count: len(actions), copied: copied, total: total}
+		}
+		// The copy goes through the counter, throttling it slows down the copy
+		if err := apply(client, action, limiter.Writer(counter)); err != nil {
+			close()
+			cobra.CheckErr(fmt.Errorf("%s of %s failed: %v", action.Kind, action.Path, err))
+		}
+		if showProgress {
+			// Clear the progress line
+			fmt.Fprint(os.Stderr, "\r\033[K")
+		}
+		copied += action.Size
+	}
+	if jsonOutput {
+		printJSON(summary)
+	}
+}
+
+// Describe the action for the JSON output
+func newJSONAction(action mirror.Action) jsonAction {
+	described := jsonAction{
+		Kind:        action.Kind.String(),
+		Path:        action.Path,
+		Source:      action.Source,
+		Destination: action.Destination,
+		Size:        action.Size,
+	}
+	if action.Kind == mirror.Copy {
+		modTime := action.ModTime.UTC()
+		described.ModTime = &modTime
+	}
+	return described
+}
+
+// Prints on stderr the progress of the file being copied and of the sync
+type syncProgress struct {
+	action        mirror.Action
+	index, count  int   // position of the action
+	transferred   int64 // bytes of the file copied
+	copied, total int64 // bytes of the sync copied before the file, and in all
+}
+
+func (p *syncProgress) Write(b []byte) (int, error) {
+	p.transferred += int64(len(b))
+	fmt.Fprintf(os.Stderr, "\r\033[K[%d/%d] %s %3.0f%%, overall %3.0f%%",
+		p.index,
+		p.count,
+		p.action.Path,
+		percent(p.transferred, p.action.Size),
+		percent(p.copied+p.transferred, p.total),
+	)
+	return len(b), nil
+}
+
+// Get the percentage of done over total, a full one when there's nothing to do
+func percent(done, total int64) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(done) / float64(total) * 100
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.AddCommand(syncPushCmd)
+	syncCmd.AddCommand(syncPullCmd)
+	addJSONFlag(syncPushCmd)
+	addJSONFlag(syncPullCmd)
+	syncCmd.PersistentFlags().BoolVar(
+		&syncDelete,
+		"delete",
+		false,
+		"delete the files of the destination missing in the source",
+	)
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// A remote directory saved to jump back to it
+type Bookmark struct {
+	Host string `yaml:"host"`
+	Path string `yaml:"path"`
+}
+
+// Get all the bookmarks saved in the config file
+func allBookmarks() ([]Bookmark, error) {
+	var bookmarks []Bookmark
+	if err := viper.UnmarshalKey("Bookmarks", &bookmarks); err != nil {
+		return nil, fmt.Errorf("reading the bookmarks failed %v", err)
+	}
+	return bookmarks, nil
+}
+
+// Get the directories bookmarked on the host
+func Bookmarks(host string) ([]string, error) {
+	bookmarks, err := allBookmarks()
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, bookmark := range bookmarks {
+		if bookmark.Host == host {
+			dirs = append(dirs, bookmark.Path)
+		}
+	}
+	return dirs, nil
+}
+
+// Save the bookmark of the directory on the host, does nothing if it
+// already exists
+func AddBookmark(host, dirPath string) error {
+	bookmarks, err := allBookmarks()
+	if err != nil {
+		return err
+	}
+	for _, bookmark := range bookmarks {
+		if bookmark.Host == host && bookmark.Path == dirPath {
+			return nil
+		}
+	}
+	return Set("Bookmarks", append(bookmarks, Bookmark{Host: host, Path: dirPath}))
+}
+
+// Remove the bookmark of the directory on the host
+func RemoveBookmark(host, dirPath string) error {
+	bookmarks, err := allBookmarks()
+	if err != nil {
+		return err
+	}
+	kept := bookmarks[:0]
+	for _, bookmark := range bookmarks {
+		if bookmark.Host != host || bookmark.Path != dirPath {
+			kept = append(kept, bookmark)
+		}
+	}
+	return Set("Bookmarks", kept)
+}
@@ -0,0 +1,45 @@
+// Package config persists the settings of sftp-tui in the viper config file.
+package config
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+)
+
+// Get the path of the config file, even if it doesn't exist yet
+func FilePath() (string, error) {
+	if configFile := viper.ConfigFileUsed(); configFile != "" {
+		return configFile, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".sftp-tui.yaml"), nil
+}
+
+// Save the setting in the config file.
+// Only the setting is written: flags, environment variables and defaults
+// don't end up in the file.
+func Set(key string, value interface{}) error {
+	viper.Set(key, value)
+
+	configFile, err := FilePath()
+	if err != nil {
+		return err
+	}
+	fileConfig := viper.New()
+	fileConfig.SetConfigFile(configFile)
+	if filepath.Ext(configFile) == "" {
+		fileConfig.SetConfigType("yaml")
+	}
+	if err := fileConfig.ReadInConfig(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	fileConfig.Set(key, value)
+	return fileConfig.WriteConfigAs(configFile)
+}
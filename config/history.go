@@ -0,0 +1,97 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Transfers kept in the history, the oldest are dropped
+const maxHistory = 1000
+
+// Guards the history file, the tabs record their transfers at the same time
+var historyMu sync.Mutex
+
+// A finished transfer of the history
+type Transfer struct {
+	Host        string        `json:"host"`
+	Upload      bool          `json:"upload"`
+	Source      string        `json:"source"`
+	Destination string        `json:"destination"`
+	Size        int64         `json:"size"` // bytes copied
+	Duration    time.Duration `json:"duration"`
+	Error       string        `json:"error,omitempty"` // empty when it succeeded
+	Time        time.Time     `json:"time"`            // when it finished
+	// Whether it copied a single file between the source and the
+	// destination, only those can be run again
+	File bool `json:"file"`
+}
+
+// Get the path of the history file, next to the config file
+func HistoryPath() (string, error) {
+	configFile, err := FilePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(configFile), ".sftp-tui-history.json"), nil
+}
+
+// Get the transfers of the history, the oldest first
+func History() ([]Transfer, error) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	return readHistory()
+}
+
+func readHistory() ([]Transfer, error) {
+	historyFile, err := HistoryPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(historyFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var transfers []Transfer
+	if err := json.Unmarshal(data, &transfers); err != nil {
+		return nil, fmt.Errorf("reading the history failed %v", err)
+	}
+	return transfers, nil
+}
+
+// Add the transfer to the history
+func AddHistory(transfer Transfer) error {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	transfers, err := readHistory()
+	if err != nil {
+		return err
+	}
+	transfers = append(transfers, transfer)
+	if len(transfers) > maxHistory {
+		transfers = transfers[len(transfers)-maxHistory:]
+	}
+	data, err := json.MarshalIndent(transfers, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Replace the file at once, a crash doesn't leave half of it
+	historyFile, err := HistoryPath()
+	if err != nil {
+		return err
+	}
+	tmpFile := historyFile + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, historyFile)
+}
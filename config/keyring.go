@@ -0,0 +1,56 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/zalando/go-keyring"
+)
+
+// Name of the secrets of sftp-tui in the keyring of the system
+const keyringService = "sftp-tui"
+
+// Kinds of secrets kept in the keyring for each profile
+const (
+	PasswordSecret   = "password"
+	PassphraseSecret = "passphrase"
+)
+
+// Get the secret of the profile kept in the keyring of the system (the macOS
+// Keychain, the Secret Service on Linux, the Credential Manager on Windows),
+// empty when there's none
+func Secret(profile, kind string) (string, error) {
+	secret, err := keyring.Get(keyringService, profile+"/"+kind)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading the %s of %s from the keyring failed %v", kind, profile, err)
+	}
+	return secret, nil
+}
+
+// Keep the secret of the profile in the keyring of the system
+func SetSecret(profile, kind, secret string) error {
+	if err := keyring.Set(keyringService, profile+"/"+kind, secret); err != nil {
+		return fmt.Errorf("saving the %s of %s in the keyring failed %v", kind, profile, err)
+	}
+	return nil
+}
+
+// Delete the secrets of the profile from the keyring of the system, returns
+// how many there were
+func ClearSecrets(profile string) (int, error) {
+	cleared := 0
+	for _, kind := range []string{PasswordSecret, PassphraseSecret} {
+		err := keyring.Delete(keyringService, profile+"/"+kind)
+		if errors.Is(err, keyring.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return cleared, fmt.Errorf("deleting the %s of %s from the keyring failed %v", kind, profile, err)
+		}
+		cleared++
+	}
+	return cleared, nil
+}
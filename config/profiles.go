@@ -0,0 +1,71 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// A named set of connection settings saved in the config file
+type Profile struct {
+	Name           string `yaml:"name"`
+	Host           string `yaml:"host"`
+	Port           string `yaml:"port,omitempty"`
+	Username       string `yaml:"username,omitempty"`
+	PrivateKeyPath string `yaml:"privatekeypath,omitempty"`
+	KnownHostsPath string `yaml:"knownhostspath,omitempty"`
+	ProxyJump      string `yaml:"proxyjump,omitempty"` // jump hosts to tunnel the connection through
+	Proxy          string `yaml:"proxy,omitempty"`     // url of the SOCKS5 or HTTP proxy
+	LocalDir       string `yaml:"localdir,omitempty"`  // local directory where the files are downloaded
+	// user certificate of the key, the -cert.pub file next to it by default
+	CertificatePath string `yaml:"certificatepath,omitempty"`
+	// offer the old algorithms too, for old servers and network appliances
+	Legacy bool `yaml:"legacy,omitempty"`
+	// remote directory where the sessions start, relative to the home unless
+	// absolute
+	RemoteDir string `yaml:"remotedir,omitempty"`
+}
+
+// Get the profiles saved in the config file
+func Profiles() ([]Profile, error) {
+	var profiles []Profile
+	if err := viper.UnmarshalKey("Profiles", &profiles); err != nil {
+		return nil, fmt.Errorf("reading the profiles failed %v", err)
+	}
+	return profiles, nil
+}
+
+// Find the profile with the given name
+func FindProfile(name string) (Profile, error) {
+	profiles, err := Profiles()
+	if err != nil {
+		return Profile{}, err
+	}
+	for _, profile := range profiles {
+		if profile.Name == name {
+			return profile, nil
+		}
+	}
+	return Profile{}, fmt.Errorf("profile %q not found", name)
+}
+
+// Save the profile in the config file, replacing the one with the same name
+func SaveProfile(profile Profile) error {
+	profiles, err := Profiles()
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i := range profiles {
+		if profiles[i].Name == profile.Name {
+			profiles[i] = profile
+			replaced = true
+		}
+	}
+	if !replaced {
+		profiles = append(profiles, profile)
+	}
+
+	return Set("Profiles", profiles)
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Where the user was when quitting the tui, restored with --resume
+type Session struct {
+	Connection Profile `json:"connection"` // the settings of the connection, without the secrets
+	RemoteDir  string  `json:"remoteDir"`
+	LocalDir   string  `json:"localDir"`
+	Sort       string  `json:"sort"` // field the list is ordered by
+	Reverse    bool    `json:"reverse"`
+	DirsFirst  bool    `json:"dirsFirst"`
+	ShowQueue  bool    `json:"showQueue"` // whether the queue pane is visible
+	ShowLog    bool    `json:"showLog"`   // whether the log pane is visible
+}
+
+// Get the path of the session file, next to the config file
+func SessionPath() (string, error) {
+	configFile, err := FilePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(configFile), ".sftp-tui-session.json"), nil
+}
+
+// Get the last session, returns false when there's none
+func LastSession() (Session, bool, error) {
+	sessionFile, err := SessionPath()
+	if err != nil {
+		return Session{}, false, err
+	}
+	data, err := os.ReadFile(sessionFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Session{}, false, nil
+	}
+	if err != nil {
+		return Session{}, false, err
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return Session{}, false, fmt.Errorf("reading the last session failed %v", err)
+	}
+	return session, true, nil
+}
+
+// Save the session, replacing the last one
+func SaveSession(session Session) error {
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+	sessionFile, err := SessionPath()
+	if err != nil {
+		return err
+	}
+	tmpFile := sessionFile + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, sessionFile)
+}
@@ -0,0 +1,94 @@
+// Package conflict decides what to do with a transfer whose destination
+// already exists.
+package conflict
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// How the conflicts are resolved
+type Policy int
+
+const (
+	Ask       Policy = iota // the user chooses for each file
+	Overwrite               // the destination is replaced
+	Skip                    // the file isn't copied
+	Rename                  // the file is copied with a new name, like "file (1).txt"
+	Newer                   // the destination is replaced when the source is newer
+	Resume                  // the rest of the file is appended to a shorter destination
+)
+
+var policyNames = []string{"ask", "overwrite", "skip", "rename", "newer", "resume"}
+
+func (p Policy) String() string {
+	return policyNames[p]
+}
+
+// Get the policy from its name, asking when empty
+func ParsePolicy(name string) (Policy, error) {
+	if name == "" {
+		return Ask, nil
+	}
+	for policy, policyName := range policyNames {
+		if strings.EqualFold(name, policyName) {
+			return Policy(policy), nil
+		}
+	}
+	return Ask, fmt.Errorf("unknown conflict policy %q, use %s", name, strings.Join(policyNames, ", "))
+}
+
+// What happens to a file whose destination exists
+type Action int
+
+const (
+	Copy       Action = iota // copy the file over the destination
+	SkipFile                 // leave the destination as it is
+	RenameFile               // copy the file with a new name
+	ResumeFile               // append the rest of the file to the destination
+)
+
+// Decide what to do with the source file, of the size and modification
+// time, whose destination exists; asking is up to the caller, it overwrites.
+// A source without a time is taken as newer. A destination as long as the
+// source is already resumed, a longer one is overwritten.
+func (p Policy) Decide(sourceSize int64, sourceTime time.Time, destination fs.FileInfo) Action {
+	switch p {
+	case Skip:
+		return SkipFile
+	case Rename:
+		return RenameFile
+	case Newer:
+		// sftp has the times in seconds
+		if sourceTime.IsZero() || sourceTime.Truncate(time.Second).After(destination.ModTime().Truncate(time.Second)) {
+			return Copy
+		}
+		return SkipFile
+	case Resume:
+		switch {
+		case destination.Size() < sourceSize:
+			return ResumeFile
+		case destination.Size() == sourceSize:
+			return SkipFile
+		}
+		return Copy
+	default:
+		return Copy
+	}
+}
+
+// Find a name like "file (1).txt" for the file that isn't taken in its
+// directory
+func UniqueName(name string, exists func(name string) bool) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 1; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if !exists(candidate) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Package localpath turns the remote names and paths into local ones that
+// work on every OS: Windows doesn't allow some characters and names, and
+// its filesystems, like the macOS ones, ignore the case.
+package localpath
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Characters Windows doesn't allow in the file names, with the control ones
+const reservedChars = `<>:"/\|?*`
+
+// Names of the Windows devices, they can't be used even with an extension
+var deviceNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// Whether the local filesystems ignore the case of the names
+var caseInsensitive = runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+
+// Whether the local names are made ASCII, for the filesystems and the
+// programs that can't handle the other characters
+var Transliterate bool
+
+// Letters written with more than one in ASCII, the others just lose their
+// accents
+var spelledLetters = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'Æ': "AE", 'œ': "oe", 'Œ': "OE", 'ø': "o", 'Ø': "O",
+	'ł': "l", 'Ł': "L", 'đ': "d", 'Đ': "D", 'ð': "d", 'Ð': "D", 'þ': "th", 'Þ': "TH",
+}
+
+// Get a local file name for the remote one. On Windows the characters it
+// doesn't allow are replaced, see windowsName. Elsewhere only the path
+// separator becomes "_". When transliterating the name is made ASCII first.
+func Name(name string) string {
+	if name == "." || name == ".." {
+		return name
+	}
+	if Transliterate {
+		name = ascii(name)
+	}
+	if runtime.GOOS != "windows" {
+		return strings.ReplaceAll(name, string(filepath.Separator), "_")
+	}
+	return windowsName(name)
+}
+
+// Get the name Windows allows: the characters it doesn't allow become "_",
+// with the bytes that aren't UTF-8, the trailing dots and spaces it drops
+// become a "_" and a device name, like "con.txt", gets one too
+func windowsName(name string) string {
+	safe := strings.Map(func(r rune) rune {
+		if r < 32 || r == utf8.RuneError || strings.ContainsRune(reservedChars, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if trimmed := strings.TrimRight(safe, ". "); trimmed != safe {
+		safe = trimmed + "_"
+	}
+	base := safe
+	if dot := strings.Index(safe, "."); dot >= 0 {
+		base = safe[:dot]
+	}
+	if deviceNames[strings.ToUpper(strings.TrimRight(base, " "))] {
+		safe = base + "_" + safe[len(base):]
+	}
+	return safe
+}
+
+// Write the name in ASCII: the accents are dropped, a few letters are
+// spelled out, like "ß" as "ss", and the other characters become "_"
+func ascii(name string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(name) {
+		switch {
+		case r < utf8.RuneSelf:
+			b.WriteRune(r)
+		case unicode.Is(unicode.Mn, r):
+			// The accent of the letter before
+		case spelledLetters[r] != "":
+			b.WriteString(spelledLetters[r])
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// Get the local relative path for the slash separated remote one, each of
+// its names made local
+func FromSlash(rel string) string {
+	names := strings.Split(rel, "/")
+	for i, name := range names {
+		names[i] = Name(name)
+	}
+	return filepath.Join(names...)
+}
+
+// Get what tells the local paths apart: two paths with the same key are the
+// same file, the case is ignored on Windows and macOS
+func Key(localPath string) string {
+	if caseInsensitive {
+		return strings.ToLower(localPath)
+	}
+	return localPath
+}
+
+// Replace the leading ~ of the path with the user home directory, followed
+// by a slash or, on Windows, a backslash
+func ExpandHome(localPath string) string {
+	if localPath != "~" && !strings.HasPrefix(localPath, "~/") && !strings.HasPrefix(localPath, "~"+string(filepath.Separator)) {
+		return localPath
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return localPath
+	}
+	return filepath.Join(home, localPath[1:])
+}
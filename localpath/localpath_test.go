@@ -0,0 +1,140 @@
+package localpath
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWindowsName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"file.txt", "file.txt"},
+		{`a<b>c:d"e`, "a_b_c_d_e"},
+		{`dir/sub\file`, "dir_sub_file"},
+		{"what?*|", "what___"},
+		{"tab\there", "tab_here"},
+		{"bad\xffbyte", "bad_byte"},
+		{"trailing.", "trailing_"},
+		{"trailing. . ", "trailing_"},
+		{"...", "_"},
+		{"CON", "CON_"},
+		{"nul.txt", "nul_.txt"},
+		{"Com1.tar.gz", "Com1_.tar.gz"},
+		{"lpt9", "lpt9_"},
+		{"CONSOLE", "CONSOLE"},
+		{"COM10", "COM10"},
+		{"con-file.txt", "con-file.txt"},
+	}
+	for _, test := range tests {
+		if got := windowsName(test.name); got != test.want {
+			t.Errorf("windowsName(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestName(t *testing.T) {
+	windows := runtime.GOOS == "windows"
+	tests := []struct {
+		name, want, wantWindows string
+	}{
+		{".", ".", "."},
+		{"..", "..", ".."},
+		{"file.txt", "file.txt", "file.txt"},
+		{"a:b", "a:b", "a_b"},
+		{"trailing.", "trailing.", "trailing_"},
+		{"NUL", "NUL", "NUL_"},
+	}
+	if !windows {
+		tests = append(tests, struct{ name, want, wantWindows string }{"a/b", "a_b", ""})
+	}
+	for _, test := range tests {
+		want := test.want
+		if windows {
+			want = test.wantWindows
+		}
+		if got := Name(test.name); got != want {
+			t.Errorf("Name(%q) = %q, want %q", test.name, got, want)
+		}
+	}
+}
+
+func TestNameTransliterate(t *testing.T) {
+	Transliterate = true
+	defer func() { Transliterate = false }()
+	tests := []struct {
+		name, want string
+	}{
+		{"café.txt", "cafe.txt"},
+		{"Straße", "Strasse"},
+		{"Ærø", "AEro"},
+		{"日本", "__"},
+		{"plain", "plain"},
+	}
+	for _, test := range tests {
+		if got := Name(test.name); got != test.want {
+			t.Errorf("Name(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFromSlash(t *testing.T) {
+	got := FromSlash("dir/a:b/file.")
+	want := filepath.Join("dir", "a:b", "file.")
+	if runtime.GOOS == "windows" {
+		want = filepath.Join("dir", "a_b", "file_")
+	}
+	if got != want {
+		t.Errorf("FromSlash = %q, want %q", got, want)
+	}
+	if got := FromSlash("file"); got != "file" {
+		t.Errorf("FromSlash of a name = %q", got)
+	}
+}
+
+func TestKey(t *testing.T) {
+	want := "Dir/File.TXT"
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		want = "dir/file.txt"
+	}
+	if got := Key("Dir/File.TXT"); got != want {
+		t.Errorf("Key = %q, want %q", got, want)
+	}
+
+	defer func(saved bool) { caseInsensitive = saved }(caseInsensitive)
+	caseInsensitive = true
+	if Key("ÀB") != Key("àb") {
+		t.Error("the keys differ in case")
+	}
+	caseInsensitive = false
+	if Key("A") == Key("a") {
+		t.Error("the keys ignore the case")
+	}
+}
+
+func TestExpandHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	tests := []struct {
+		path, want string
+	}{
+		{"~", home},
+		{"~/dir", filepath.Join(home, "dir")},
+		{"~user/dir", "~user/dir"},
+		{"dir/~", "dir/~"},
+		{"/abs", "/abs"},
+		{"", ""},
+	}
+	if runtime.GOOS == "windows" {
+		tests = append(tests, struct{ path, want string }{`~\dir`, filepath.Join(home, "dir")})
+	} else {
+		tests = append(tests, struct{ path, want string }{`~\dir`, `~\dir`})
+	}
+	for _, test := range tests {
+		if got := ExpandHome(test.path); got != test.want {
+			t.Errorf("ExpandHome(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Package logging records what the program does in a log file, the tui hides
+// stderr. Without a log file nothing is logged.
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+const (
+	// Size of the log file before it's rotated
+	maxSize = 10 * 1024 * 1024
+	// Rotated log files kept, named after the log file with .1, .2 and so on
+	maxBackups = 3
+)
+
+// Setup sends the logs to the file, rotating it once too big. The debug
+// logs are written only when verbose. Without a file the logs are dropped.
+// The returned closer closes the file.
+func Setup(path string, verbose bool) (io.Closer, error) {
+	if path == "" {
+		slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+		return io.NopCloser(nil), nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	writer, err := newRotatingWriter(path)
+	if err != nil {
+		return nil, err
+	}
+	level := slog.LevelInfo
+	if verbose {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(writer, &slog.HandlerOptions{Level: level})))
+	return writer, nil
+}
+
+// Appends to the log file, moving it aside when it gets bigger than maxSize
+type rotatingWriter struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	size int64
+}
+
+func newRotatingWriter(path string) (*rotatingWriter, error) {
+	w := &rotatingWriter{path: path}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Open the log file to append to it
+func (w *rotatingWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	fileInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file, w.size = file, fileInfo.Size()
+	return nil
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.size > 0 && w.size+int64(len(p)) > maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Shift the old log files by one, dropping the oldest, and start a new one
+func (w *rotatingWriter) rotate() error {
+	w.file.Close()
+	for i := maxBackups - 1; i > 0; i-- {
+		os.Rename(backupName(w.path, i), backupName(w.path, i+1))
+	}
+	os.Rename(w.path, backupName(w.path, 1))
+	return w.open()
+}
+
+func (w *rotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// Name of the nth rotated log file
+func backupName(path string, n int) string {
+	return path + "." + strconv.Itoa(n)
+}
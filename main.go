@@ -23,6 +23,14 @@ package main
 
 import "github.com/guglielmobartelloni/sftp-tui/cmd"
 
+// Set by the release build
+var (
+	Version    string
+	CommitSHA  string
+	CommitDate string
+)
+
 func main() {
+	cmd.SetVersion(Version, CommitSHA, CommitDate)
 	cmd.Execute()
 }
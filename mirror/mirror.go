@@ -0,0 +1,172 @@
+// Package mirror compares a local and a remote directory tree and makes one
+// match the other, copying only the files that changed.
+package mirror
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+)
+
+// What an action does to the destination
+type Kind int
+
+const (
+	MakeDir Kind = iota // create the directory
+	Copy                // copy the file from the source
+	Delete              // remove the extra file or directory, with its content
+)
+
+func (k Kind) String() string {
+	switch k {
+	case MakeDir:
+		return "mkdir"
+	case Copy:
+		return "copy"
+	default:
+		return "delete"
+	}
+}
+
+// A change needed to make the destination match the source
+type Action struct {
+	Kind        Kind
+	Path        string    // path relative to the synced directories, slash separated
+	Source      string    // the file to copy
+	Destination string    // the path changed
+	Size        int64     // bytes to copy
+	ModTime     time.Time // modification time given to the copy
+}
+
+// The entries of a tree by their slash separated relative path
+type tree map[string]fs.FileInfo
+
+// Read the local directory tree, only the directories and the regular
+// files are considered
+func localTree(dir string) (tree, error) {
+	entries := tree{}
+	err := filepath.WalkDir(dir, func(localPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if localPath == dir || !(d.IsDir() || d.Type().IsRegular()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, localPath)
+		if err != nil {
+			return err
+		}
+		entries[filepath.ToSlash(rel)] = info
+		return nil
+	})
+	return entries, err
+}
+
+// Read the remote directory tree, empty if the directory doesn't exist
+func remoteTree(remote remotefs.FileSystem, dir string) (tree, error) {
+	entries := tree{}
+	if _, err := remote.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return entries, nil
+	}
+	walker := remotefs.Walk(remote, dir)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return nil, err
+		}
+		if walker.Path() == dir {
+			continue
+		}
+		info := walker.Stat()
+		if !(info.IsDir() || info.Mode().IsRegular()) {
+			continue
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), dir), "/")
+		entries[rel] = info
+	}
+	return entries, nil
+}
+
+// Tell if the copy is up to date: same size and modification time, to the
+// second as it's what sftp keeps
+func upToDate(source, destination fs.FileInfo) bool {
+	return source.Size() == destination.Size() && source.ModTime().Unix() == destination.ModTime().Unix()
+}
+
+// Compare the trees and list the actions making the destination match the
+// source, the extras are deleted only if asked. The entries whose paths
+// have the same key are the same file. The paths of the actions are joined
+// with the source and destination directories.
+func plan(source, destination tree, deleteExtras bool, key, sourceJoin, destinationJoin func(string) string) ([]Action, error) {
+	destinationPaths := map[string]string{}
+	for rel := range destination {
+		destinationPaths[key(rel)] = rel
+	}
+
+	var actions []Action
+	for _, rel := range sortedPaths(source) {
+		info := source[rel]
+		// The copy keeps the name of the destination file it replaces
+		destinationRel, exists := destinationPaths[key(rel)]
+		if !exists {
+			destinationRel = rel
+		}
+		existing := destination[destinationRel]
+		if exists && existing.IsDir() != info.IsDir() {
+			return nil, fmt.Errorf("%s is a file on one side and a directory on the other", rel)
+		}
+		switch {
+		case info.IsDir() && !exists:
+			actions = append(actions, Action{Kind: MakeDir, Path: rel, Destination: destinationJoin(rel)})
+		case !info.IsDir() && (!exists || !upToDate(info, existing)):
+			actions = append(actions, Action{
+				Kind:        Copy,
+				Path:        rel,
+				Source:      sourceJoin(rel),
+				Destination: destinationJoin(destinationRel),
+				Size:        info.Size(),
+				ModTime:     info.ModTime(),
+			})
+		}
+	}
+
+	if deleteExtras {
+		inSource := map[string]bool{}
+		for rel := range source {
+			inSource[key(rel)] = true
+		}
+		for _, rel := range sortedPaths(destination) {
+			if inSource[key(rel)] {
+				continue
+			}
+			// The content goes away with the extra directory
+			if _, ok := destination[path.Dir(rel)]; ok && path.Dir(rel) != "." {
+				if !inSource[key(path.Dir(rel))] {
+					continue
+				}
+			}
+			actions = append(actions, Action{Kind: Delete, Path: rel, Destination: destinationJoin(rel)})
+		}
+	}
+	return actions, nil
+}
+
+// Get the paths of the tree, the parents before their children
+func sortedPaths(entries tree) []string {
+	paths := make([]string, 0, len(entries))
+	for rel := range entries {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+	return paths
+}
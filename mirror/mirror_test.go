@@ -0,0 +1,276 @@
+package mirror
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+)
+
+// Create the local files, holding their name, and the directories, the
+// paths ending with a slash
+func localFiles(t *testing.T, dir string, paths ...string) {
+	t.Helper()
+	for _, p := range paths {
+		localPath := filepath.Join(dir, filepath.FromSlash(p))
+		if p[len(p)-1] == '/' {
+			if err := os.MkdirAll(localPath, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.WriteFile(localPath, []byte(p), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Create the remote files, holding their name, and the directories
+func remoteFiles(t *testing.T, remote remotefs.FileSystem, dir string, paths ...string) {
+	t.Helper()
+	if err := remote.MkdirAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range paths {
+		remotePath := remote.Join(dir, p)
+		if p[len(p)-1] == '/' {
+			if err := remote.MkdirAll(remotePath); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		file, err := remote.Create(remotePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(file, p)
+		file.Close()
+	}
+}
+
+// Describe the actions as kind and relative path
+func summary(actions []Action) []string {
+	var lines []string
+	for _, action := range actions {
+		lines = append(lines, action.Kind.String()+" "+action.Path)
+	}
+	return lines
+}
+
+func apply(t *testing.T, actions []Action, run func(Action, io.Writer) error) {
+	t.Helper()
+	for _, action := range actions {
+		if err := run(action, io.Discard); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPush(t *testing.T) {
+	localDir := t.TempDir()
+	localFiles(t, localDir, "a/", "a/file", "top")
+	remote := remotefs.NewMemory()
+
+	actions, err := PlanPush(remote, localDir, "/dest", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"mkdir .", "mkdir a", "copy a/file", "copy top"}
+	if got := summary(actions); !reflect.DeepEqual(got, want) {
+		t.Fatalf("planned %v, want %v", got, want)
+	}
+	apply(t, actions, func(action Action, counter io.Writer) error {
+		return ApplyPush(remote, action, counter)
+	})
+
+	// The copies keep the modification time: nothing left to do
+	actions, err = PlanPush(remote, localDir, "/dest", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 0 {
+		t.Errorf("planned %v after the push", summary(actions))
+	}
+
+	// The changed file is copied again
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(filepath.Join(localDir, "top"), later, later)
+	actions, err = PlanPush(remote, localDir, "/dest", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summary(actions), []string{"copy top"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("planned %v, want %v", got, want)
+	}
+}
+
+func TestPushDeleteExtras(t *testing.T) {
+	localDir := t.TempDir()
+	localFiles(t, localDir, "kept")
+	remote := remotefs.NewMemory()
+	remoteFiles(t, remote, "/dest", "extra/", "extra/file", "other")
+
+	actions, err := PlanPush(remote, localDir, "/dest", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summary(actions), []string{"copy kept"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("planned %v without deleting, want %v", got, want)
+	}
+
+	// The content goes away with its directory, it's not listed
+	actions, err = PlanPush(remote, localDir, "/dest", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"copy kept", "delete extra", "delete other"}
+	if got := summary(actions); !reflect.DeepEqual(got, want) {
+		t.Fatalf("planned %v, want %v", got, want)
+	}
+	apply(t, actions, func(action Action, counter io.Writer) error {
+		return ApplyPush(remote, action, counter)
+	})
+	entries, err := remote.ReadDir("/dest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "kept" {
+		t.Errorf("the destination holds %v", entries)
+	}
+}
+
+func TestPull(t *testing.T) {
+	remote := remotefs.NewMemory()
+	remoteFiles(t, remote, "/src", "a/", "a/file", "top")
+	localDir := filepath.Join(t.TempDir(), "dest")
+
+	actions, err := PlanPull(remote, "/src", localDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"mkdir .", "mkdir a", "copy a/file", "copy top"}
+	if got := summary(actions); !reflect.DeepEqual(got, want) {
+		t.Fatalf("planned %v, want %v", got, want)
+	}
+	apply(t, actions, func(action Action, counter io.Writer) error {
+		return ApplyPull(remote, action, counter)
+	})
+
+	content, err := os.ReadFile(filepath.Join(localDir, "a", "file"))
+	if err != nil || string(content) != "a/file" {
+		t.Errorf("pulled %q, %v", content, err)
+	}
+	actions, err = PlanPull(remote, "/src", localDir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 0 {
+		t.Errorf("planned %v after the pull", summary(actions))
+	}
+}
+
+func TestPlanFileAndDirectory(t *testing.T) {
+	localDir := t.TempDir()
+	localFiles(t, localDir, "name/")
+	remote := remotefs.NewMemory()
+	remoteFiles(t, remote, "/dest", "name")
+
+	if _, err := PlanPush(remote, localDir, "/dest", false); err == nil {
+		t.Error("a directory was planned over a file")
+	}
+}
+
+// The attributes of a file of the trees compared
+type fileInfo struct {
+	name    string
+	size    int64
+	dir     bool
+	modTime time.Time
+}
+
+func (f fileInfo) Name() string       { return f.name }
+func (f fileInfo) Size() int64        { return f.size }
+func (f fileInfo) ModTime() time.Time { return f.modTime }
+func (f fileInfo) IsDir() bool        { return f.dir }
+func (f fileInfo) Sys() interface{}   { return nil }
+func (f fileInfo) Mode() os.FileMode {
+	if f.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+func TestPlanIgnoringCase(t *testing.T) {
+	now := time.Now()
+	source := tree{
+		"Dir":          fileInfo{name: "Dir", dir: true},
+		"Dir/File.txt": fileInfo{name: "File.txt", size: 2, modTime: now},
+		"Same":         fileInfo{name: "Same", size: 1, modTime: now},
+	}
+	destination := tree{
+		"dir":          fileInfo{name: "dir", dir: true},
+		"dir/file.TXT": fileInfo{name: "file.TXT", size: 1, modTime: now},
+		"same":         fileInfo{name: "same", size: 1, modTime: now},
+		"extra":        fileInfo{name: "extra", size: 1, modTime: now},
+	}
+	join := func(dir string) func(string) string {
+		return func(rel string) string { return dir + "/" + rel }
+	}
+
+	actions, err := plan(source, destination, true, strings.ToLower, join("/src"), join("/dest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The copy replaces the file of the same name, keeping its case
+	want := []Action{
+		{Kind: Copy, Path: "Dir/File.txt", Source: "/src/Dir/File.txt", Destination: "/dest/dir/file.TXT", Size: 2, ModTime: now},
+		{Kind: Delete, Path: "extra", Destination: "/dest/extra"},
+	}
+	if !reflect.DeepEqual(actions, want) {
+		t.Errorf("planned %+v, want %+v", actions, want)
+	}
+
+	// Telling the case apart they're all different files
+	actions, err = plan(source, destination, true, func(rel string) string { return rel }, join("/src"), join("/dest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantKinds := []string{"mkdir Dir", "copy Dir/File.txt", "copy Same", "delete dir", "delete extra", "delete same"}
+	if got := summary(actions); !reflect.DeepEqual(got, wantKinds) {
+		t.Errorf("planned %v, want %v", got, wantKinds)
+	}
+}
+
+// The pulled names match the local ones as the local filesystem does
+func TestPullIgnoringCase(t *testing.T) {
+	remote := remotefs.NewMemory()
+	remoteFiles(t, remote, "/src", "Dir/", "Dir/File")
+	localDir := t.TempDir()
+	localFiles(t, localDir, "dir/", "dir/file")
+
+	actions, err := PlanPull(remote, "/src", localDir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"mkdir Dir", "copy Dir/File", "delete dir"}
+	destination := filepath.Join(localDir, "Dir", "File")
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		want = []string{"copy Dir/File"}
+		destination = filepath.Join(localDir, "dir", "file")
+	}
+	got := summary(actions)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("planned %v, want %v", got, want)
+	}
+	for _, action := range actions {
+		if action.Kind == Copy && action.Destination != destination {
+			t.Errorf("copied to %s, want %s", action.Destination, destination)
+		}
+	}
+}
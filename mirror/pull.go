@@ -0,0 +1,79 @@
+package mirror
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/guglielmobartelloni/sftp-tui/chunked"
+	"github.com/guglielmobartelloni/sftp-tui/localpath"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+)
+
+// PlanPull lists the actions making the local directory a copy of the
+// remote one, deleting the local files missing on the remote if asked
+func PlanPull(remote remotefs.FileSystem, remoteDir, localDir string, deleteExtras bool) ([]Action, error) {
+	remoteEntries, err := remoteTree(remote, remoteDir)
+	if err != nil {
+		return nil, err
+	}
+	local := tree{}
+	if _, err := os.Stat(localDir); err == nil {
+		if local, err = localTree(localDir); err != nil {
+			return nil, err
+		}
+	}
+
+	// The remote names are compared with the local ones they're saved as
+	actions, err := plan(remoteEntries, local, deleteExtras,
+		func(rel string) string { return localpath.Key(localpath.FromSlash(rel)) },
+		func(rel string) string { return remote.Join(remoteDir, rel) },
+		func(rel string) string { return filepath.Join(localDir, localpath.FromSlash(rel)) },
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the local directory first if missing
+	if _, err := os.Stat(localDir); err != nil {
+		actions = append([]Action{{Kind: MakeDir, Path: ".", Destination: localDir}}, actions...)
+	}
+	return actions, nil
+}
+
+// ApplyPull runs the action on the local directory, the bytes copied are
+// written to the counter
+func ApplyPull(remote remotefs.FileSystem, action Action, counter io.Writer) error {
+	switch action.Kind {
+	case MakeDir:
+		return os.MkdirAll(action.Destination, 0755)
+	case Delete:
+		return os.RemoveAll(action.Destination)
+	}
+
+	srcFile, err := remote.Open(action.Source)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	// The file is complete only once renamed
+	partPath := chunked.PartPath(action.Destination)
+	destFile, err := os.Create(partPath)
+	if err != nil {
+		return err
+	}
+	if err := chunked.Copy(srcFile, destFile, 0, counter); err != nil {
+		destFile.Close()
+		return err
+	}
+	if err := destFile.Close(); err != nil {
+		return err
+	}
+
+	// Keep the modification time so the next sync finds it up to date
+	if err := os.Chtimes(partPath, action.ModTime, action.ModTime); err != nil {
+		return err
+	}
+	return os.Rename(partPath, action.Destination)
+}
@@ -0,0 +1,74 @@
+package mirror
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+)
+
+// PlanPush lists the actions making the remote directory a copy of the
+// local one, deleting the remote files missing locally if asked
+func PlanPush(remote remotefs.FileSystem, localDir, remoteDir string, deleteExtras bool) ([]Action, error) {
+	local, err := localTree(localDir)
+	if err != nil {
+		return nil, err
+	}
+	remoteEntries, err := remoteTree(remote, remoteDir)
+	if err != nil {
+		return nil, err
+	}
+
+	actions, err := plan(local, remoteEntries, deleteExtras,
+		func(rel string) string { return rel },
+		func(rel string) string { return filepath.Join(localDir, filepath.FromSlash(rel)) },
+		func(rel string) string { return remote.Join(remoteDir, rel) },
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the remote directory first if missing
+	if _, err := remote.Stat(remoteDir); err != nil {
+		actions = append([]Action{{Kind: MakeDir, Path: ".", Destination: remoteDir}}, actions...)
+	}
+	return actions, nil
+}
+
+// ApplyPush runs the action on the remote, the bytes copied are written to
+// the counter
+func ApplyPush(remote remotefs.FileSystem, action Action, counter io.Writer) error {
+	switch action.Kind {
+	case MakeDir:
+		return remote.MkdirAll(action.Destination)
+	case Delete:
+		return remotefs.RemoveAll(remote, action.Destination)
+	}
+
+	srcFile, err := os.Open(action.Source)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	destFile, err := remote.Create(action.Destination)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(destFile, io.TeeReader(srcFile, counter)); err != nil {
+		destFile.Close()
+		return err
+	}
+	if err := destFile.Close(); err != nil {
+		return err
+	}
+
+	// Keep the modification time so the next sync finds it up to date. On
+	// the servers that can't set it the file is copied again by the next sync.
+	if err := remote.Chtimes(action.Destination, action.ModTime, action.ModTime); !errors.Is(err, errors.ErrUnsupported) {
+		return err
+	}
+	return nil
+}
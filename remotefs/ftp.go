@@ -0,0 +1,708 @@
+package remotefs
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/textproto"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jlaffaye/ftp"
+)
+
+// How the connections to an ftp server are protected
+type FTPSecurity int
+
+const (
+	// Everything in clear, the password too
+	FTPPlain FTPSecurity = iota
+	// TLS from the start, on its own port
+	FTPImplicitTLS
+	// Plain connection upgraded to TLS with AUTH TLS
+	FTPExplicitTLS
+)
+
+// Connections left idle longer are checked before they're used again, the
+// servers close them after a while
+const ftpIdleCheck = 30 * time.Second
+
+// Where and how to log in to an ftp server
+type FTPOptions struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Security FTPSecurity
+	// Limit to open a connection, none when zero
+	Timeout time.Duration
+}
+
+// The filesystem of an ftp server. A connection runs one command at a time
+// and can't be used while a file is transferred, so the filesystem opens
+// more of them as needed and keeps the idle ones for the next operations.
+//
+// Ftp has no permissions, owners or symlinks to create: those operations
+// fail with errors.ErrUnsupported. The files are streamed: they're read
+// and written in order, seeking restarts the transfer from the offset and
+// writing replaces the file from where it starts.
+type FTP struct {
+	options FTPOptions
+	wd      string
+	setTime bool // the server changes the modification times
+
+	mu     sync.Mutex // guards idle and closed
+	idle   []*ftpConn
+	closed bool
+}
+
+// A connection to the server and when it was last used
+type ftpConn struct {
+	*ftp.ServerConn
+	used time.Time
+}
+
+// Log in to the ftp server, the working directory is where the server puts
+// the user
+func DialFTP(ctx context.Context, options FTPOptions) (*FTP, error) {
+	f := &FTP{options: options}
+	conn, err := f.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wd, err := conn.CurrentDir()
+	if err != nil || !path.IsAbs(wd) {
+		wd = "/"
+	}
+	f.wd = path.Clean(wd)
+	f.setTime = conn.IsSetTimeSupported()
+	f.release(conn, nil)
+	return f, nil
+}
+
+// Open a new connection and log in
+func (f *FTP) dial(ctx context.Context) (*ftpConn, error) {
+	options := []ftp.DialOption{
+		ftp.DialWithContext(ctx),
+		ftp.DialWithTimeout(f.options.Timeout),
+		ftp.DialWithShutTimeout(f.options.Timeout),
+	}
+	tlsConfig := &tls.Config{ServerName: f.options.Host}
+	switch f.options.Security {
+	case FTPImplicitTLS:
+		options = append(options, ftp.DialWithTLS(tlsConfig))
+	case FTPExplicitTLS:
+		options = append(options, ftp.DialWithExplicitTLS(tlsConfig))
+	}
+	addr := net.JoinHostPort(f.options.Host, strconv.Itoa(f.options.Port))
+	conn, err := ftp.Dial(addr, options...)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Login(f.options.User, f.options.Password); err != nil {
+		conn.Quit()
+		return nil, err
+	}
+	return &ftpConn{ServerConn: conn}, nil
+}
+
+// Take an idle connection, still open, or open a new one
+func (f *FTP) conn() (*ftpConn, error) {
+	for {
+		f.mu.Lock()
+		if f.closed {
+			f.mu.Unlock()
+			return nil, net.ErrClosed
+		}
+		if len(f.idle) == 0 {
+			f.mu.Unlock()
+			return f.dial(context.Background())
+		}
+		conn := f.idle[len(f.idle)-1]
+		f.idle = f.idle[:len(f.idle)-1]
+		f.mu.Unlock()
+
+		if time.Since(conn.used) < ftpIdleCheck || conn.NoOp() == nil {
+			return conn, nil
+		}
+		conn.Quit()
+	}
+}
+
+// Give back the connection after an operation: kept when it worked or the
+// server refused it, closed when the connection itself failed
+func (f *FTP) release(conn *ftpConn, err error) {
+	var refused *textproto.Error
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed || (err != nil && !errors.As(err, &refused)) {
+		conn.Quit()
+		return
+	}
+	conn.used = time.Now()
+	f.idle = append(f.idle, conn)
+}
+
+// Run the operation on a connection
+func (f *FTP) do(operation func(conn *ftpConn) error) error {
+	conn, err := f.conn()
+	if err != nil {
+		return err
+	}
+	err = operation(conn)
+	f.release(conn, err)
+	return err
+}
+
+// Get the absolute clean path, the relative ones start from the working
+// directory
+func (f *FTP) abs(name string) string {
+	if !path.IsAbs(name) {
+		name = path.Join(f.wd, name)
+	}
+	return absPath(name)
+}
+
+// Get the entry of the path, the symlink itself when it's one. The servers
+// without MLST are asked the listing of the directory.
+func (f *FTP) entry(op, name string) (*ftp.Entry, error) {
+	name = f.abs(name)
+	if name == "/" {
+		return &ftp.Entry{Name: "/", Type: ftp.EntryTypeFolder}, nil
+	}
+	var found *ftp.Entry
+	err := f.do(func(conn *ftpConn) error {
+		if entry, err := conn.GetEntry(name); err == nil {
+			found = entry
+			return nil
+		}
+		entries, err := conn.List(path.Dir(name))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.Name == path.Base(name) {
+				found = entry
+				break
+			}
+		}
+		return nil
+	})
+	if err == nil && found == nil {
+		err = fs.ErrNotExist
+	}
+	if err != nil {
+		return nil, &os.PathError{Op: op, Path: name, Err: err}
+	}
+	return found, nil
+}
+
+func (f *FTP) Getwd() (string, error) {
+	return f.wd, nil
+}
+
+func (f *FTP) RealPath(name string) (string, error) {
+	return f.abs(name), nil
+}
+
+func (f *FTP) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+func (f *FTP) Stat(name string) (os.FileInfo, error) {
+	current := f.abs(name)
+	for hops := 0; hops <= maxLinks; hops++ {
+		entry, err := f.entry("stat", current)
+		if err != nil {
+			return nil, err
+		}
+		if entry.Type != ftp.EntryTypeLink {
+			return newFTPInfo(path.Base(f.abs(name)), entry), nil
+		}
+		target := entry.Target
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(current), target)
+		}
+		current = target
+	}
+	return nil, &os.PathError{Op: "stat", Path: name, Err: errors.New("too many levels of symbolic links")}
+}
+
+func (f *FTP) Lstat(name string) (os.FileInfo, error) {
+	entry, err := f.entry("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return newFTPInfo(path.Base(f.abs(name)), entry), nil
+}
+
+func (f *FTP) ReadDir(name string) ([]os.FileInfo, error) {
+	var entries []*ftp.Entry
+	err := f.do(func(conn *ftpConn) error {
+		var err error
+		entries, err = conn.List(f.abs(name))
+		return err
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Name == "." || entry.Name == ".." {
+			continue
+		}
+		infos = append(infos, newFTPInfo(entry.Name, entry))
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+func (f *FTP) ReadLink(name string) (string, error) {
+	entry, err := f.entry("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if entry.Type != ftp.EntryTypeLink {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: errors.New("not a symlink")}
+	}
+	return entry.Target, nil
+}
+
+func (f *FTP) Open(name string) (File, error) {
+	return f.OpenFile(name, os.O_RDONLY)
+}
+
+func (f *FTP) Create(name string) (File, error) {
+	return f.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+}
+
+func (f *FTP) OpenFile(name string, flags int) (File, error) {
+	name = f.abs(name)
+	file := &ftpFile{fsys: f, name: name, flags: flags}
+	info, err := f.Stat(name)
+	switch {
+	case err != nil && (!errors.Is(err, fs.ErrNotExist) || flags&os.O_CREATE == 0):
+		return nil, err
+	case err != nil:
+		file.info = newFTPInfo(path.Base(name), &ftp.Entry{Type: ftp.EntryTypeFile, Time: time.Now()})
+		file.created = true
+	case info.IsDir():
+		return nil, &os.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	case flags&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &os.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	default:
+		file.info = info.(*ftpInfo)
+	}
+	file.size = file.info.Size()
+	if flags&os.O_TRUNC != 0 && file.writable() {
+		file.truncated = true
+		file.size = 0
+	}
+	return file, nil
+}
+
+func (f *FTP) Mkdir(name string) error {
+	return f.do(func(conn *ftpConn) error {
+		return conn.MakeDir(f.abs(name))
+	})
+}
+
+func (f *FTP) MkdirAll(name string) error {
+	current := "/"
+	for _, part := range splitPath(f.abs(name)) {
+		current = path.Join(current, part)
+		info, err := f.Stat(current)
+		if err == nil && !info.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: current, Err: errors.New("not a directory")}
+		}
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if err := f.Mkdir(current); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *FTP) Rename(oldname, newname string) error {
+	// Like sftp, the new path isn't replaced
+	if _, err := f.Lstat(newname); err == nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrExist}
+	}
+	return f.do(func(conn *ftpConn) error {
+		return conn.Rename(f.abs(oldname), f.abs(newname))
+	})
+}
+
+func (f *FTP) Remove(name string) error {
+	return f.do(func(conn *ftpConn) error {
+		err := conn.Delete(f.abs(name))
+		if err != nil && conn.RemoveDir(f.abs(name)) == nil {
+			return nil
+		}
+		return err
+	})
+}
+
+func (f *FTP) RemoveDirectory(name string) error {
+	return f.do(func(conn *ftpConn) error {
+		return conn.RemoveDir(f.abs(name))
+	})
+}
+
+func (f *FTP) Symlink(oldname, newname string) error {
+	return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: errors.ErrUnsupported}
+}
+
+func (f *FTP) Chmod(name string, mode os.FileMode) error {
+	return &os.PathError{Op: "chmod", Path: name, Err: errors.ErrUnsupported}
+}
+
+func (f *FTP) Chown(name string, uid, gid int) error {
+	return &os.PathError{Op: "chown", Path: name, Err: errors.ErrUnsupported}
+}
+
+func (f *FTP) Chtimes(name string, atime, mtime time.Time) error {
+	if !f.setTime {
+		return &os.PathError{Op: "chtimes", Path: name, Err: errors.ErrUnsupported}
+	}
+	return f.do(func(conn *ftpConn) error {
+		return conn.SetTime(f.abs(name), mtime)
+	})
+}
+
+// Log out of the server, the connections of the files still open are closed
+// when they're done
+func (f *FTP) Close() error {
+	f.mu.Lock()
+	idle := f.idle
+	f.idle = nil
+	f.closed = true
+	f.mu.Unlock()
+	for _, conn := range idle {
+		conn.Quit()
+	}
+	return nil
+}
+
+// Get the elements of the absolute clean path
+func splitPath(name string) []string {
+	var parts []string
+	for name != "/" {
+		parts = append([]string{path.Base(name)}, parts...)
+		name = path.Dir(name)
+	}
+	return parts
+}
+
+// The description of an entry of an ftp listing, which has no permissions:
+// the usual ones are shown
+type ftpInfo struct {
+	name  string
+	entry *ftp.Entry
+}
+
+func newFTPInfo(name string, entry *ftp.Entry) *ftpInfo {
+	return &ftpInfo{name: name, entry: entry}
+}
+
+func (i *ftpInfo) Name() string       { return i.name }
+func (i *ftpInfo) Size() int64        { return int64(i.entry.Size) }
+func (i *ftpInfo) ModTime() time.Time { return i.entry.Time }
+func (i *ftpInfo) IsDir() bool        { return i.entry.Type == ftp.EntryTypeFolder }
+func (i *ftpInfo) Sys() interface{}   { return nil }
+
+func (i *ftpInfo) Mode() os.FileMode {
+	switch i.entry.Type {
+	case ftp.EntryTypeFolder:
+		return os.ModeDir | 0755
+	case ftp.EntryTypeLink:
+		return os.ModeSymlink | 0777
+	}
+	return 0644
+}
+
+// An open file of an ftp server. The content comes from a download started
+// at the offset, and goes to an upload started at the first write.
+type ftpFile struct {
+	fsys  *FTP
+	name  string
+	flags int
+
+	mu        sync.Mutex // guards the fields below
+	info      *ftpInfo
+	size      int64
+	offset    int64
+	created   bool // missing when it was opened
+	truncated bool // emptied before any write
+	closed    bool
+
+	// The download being read and where it is
+	download       *ftp.Response
+	downloadConn   *ftpConn
+	downloadOffset int64
+
+	// The upload being written, where it is and its result once done
+	upload       *io.PipeWriter
+	uploadConn   *ftpConn
+	uploadOffset int64
+	uploadDone   chan error
+}
+
+// Tell the file can't be read out of order without restarting the transfer
+func (f *ftpFile) Streamed() bool {
+	return true
+}
+
+func (f *ftpFile) writable() bool {
+	return f.flags&(os.O_WRONLY|os.O_RDWR) != 0
+}
+
+// Check the file can be used for the operation
+func (f *ftpFile) check(op string, write bool) error {
+	switch {
+	case f.closed:
+		return &os.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	case write && !f.writable(), !write && f.flags&os.O_WRONLY != 0:
+		return &os.PathError{Op: op, Path: f.name, Err: fs.ErrPermission}
+	}
+	return nil
+}
+
+func (f *ftpFile) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("read", false); err != nil {
+		return 0, err
+	}
+	n, err := f.readAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *ftpFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("read", false); err != nil {
+		return 0, err
+	}
+	read := 0
+	for read < len(p) {
+		n, err := f.readAt(p[read:], off+int64(read))
+		read += n
+		if err != nil {
+			return read, err
+		}
+	}
+	return read, nil
+}
+
+// Read from the offset, continuing the download when it's there
+func (f *ftpFile) readAt(p []byte, off int64) (int, error) {
+	if off >= f.size {
+		f.stopDownload()
+		return 0, io.EOF
+	}
+	if f.download == nil || f.downloadOffset != off {
+		f.stopDownload()
+		conn, err := f.fsys.conn()
+		if err != nil {
+			return 0, err
+		}
+		download, err := conn.RetrFrom(f.name, uint64(off))
+		if err != nil {
+			f.fsys.release(conn, err)
+			return 0, err
+		}
+		f.download, f.downloadConn, f.downloadOffset = download, conn, off
+	}
+	n, err := f.download.Read(p)
+	f.downloadOffset += int64(n)
+	if err == io.EOF {
+		// The server confirms the end of the transfer
+		err = f.download.Close()
+		f.fsys.release(f.downloadConn, err)
+		f.download, f.downloadConn = nil, nil
+		if err == nil && n == 0 {
+			err = io.EOF
+		}
+	}
+	return n, err
+}
+
+// Close the download before its end: the connection is dropped, the server
+// may answer it in any way
+func (f *ftpFile) stopDownload() {
+	if f.download == nil {
+		return
+	}
+	f.download.Close()
+	f.fsys.release(f.downloadConn, net.ErrClosed)
+	f.download, f.downloadConn = nil, nil
+}
+
+func (f *ftpFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("write", true); err != nil {
+		return 0, err
+	}
+	n, err := f.writeAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *ftpFile) WriteAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("write", true); err != nil {
+		return 0, err
+	}
+	return f.writeAt(p, off)
+}
+
+// Write at the offset, which has to follow what was written
+func (f *ftpFile) writeAt(p []byte, off int64) (int, error) {
+	if f.upload == nil {
+		if err := f.startUpload(off); err != nil {
+			return 0, err
+		}
+	} else if off != f.uploadOffset {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: fmt.Errorf("writing out of order: %w", errors.ErrUnsupported)}
+	}
+	n, err := f.upload.Write(p)
+	f.uploadOffset += int64(n)
+	if f.uploadOffset > f.size {
+		f.size = f.uploadOffset
+	}
+	if err != nil {
+		// The reason the server stopped reading
+		err = f.finishUpload()
+	}
+	return n, err
+}
+
+// Start uploading what's written from the offset, appending when the file
+// is opened to
+func (f *ftpFile) startUpload(off int64) error {
+	if f.flags&os.O_APPEND != 0 {
+		off = f.size
+	}
+	if off > f.size {
+		return &os.PathError{Op: "write", Path: f.name, Err: fmt.Errorf("writing past the end: %w", errors.ErrUnsupported)}
+	}
+	conn, err := f.fsys.conn()
+	if err != nil {
+		return err
+	}
+	reader, writer := io.Pipe()
+	done := make(chan error, 1)
+	appending := f.flags&os.O_APPEND != 0 && off > 0
+	go func() {
+		var err error
+		switch {
+		case appending:
+			err = conn.Append(f.name, reader)
+		case off > 0:
+			err = conn.StorFrom(f.name, reader, uint64(off))
+		default:
+			err = conn.Stor(f.name, reader)
+		}
+		reader.CloseWithError(err)
+		done <- err
+	}()
+	f.upload, f.uploadConn, f.uploadOffset, f.uploadDone = writer, conn, off, done
+	// The upload replaces what follows the offset
+	f.size = off
+	return nil
+}
+
+// End the upload and get its result
+func (f *ftpFile) finishUpload() error {
+	if f.upload == nil {
+		return nil
+	}
+	f.upload.Close()
+	err := <-f.uploadDone
+	f.fsys.release(f.uploadConn, err)
+	f.upload, f.uploadConn = nil, nil
+	return err
+}
+
+func (f *ftpFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: fs.ErrClosed}
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Only emptying the file is possible, before writing it
+func (f *ftpFile) Truncate(size int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("truncate", true); err != nil {
+		return err
+	}
+	switch {
+	case size == f.size:
+		return nil
+	case size == 0 && f.upload == nil:
+		f.truncated = true
+		f.size = 0
+		return nil
+	}
+	return &os.PathError{Op: "truncate", Path: f.name, Err: errors.ErrUnsupported}
+}
+
+func (f *ftpFile) Stat() (os.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entry := *f.info.entry
+	entry.Size = uint64(f.size)
+	return newFTPInfo(f.info.name, &entry), nil
+}
+
+func (f *ftpFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &os.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	f.stopDownload()
+	if f.upload != nil {
+		return f.finishUpload()
+	}
+	if f.writable() && (f.created || f.truncated) {
+		// Nothing written, the file is still created or emptied
+		return f.fsys.do(func(conn *ftpConn) error {
+			return conn.Stor(f.name, bytes.NewReader(nil))
+		})
+	}
+	return nil
+}
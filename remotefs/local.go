@@ -0,0 +1,181 @@
+package remotefs
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A local directory seen as the filesystem of a server: the slash separated
+// paths are inside it and "/" is the directory itself. The working
+// directory is its root, or the home of the user for the whole filesystem.
+type Local struct {
+	root string
+	wd   string
+}
+
+// Use the local directory as a filesystem
+func NewLocal(root string) (*Local, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return &Local{root: root, wd: "/"}, nil
+}
+
+// Use the whole local filesystem, the one of the drive of the home on
+// Windows, starting from the home of the user
+func NewLocalHome() (*Local, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	l := &Local{root: filepath.VolumeName(home) + string(filepath.Separator), wd: "/"}
+	if l.wd, err = l.Path(home); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Get the path in the filesystem of the local path, which has to be inside
+// the root
+func (l *Local) Path(localPath string) (string, error) {
+	localPath, err := filepath.Abs(localPath)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(l.root, localPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is out of %s", localPath, l.root)
+	}
+	return path.Join("/", filepath.ToSlash(rel)), nil
+}
+
+// Get the absolute clean path of the path of the filesystem, which can't
+// go out of the root
+func (l *Local) abs(name string) string {
+	if !path.IsAbs(name) {
+		name = path.Join(l.wd, name)
+	}
+	return path.Clean("/" + name)
+}
+
+// Get the local path of the path of the filesystem
+func (l *Local) local(name string) string {
+	return filepath.Join(l.root, filepath.FromSlash(l.abs(name)))
+}
+
+func (l *Local) Getwd() (string, error) {
+	return l.wd, nil
+}
+
+func (l *Local) RealPath(name string) (string, error) {
+	return l.abs(name), nil
+}
+
+func (l *Local) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+func (l *Local) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(l.local(name))
+}
+
+func (l *Local) Lstat(name string) (os.FileInfo, error) {
+	return os.Lstat(l.local(name))
+}
+
+func (l *Local) ReadDir(name string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(l.local(name))
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			// Removed meanwhile
+			continue
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+func (l *Local) ReadLink(name string) (string, error) {
+	target, err := os.Readlink(l.local(name))
+	return filepath.ToSlash(target), err
+}
+
+func (l *Local) Open(name string) (File, error) {
+	return l.OpenFile(name, os.O_RDONLY)
+}
+
+func (l *Local) Create(name string) (File, error) {
+	return l.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+}
+
+func (l *Local) OpenFile(name string, flags int) (File, error) {
+	file, err := os.OpenFile(l.local(name), flags, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (l *Local) Mkdir(name string) error {
+	return os.Mkdir(l.local(name), 0777)
+}
+
+func (l *Local) MkdirAll(name string) error {
+	return os.MkdirAll(l.local(name), 0777)
+}
+
+func (l *Local) Rename(oldname, newname string) error {
+	// Like sftp, the new path isn't replaced
+	if _, err := os.Lstat(l.local(newname)); err == nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrExist}
+	}
+	return os.Rename(l.local(oldname), l.local(newname))
+}
+
+func (l *Local) Remove(name string) error {
+	return os.Remove(l.local(name))
+}
+
+func (l *Local) RemoveDirectory(name string) error {
+	info, err := os.Lstat(l.local(name))
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &os.PathError{Op: "rmdir", Path: name, Err: fmt.Errorf("not a directory")}
+	}
+	return os.Remove(l.local(name))
+}
+
+func (l *Local) Symlink(oldname, newname string) error {
+	return os.Symlink(filepath.FromSlash(oldname), l.local(newname))
+}
+
+func (l *Local) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(l.local(name), mode)
+}
+
+func (l *Local) Chown(name string, uid, gid int) error {
+	return os.Chown(l.local(name), uid, gid)
+}
+
+func (l *Local) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(l.local(name), atime, mtime)
+}
+
+func (l *Local) Close() error {
+	return nil
+}
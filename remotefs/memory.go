@@ -0,0 +1,562 @@
+package remotefs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// Symlinks followed resolving a path before giving up, like the kernels do
+const maxLinks = 40
+
+// A filesystem held in memory, empty but for the root directory which is
+// the working one: a server without the network, for the tests
+type Memory struct {
+	mu    sync.Mutex
+	nodes map[string]*memNode // by absolute clean path
+}
+
+// A file, directory or symlink of the memory filesystem
+type memNode struct {
+	mode     os.FileMode
+	modTime  time.Time
+	atime    time.Time
+	data     []byte // content of a file
+	target   string // where a symlink points
+	uid, gid uint32
+}
+
+// Create an empty memory filesystem
+func NewMemory() *Memory {
+	now := time.Now()
+	return &Memory{nodes: map[string]*memNode{
+		"/": {mode: os.ModeDir | 0755, modTime: now, atime: now},
+	}}
+}
+
+// Get the absolute clean path, the relative ones start from the root
+func absPath(name string) string {
+	return path.Clean("/" + name)
+}
+
+// Find the node of the path, following the symlinks of its directories and,
+// if asked, its own. The node is nil when the last element is missing, the
+// path returned is then where it would be.
+func (m *Memory) resolve(name string, followLast bool) (string, *memNode, error) {
+	current := absPath(name)
+	for hops := 0; hops <= maxLinks; hops++ {
+		parts := strings.Split(strings.TrimPrefix(current, "/"), "/")
+		dir := "/"
+		followed := false
+		for i, part := range parts {
+			if part == "" {
+				continue
+			}
+			next := path.Join(dir, part)
+			last := i == len(parts)-1
+			node, ok := m.nodes[next]
+			if !ok {
+				if last {
+					return next, nil, nil
+				}
+				return "", nil, fs.ErrNotExist
+			}
+			if node.mode&os.ModeSymlink != 0 && (!last || followLast) {
+				target := node.target
+				if !path.IsAbs(target) {
+					target = path.Join(dir, target)
+				}
+				current = path.Join(append([]string{target}, parts[i+1:]...)...)
+				followed = true
+				break
+			}
+			if !last && !node.mode.IsDir() {
+				return "", nil, errNotDir
+			}
+			dir = next
+		}
+		if !followed {
+			return dir, m.nodes[dir], nil
+		}
+	}
+	return "", nil, errTooManyLinks
+}
+
+var (
+	errNotDir       = errors.New("not a directory")
+	errIsDir        = errors.New("is a directory")
+	errNotEmpty     = errors.New("directory not empty")
+	errTooManyLinks = errors.New("too many levels of symbolic links")
+)
+
+// Find the existing node of the path
+func (m *Memory) existing(op, name string, followLast bool) (string, *memNode, error) {
+	resolved, node, err := m.resolve(name, followLast)
+	if err == nil && node == nil {
+		err = fs.ErrNotExist
+	}
+	if err != nil {
+		return "", nil, &os.PathError{Op: op, Path: name, Err: err}
+	}
+	return resolved, node, nil
+}
+
+// Find where the missing path is created, its directory has to exist
+func (m *Memory) missing(op, name string) (string, error) {
+	resolved, node, err := m.resolve(name, false)
+	if err == nil && node != nil {
+		err = fs.ErrExist
+	}
+	if err == nil {
+		if parent := m.nodes[path.Dir(resolved)]; parent == nil || !parent.mode.IsDir() {
+			err = fs.ErrNotExist
+		}
+	}
+	if err != nil {
+		return "", &os.PathError{Op: op, Path: name, Err: err}
+	}
+	return resolved, nil
+}
+
+// Get the paths of the entries of the directory
+func (m *Memory) children(dir string) []string {
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	var paths []string
+	for p := range m.nodes {
+		if p != "/" && strings.HasPrefix(p, prefix) && !strings.Contains(p[len(prefix):], "/") {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (m *Memory) Getwd() (string, error) {
+	return "/", nil
+}
+
+func (m *Memory) RealPath(name string) (string, error) {
+	return absPath(name), nil
+}
+
+func (m *Memory) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+func (m *Memory) Stat(name string) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, node, err := m.existing("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	// Named like the path, not like where its symlink points
+	return node.info(path.Base(absPath(name))), nil
+}
+
+func (m *Memory) Lstat(name string) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resolved, node, err := m.existing("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return node.info(path.Base(resolved)), nil
+}
+
+func (m *Memory) ReadDir(name string) ([]os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resolved, node, err := m.existing("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !node.mode.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+	var infos []os.FileInfo
+	for _, child := range m.children(resolved) {
+		infos = append(infos, m.nodes[child].info(path.Base(child)))
+	}
+	return infos, nil
+}
+
+func (m *Memory) ReadLink(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, node, err := m.existing("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if node.mode&os.ModeSymlink == 0 {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return node.target, nil
+}
+
+func (m *Memory) Open(name string) (File, error) {
+	return m.OpenFile(name, os.O_RDONLY)
+}
+
+func (m *Memory) Create(name string) (File, error) {
+	return m.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+}
+
+func (m *Memory) OpenFile(name string, flags int) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resolved, node, err := m.resolve(name, true)
+	switch {
+	case err != nil:
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	case node != nil && flags&os.O_CREATE != 0 && flags&os.O_EXCL != 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case node == nil && flags&os.O_CREATE == 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	case node == nil:
+		if resolved, err = m.missing("open", resolved); err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		node = &memNode{mode: 0644, modTime: now, atime: now}
+		m.nodes[resolved] = node
+	}
+
+	access := flags & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR)
+	file := &memFile{
+		fs:     m,
+		node:   node,
+		name:   path.Base(resolved),
+		read:   access == os.O_RDONLY || access == os.O_RDWR,
+		write:  access == os.O_WRONLY || access == os.O_RDWR,
+		append: flags&os.O_APPEND != 0,
+	}
+	if node.mode.IsDir() && file.write {
+		return nil, &os.PathError{Op: "open", Path: name, Err: errIsDir}
+	}
+	if flags&os.O_TRUNC != 0 && file.write {
+		node.data = nil
+		node.modTime = time.Now()
+	}
+	return file, nil
+}
+
+func (m *Memory) Mkdir(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resolved, err := m.missing("mkdir", name)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	m.nodes[resolved] = &memNode{mode: os.ModeDir | 0755, modTime: now, atime: now}
+	return nil
+}
+
+func (m *Memory) MkdirAll(name string) error {
+	dir := "/"
+	for _, part := range strings.Split(strings.TrimPrefix(absPath(name), "/"), "/") {
+		if part == "" {
+			continue
+		}
+		dir = path.Join(dir, part)
+		info, err := m.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return &os.PathError{Op: "mkdir", Path: dir, Err: errNotDir}
+			}
+			continue
+		}
+		if err := m.Mkdir(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Rename(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	oldPath, _, err := m.existing("rename", oldname, false)
+	if err != nil {
+		return err
+	}
+	newPath, err := m.missing("rename", newname)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(newPath, oldPath+"/") {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+	// The content of a directory moves with it
+	for p, node := range m.nodes {
+		if p == oldPath || strings.HasPrefix(p, oldPath+"/") {
+			delete(m.nodes, p)
+			m.nodes[newPath+p[len(oldPath):]] = node
+		}
+	}
+	m.nodes[path.Dir(newPath)].modTime = time.Now()
+	return nil
+}
+
+func (m *Memory) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resolved, node, err := m.existing("remove", name, false)
+	if err != nil {
+		return err
+	}
+	return m.remove(name, resolved, node)
+}
+
+func (m *Memory) RemoveDirectory(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resolved, node, err := m.existing("rmdir", name, false)
+	if err != nil {
+		return err
+	}
+	if !node.mode.IsDir() {
+		return &os.PathError{Op: "rmdir", Path: name, Err: errNotDir}
+	}
+	return m.remove(name, resolved, node)
+}
+
+// Remove the node, a directory has to be empty
+func (m *Memory) remove(name, resolved string, node *memNode) error {
+	if resolved == "/" {
+		return &os.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+	}
+	if node.mode.IsDir() && len(m.children(resolved)) > 0 {
+		return &os.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+	}
+	delete(m.nodes, resolved)
+	m.nodes[path.Dir(resolved)].modTime = time.Now()
+	return nil
+}
+
+func (m *Memory) Symlink(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	resolved, err := m.missing("symlink", newname)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	m.nodes[resolved] = &memNode{mode: os.ModeSymlink | 0777, modTime: now, atime: now, target: oldname}
+	return nil
+}
+
+func (m *Memory) Chmod(name string, mode os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, node, err := m.existing("chmod", name, true)
+	if err != nil {
+		return err
+	}
+	node.mode = node.mode.Type() | mode&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)
+	return nil
+}
+
+func (m *Memory) Chown(name string, uid, gid int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, node, err := m.existing("chown", name, true)
+	if err != nil {
+		return err
+	}
+	node.uid, node.gid = uint32(uid), uint32(gid)
+	return nil
+}
+
+func (m *Memory) Chtimes(name string, atime, mtime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, node, err := m.existing("chtimes", name, true)
+	if err != nil {
+		return err
+	}
+	node.atime, node.modTime = atime, mtime
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// Describe the node as it is now, with the attributes an sftp server sends
+func (n *memNode) info(name string) os.FileInfo {
+	size := int64(len(n.data))
+	if n.mode&os.ModeSymlink != 0 {
+		size = int64(len(n.target))
+	}
+	return &memInfo{
+		name:    name,
+		size:    size,
+		mode:    n.mode,
+		modTime: n.modTime,
+		stat: &sftp.FileStat{
+			Size:  uint64(size),
+			Mtime: uint32(n.modTime.Unix()),
+			Atime: uint32(n.atime.Unix()),
+			UID:   n.uid,
+			GID:   n.gid,
+		},
+	}
+}
+
+// The description of a node of the memory filesystem
+type memInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+	stat    *sftp.FileStat
+}
+
+func (i *memInfo) Name() string       { return i.name }
+func (i *memInfo) Size() int64        { return i.size }
+func (i *memInfo) Mode() os.FileMode  { return i.mode }
+func (i *memInfo) ModTime() time.Time { return i.modTime }
+func (i *memInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memInfo) Sys() interface{}   { return i.stat }
+
+// An open file of the memory filesystem
+type memFile struct {
+	fs     *Memory
+	node   *memNode
+	name   string
+	offset int64
+	read   bool
+	write  bool
+	append bool
+	closed bool
+}
+
+// Check the file can be used for the operation
+func (f *memFile) check(op string, write bool) error {
+	switch {
+	case f.closed:
+		return &os.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	case f.node.mode.IsDir():
+		return &os.PathError{Op: op, Path: f.name, Err: errIsDir}
+	case write && !f.write, !write && !f.read:
+		return &os.PathError{Op: op, Path: f.name, Err: fs.ErrPermission}
+	}
+	return nil
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	n, err := f.readAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	return f.readAt(p, off)
+}
+
+func (f *memFile) readAt(p []byte, off int64) (int, error) {
+	if err := f.check("read", false); err != nil {
+		return 0, err
+	}
+	if off >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.node.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.append {
+		f.offset = int64(len(f.node.data))
+	}
+	n, err := f.writeAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *memFile) WriteAt(p []byte, off int64) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	return f.writeAt(p, off)
+}
+
+func (f *memFile) writeAt(p []byte, off int64) (int, error) {
+	if err := f.check("write", true); err != nil {
+		return 0, err
+	}
+	if end := off + int64(len(p)); end > int64(len(f.node.data)) {
+		data := make([]byte, end)
+		copy(data, f.node.data)
+		f.node.data = data
+	}
+	copy(f.node.data[off:], p)
+	f.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.node.data))
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if err := f.check("truncate", true); err != nil {
+		return err
+	}
+	if size < 0 {
+		return &os.PathError{Op: "truncate", Path: f.name, Err: fs.ErrInvalid}
+	}
+	data := make([]byte, size)
+	copy(data, f.node.data)
+	f.node.data = data
+	f.node.modTime = time.Now()
+	return nil
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	return f.node.info(f.name), nil
+}
+
+func (f *memFile) Close() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.closed {
+		return &os.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
@@ -0,0 +1,194 @@
+package remotefs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// Create the files with their content and the directories, the paths
+// ending with a slash
+func memoryTree(t *testing.T, paths ...string) *Memory {
+	t.Helper()
+	m := NewMemory()
+	for _, p := range paths {
+		if p[len(p)-1] == '/' {
+			if err := m.MkdirAll(p); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		writeFile(t, m, p, p)
+	}
+	return m
+}
+
+func writeFile(t *testing.T, fsys FileSystem, name, content string) {
+	t.Helper()
+	file, err := fsys.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(file, content); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, fsys FileSystem, name string) string {
+	t.Helper()
+	file, err := fsys.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestMemoryFiles(t *testing.T) {
+	m := memoryTree(t, "/dir/", "/dir/file")
+	if content := readFile(t, m, "dir/file"); content != "/dir/file" {
+		t.Errorf("read %q", content)
+	}
+
+	file, err := m.OpenFile("/dir/file", os.O_WRONLY|os.O_APPEND)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(file, "+")
+	file.Close()
+	if content := readFile(t, m, "/dir/file"); content != "/dir/file+" {
+		t.Errorf("appended %q", content)
+	}
+
+	if _, err := m.OpenFile("/dir/file", os.O_WRONLY|os.O_CREATE|os.O_EXCL); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("exclusive create of an existing file: %v", err)
+	}
+	if _, err := m.Create("/missing/file"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("create in a missing directory: %v", err)
+	}
+	if _, err := m.Create("/dir"); err == nil {
+		t.Error("a directory was opened for writing")
+	}
+}
+
+func TestMemorySymlinks(t *testing.T) {
+	m := memoryTree(t, "/dir/", "/dir/file")
+	if err := m.Symlink("dir", "/link"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Symlink("/loop", "/loop"); err != nil {
+		t.Fatal(err)
+	}
+
+	if content := readFile(t, m, "/link/file"); content != "/dir/file" {
+		t.Errorf("read through the link %q", content)
+	}
+	info, err := m.Stat("/link")
+	if err != nil || !info.IsDir() || info.Name() != "link" {
+		t.Errorf("stat of the link: %v, %v", info, err)
+	}
+	info, err = m.Lstat("/link")
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("lstat of the link: %v, %v", info, err)
+	}
+	if target, err := m.ReadLink("/link"); target != "dir" || err != nil {
+		t.Errorf("link target %q, %v", target, err)
+	}
+	if _, err := m.Stat("/loop"); err == nil {
+		t.Error("the loop was resolved")
+	}
+}
+
+func TestMemoryRename(t *testing.T) {
+	m := memoryTree(t, "/a/", "/a/file", "/b")
+	if err := m.Rename("/a", "/b"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("rename over an existing file: %v", err)
+	}
+	if err := m.Rename("/a", "/a/inside"); err == nil {
+		t.Error("a directory was moved inside itself")
+	}
+	if err := m.Rename("/a", "/c"); err != nil {
+		t.Fatal(err)
+	}
+	if content := readFile(t, m, "/c/file"); content != "/a/file" {
+		t.Errorf("the content moved with the directory is %q", content)
+	}
+	if _, err := m.Stat("/a"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("the old path is still there: %v", err)
+	}
+}
+
+func TestMemoryRemove(t *testing.T) {
+	m := memoryTree(t, "/dir/", "/dir/file")
+	if err := m.RemoveDirectory("/dir"); err == nil {
+		t.Error("a directory with content was removed")
+	}
+	if err := m.RemoveDirectory("/dir/file"); err == nil {
+		t.Error("a file was removed as a directory")
+	}
+	if err := m.Remove("/"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("removing the root: %v", err)
+	}
+	if err := m.Remove("/dir/file"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RemoveDirectory("/dir"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	m := memoryTree(t, "/root/b/", "/root/b/file", "/root/a", "/other")
+	if err := m.Symlink("/other", "/root/c"); err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	walker := Walk(m, "/root")
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, walker.Path())
+	}
+	want := []string{"/root", "/root/a", "/root/b", "/root/b/file", "/root/c"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("walked %v, want %v", paths, want)
+	}
+}
+
+func TestRemoveAll(t *testing.T) {
+	m := memoryTree(t, "/root/dir/sub/", "/root/dir/sub/file", "/root/dir/file", "/root/kept", "/other")
+	// The symlink is removed, not where it points
+	if err := m.Symlink("/other", "/root/dir/link"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RemoveAll(m, "/root/dir"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Lstat("/root/dir"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("the directory is still there: %v", err)
+	}
+	for _, kept := range []string{"/root/kept", "/other"} {
+		if _, err := m.Stat(kept); err != nil {
+			t.Errorf("%s was removed: %v", kept, err)
+		}
+	}
+	if err := RemoveAll(m, "/root/kept"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Stat("/root/kept"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("the file is still there: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+// Package remotefs abstracts the filesystem of the server behind the
+// operations the program makes on it, so the code browsing and changing the
+// remote files runs on an sftp session, an ftp server, a local directory or in
+// memory.
+package remotefs
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/kr/fs"
+)
+
+// An open file of the filesystem
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Writer
+	io.WriterAt
+	io.Seeker
+	io.Closer
+	// Cut or extend the file to the size
+	Truncate(size int64) error
+	Stat() (os.FileInfo, error)
+}
+
+// A file read through a single stream, like over ftp: reading it at other
+// offsets restarts the transfer, so it's better read in order
+type Streamed interface {
+	Streamed() bool
+}
+
+// The operations on the files of the server, with the semantics of sftp:
+// the paths are slash separated, the relative ones start from the working
+// directory and Rename doesn't replace an existing file.
+type FileSystem interface {
+	// Get the working directory, the home of the user on a server
+	Getwd() (string, error)
+	// Get the absolute clean path of the path
+	RealPath(path string) (string, error)
+	// Join the elements of a path, the result is cleaned
+	Join(elem ...string) string
+
+	// Describe the file, following the symlinks
+	Stat(path string) (os.FileInfo, error)
+	// Describe the file, the symlink itself when it's one
+	Lstat(path string) (os.FileInfo, error)
+	// List the entries of the directory
+	ReadDir(path string) ([]os.FileInfo, error)
+	// Get where the symlink points
+	ReadLink(path string) (string, error)
+
+	// Open the file for reading
+	Open(path string) (File, error)
+	// Create the file for writing, truncating it if it exists
+	Create(path string) (File, error)
+	// Open the file with the flags of os.OpenFile
+	OpenFile(path string, flags int) (File, error)
+
+	// Create the directory, its parent has to exist
+	Mkdir(path string) error
+	// Create the directory and the missing parents, no error if it exists
+	MkdirAll(path string) error
+	// Move the file, failing when the new path exists
+	Rename(oldname, newname string) error
+	// Remove the file or the empty directory
+	Remove(path string) error
+	// Remove the empty directory
+	RemoveDirectory(path string) error
+	// Create the symlink newname pointing to oldname
+	Symlink(oldname, newname string) error
+
+	// Change the permissions
+	Chmod(path string, mode os.FileMode) error
+	// Change the owner and the group
+	Chown(path string, uid, gid int) error
+	// Change the access and the modification time
+	Chtimes(path string, atime, mtime time.Time) error
+
+	// Release the filesystem, the connection of a server
+	Close() error
+}
+
+// Walk the tree of the root, the directories before their content. The
+// symlinks aren't followed.
+func Walk(fsys FileSystem, root string) *fs.Walker {
+	return fs.WalkFS(root, fsys)
+}
+
+// Remove the path, and all its content if it's a directory
+func RemoveAll(fsys FileSystem, path string) error {
+	var paths []string
+	var dirs []bool
+	walker := Walk(fsys, path)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return err
+		}
+		paths = append(paths, walker.Path())
+		dirs = append(dirs, walker.Stat().IsDir())
+	}
+
+	// Remove the children before their directory
+	for i := len(paths) - 1; i >= 0; i-- {
+		var err error
+		if dirs[i] {
+			err = fsys.RemoveDirectory(paths[i])
+		} else {
+			err = fsys.Remove(paths[i])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,597 @@
+package remotefs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// Size of the parts of the uploads, which are held in memory: the objects
+// of unknown size are sent a part at a time
+const s3PartSize = 16 * 1024 * 1024
+
+// Where the bucket is and how to sign the requests
+type S3Options struct {
+	// host and port of the service, like s3.amazonaws.com
+	Endpoint string
+	// whether the service is reached with https
+	Secure bool
+	Bucket string
+	// region of the bucket, asked to the service when empty
+	Region string
+	// the credentials, when empty they're looked for in the environment,
+	// the files of the aws and minio clients and the instance metadata
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// The objects of an s3 bucket seen as files. The keys are split on the
+// slashes into directories: "/" is the bucket, a directory exists while
+// there are keys below it, and creating an empty one stores a marker, a
+// key ending with a slash.
+//
+// The objects have no permissions, owners, symlinks or modification times
+// to set: those operations fail with errors.ErrUnsupported. An object is
+// written whole, opening it for writing starts over from the beginning.
+type S3 struct {
+	client    *minio.Client
+	transport *http.Transport // keeps the connections to the service open
+	bucket    string
+}
+
+// Connect to the service and check the bucket is there
+func DialS3(ctx context.Context, options S3Options) (*S3, error) {
+	creds := credentials.NewStaticV4(options.AccessKey, options.SecretKey, options.SessionToken)
+	if options.AccessKey == "" {
+		creds = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.EnvMinio{},
+			&credentials.FileAWSCredentials{},
+			&credentials.FileMinioClient{},
+			&credentials.IAM{},
+		})
+	}
+	transport, err := minio.DefaultTransport(options.Secure)
+	if err != nil {
+		return nil, err
+	}
+	client, err := minio.New(options.Endpoint, &minio.Options{
+		Creds:     creds,
+		Secure:    options.Secure,
+		Region:    options.Region,
+		Transport: transport,
+	})
+	if err != nil {
+		return nil, err
+	}
+	exists, err := client.BucketExists(ctx, options.Bucket)
+	if err == nil && !exists {
+		err = fmt.Errorf("no bucket %s", options.Bucket)
+	}
+	if err != nil {
+		transport.CloseIdleConnections()
+		return nil, err
+	}
+	return &S3{client: client, transport: transport, bucket: options.Bucket}, nil
+}
+
+// Get the key of the path, without the leading slash
+func (s *S3) key(name string) string {
+	return strings.TrimPrefix(absPath(name), "/")
+}
+
+// Get the prefix of the keys inside the directory
+func (s *S3) dirPrefix(name string) string {
+	if key := s.key(name); key != "" {
+		return key + "/"
+	}
+	return ""
+}
+
+// Tell whether there are keys inside the directory, the marker included
+func (s *S3) hasKeys(prefix string) (bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, MaxKeys: 1}) {
+		return object.Err == nil, object.Err
+	}
+	return false, nil
+}
+
+// Translate the errors of the service to the ones of the files
+func s3Error(op, name string, err error) error {
+	switch minio.ToErrorResponse(err).Code {
+	case "NoSuchKey":
+		err = fs.ErrNotExist
+	case "AccessDenied":
+		err = fs.ErrPermission
+	}
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+func (s *S3) Getwd() (string, error) {
+	return "/", nil
+}
+
+func (s *S3) RealPath(name string) (string, error) {
+	return absPath(name), nil
+}
+
+func (s *S3) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+func (s *S3) Stat(name string) (os.FileInfo, error) {
+	key := s.key(name)
+	if key == "" {
+		return &s3Info{name: "/", dir: true}, nil
+	}
+	object, err := s.client.StatObject(context.Background(), s.bucket, key, minio.StatObjectOptions{})
+	if err == nil {
+		return &s3Info{name: path.Base(key), size: object.Size, modTime: object.LastModified}, nil
+	}
+	if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+		return nil, s3Error("stat", name, err)
+	}
+	dir, err := s.hasKeys(key + "/")
+	if err != nil {
+		return nil, s3Error("stat", name, err)
+	}
+	if !dir {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return &s3Info{name: path.Base(key), dir: true}, nil
+}
+
+// There are no symlinks
+func (s *S3) Lstat(name string) (os.FileInfo, error) {
+	return s.Stat(name)
+}
+
+func (s *S3) ReadDir(name string) ([]os.FileInfo, error) {
+	prefix := s.dirPrefix(name)
+	// An object hides the directory of the same name, like in Stat
+	entries := make(map[string]*s3Info)
+	for object := range s.client.ListObjects(context.Background(), s.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if object.Err != nil {
+			return nil, s3Error("readdir", name, object.Err)
+		}
+		entry := strings.TrimPrefix(object.Key, prefix)
+		switch {
+		case entry == "":
+			// The marker of the directory itself
+			continue
+		case strings.HasSuffix(entry, "/"):
+			entry = strings.TrimSuffix(entry, "/")
+			if entries[entry] == nil {
+				entries[entry] = &s3Info{name: entry, dir: true}
+			}
+		default:
+			entries[entry] = &s3Info{name: entry, size: object.Size, modTime: object.LastModified}
+		}
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, info := range entries {
+		infos = append(infos, info)
+	}
+	if len(infos) == 0 && prefix != "" {
+		// An empty listing is a missing directory, unless it has a marker
+		if _, err := s.Stat(name); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+func (s *S3) ReadLink(name string) (string, error) {
+	return "", &os.PathError{Op: "readlink", Path: name, Err: errors.ErrUnsupported}
+}
+
+func (s *S3) Open(name string) (File, error) {
+	return s.OpenFile(name, os.O_RDONLY)
+}
+
+func (s *S3) Create(name string) (File, error) {
+	return s.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC)
+}
+
+// The objects opened for writing start empty: they're replaced by what's
+// written, appending to them isn't possible
+func (s *S3) OpenFile(name string, flags int) (File, error) {
+	file := &s3File{fsys: s, name: name, key: s.key(name), flags: flags}
+	info, err := s.Stat(name)
+	switch {
+	case err != nil && (!errors.Is(err, fs.ErrNotExist) || flags&os.O_CREATE == 0):
+		return nil, err
+	case err != nil:
+		file.info = &s3Info{name: path.Base(file.key), modTime: time.Now()}
+		file.created = true
+	case info.IsDir():
+		return nil, &os.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	case flags&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &os.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	default:
+		file.info = info.(*s3Info)
+	}
+
+	if !file.writable() {
+		object, err := s.client.GetObject(context.Background(), s.bucket, file.key, minio.GetObjectOptions{})
+		if err != nil {
+			return nil, s3Error("open", name, err)
+		}
+		file.object = object
+		return file, nil
+	}
+	if flags&os.O_APPEND != 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: fmt.Errorf("appending: %w", errors.ErrUnsupported)}
+	}
+	file.info = &s3Info{name: file.info.name, modTime: file.info.modTime}
+	file.truncated = flags&os.O_TRUNC != 0
+	return file, nil
+}
+
+func (s *S3) Mkdir(name string) error {
+	if _, err := s.Stat(name); err == nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	parent, err := s.Stat(path.Dir(absPath(name)))
+	if err != nil {
+		return err
+	}
+	if !parent.IsDir() {
+		return &os.PathError{Op: "mkdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return s.putMarker(name)
+}
+
+// The parents of the directory exist as long as it does, only its own
+// marker is stored
+func (s *S3) MkdirAll(name string) error {
+	info, err := s.Stat(name)
+	switch {
+	case err == nil && info.IsDir():
+		return nil
+	case err == nil:
+		return &os.PathError{Op: "mkdir", Path: name, Err: errors.New("not a directory")}
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+	return s.putMarker(name)
+}
+
+// Store the marker of the empty directory
+func (s *S3) putMarker(name string) error {
+	_, err := s.client.PutObject(context.Background(), s.bucket, s.dirPrefix(name), bytes.NewReader(nil), 0, minio.PutObjectOptions{})
+	if err != nil {
+		return s3Error("mkdir", name, err)
+	}
+	return nil
+}
+
+// The objects are copied to their new keys then removed, the directories
+// with all the keys below them
+func (s *S3) Rename(oldname, newname string) error {
+	if _, err := s.Stat(newname); err == nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrExist}
+	}
+	info, err := s.Stat(oldname)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return s.move(s.key(oldname), s.key(newname))
+	}
+
+	oldPrefix, newPrefix := s.dirPrefix(oldname), s.dirPrefix(newname)
+	if oldPrefix == "" || strings.HasPrefix(newPrefix, oldPrefix) {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+	for object := range s.client.ListObjects(context.Background(), s.bucket, minio.ListObjectsOptions{Prefix: oldPrefix, Recursive: true}) {
+		if object.Err != nil {
+			return s3Error("rename", oldname, object.Err)
+		}
+		if err := s.move(object.Key, newPrefix+strings.TrimPrefix(object.Key, oldPrefix)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Copy the object to the new key, then remove it
+func (s *S3) move(oldKey, newKey string) error {
+	ctx := context.Background()
+	_, err := s.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: s.bucket, Object: newKey},
+		minio.CopySrcOptions{Bucket: s.bucket, Object: oldKey})
+	if err != nil {
+		return s3Error("rename", "/"+oldKey, err)
+	}
+	if err := s.client.RemoveObject(ctx, s.bucket, oldKey, minio.RemoveObjectOptions{}); err != nil {
+		return s3Error("rename", "/"+oldKey, err)
+	}
+	return nil
+}
+
+func (s *S3) Remove(name string) error {
+	info, err := s.Stat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return s.RemoveDirectory(name)
+	}
+	if err := s.client.RemoveObject(context.Background(), s.bucket, s.key(name), minio.RemoveObjectOptions{}); err != nil {
+		return s3Error("remove", name, err)
+	}
+	return nil
+}
+
+// Remove the marker of the empty directory, the ones without a marker go
+// away with their last key
+func (s *S3) RemoveDirectory(name string) error {
+	prefix := s.dirPrefix(name)
+	if prefix == "" {
+		return &os.PathError{Op: "rmdir", Path: name, Err: fs.ErrPermission}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	found := false
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if object.Err != nil {
+			return s3Error("rmdir", name, object.Err)
+		}
+		if object.Key != prefix {
+			return &os.PathError{Op: "rmdir", Path: name, Err: errors.New("directory not empty")}
+		}
+		found = true
+	}
+	if !found {
+		// Gone with its last key, unless it's an object
+		if info, err := s.Stat(name); err == nil && !info.IsDir() {
+			return &os.PathError{Op: "rmdir", Path: name, Err: errors.New("not a directory")}
+		}
+		return nil
+	}
+	if err := s.client.RemoveObject(ctx, s.bucket, prefix, minio.RemoveObjectOptions{}); err != nil {
+		return s3Error("rmdir", name, err)
+	}
+	return nil
+}
+
+func (s *S3) Symlink(oldname, newname string) error {
+	return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: errors.ErrUnsupported}
+}
+
+func (s *S3) Chmod(name string, mode os.FileMode) error {
+	return &os.PathError{Op: "chmod", Path: name, Err: errors.ErrUnsupported}
+}
+
+func (s *S3) Chown(name string, uid, gid int) error {
+	return &os.PathError{Op: "chown", Path: name, Err: errors.ErrUnsupported}
+}
+
+func (s *S3) Chtimes(name string, atime, mtime time.Time) error {
+	return &os.PathError{Op: "chtimes", Path: name, Err: errors.ErrUnsupported}
+}
+
+// Close the connections kept open for the next requests
+func (s *S3) Close() error {
+	s.transport.CloseIdleConnections()
+	return nil
+}
+
+// The description of an object, or of a directory of keys
+type s3Info struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (i *s3Info) Name() string       { return i.name }
+func (i *s3Info) Size() int64        { return i.size }
+func (i *s3Info) ModTime() time.Time { return i.modTime }
+func (i *s3Info) IsDir() bool        { return i.dir }
+func (i *s3Info) Sys() interface{}   { return nil }
+
+func (i *s3Info) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+// An open object: read with ranged requests, or written in order by an
+// upload started at the first write
+type s3File struct {
+	fsys  *S3
+	name  string
+	key   string
+	flags int
+
+	mu        sync.Mutex // guards the fields below
+	info      *s3Info
+	object    *minio.Object // the object read
+	offset    int64
+	created   bool // missing when it was opened
+	truncated bool // emptied before any write
+	closed    bool
+
+	// The upload being written and its result once done
+	upload     *io.PipeWriter
+	uploadDone chan error
+}
+
+func (f *s3File) writable() bool {
+	return f.flags&(os.O_WRONLY|os.O_RDWR) != 0
+}
+
+// Check the file can be used for the operation
+func (f *s3File) check(op string, write bool) error {
+	switch {
+	case f.closed:
+		return &os.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	case write != f.writable():
+		return &os.PathError{Op: op, Path: f.name, Err: fs.ErrPermission}
+	}
+	return nil
+}
+
+func (f *s3File) Read(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("read", false); err != nil {
+		return 0, err
+	}
+	return f.object.Read(p)
+}
+
+func (f *s3File) ReadAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	object := f.object
+	err := f.check("read", false)
+	f.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	// The ranged requests can run together
+	return object.ReadAt(p, off)
+}
+
+func (f *s3File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("write", true); err != nil {
+		return 0, err
+	}
+	return f.writeAt(p, f.offset)
+}
+
+func (f *s3File) WriteAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("write", true); err != nil {
+		return 0, err
+	}
+	return f.writeAt(p, off)
+}
+
+// Write at the offset, which has to follow what was written
+func (f *s3File) writeAt(p []byte, off int64) (int, error) {
+	if off != f.info.size {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: fmt.Errorf("writing out of order: %w", errors.ErrUnsupported)}
+	}
+	if f.upload == nil {
+		reader, writer := io.Pipe()
+		done := make(chan error, 1)
+		go func() {
+			_, err := f.fsys.client.PutObject(context.Background(), f.fsys.bucket, f.key, reader, -1, minio.PutObjectOptions{PartSize: s3PartSize})
+			reader.CloseWithError(err)
+			done <- err
+		}()
+		f.upload, f.uploadDone = writer, done
+	}
+	n, err := f.upload.Write(p)
+	f.info.size += int64(n)
+	f.offset = f.info.size
+	if err != nil {
+		// The reason the service stopped reading
+		err = f.finishUpload()
+	}
+	return n, err
+}
+
+// End the upload and get its result
+func (f *s3File) finishUpload() error {
+	f.upload.Close()
+	err := <-f.uploadDone
+	f.upload = nil
+	if err != nil {
+		return s3Error("write", f.name, err)
+	}
+	return nil
+}
+
+func (f *s3File) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: fs.ErrClosed}
+	}
+	if f.object != nil {
+		return f.object.Seek(offset, whence)
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.size
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Only emptying the object is possible, before writing it
+func (f *s3File) Truncate(size int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.check("truncate", true); err != nil {
+		return err
+	}
+	switch {
+	case size == f.info.size:
+		return nil
+	case size == 0 && f.upload == nil:
+		f.truncated = true
+		f.info.size = 0
+		return nil
+	}
+	return &os.PathError{Op: "truncate", Path: f.name, Err: errors.ErrUnsupported}
+}
+
+func (f *s3File) Stat() (os.FileInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	info := *f.info
+	return &info, nil
+}
+
+func (f *s3File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return &os.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	switch {
+	case f.object != nil:
+		return f.object.Close()
+	case f.upload != nil:
+		return f.finishUpload()
+	case f.created || f.truncated:
+		// Nothing written, the object is still created or emptied
+		_, err := f.fsys.client.PutObject(context.Background(), f.fsys.bucket, f.key, bytes.NewReader(nil), 0, minio.PutObjectOptions{})
+		if err != nil {
+			return s3Error("close", f.name, err)
+		}
+	}
+	return nil
+}
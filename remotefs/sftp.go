@@ -0,0 +1,40 @@
+package remotefs
+
+import (
+	"github.com/pkg/sftp"
+)
+
+// The filesystem of the server reached by the sftp session. The client is
+// embedded: its other methods, like the free space, stay at hand.
+type SFTP struct {
+	*sftp.Client
+}
+
+// Use the sftp session as a filesystem
+func NewSFTP(client *sftp.Client) *SFTP {
+	return &SFTP{Client: client}
+}
+
+func (s *SFTP) Open(path string) (File, error) {
+	file, err := s.Client.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (s *SFTP) Create(path string) (File, error) {
+	file, err := s.Client.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (s *SFTP) OpenFile(path string, flags int) (File, error) {
+	file, err := s.Client.OpenFile(path, flags)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
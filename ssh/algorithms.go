@@ -0,0 +1,76 @@
+package ssh
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Start of the error of x/crypto when the server shares no algorithm
+const noCommonPrefix = "no common algorithm for "
+
+// Algorithms of the Legacy preset: the default ones first, then the old ones
+// still offered by network appliances and old servers. They are the last
+// choice, a server offering better ones still gets those.
+var (
+	legacyHostKeyAlgorithms = []string{
+		ssh.CertAlgoED25519v01, ssh.CertAlgoECDSA256v01, ssh.CertAlgoECDSA384v01, ssh.CertAlgoECDSA521v01,
+		ssh.CertAlgoRSASHA512v01, ssh.CertAlgoRSASHA256v01,
+		ssh.KeyAlgoED25519, ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521,
+		ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256,
+		ssh.CertAlgoRSAv01, ssh.CertAlgoDSAv01, ssh.KeyAlgoRSA, ssh.KeyAlgoDSA,
+	}
+	legacyKeyExchanges = []string{
+		"curve25519-sha256", "curve25519-sha256@libssh.org",
+		"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+		"diffie-hellman-group14-sha256", "diffie-hellman-group-exchange-sha256",
+		"diffie-hellman-group14-sha1", "diffie-hellman-group-exchange-sha1", "diffie-hellman-group1-sha1",
+	}
+	legacyCiphers = []string{
+		"aes128-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+		"aes128-ctr", "aes192-ctr", "aes256-ctr",
+		"aes128-cbc", "3des-cbc",
+	}
+	legacyMACs = []string{
+		"hmac-sha2-256-etm@openssh.com", "hmac-sha2-256", "hmac-sha1", "hmac-sha1-96",
+	}
+)
+
+// Set the algorithms of the options in the config, the empty lists keep the
+// defaults of x/crypto or the ones of the Legacy preset
+func applyAlgorithms(config *ssh.ClientConfig, options Options) {
+	if options.Legacy {
+		config.HostKeyAlgorithms = legacyHostKeyAlgorithms
+		config.KeyExchanges = legacyKeyExchanges
+		config.Ciphers = legacyCiphers
+		config.MACs = legacyMACs
+	}
+	if len(options.HostKeyAlgorithms) > 0 {
+		config.HostKeyAlgorithms = options.HostKeyAlgorithms
+	}
+	if len(options.KeyExchanges) > 0 {
+		config.KeyExchanges = options.KeyExchanges
+	}
+	if len(options.Ciphers) > 0 {
+		config.Ciphers = options.Ciphers
+	}
+	if len(options.MACs) > 0 {
+		config.MACs = options.MACs
+	}
+}
+
+// Explain the handshake that failed because the server offers none of the
+// algorithms of the client, the message of x/crypto is like "ssh: no common
+// algorithm for key exchange; client offered: [...], server offered: [...]"
+func noCommonAlgorithm(text, host string) string {
+	what := text[strings.Index(text, noCommonPrefix)+len(noCommonPrefix):]
+	if end := strings.Index(what, ";"); end >= 0 {
+		what = what[:end]
+	}
+	offered := ""
+	if at := strings.LastIndex(text, "server offered: "); at >= 0 {
+		offered = " (it offers " + text[at+len("server offered: "):] + ")"
+	}
+	return fmt.Sprintf("%s accepts none of the %s algorithms tried%s, connect with --legacy or set the algorithms in the config file", host, what, offered)
+}
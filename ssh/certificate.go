@@ -0,0 +1,51 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/guglielmobartelloni/sftp-tui/localpath"
+	"golang.org/x/crypto/ssh"
+)
+
+// Get the signer presenting the OpenSSH user certificate of the private key,
+// like the ones issued by Vault or Teleport. The certificate is read from the
+// path, or from the -cert.pub file next to the key; nil is returned when
+// there's none.
+func loadCertificate(certPath, privateKeyPath string, signer ssh.Signer) (ssh.Signer, error) {
+	explicit := certPath != ""
+	if !explicit {
+		certPath = privateKeyPath + "-cert.pub"
+	}
+	certPath = localpath.ExpandHome(certPath)
+	data, err := os.ReadFile(certPath)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading the certificate failed %v", err)
+	}
+
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the certificate %s failed %v", certPath, err)
+	}
+	cert, ok := publicKey.(*ssh.Certificate)
+	if !ok || cert.CertType != ssh.UserCert {
+		return nil, fmt.Errorf("%s isn't an ssh user certificate", certPath)
+	}
+	if cert.ValidBefore != ssh.CertTimeInfinity && uint64(time.Now().Unix()) >= cert.ValidBefore {
+		expired := time.Unix(int64(cert.ValidBefore), 0).Format(time.RFC1123)
+		return nil, fmt.Errorf("the certificate %s expired on %s, get a new one", certPath, expired)
+	}
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, fmt.Errorf("the certificate %s isn't for the private key %s", certPath, privateKeyPath)
+	}
+	slog.Debug("using the certificate", "path", certPath, "key id", cert.KeyId, "principals", cert.ValidPrincipals)
+	return certSigner, nil
+}
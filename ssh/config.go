@@ -0,0 +1,85 @@
+package ssh
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/guglielmobartelloni/sftp-tui/localpath"
+	"github.com/kevinburke/ssh_config"
+)
+
+// Connection settings of a host read from the ssh config files
+type HostConfig struct {
+	HostName        string
+	User            string
+	Port            string
+	IdentityFile    string
+	CertificateFile string
+	ProxyJump       string
+}
+
+// Resolve the host alias using ~/.ssh/config and /etc/ssh/ssh_config.
+// The settings not present in the config files are left empty, except for
+// the host name which defaults to the alias itself.
+func ResolveHost(alias string) HostConfig {
+	hostConfig := HostConfig{
+		HostName:        configValue(alias, "HostName"),
+		User:            configValue(alias, "User"),
+		Port:            configValue(alias, "Port"),
+		IdentityFile:    localpath.ExpandHome(configValue(alias, "IdentityFile")),
+		CertificateFile: localpath.ExpandHome(configValue(alias, "CertificateFile")),
+		ProxyJump:       configValue(alias, "ProxyJump"),
+	}
+	if hostConfig.HostName == "" {
+		hostConfig.HostName = alias
+	}
+	return hostConfig
+}
+
+// Get the value of the key for the alias ignoring the ssh defaults
+func configValue(alias, key string) string {
+	value := ssh_config.Get(alias, key)
+	if value == ssh_config.Default(key) {
+		return ""
+	}
+	return value
+}
+
+// Get the host aliases named in ~/.ssh/config and /etc/ssh/ssh_config, the
+// patterns with wildcards and the negated ones are left out. The files
+// missing or that can't be parsed are skipped.
+func Hosts() []string {
+	files := []string{filepath.Join("/", "etc", "ssh", "ssh_config")}
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append([]string{filepath.Join(home, ".ssh", "config")}, files...)
+	}
+
+	seen := map[string]bool{}
+	var hosts []string
+	for _, name := range files {
+		file, err := os.Open(name)
+		if err != nil {
+			continue
+		}
+		config, err := ssh_config.Decode(file)
+		file.Close()
+		if err != nil {
+			continue
+		}
+		for _, host := range config.Hosts {
+			for _, pattern := range host.Patterns {
+				alias := pattern.String()
+				// A negated pattern doesn't match its alias
+				if strings.ContainsAny(alias, "*?") || !host.Matches(alias) || seen[alias] {
+					continue
+				}
+				seen[alias] = true
+				hosts = append(hosts, alias)
+			}
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
@@ -0,0 +1,76 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"golang.org/x/net/proxy"
+)
+
+// An error of the connection explained to the user, with what to check
+type connectionError struct {
+	reason string
+	err    error // the error of the dial or of the handshake
+}
+
+func (e *connectionError) Error() string { return e.reason }
+func (e *connectionError) Unwrap() error { return e.err }
+
+// Open and close a TCP connection to the address, telling quickly when the
+// host can't be reached before loading the keys and asking the passphrases
+func checkReachable(ctx context.Context, dialer proxy.Dialer, addr string) error {
+	conn, err := dialContext(ctx, dialer, addr)
+	if err != nil {
+		return explain(err, nil, addr, "")
+	}
+	return conn.Close()
+}
+
+// Replace the error of the connection to the address with one telling what
+// went wrong and what to check. The errors of the host key callback are
+// already explained, the others are returned as they are.
+func explain(err, hostKeyErr error, addr, user string) error {
+	var explained *connectionError
+	if err == nil || errors.As(err, &explained) {
+		return err
+	}
+	host, port, splitErr := net.SplitHostPort(addr)
+	if splitErr != nil {
+		host, port = addr, "22"
+	}
+
+	// The handshake keeps only the text of its errors
+	text := err.Error()
+	var reason string
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case hostKeyErr != nil:
+		reason = hostKeyErr.Error()
+	case errors.As(err, &dnsErr) && !dnsErr.IsTimeout:
+		reason = fmt.Sprintf("the host %s can't be found, check its name and the DNS of the network", host)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		reason = fmt.Sprintf("%s refused the connection on port %s, check the port and that the ssh server is running", host, port)
+	case errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH):
+		reason = fmt.Sprintf("%s can't be reached, check the network connection", host)
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) ||
+		errors.As(err, &netErr) && netErr.Timeout():
+		reason = fmt.Sprintf("%s didn't answer on port %s, check the host, the port and the firewall", host, port)
+	case errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) ||
+		strings.HasSuffix(text, syscall.ECONNRESET.Error()) || strings.HasSuffix(text, ": EOF"):
+		reason = fmt.Sprintf("%s closed the connection before the login, check that port %s is the one of the ssh server", host, port)
+	case strings.Contains(text, noCommonPrefix):
+		reason = noCommonAlgorithm(text, host)
+	case strings.Contains(text, "unable to authenticate"):
+		reason = fmt.Sprintf("%s rejected the login of %s, check the user name and the private key, or add the key to the ssh-agent", host, user)
+	default:
+		return err
+	}
+	return &connectionError{reason: reason, err: err}
+}
@@ -0,0 +1,147 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/proxy"
+)
+
+// A host the connection is tunneled through
+type jumpHost struct {
+	user string
+	addr string // host:port
+}
+
+// Parse the jump hosts in the ProxyJump format, a comma separated list of
+// [user@]host[:port] where host can be an alias of the ssh config
+func parseJumpHosts(jumpHosts, defaultUser string) []jumpHost {
+	if jumpHosts == "" || jumpHosts == "none" {
+		return nil
+	}
+
+	var jumps []jumpHost
+	for _, jump := range strings.Split(jumpHosts, ",") {
+		jump = strings.TrimSpace(jump)
+		user := ""
+		if at := strings.LastIndex(jump, "@"); at >= 0 {
+			user, jump = jump[:at], jump[at+1:]
+		}
+		host, port, err := net.SplitHostPort(jump)
+		if err != nil {
+			host, port = jump, ""
+		}
+
+		hostConfig := ResolveHost(host)
+		if user == "" {
+			user = hostConfig.User
+		}
+		if user == "" {
+			user = defaultUser
+		}
+		if port == "" {
+			port = hostConfig.Port
+		}
+		if port == "" {
+			port = "22"
+		}
+		jumps = append(jumps, jumpHost{user: user, addr: net.JoinHostPort(hostConfig.HostName, port)})
+	}
+	return jumps
+}
+
+// Connect to the address through the jump hosts, one after the other, the
+// first connection is opened with the dialer. The tunnels are closed with the
+// returned client.
+func dialThrough(ctx context.Context, dialer proxy.Dialer, jumps []jumpHost, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	var via *ssh.Client
+	for _, jump := range jumps {
+		jumpConfig := *config
+		jumpConfig.User = jump.user
+		client, err := dialVia(ctx, dialer, via, jump.addr, &jumpConfig)
+		if err != nil {
+			if via != nil {
+				via.Close()
+			}
+			// Already telling which host failed
+			var explained *connectionError
+			if errors.As(err, &explained) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("connecting to the jump host %s failed %w", jump.addr, err)
+		}
+		via = client
+	}
+
+	client, err := dialVia(ctx, dialer, via, addr, config)
+	if err != nil {
+		if via != nil {
+			via.Close()
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+// Connect to the address with the dialer, or through the tunnel of the
+// client when given. The failures are explained to the user, cancelling the
+// context stops waiting for the server.
+func dialVia(ctx context.Context, dialer proxy.Dialer, via *ssh.Client, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	var conn net.Conn
+	var err error
+	if via == nil {
+		conn, err = dialContext(ctx, dialer, addr)
+	} else {
+		// Closing the tunnel is the only way to stop waiting for it
+		stop := context.AfterFunc(ctx, func() { via.Close() })
+		conn, err = via.Dial("tcp", addr)
+		stop()
+	}
+	if err != nil {
+		return nil, explain(err, nil, addr, config.User)
+	}
+
+	// The handshake reports the error of the host key only as text
+	var hostKeyErr error
+	checked := *config
+	checked.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		hostKeyErr = config.HostKeyCallback(hostname, remote, key)
+		return hostKeyErr
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, addr, &checked)
+	if !stop() {
+		// Cancelled during the handshake, the connection is closed
+		if err == nil {
+			clientConn.Close()
+		}
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, explain(err, hostKeyErr, addr, config.User)
+	}
+	client := ssh.NewClient(clientConn, chans, reqs)
+
+	// Close the tunnel once the connection is closed
+	if via != nil {
+		go func() {
+			client.Wait()
+			via.Close()
+		}()
+	}
+	return client, nil
+}
+
+// Dial the address, giving up when the context is cancelled if the dialer
+// supports it
+func dialContext(ctx context.Context, dialer proxy.Dialer, addr string) (net.Conn, error) {
+	if contextDialer, ok := dialer.(proxy.ContextDialer); ok {
+		return contextDialer.DialContext(ctx, "tcp", addr)
+	}
+	return dialer.Dial("tcp", addr)
+}
@@ -0,0 +1,115 @@
+package ssh
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/guglielmobartelloni/sftp-tui/localpath"
+	"github.com/kevinburke/ssh_config"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Create the host key callback checking the known hosts file.
+// Like OpenSSH, unknown hosts are trusted on first use: the user is asked to
+// confirm the key fingerprint, and the key is appended to the file.
+func knownHostsCallback(knownHostsPath string) (ssh.HostKeyCallback, error) {
+	if knownHostsPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		knownHostsPath = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	knownHostsPath = localpath.ExpandHome(knownHostsPath)
+
+	// Start with an empty file, the accepted keys are added to it
+	if _, err := os.Stat(knownHostsPath); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(knownHostsPath), 0700); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(knownHostsPath, nil, 0600); err != nil {
+			return nil, err
+		}
+	}
+
+	callback, err := knownhosts.New(knownHostsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			return fmt.Errorf(
+				"the host key of %s has changed, it may be a man-in-the-middle attack, remove the old key from %s if the change is expected: %v",
+				hostname, knownHostsPath, err,
+			)
+		}
+
+		// Unknown host
+		if !confirmHostKey(hostname, key) {
+			return fmt.Errorf("host key verification of %s failed", hostname)
+		}
+		return appendKnownHost(knownHostsPath, hostname, key)
+	}, nil
+}
+
+// Ask the user if the key of the unknown host can be trusted
+func confirmHostKey(hostname string, key ssh.PublicKey) bool {
+	fmt.Fprintf(os.Stderr, "The authenticity of host '%s' can't be established.\n", hostname)
+	fmt.Fprintf(os.Stderr, "%s key fingerprint is %s.\n", key.Type(), ssh.FingerprintSHA256(key))
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Fprint(os.Stderr, "Are you sure you want to continue connecting (yes/no)? ")
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return false
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "yes":
+			return true
+		case "no":
+			return false
+		}
+	}
+}
+
+// Add the host key to the known hosts file
+func appendKnownHost(knownHostsPath, hostname string, key ssh.PublicKey) error {
+	file, err := os.OpenFile(knownHostsPath, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	address := knownhosts.Normalize(hostname)
+	if hashKnownHosts(hostname) {
+		address = knownhosts.HashHostname(address)
+	}
+	line := knownhosts.Line([]string{address}, key)
+	if _, err := fmt.Fprintln(file, line); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Permanently added '%s' to the list of known hosts.\n", hostname)
+	return nil
+}
+
+// Whether the ssh config asks to hash the host names added to the known hosts
+func hashKnownHosts(hostname string) bool {
+	if host, _, err := net.SplitHostPort(hostname); err == nil {
+		hostname = host
+	}
+	return strings.EqualFold(ssh_config.Get(hostname, "HashKnownHosts"), "yes")
+}
@@ -0,0 +1,98 @@
+package ssh
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+func init() {
+	proxy.RegisterDialerType("http", newHTTPConnectDialer)
+}
+
+// Get the dialer of the TCP connections, through the proxy when its url
+// is given: socks5://[user:password@]host:port or http://[user:password@]host:port
+// The TCP connections give up after the timeout.
+func proxyDialer(proxyURL string, timeout time.Duration) (proxy.Dialer, error) {
+	direct := &net.Dialer{Timeout: timeout}
+	if proxyURL == "" {
+		return direct, nil
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the proxy url failed %v", err)
+	}
+	dialer, err := proxy.FromURL(u, direct)
+	if err != nil {
+		return nil, fmt.Errorf("creating the proxy dialer failed %v", err)
+	}
+	return dialer, nil
+}
+
+// Dials through an HTTP proxy with the CONNECT method
+type httpConnectDialer struct {
+	proxyURL *url.URL
+	forward  proxy.Dialer
+}
+
+func newHTTPConnectDialer(proxyURL *url.URL, forward proxy.Dialer) (proxy.Dialer, error) {
+	return &httpConnectDialer{proxyURL: proxyURL, forward: forward}, nil
+}
+
+func (d *httpConnectDialer) Dial(network, addr string) (net.Conn, error) {
+	proxyAddr := d.proxyURL.Host
+	if d.proxyURL.Port() == "" {
+		proxyAddr = net.JoinHostPort(proxyAddr, "80")
+	}
+	conn, err := d.forward.Dial(network, proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if user := d.proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		request.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := request.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// The tunnel is open once the proxy answers 200
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("the proxy refused the connection: %s", response.Status)
+	}
+	// The server may have already sent some data through the tunnel
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+// A connection whose first bytes were read in the buffer
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
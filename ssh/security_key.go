@@ -0,0 +1,76 @@
+package ssh
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Magic at the start of the keys in the OpenSSH format
+const opensshKeyMagic = "openssh-key-v1\x00"
+
+// Tell if the key lives on a FIDO2 security key, like a YubiKey: sk-ed25519
+// and sk-ecdsa, their certificates included
+func isSecurityKey(key ssh.PublicKey) bool {
+	return strings.HasPrefix(key.Type(), "sk-")
+}
+
+// Get the public key of the private key file when it's a security key. The
+// file only holds a handle of the key, signing needs the token and is left to
+// the ssh-agent.
+func securityKeyFile(pemBytes []byte) (ssh.PublicKey, bool) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil || block.Type != "OPENSSH PRIVATE KEY" || !bytes.HasPrefix(block.Bytes, []byte(opensshKeyMagic)) {
+		return nil, false
+	}
+	// The public key is never encrypted
+	var header struct {
+		CipherName string
+		KdfName    string
+		KdfOpts    string
+		NumKeys    uint32
+		PubKey     []byte
+		Rest       []byte `ssh:"rest"`
+	}
+	if err := ssh.Unmarshal(block.Bytes[len(opensshKeyMagic):], &header); err != nil {
+		return nil, false
+	}
+	key, err := ssh.ParsePublicKey(header.PubKey)
+	if err != nil || !isSecurityKey(key) {
+		return nil, false
+	}
+	return key, true
+}
+
+// Offer the security keys of the agent first, they can't be copied so
+// they're the ones meant for the server
+func preferSecurityKeys(signers []ssh.Signer) []ssh.Signer {
+	sort.SliceStable(signers, func(i, j int) bool {
+		return isSecurityKey(signers[i].PublicKey()) && !isSecurityKey(signers[j].PublicKey())
+	})
+	for i, signer := range signers {
+		if isSecurityKey(signer.PublicKey()) {
+			signers[i] = touchSigner{signer}
+		}
+	}
+	return signers
+}
+
+// Signs with a security key, telling the user to touch it first. The server
+// is asked whether it accepts the key before signing, so only the key used is
+// touched.
+type touchSigner struct {
+	ssh.Signer
+}
+
+func (s touchSigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	key := s.PublicKey()
+	fmt.Fprintf(os.Stderr, "Confirm user presence for key %s %s\n", key.Type(), ssh.FingerprintSHA256(key))
+	return s.Signer.Sign(rand, data)
+}
@@ -0,0 +1,24 @@
+package ssh
+
+import (
+	"log/slog"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Start the sftp session on the connection, with the packet size and the
+// requests in flight of the options. Bigger packets and more requests copy
+// faster over high latency links, some servers refuse packets bigger than
+// 32768 bytes.
+func NewSFTP(sshClient *ssh.Client, options Options) (*sftp.Client, error) {
+	var clientOptions []sftp.ClientOption
+	if options.MaxPacket > 0 {
+		clientOptions = append(clientOptions, sftp.MaxPacketUnchecked(options.MaxPacket))
+	}
+	if options.MaxRequests > 0 {
+		clientOptions = append(clientOptions, sftp.MaxConcurrentRequestsPerFile(options.MaxRequests))
+	}
+	slog.Debug("starting the sftp session", "max packet", options.MaxPacket, "max requests", options.MaxRequests)
+	return sftp.NewClient(sshClient, clientOptions...)
+}
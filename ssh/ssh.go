@@ -1,114 +1,346 @@
 package ssh
 
 import (
+	"context"
 	"crypto/x509"
-	"encoding/pem"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/crypto/ssh"
-	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/crypto/ssh/agent"
 )
 
-// Function to create an ssh connection using a private key
-func ConnectSSH(username, privateKeyPath, privateKeyPassword, host, port, knownHostPath string) *ssh.Client {
+// How many times the passphrase is asked before giving up
+const passphraseAttempts = 3
 
-	pemBytes, err := ioutil.ReadFile(privateKeyPath)
+// How long to wait for the TCP connection when the options don't set it
+const DefaultTimeout = 10 * time.Second
 
-	if err != nil {
-		panic(err)
+var errPassphraseMissing = errors.New("the private key is encrypted, set Password or SSSFTP_PASSPHRASE to decrypt it")
+
+// Returned by the unattended connections when the server asks the questions
+// of the keyboard-interactive authentication
+var ErrNeedsAnswers = errors.New("reconnecting needs the answers to the questions of the server")
+
+// Asks the passphrase of the encrypted private key, wrong tells that the
+// previous one didn't decrypt it. Returns false if the user gave up.
+// When nil the key is decrypted only with the password.
+var AskPassphrase func(privateKeyPath string, wrong bool) (string, bool)
+
+// Asks the questions of the keyboard-interactive authentication, like the one
+// time passwords of the servers with two factors. echos tells which answers
+// can be shown. Returns false if the user gave up. When nil the
+// keyboard-interactive authentication isn't offered.
+var AskChallenge func(name, instruction string, questions []string, echos []bool) ([]string, bool)
+
+// The private keys decrypted with the passphrase asked to the user
+var (
+	decrypted   = map[string]ssh.Signer{}
+	decryptedMu sync.Mutex
+)
+
+// Settings of the ssh connection
+type Options struct {
+	Username           string
+	PrivateKeyPath     string
+	PrivateKeyPassword string
+	// password of the user on the server, offered after the keys
+	Password string
+	// OpenSSH user certificate of the private key, the -cert.pub file next
+	// to it when empty
+	CertificatePath string
+	Host            string
+	Port            string
+	KnownHostsPath  string
+	ProxyJump       string // jump hosts in the ProxyJump format
+	Proxy           string // url of the SOCKS5 or HTTP proxy to dial through
+	// payload of the sftp packets in bytes, 0 for the default of 32768
+	MaxPacket int
+	// sftp requests in flight for each file, 0 for the default of 64
+	MaxRequests int
+	// wait for the TCP connection to each host, 0 for DefaultTimeout
+	Timeout time.Duration
+	// algorithms offered to the server, the defaults of x/crypto when empty
+	HostKeyAlgorithms []string
+	KeyExchanges      []string
+	Ciphers           []string
+	MACs              []string
+	// add the old algorithms of the network appliances to the defaults
+	Legacy bool
+	// called once logged in with the password and the passphrase that
+	// decrypted the private key, empty when not used, to keep them
+	Remember func(password, passphrase string)
+	// called with the host key of the server once it's verified
+	OnHostKey func(key ssh.PublicKey)
+	// made in the background while the terminal is in use: the questions
+	// of the server aren't asked, the connection fails with ErrNeedsAnswers
+	Unattended bool
+}
+
+// Function to create an ssh connection using a private key, tunneled
+// through the jump hosts and the proxy when given
+func ConnectSSH(options Options) (*ssh.Client, error) {
+	return ConnectSSHContext(context.Background(), options)
+}
+
+// Create the ssh connection like ConnectSSH, cancelling the context stops
+// waiting for a server that doesn't answer
+func ConnectSSHContext(ctx context.Context, options Options) (*ssh.Client, error) {
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
 	}
-	signer, err := signerFromPem(pemBytes, []byte(privateKeyPassword))
+	dialer, err := proxyDialer(options.Proxy, timeout)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	// Check the first host answers before asking the passphrases
+	target := net.JoinHostPort(options.Host, options.Port)
+	jumps := parseJumpHosts(options.ProxyJump, options.Username)
+	first := target
+	if len(jumps) > 0 {
+		first = jumps[0].addr
+	}
+	if err := checkReachable(ctx, dialer, first); err != nil {
+		return nil, connectFailed(ctx, options.Host, err)
+	}
+
+	var authMethods []ssh.AuthMethod
+	var passphrase string // the one that decrypted the private key
+
+	// Offer the identities of the ssh-agent first
+	if agentAuth := agentAuthMethod(); agentAuth != nil {
+		authMethods = append(authMethods, agentAuth)
 	}
 
-	hostKeyCallback, err := knownhosts.New(knownHostPath)
+	// Fall back to the private key file
+	if options.PrivateKeyPath != "" {
+		pemBytes, err := ioutil.ReadFile(options.PrivateKeyPath)
+		if err != nil && len(authMethods) == 0 && options.Password == "" {
+			return nil, fmt.Errorf("reading the private key failed %v", err)
+		}
+		securityKey, onToken := securityKeyFile(pemBytes)
+		switch {
+		case err != nil:
+		case onToken && len(authMethods) == 0:
+			return nil, fmt.Errorf("the private key %s is a %s security key, add it to the ssh-agent with ssh-add to use it", options.PrivateKeyPath, securityKey.Type())
+		case onToken:
+			// The agent signs with it when it holds it
+			slog.Debug("skipping the security key file", "path", options.PrivateKeyPath, "type", securityKey.Type())
+		default:
+			signer, decryptedWith, err := loadSigner(options.PrivateKeyPath, pemBytes, []byte(options.PrivateKeyPassword))
+			if err != nil {
+				return nil, err
+			}
+			passphrase = decryptedWith
+			// The certificate goes first, the servers trusting its CA don't
+			// need the key in authorized_keys
+			certSigner, err := loadCertificate(options.CertificatePath, options.PrivateKeyPath, signer)
+			if err != nil {
+				return nil, err
+			}
+			if certSigner != nil {
+				authMethods = append(authMethods, ssh.PublicKeys(certSigner, signer))
+			} else {
+				authMethods = append(authMethods, ssh.PublicKeys(signer))
+			}
+		}
+	}
+
+	if options.Password != "" {
+		authMethods = append(authMethods, ssh.Password(options.Password))
+	}
+
+	// Then the questions of the server, alone or after the key when it asks
+	// for both
+	if AskChallenge != nil || options.Password != "" {
+		authMethods = append(authMethods, ssh.KeyboardInteractive(challengeAnswerer(options.Password, options.Unattended)))
+	}
+
+	if len(authMethods) == 0 {
+		return nil, errors.New("no authentication method available, start an ssh-agent, provide a private key or a password")
+	}
+
+	hostKeyCallback, err := knownHostsCallback(options.KnownHostsPath)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("reading the known hosts failed %v", err)
 	}
 	config := &ssh.ClientConfig{
-		User: username,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
+		User: options.Username,
+		Auth: authMethods,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if err := hostKeyCallback(hostname, remote, key); err != nil {
+				return err
+			}
+			// The jump hosts are verified with the same callback
+			if hostname == target && options.OnHostKey != nil {
+				options.OnHostKey(key)
+			}
+			return nil
 		},
-		HostKeyCallback: hostKeyCallback,
 	}
+	applyAlgorithms(config, options)
 
 	// connect ot ssh server
-	conn, err := ssh.Dial("tcp", fmt.Sprintf("%s:%s", host, port), config)
+	slog.Info("connecting", "host", options.Host, "port", options.Port, "user", options.Username,
+		"jump", options.ProxyJump, "proxy", options.Proxy != "", "legacy", options.Legacy, "auth methods", len(authMethods))
+	conn, err := dialThrough(ctx, dialer, jumps, target, config)
 	if err != nil {
-		panic(err)
+		return nil, connectFailed(ctx, options.Host, err)
+	}
+	slog.Info("connected", "host", options.Host, "server", string(conn.ServerVersion()))
+	if options.Remember != nil {
+		options.Remember(options.Password, passphrase)
 	}
-	return conn
+	return conn, nil
 }
 
-func signerFromPem(pemBytes []byte, password []byte) (ssh.Signer, error) {
-
-	// read pem block
-	err := errors.New("pem decode failed, no key found")
-	pemBlock, _ := pem.Decode(pemBytes)
-	if pemBlock == nil {
-		return nil, err
+// Get the error of the failed connection to the host, the explained ones are
+// returned as they are
+func connectFailed(ctx context.Context, host string, err error) error {
+	if ctx.Err() != nil {
+		slog.Info("connection cancelled", "host", host)
+		return fmt.Errorf("connecting to %s cancelled: %w", host, ctx.Err())
+	}
+	slog.Error("connection failed", "host", host, "err", err)
+	var explained *connectionError
+	if errors.As(err, &explained) {
+		return err
 	}
+	return fmt.Errorf("connecting to %s failed %w", host, err)
+}
 
-	// handle encrypted key
-	if x509.IsEncryptedPEMBlock(pemBlock) {
-		// decrypt PEM
-		pemBlock.Bytes, err = x509.DecryptPEMBlock(pemBlock, []byte(password))
-		if err != nil {
-			return nil, fmt.Errorf("decrypting PEM block failed %v", err)
+// Get the function answering the keyboard-interactive questions of the
+// server asking the user, the rounds without questions only carry the
+// instruction. The password, when given, answers the question asking for it:
+// PAM asks the password this way. Unattended, the other questions fail
+// with ErrNeedsAnswers.
+func challengeAnswerer(password string, unattended bool) ssh.KeyboardInteractiveChallenge {
+	return func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		if len(questions) == 0 {
+			return nil, nil
 		}
+		if password != "" && len(questions) == 1 && !echos[0] &&
+			strings.Contains(strings.ToLower(questions[0]), "password") {
+			return []string{password}, nil
+		}
+		if AskChallenge == nil {
+			return nil, errors.New("the server asks questions that can't be answered without a terminal")
+		}
+		if unattended {
+			return nil, ErrNeedsAnswers
+		}
+		return answerChallenge(name, instruction, questions, echos)
+	}
+}
+
+// Ask the user the keyboard-interactive questions of the server
+func answerChallenge(name, instruction string, questions []string, echos []bool) ([]string, error) {
+	answers, ok := AskChallenge(name, instruction, questions, echos)
+	if !ok {
+		return nil, errors.New("no answer given to the questions of the server")
+	}
+	return answers, nil
+}
+
+// Get the auth method backed by the ssh-agent listening on SSH_AUTH_SOCK, if any
+func agentAuthMethod() ssh.AuthMethod {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil
+	}
 
-		// get RSA, EC or DSA key
-		key, err := parsePemBlock(pemBlock)
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil
+	}
+	agentClient := agent.NewClient(conn)
+
+	// Skip the agent if it holds no keys
+	if signers, err := agentClient.Signers(); err != nil || len(signers) == 0 {
+		conn.Close()
+		return nil
+	}
+	return ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		signers, err := agentClient.Signers()
 		if err != nil {
 			return nil, err
 		}
+		return preferSecurityKeys(signers), nil
+	})
+}
 
-		// generate signer instance from key
-		signer, err := ssh.NewSignerFromKey(key)
-		if err != nil {
-			return nil, fmt.Errorf("creating signer from encrypted key failed %v", err)
-		}
+// Parse the private key asking the passphrase when it's encrypted and the
+// password doesn't decrypt it. The keys decrypted with the passphrase are
+// kept, so reconnecting doesn't ask it again. Returns the passphrase that
+// decrypted the key, empty when it isn't encrypted or was already decrypted.
+func loadSigner(privateKeyPath string, pemBytes, password []byte) (ssh.Signer, string, error) {
+	decryptedMu.Lock()
+	defer decryptedMu.Unlock()
+	if signer, ok := decrypted[privateKeyPath]; ok {
+		return signer, "", nil
+	}
 
-		return signer, nil
-	} else {
-		// generate signer instance from plain key
-		signer, err := ssh.ParsePrivateKey(pemBytes)
-		if err != nil {
-			return nil, fmt.Errorf("parsing plain private key failed %v", err)
+	signer, err := signerFromPem(pemBytes, password)
+	needsPassphrase := errors.Is(err, errPassphraseMissing) || errors.Is(err, x509.IncorrectPasswordError)
+	if !needsPassphrase || AskPassphrase == nil {
+		if err != nil || !isEncrypted(pemBytes) {
+			return signer, "", err
 		}
+		return signer, string(password), nil
+	}
 
-		return signer, nil
+	var passphrase string
+	for attempt := 0; attempt < passphraseAttempts; attempt++ {
+		var ok bool
+		passphrase, ok = AskPassphrase(privateKeyPath, attempt > 0)
+		if !ok {
+			return nil, "", errors.New("no passphrase given for the private key")
+		}
+		signer, err = signerFromPem(pemBytes, []byte(passphrase))
+		if !errors.Is(err, x509.IncorrectPasswordError) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, "", err
 	}
+	decrypted[privateKeyPath] = signer
+	return signer, passphrase, nil
 }
 
-func parsePemBlock(block *pem.Block) (interface{}, error) {
-	switch block.Type {
-	case "RSA PRIVATE KEY":
-		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
-		if err != nil {
-			return nil, fmt.Errorf("parsing PKCS private key failed %v", err)
-		} else {
-			return key, nil
-		}
-	case "EC PRIVATE KEY":
-		key, err := x509.ParseECPrivateKey(block.Bytes)
-		if err != nil {
-			return nil, fmt.Errorf("parsing EC private key failed %v", err)
-		} else {
-			return key, nil
+// Tell if the private key needs a passphrase
+func isEncrypted(pemBytes []byte) bool {
+	_, err := ssh.ParsePrivateKey(pemBytes)
+	var missingErr *ssh.PassphraseMissingError
+	return errors.As(err, &missingErr)
+}
+
+// Parse the private key, in any of the formats supported by OpenSSH, using
+// the password when the key is encrypted
+func signerFromPem(pemBytes []byte, password []byte) (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey(pemBytes)
+	var missingErr *ssh.PassphraseMissingError
+	if errors.As(err, &missingErr) {
+		if len(password) == 0 {
+			return nil, errPassphraseMissing
 		}
-	case "DSA PRIVATE KEY":
-		key, err := ssh.ParseDSAPrivateKey(block.Bytes)
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(pemBytes, password)
 		if err != nil {
-			return nil, fmt.Errorf("parsing DSA private key failed %v", err)
-		} else {
-			return key, nil
+			return nil, fmt.Errorf("decrypting private key failed %w", err)
 		}
-	default:
-		return nil, fmt.Errorf("parsing private key failed, unsupported key type %q", block.Type)
+		return signer, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key failed %v", err)
 	}
+	return signer, nil
 }
@@ -0,0 +1,39 @@
+package ssh
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestChallengeAnswerer(t *testing.T) {
+	asked := 0
+	AskChallenge = func(name, instruction string, questions []string, echos []bool) ([]string, bool) {
+		asked++
+		return []string{"123456"}, true
+	}
+	defer func() { AskChallenge = nil }()
+
+	tests := []struct {
+		name       string
+		password   string
+		unattended bool
+		questions  []string
+		want       []string
+		wantErr    error
+		wantAsked  int
+	}{
+		{"no questions", "", true, nil, nil, nil, 0},
+		{"password", "secret", true, []string{"Password: "}, []string{"secret"}, nil, 0},
+		{"code", "secret", false, []string{"Verification code: "}, []string{"123456"}, nil, 1},
+		{"code unattended", "secret", true, []string{"Verification code: "}, nil, ErrNeedsAnswers, 0},
+	}
+	for _, test := range tests {
+		asked = 0
+		echos := make([]bool, len(test.questions))
+		answers, err := challengeAnswerer(test.password, test.unattended)("", "", test.questions, echos)
+		if !errors.Is(err, test.wantErr) || !reflect.DeepEqual(answers, test.want) || asked != test.wantAsked {
+			t.Errorf("%s: answered %v, %v asking %d times", test.name, answers, err, asked)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Package throttle caps the throughput of the transfers with a token bucket
+// shared by all of them.
+package throttle
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limiter lets through up to rate bytes per second, bursting at most a
+// second worth of bytes. A nil Limiter or a zero rate doesn't limit.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   int64     // bytes per second, 0 for no limit
+	tokens float64   // bytes that can go through now
+	last   time.Time // when the tokens were last refilled
+}
+
+// NewLimiter creates a limiter letting through rate bytes per second
+func NewLimiter(rate int64) *Limiter {
+	return &Limiter{rate: rate, last: time.Now()}
+}
+
+// Rate gets the bytes per second let through, 0 when unlimited
+func (l *Limiter) Rate() int64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// SetRate changes the bytes per second let through, 0 removes the limit
+func (l *Limiter) SetRate(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = rate
+	l.tokens = 0
+	l.last = time.Now()
+}
+
+// Wait blocks until n bytes can go through
+func (l *Limiter) Wait(n int) {
+	if l == nil {
+		return
+	}
+	remaining := float64(n)
+	for remaining > 0 {
+		l.mu.Lock()
+		if l.rate <= 0 {
+			l.mu.Unlock()
+			return
+		}
+		now := time.Now()
+		burst := float64(l.rate)
+		l.tokens += now.Sub(l.last).Seconds() * float64(l.rate)
+		if l.tokens > burst {
+			l.tokens = burst
+		}
+		l.last = now
+
+		// Take what's available, the bigger writes go through in pieces
+		taken := remaining
+		if taken > l.tokens {
+			taken = l.tokens
+		}
+		if taken > 0 {
+			l.tokens -= taken
+			remaining -= taken
+		}
+		wait := time.Duration(0)
+		if remaining > 0 {
+			needed := remaining
+			if needed > burst {
+				needed = burst
+			}
+			wait = time.Duration(needed / float64(l.rate) * float64(time.Second))
+		}
+		l.mu.Unlock()
+		time.Sleep(wait)
+	}
+}
+
+// Writer wraps the writer so the writes are throttled by the limiter
+func (l *Limiter) Writer(w io.Writer) io.Writer {
+	return &limitedWriter{limiter: l, w: w}
+}
+
+type limitedWriter struct {
+	limiter *Limiter
+	w       io.Writer
+}
+
+func (lw *limitedWriter) Write(p []byte) (int, error) {
+	lw.limiter.Wait(len(p))
+	return lw.w.Write(p)
+}
+
+// ParseRate parses a rate in bytes per second with an optional K, M or G
+// suffix (powers of 1024), like 500K or 2M. An empty rate means no limit.
+func ParseRate(rate string) (int64, error) {
+	original := rate
+	rate = strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(rate)), "B")
+	if rate == "" || rate == "0" {
+		return 0, nil
+	}
+
+	multiplier := 1.0
+	switch rate[len(rate)-1] {
+	case 'K':
+		multiplier = 1 << 10
+	case 'M':
+		multiplier = 1 << 20
+	case 'G':
+		multiplier = 1 << 30
+	}
+	if multiplier != 1 {
+		rate = rate[:len(rate)-1]
+	}
+	value, err := strconv.ParseFloat(rate, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid rate %q, use a number of bytes like 500K or 2M", original)
+	}
+	return int64(value * multiplier), nil
+}
@@ -0,0 +1,163 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/localpath"
+	"golang.org/x/crypto/ssh"
+)
+
+// The tar flags extracting the archives by their extension
+var tarExtensions = []struct {
+	extension string
+	flags     string
+}{
+	{".tar.gz", "xzf"},
+	{".tgz", "xzf"},
+	{".tar.bz2", "xjf"},
+	{".tbz2", "xjf"},
+	{".tar.xz", "xJf"},
+	{".txz", "xJf"},
+	{".tar", "xf"},
+}
+
+// Message sent when a directory has been compressed into an archive on the
+// server, it's downloaded
+type archivedMsg struct {
+	download download
+}
+
+// Extract the highlighted archive on the server into the current directory,
+// with tar, unzip or python3, the first one installed that can open it
+func (m *Model) extractArchive(i *item) tea.Cmd {
+	name := i.rawValue.Name()
+	sshClient, currentDir := m.sshClient, m.currentDir
+	refresh := m.changeDir(m.currentDir, fmt.Sprintf("Extracted %s", name))
+	return tea.Batch(
+		m.setStatus("Extracting "+name),
+		func() tea.Msg {
+			command, err := extractCommand(sshClient, name)
+			if err == nil {
+				err = runIn(sshClient, currentDir, command)
+			}
+			if err != nil {
+				return errorMsg{err: fmt.Errorf("extracting %s failed: %v", name, err)}
+			}
+			return refresh()
+		},
+	)
+}
+
+// Get the command extracting the archive in its directory, by its extension
+// and the tools installed on the server
+func extractCommand(sshClient *ssh.Client, name string) (string, error) {
+	// The ./ keeps a name starting with - from being taken as an option
+	quoted := shellQuote("./" + name)
+	lower := strings.ToLower(name)
+	for _, t := range tarExtensions {
+		if !strings.HasSuffix(lower, t.extension) {
+			continue
+		}
+		if installed, err := remoteCommands(sshClient, "tar"); err != nil || !installed["tar"] {
+			return "", fmt.Errorf("tar isn't installed on the server")
+		}
+		return fmt.Sprintf("tar %s %s", t.flags, quoted), nil
+	}
+	if !strings.HasSuffix(lower, ".zip") {
+		return "", fmt.Errorf("not an archive, the extensions are .zip, .tar and .tar.gz, .tar.bz2, .tar.xz")
+	}
+	installed, err := remoteCommands(sshClient, "unzip", "python3")
+	switch {
+	case err != nil:
+		return "", err
+	case installed["unzip"]:
+		return "unzip -oq " + quoted, nil
+	case installed["python3"]:
+		return fmt.Sprintf("python3 -m zipfile -e %s .", quoted), nil
+	}
+	return "", fmt.Errorf("neither unzip nor python3 is installed on the server")
+}
+
+// Compress the highlighted directory into an archive next to it on the
+// server, a .tar.gz or a .zip when tar isn't installed, and download it
+func (m *Model) compressDir(i *item) tea.Cmd {
+	name := i.rawValue.Name()
+	sshClient, remote, currentDir, localDir := m.sshClient, m.remote, m.currentDir, m.localDir
+	return tea.Batch(
+		m.setStatus("Compressing "+name),
+		func() tea.Msg {
+			installed, err := remoteCommands(sshClient, "tar", "zip")
+			if err != nil {
+				return errorMsg{err: fmt.Errorf("compressing %s failed: %v", name, err)}
+			}
+			var archive, command string
+			switch {
+			case installed["tar"]:
+				archive = name + ".tar.gz"
+				command = fmt.Sprintf("tar czf %s %s", shellQuote("./"+archive), shellQuote("./"+name))
+			case installed["zip"]:
+				archive = name + ".zip"
+				command = fmt.Sprintf("zip -qry %s %s", shellQuote("./"+archive), shellQuote("./"+name))
+			default:
+				return errorMsg{err: fmt.Errorf("compressing %s failed: neither tar nor zip is installed on the server", name)}
+			}
+			if err := runIn(sshClient, currentDir, command); err != nil {
+				return errorMsg{err: fmt.Errorf("compressing %s failed: %v", name, err)}
+			}
+
+			remotePath := path.Join(currentDir, archive)
+			fileInfo, err := remote.Stat(remotePath)
+			if err != nil {
+				return errorMsg{err: fmt.Errorf("compressing %s failed: %v", name, err)}
+			}
+			return archivedMsg{download: download{
+				remotePath: remotePath,
+				localPath:  filepath.Join(localDir, localpath.Name(archive)),
+				size:       fileInfo.Size(),
+				modTime:    fileInfo.ModTime(),
+			}}
+		},
+	)
+}
+
+// Tell which of the commands are installed on the server
+func remoteCommands(sshClient *ssh.Client, names ...string) (map[string]bool, error) {
+	session, err := newSession(sshClient)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = shellQuote(name)
+	}
+	// command -v fails when one of them is missing, the others are printed
+	command := "command -v " + strings.Join(quoted, " ")
+	slog.Debug("remote command", "command", command)
+	output, _ := session.Output(command)
+	installed := make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n") {
+		installed[path.Base(strings.TrimSpace(line))] = true
+	}
+	return installed, nil
+}
+
+// Run the command with the remote shell in the directory
+func runIn(sshClient *ssh.Client, dir, command string) error {
+	session, err := newSession(sshClient)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	command = fmt.Sprintf("cd %s && %s", shellQuote(dir), command)
+	slog.Info("remote command", "command", command)
+	return commandError(session.Run(command), &stderr)
+}
@@ -0,0 +1,107 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/config"
+)
+
+// Rapresents a bookmarked directory as an item of the bookmark list
+type bookmarkItem string
+
+func (i bookmarkItem) Title() string { return dirItemStyle(displayName(string(i))) }
+
+func (i bookmarkItem) Description() string { return "" }
+
+func (i bookmarkItem) FilterValue() string { return string(i) }
+
+// Bookmark the current directory in the config file
+func (m *Model) addBookmark() tea.Cmd {
+	host, dirPath := m.host, m.currentDir
+	return func() tea.Msg {
+		if err := config.AddBookmark(host, dirPath); err != nil {
+			return errorMsg{err: fmt.Errorf("saving the bookmark failed: %v", err)}
+		}
+		return statusMsg(fmt.Sprintf("Bookmarked %s", dirPath))
+	}
+}
+
+// Show the directories bookmarked on the host
+func (m *Model) openBookmarks() tea.Cmd {
+	dirs, err := config.Bookmarks(m.host)
+	if err != nil {
+		return reportError(err)
+	}
+	if len(dirs) == 0 {
+		return m.setStatus("No bookmarks, press b to bookmark the current directory")
+	}
+
+	items := make([]list.Item, len(dirs))
+	for i, dir := range dirs {
+		items[i] = bookmarkItem(dir)
+	}
+	delegate := newDelegate()
+	delegate.ShowDescription = false
+	bookmarks := list.New(items, delegate, 0, 0)
+	bookmarks.Title = "Bookmarks"
+	bookmarks.SetStatusBarItemName("bookmark", "bookmarks")
+	m.bookmarks = &bookmarks
+	m.resize()
+	return nil
+}
+
+// Handle the key presses while the bookmark list is shown
+func (m Model) updateBookmarks(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.bookmarks.FilterState() == list.Filtering {
+		var cmd tea.Cmd
+		*m.bookmarks, cmd = m.bookmarks.Update(msg)
+		return m, cmd
+	}
+
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q", "B":
+		// Clear the filter first
+		if m.bookmarks.FilterState() != list.Unfiltered {
+			break
+		}
+		m.bookmarks = nil
+		return m, nil
+	case "enter":
+		selected, ok := m.bookmarks.SelectedItem().(bookmarkItem)
+		m.bookmarks = nil
+		if !ok {
+			return m, nil
+		}
+		return m, m.goTo(string(selected))
+	case "x", "delete":
+		selected, ok := m.bookmarks.SelectedItem().(bookmarkItem)
+		if !ok {
+			return m, nil
+		}
+		// The index of the selection doesn't match the items while filtering
+		for i, listItem := range m.bookmarks.Items() {
+			if listItem == selected {
+				m.bookmarks.RemoveItem(i)
+				break
+			}
+		}
+		if m.bookmarks.Index() >= len(m.bookmarks.Items()) {
+			m.bookmarks.CursorUp()
+		}
+		host := m.host
+		return m, func() tea.Msg {
+			if err := config.RemoveBookmark(host, string(selected)); err != nil {
+				return errorMsg{err: fmt.Errorf("removing the bookmark failed: %v", err)}
+			}
+			return nil
+		}
+	}
+
+	var cmd tea.Cmd
+	*m.bookmarks, cmd = m.bookmarks.Update(msg)
+	return m, cmd
+}
@@ -0,0 +1,52 @@
+package tui
+
+import (
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+// Keeps the listings of the directories already visited, going back to one
+// of them shows it right away while it's read again
+type listingCache struct {
+	ttl     time.Duration // how long a listing is used, 0 to never cache
+	entries map[string]cachedListing
+}
+
+// A listing of the cache
+type cachedListing struct {
+	items []list.Item
+	read  time.Time // when the directory was read
+}
+
+func newListingCache(ttl time.Duration) *listingCache {
+	return &listingCache{ttl: ttl, entries: make(map[string]cachedListing)}
+}
+
+// Get the listing of the directory, unless it's older than the ttl
+func (c *listingCache) get(dirPath string) ([]list.Item, bool) {
+	if c == nil {
+		return nil, false
+	}
+	entry, ok := c.entries[dirPath]
+	if !ok {
+		return nil, false
+	}
+	if time.Since(entry.read) > c.ttl {
+		delete(c.entries, dirPath)
+		return nil, false
+	}
+	// The marks don't survive leaving the directory
+	for _, listItem := range entry.items {
+		listItem.(*item).marked = false
+	}
+	return entry.items, true
+}
+
+// Keep the listing just read
+func (c *listingCache) put(dirPath string, items []list.Item) {
+	if c == nil || c.ttl <= 0 {
+		return
+	}
+	c.entries[dirPath] = cachedListing{items: items, read: time.Now()}
+}
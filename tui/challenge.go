@@ -0,0 +1,108 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Holds the state of the questions of the keyboard-interactive
+// authentication, like the one time passwords
+type challengePrompt struct {
+	header    string // the name and the instruction sent by the server
+	inputs    []textinput.Model
+	focused   int
+	submitted bool
+}
+
+func (m challengePrompt) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m challengePrompt) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			return m, tea.Quit
+		case "enter", "tab":
+			// The last answer sends them all
+			if m.focused == len(m.inputs)-1 {
+				if msg.String() == "enter" {
+					m.submitted = true
+					return m, tea.Quit
+				}
+				return m, nil
+			}
+			m.inputs[m.focused].Blur()
+			m.focused++
+			return m, m.inputs[m.focused].Focus()
+		case "shift+tab":
+			if m.focused > 0 {
+				m.inputs[m.focused].Blur()
+				m.focused--
+				return m, m.inputs[m.focused].Focus()
+			}
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.inputs[m.focused], cmd = m.inputs[m.focused].Update(msg)
+	return m, cmd
+}
+
+func (m challengePrompt) View() string {
+	if m.submitted {
+		return ""
+	}
+	var lines []string
+	if m.header != "" {
+		lines = append(lines, m.header)
+	}
+	for _, input := range m.inputs {
+		lines = append(lines, input.View())
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Ask the questions of the server, the answers the server doesn't want
+// echoed are masked. Returns false if the user gave up.
+func AskChallenge(name, instruction string, questions []string, echos []bool) ([]string, bool) {
+	var header []string
+	for _, line := range []string{name, instruction} {
+		if line = strings.TrimSpace(line); line != "" {
+			header = append(header, line)
+		}
+	}
+
+	inputs := make([]textinput.Model, len(questions))
+	for i, question := range questions {
+		input := textinput.New()
+		input.Prompt = question
+		if !strings.HasSuffix(question, " ") {
+			input.Prompt += " "
+		}
+		if !echos[i] {
+			input.EchoMode = textinput.EchoPassword
+			input.EchoCharacter = '•'
+		}
+		inputs[i] = input
+	}
+	inputs[0].Focus()
+
+	prompt := challengePrompt{header: strings.Join(header, "\n"), inputs: inputs}
+	finalModel, err := tea.NewProgram(prompt).StartReturningModel()
+	if err != nil {
+		return nil, false
+	}
+	prompt = finalModel.(challengePrompt)
+	if !prompt.submitted {
+		return nil, false
+	}
+	answers := make([]string, len(prompt.inputs))
+	for i, input := range prompt.inputs {
+		answers[i] = input.Value()
+	}
+	return answers, true
+}
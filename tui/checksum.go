@@ -0,0 +1,115 @@
+package tui
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+	"golang.org/x/crypto/ssh"
+)
+
+// Error of a transfer whose copy doesn't match the original file
+type checksumMismatchError struct {
+	local  string // sha256 of the local file
+	remote string // sha256 of the remote file
+}
+
+func (e *checksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum mismatch, local sha256 %.12s remote %.12s", e.local, e.remote)
+}
+
+// Wrap the copy so that once it's done the local and remote files are hashed
+// and compared, a mismatch fails the transfer
+func verifiedCopy(sshClient *ssh.Client, remote remotefs.FileSystem, localPath, remotePath string, copyFunc func(counter io.Writer) error) func(counter io.Writer) error {
+	return func(counter io.Writer) error {
+		if err := copyFunc(counter); err != nil {
+			return err
+		}
+		localSum, err := localChecksum(localPath)
+		if err != nil {
+			return fmt.Errorf("hashing %s failed: %v", localPath, err)
+		}
+		remoteSum, err := remoteChecksum(sshClient, remote, remotePath)
+		if err != nil {
+			return fmt.Errorf("hashing %s failed: %v", remotePath, err)
+		}
+		if localSum != remoteSum {
+			return &checksumMismatchError{local: localSum, remote: remoteSum}
+		}
+		return nil
+	}
+}
+
+// Get the sha256 of the local file in hex
+func localChecksum(localPath string) (string, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return checksum(file)
+}
+
+// Get the sha256 of the remote file in hex, computed on the server with
+// sha256sum. When the command can't run the file is read back and hashed.
+func remoteChecksum(sshClient *ssh.Client, remote remotefs.FileSystem, remotePath string) (string, error) {
+	if session, err := newSession(sshClient); err == nil {
+		// Read from the standard input sha256sum doesn't print the name,
+		// which it would escape
+		command := "sha256sum < " + shellQuote(remotePath)
+		slog.Info("remote command", "command", command)
+		output, err := session.Output(command)
+		session.Close()
+		if fields := strings.Fields(string(output)); err == nil && len(fields) > 0 && len(fields[0]) == sha256.Size*2 {
+			return fields[0], nil
+		}
+	}
+
+	file, err := remote.Open(remotePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return checksum(file)
+}
+
+// Hash the content of the reader with sha256
+func checksum(r io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Quote the argument for the remote shell
+func shellQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// Turn the checksum verification of the transfers on or off
+func (m *Model) toggleVerify() tea.Cmd {
+	m.verify = !m.verify
+	status := "Transfers are not verified"
+	if m.verify {
+		status = "Transfers are verified with sha256"
+	}
+	return m.setStatus(status)
+}
+
+// Ask whether to copy again the file that doesn't match the original
+func (m *Model) askRetransfer(t *transfer, err error) {
+	m.askChoice(
+		fmt.Sprintf("%s doesn't match the original: %v", t.name, err),
+		choice{key: "t", label: "transfer again", action: func(m *Model) tea.Cmd {
+			return m.queue.retry(t)
+		}},
+		choice{key: "k", label: "keep it", action: func(*Model) tea.Cmd { return nil }},
+	)
+}
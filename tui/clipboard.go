@@ -0,0 +1,50 @@
+package tui
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Copy the full remote path of the highlighted item to the clipboard, or its
+// sftp:// url
+func (m *Model) copyPath(asURL bool) tea.Cmd {
+	selectedItem, ok := m.List.SelectedItem().(*item)
+	if !ok {
+		return nil
+	}
+	text := m.remote.Join(m.currentDir, selectedItem.rawValue.Name())
+	if asURL {
+		text = m.sftpURL(text)
+	}
+	if err := clipboard.WriteAll(text); err != nil {
+		return reportError(fmt.Errorf("copying to the clipboard failed: %v", err))
+	}
+	return m.setStatus("Copied " + text)
+}
+
+// Default ports of the schemes of the urls, left out of them
+var defaultPorts = map[string]string{"sftp": "22", "ftp": "21", "ftps": "990", "ftpes": "21"}
+
+// Get the sftp:// url of the remote path, the default port is left out.
+// The local files get a file:// one, the ftp servers an ftp:// one.
+func (m *Model) sftpURL(remotePath string) string {
+	if m.local {
+		return (&url.URL{Scheme: "file", Path: remotePath}).String()
+	}
+	scheme := m.scheme
+	if scheme == "" {
+		scheme = "sftp"
+	}
+	u := url.URL{Scheme: scheme, Host: m.host, Path: remotePath}
+	if m.port != "" && m.port != defaultPorts[scheme] {
+		u.Host = net.JoinHostPort(m.host, m.port)
+	}
+	if m.user != "" {
+		u.User = url.User(m.user)
+	}
+	return u.String()
+}
@@ -0,0 +1,101 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/guglielmobartelloni/sftp-tui/config"
+	"github.com/muesli/reflow/truncate"
+)
+
+// Widths of the columns of the column view, the name takes the rest
+const (
+	sizeColumnWidth = 7
+	modeColumnWidth = 10
+	timeColumnWidth = 16
+	columnGap       = "  "
+)
+
+// Renders the entries on a line each, with the size, the owner, the
+// permissions and the modification time aligned in columns like ls -l
+type columnDelegate struct {
+	styles     list.DefaultItemStyles
+	ownerWidth int // width of the widest owner:group listed
+}
+
+// Create the delegate of the column view for the listed items
+func newColumnDelegate(items []list.Item) columnDelegate {
+	delegate := columnDelegate{styles: newDelegate().Styles}
+	for _, listItem := range items {
+		if width := len(listItem.(*item).owner); width > delegate.ownerWidth {
+			delegate.ownerWidth = width
+		}
+	}
+	return delegate
+}
+
+func (d columnDelegate) Height() int { return 1 }
+
+func (d columnDelegate) Spacing() int { return 0 }
+
+func (d columnDelegate) Update(tea.Msg, *list.Model) tea.Cmd { return nil }
+
+func (d columnDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	fileItem, ok := listItem.(*item)
+	if !ok {
+		return
+	}
+	style := d.styles.NormalTitle
+	if index == m.Index() {
+		style = d.styles.SelectedTitle
+	}
+
+	// The name takes the width left by the other columns and the padding
+	nameWidth := m.Width() - style.GetHorizontalFrameSize() -
+		sizeColumnWidth - d.ownerWidth - modeColumnWidth - timeColumnWidth - 4*len(columnGap)
+	if nameWidth < 10 {
+		nameWidth = 10
+	}
+	name := truncate.StringWithTail(fileItem.Title(), uint(nameWidth), "…")
+	name += strings.Repeat(" ", nameWidth-lipgloss.Width(name))
+	if fileItem.rawValue.Name() == ".." {
+		fmt.Fprint(w, style.Render(name))
+		return
+	}
+
+	// Like the description, a symlink shows the properties of its target
+	info := fileItem.rawValue
+	if fileItem.target != nil {
+		info = fileItem.target
+	}
+	size := ConvertBytesToSizeString(info.Size())
+	if fileItem.sized {
+		size = ConvertBytesToSizeString(fileItem.dirSize)
+	}
+	line := name + columnGap +
+		fmt.Sprintf("%*s", sizeColumnWidth, size) + columnGap +
+		fmt.Sprintf("%-*s", d.ownerWidth, fileItem.owner) + columnGap +
+		fmt.Sprintf("%-*s", modeColumnWidth, info.Mode().String()) + columnGap +
+		info.ModTime().Format("2006-01-02 15:04")
+	fmt.Fprint(w, style.Render(line))
+}
+
+// Switch between the listing with the descriptions and the column view,
+// saving the choice in the config file
+func (m *Model) toggleColumns() tea.Cmd {
+	m.columns = !m.columns
+	if !m.columns {
+		m.List.SetDelegate(newDelegate())
+	}
+	columns := m.columns
+	return tea.Batch(m.setDirItems(m.dirItems), func() tea.Msg {
+		if err := config.Set("Columns", columns); err != nil {
+			return errorMsg{err: fmt.Errorf("saving the config failed: %v", err)}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,54 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/crypto/ssh"
+)
+
+// Error of the commands run when browsing the local files or an ftp server,
+// there's no shell to run them in
+var errNoServer = errors.New("no shell, the files aren't browsed with ssh")
+
+// Open a session to run a command on the server
+func newSession(sshClient *ssh.Client) (*ssh.Session, error) {
+	if sshClient == nil {
+		return nil, errNoServer
+	}
+	return sshClient.NewSession()
+}
+
+// Run the command with the remote shell in the current directory in the
+// background, its output is shown in the preview pane. The listing is
+// refreshed since the command may have changed the files.
+func (m *Model) runCommand(command string) tea.Cmd {
+	sshClient := m.sshClient
+	refresh := m.changeDir(m.currentDir, "")
+	shellCommand := fmt.Sprintf("cd %s && %s", shellQuote(m.currentDir), command)
+	return tea.Batch(
+		m.setStatus("Running "+command),
+		func() tea.Msg {
+			session, err := newSession(sshClient)
+			if err != nil {
+				return errorMsg{err: fmt.Errorf("running %s failed: %v", command, err)}
+			}
+			defer session.Close()
+
+			slog.Info("remote command", "command", shellCommand)
+			output, err := session.CombinedOutput(shellCommand)
+			content := displayText(strings.ReplaceAll(string(output), "\t", "    "))
+			if err != nil {
+				content += fmt.Sprintf("\n%v", err)
+			}
+			if strings.TrimSpace(content) == "" {
+				content = "No output"
+			}
+			msg := previewMsg{name: "$ " + command, content: content}
+			return tea.Batch(func() tea.Msg { return msg }, refresh)()
+		},
+	)
+}
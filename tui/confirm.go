@@ -0,0 +1,90 @@
+package tui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// One of the answers to a confirmation
+type choice struct {
+	key    string
+	label  string
+	action func(m *Model) tea.Cmd // run when the user picks the choice
+	hidden bool                   // whether it's left out of the modal, like the shifted keys
+}
+
+// A question the user has to answer before going on
+type confirmation struct {
+	question string
+	choices  []choice
+}
+
+// Ask the user to confirm the action
+func (m *Model) askConfirmation(question string, action tea.Cmd) {
+	m.askChoice(
+		question,
+		choice{key: "y", label: "yes", action: func(*Model) tea.Cmd { return action }},
+		choice{key: "n", label: "no", action: func(*Model) tea.Cmd { return nil }},
+	)
+}
+
+// Ask the user to pick one of the choices, esc picks none
+func (m *Model) askChoice(question string, choices ...choice) {
+	m.confirmation = &confirmation{question: question, choices: choices}
+}
+
+// Handle the key presses while the confirmation is shown
+func (m Model) updateConfirmation(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q":
+		m.confirmation = nil
+		return m, nil
+	}
+
+	// A key matching a choice exactly wins over the ones differing in case
+	picked := -1
+	for i, c := range m.confirmation.choices {
+		if msg.String() == c.key {
+			picked = i
+			break
+		}
+		if picked < 0 && !c.hidden && strings.EqualFold(msg.String(), c.key) {
+			picked = i
+		}
+	}
+	if picked < 0 {
+		return m, nil
+	}
+	// The action can ask another question
+	action := m.confirmation.choices[picked].action
+	m.confirmation = nil
+	return m, action(&m)
+}
+
+// Render the confirmation as a modal in the middle of the screen
+func (m Model) confirmationView() string {
+	var choices []string
+	for _, c := range m.confirmation.choices {
+		if c.hidden {
+			continue
+		}
+		label := strings.Replace(c.label, c.key, "["+c.key+"]", 1)
+		if !strings.Contains(label, "[") {
+			label = "[" + c.key + "] " + label
+		}
+		choices = append(choices, label)
+	}
+	choices[0] = statusMessageStyle(choices[0])
+
+	return lipgloss.Place(
+		m.width,
+		m.height,
+		lipgloss.Center,
+		lipgloss.Center,
+		modalStyle.Render(m.confirmation.question+"\n\n"+strings.Join(choices, " / ")),
+	)
+}
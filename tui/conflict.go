@@ -0,0 +1,60 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/conflict"
+)
+
+// The answers to a conflict, with the policy each one applies
+var conflictChoices = []struct {
+	key    string
+	label  string
+	policy conflict.Policy
+}{
+	{"o", "overwrite", conflict.Overwrite},
+	{"n", "if newer", conflict.Newer},
+	{"e", "resume", conflict.Resume},
+	{"r", "rename", conflict.Rename},
+	{"s", "skip", conflict.Skip},
+}
+
+// Ask what to do with the file whose destination already exists. In a batch
+// the key with shift applies the choice to the other conflicts too.
+func (m *Model) askConflict(destination string, batch bool, resolve func(m *Model, policy conflict.Policy, all bool) tea.Cmd) {
+	question := fmt.Sprintf("%s already exists", destination)
+	if batch {
+		question += "\nwith shift the choice applies to the next files too"
+	}
+	var choices []choice
+	for _, c := range conflictChoices {
+		policy := c.policy
+		choices = append(choices, choice{key: c.key, label: c.label, action: func(m *Model) tea.Cmd {
+			return resolve(m, policy, false)
+		}})
+		if batch {
+			choices = append(choices, choice{key: strings.ToUpper(c.key), hidden: true, action: func(m *Model) tea.Cmd {
+				return resolve(m, policy, true)
+			}})
+		}
+	}
+	m.askChoice(question, choices...)
+}
+
+// Get the policy for the rest of the batch once the user answered
+func nextPolicy(picked conflict.Policy, all bool) conflict.Policy {
+	if all {
+		return picked
+	}
+	return conflict.Ask
+}
+
+// Tell how many files were skipped because they exist
+func (m *Model) skippedStatus(skipped int) tea.Cmd {
+	if skipped == 0 {
+		return nil
+	}
+	return m.setStatus(fmt.Sprintf("Skipped %d existing files", skipped))
+}
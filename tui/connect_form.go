@@ -0,0 +1,177 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/guglielmobartelloni/sftp-tui/ssh"
+)
+
+// Fields of the connection form
+const (
+	hostField = iota
+	portField
+	userField
+	keyField
+	passwordField
+	connectFields
+)
+
+// Holds the state of the form asking where to connect, it's shown at the
+// start and when a connection fails so it can be corrected
+type connectForm struct {
+	inputs  []textinput.Model
+	focused int
+	orig    ssh.Options // the settings filled in, used as they are when not edited
+	err     error       // why the last connection failed
+}
+
+// Create the form filled with the settings of the connection
+func newConnectForm(options ssh.Options, err error) *connectForm {
+	form := &connectForm{inputs: make([]textinput.Model, connectFields), orig: options, err: err}
+	labels := []string{"Host:        ", "Port:        ", "User:        ", "Private key: ", "Passphrase:  "}
+	values := []string{options.Host, options.Port, options.Username, options.PrivateKeyPath, options.PrivateKeyPassword}
+	for i := range form.inputs {
+		input := textinput.New()
+		input.Prompt = labels[i]
+		input.CharLimit = 4096
+		input.SetValue(values[i])
+		form.inputs[i] = input
+	}
+	form.inputs[hostField].Placeholder = "host, ssh config alias or profile"
+	form.inputs[portField].Placeholder = "22"
+	form.inputs[passwordField].EchoMode = textinput.EchoPassword
+	form.inputs[passwordField].EchoCharacter = '•'
+	form.moveTo(hostField)
+	return form
+}
+
+// Focus the field, wrapping around
+func (f *connectForm) moveTo(field int) tea.Cmd {
+	f.focused = (field + connectFields) % connectFields
+	for i := range f.inputs {
+		f.inputs[i].Blur()
+	}
+	f.inputs[f.focused].CursorEnd()
+	return f.inputs[f.focused].Focus()
+}
+
+// Get the settings of the connection. A host other than the one filled in
+// is resolved like the one of a new tab, it can be the name of a profile;
+// the edited fields replace its settings.
+func (f *connectForm) options(resolveHost func(host string) (ssh.Options, error)) (ssh.Options, error) {
+	host := strings.TrimSpace(f.inputs[hostField].Value())
+	options := f.orig
+	switch {
+	case host == f.orig.Host:
+	case resolveHost == nil:
+		options.Host = host
+	default:
+		var err error
+		if options, err = resolveHost(host); err != nil {
+			return ssh.Options{Host: host}, err
+		}
+	}
+
+	edited := func(field int, orig string) (string, bool) {
+		value := strings.TrimSpace(f.inputs[field].Value())
+		return value, value != orig
+	}
+	if port, ok := edited(portField, f.orig.Port); ok {
+		options.Port = port
+	}
+	if options.Port == "" {
+		options.Port = "22"
+	}
+	if user, ok := edited(userField, f.orig.Username); ok {
+		options.Username = user
+	}
+	if keyPath, ok := edited(keyField, f.orig.PrivateKeyPath); ok {
+		options.PrivateKeyPath = keyPath
+	}
+	// The passphrase can have spaces
+	if password := f.inputs[passwordField].Value(); password != f.orig.PrivateKeyPassword {
+		options.PrivateKeyPassword = password
+	}
+	return options, nil
+}
+
+// Tell if the fields have been left as they were filled in
+func (f *connectForm) untouched() bool {
+	values := []string{f.orig.Host, f.orig.Port, f.orig.Username, f.orig.PrivateKeyPath, f.orig.PrivateKeyPassword}
+	for i, input := range f.inputs {
+		if input.Value() != values[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Handle the key presses while the connection form is shown
+func (t tabs) updateForm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	form := t.form
+	switch msg.String() {
+	case "ctrl+c":
+		// The question about the running transfers is asked in the tab
+		if len(t.tabs) > 0 {
+			t.form = nil
+		}
+		return t.quit()
+	case "esc":
+		// Without a connection there's nothing to go back to
+		if len(t.tabs) == 0 {
+			return t, tea.Quit
+		}
+		t.form = nil
+		return t, nil
+	case "enter":
+		if strings.TrimSpace(form.inputs[hostField].Value()) == "" {
+			// Without a server the local files are browsed
+			if form.orig.Host == "" && form.untouched() {
+				return t, t.openLocal()
+			}
+			return t, form.moveTo(hostField)
+		}
+		options, err := form.options(t.settings.ResolveHost)
+		form.err = err
+		if err != nil {
+			return t, nil
+		}
+		return t, t.connect(options)
+	case "tab", "down":
+		return t, form.moveTo(form.focused + 1)
+	case "shift+tab", "up":
+		return t, form.moveTo(form.focused - 1)
+	}
+
+	var cmd tea.Cmd
+	form.inputs[form.focused], cmd = form.inputs[form.focused].Update(msg)
+	return t, cmd
+}
+
+// Render the connection form as a modal in the middle of the screen
+func (t tabs) formView() string {
+	form := t.form
+	lines := []string{"Connect to a server", ""}
+	for _, input := range form.inputs {
+		lines = append(lines, input.View())
+	}
+	if form.err != nil {
+		lines = append(lines, "", brokenLinkStyle(lipgloss.NewStyle().Width(70).Render(form.err.Error())))
+	}
+	help := "tab next field • enter connect, without a host browse the local files • esc quit"
+	if len(t.tabs) > 0 {
+		help = "tab next field • enter connect in a new tab, without a host the local files • esc cancel"
+	}
+	lines = append(lines, "", statusMessageStyle(help))
+
+	return lipgloss.Place(
+		t.width,
+		t.height,
+		lipgloss.Center,
+		lipgloss.Center,
+		modalStyle.Render(strings.Join(lines, "\n")),
+	)
+}
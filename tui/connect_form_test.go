@@ -0,0 +1,35 @@
+package tui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/ssh"
+)
+
+func TestEmptyFormBrowsesLocal(t *testing.T) {
+	settings := Settings{LocalDir: t.TempDir()}
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+
+	updated, cmd := newTabs(ssh.Options{}, settings, nil).Update(enter)
+	if cmd == nil {
+		t.Fatal("nothing opened")
+	}
+	opened, ok := cmd().(tabOpenedMsg)
+	if !ok || opened.err != nil || !opened.model.local {
+		t.Fatalf("opened %#v", opened)
+	}
+	updated, _ = updated.(tabs).Update(opened)
+	if got := updated.(tabs); got.form != nil || len(got.tabs) != 1 {
+		t.Errorf("the local tab isn't shown, %d tabs", len(got.tabs))
+	}
+
+	// A form being filled asks for the host
+	form := newTabs(ssh.Options{}, settings, nil)
+	form.form.inputs[userField].SetValue("bob")
+	if _, cmd := form.Update(enter); cmd != nil {
+		if _, ok := cmd().(tabOpenedMsg); ok {
+			t.Error("the local files were opened with the user filled in")
+		}
+	}
+}
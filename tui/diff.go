@@ -0,0 +1,165 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/localpath"
+	"github.com/pmezard/go-difflib/difflib"
+	"golang.org/x/crypto/ssh"
+)
+
+// Files bigger than this aren't compared, the diff would be unreadable
+const maxDiffSize = 8 * 1024 * 1024
+
+// Ask for the local file to compare the highlighted one with, the one with
+// the same name in the local directory by default
+func (m *Model) askDiff(fileInfo fs.FileInfo) tea.Cmd {
+	return m.openPrompt(diffPrompt, fmt.Sprintf("Diff %s with the local file: ", fileInfo.Name()), filepath.Join(m.localDir, localpath.Name(fileInfo.Name())))
+}
+
+// Read the highlighted file into memory and show its unified diff with the
+// local file in the preview pane
+func (m *Model) diffFile(localPath string) tea.Cmd {
+	selectedItem, ok := m.List.SelectedItem().(*item)
+	if !ok || selectedItem.isDir() {
+		return nil
+	}
+	name := selectedItem.rawValue.Name()
+	remote := m.remote
+	remotePath := remote.Join(m.currentDir, name)
+	localPath = expandLocalPath(localPath, m.localDir)
+	return func() tea.Msg {
+		localContent, err := readDiffed(localPath, func() (io.ReadCloser, error) { return os.Open(localPath) })
+		if err != nil {
+			return errorMsg{err: fmt.Errorf("diffing %s failed: %v", name, err)}
+		}
+		remoteContent, err := readDiffed(remotePath, func() (io.ReadCloser, error) { return remote.Open(remotePath) })
+		if err != nil {
+			return errorMsg{err: fmt.Errorf("diffing %s failed: %v", name, err)}
+		}
+		diff, err := unifiedDiff(localPath, localContent, remotePath, remoteContent)
+		if err != nil {
+			return errorMsg{err: fmt.Errorf("diffing %s failed: %v", name, err)}
+		}
+		return diffPreview(filepath.Base(localPath), name, localPath, remotePath, diff)
+	}
+}
+
+// Show the unified diff of the two remote files, computed on the server
+// with diff. When the command can't run the files are read and compared
+// here.
+func (m *Model) diffMarked(first, second *item) tea.Cmd {
+	if first.isDir() || second.isDir() {
+		m.setError(fmt.Errorf("diffing failed: only files can be compared"))
+		return nil
+	}
+	sshClient, remote := m.sshClient, m.remote
+	firstName, secondName := first.rawValue.Name(), second.rawValue.Name()
+	firstPath, secondPath := remote.Join(m.currentDir, firstName), remote.Join(m.currentDir, secondName)
+	return func() tea.Msg {
+		if diff, ok := remoteDiff(sshClient, firstPath, secondPath); ok {
+			return diffPreview(firstName, secondName, firstPath, secondPath, diff)
+		}
+
+		var contents [2]string
+		for i, remotePath := range []string{firstPath, secondPath} {
+			content, err := readDiffed(remotePath, func() (io.ReadCloser, error) { return remote.Open(remotePath) })
+			if err != nil {
+				return errorMsg{err: fmt.Errorf("diffing %s and %s failed: %v", firstName, secondName, err)}
+			}
+			contents[i] = content
+		}
+		diff, err := unifiedDiff(firstPath, contents[0], secondPath, contents[1])
+		if err != nil {
+			return errorMsg{err: fmt.Errorf("diffing %s and %s failed: %v", firstName, secondName, err)}
+		}
+		return diffPreview(firstName, secondName, firstPath, secondPath, diff)
+	}
+}
+
+// Run diff -u on the server, returns false when it can't: diff exits with 1
+// when the files differ, 2 on errors
+func remoteDiff(sshClient *ssh.Client, firstPath, secondPath string) (string, bool) {
+	if sshClient == nil {
+		return "", false
+	}
+	session, err := newSession(sshClient)
+	if err != nil {
+		return "", false
+	}
+	defer session.Close()
+	command := "diff -u -- " + shellQuote(firstPath) + " " + shellQuote(secondPath)
+	slog.Info("remote command", "command", command)
+	output, err := session.Output(command)
+	var exitErr *ssh.ExitError
+	switch {
+	case err == nil:
+		return "", true
+	case errors.As(err, &exitErr) && exitErr.ExitStatus() == 1:
+		return string(output), true
+	default:
+		return "", false
+	}
+}
+
+// Get the unified diff of the two texts, empty when they are the same
+func unifiedDiff(fromPath, from, toPath, to string) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(from),
+		B:        splitLines(to),
+		FromFile: fromPath,
+		ToFile:   toPath,
+		Context:  3,
+	})
+}
+
+// Create the message showing the diff in the preview pane
+func diffPreview(fromName, toName, fromPath, toPath, diff string) previewMsg {
+	title := fmt.Sprintf("%s ↔ %s", fromName, toName)
+	if diff == "" {
+		return previewMsg{name: title, content: fmt.Sprintf("%s and %s are the same", fromPath, toPath)}
+	}
+	// The diff is colored like a .diff file
+	return previewMsg{name: title, content: highlight("changes.diff", strings.ReplaceAll(diff, "\t", "    "))}
+}
+
+// Read the text of a file to compare, refusing the binary and the big ones
+func readDiffed(path string, open func() (io.ReadCloser, error)) (string, error) {
+	file, err := open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, maxDiffSize+1))
+	if err != nil {
+		return "", fmt.Errorf("reading %s failed: %v", path, err)
+	}
+	if len(content) > maxDiffSize {
+		return "", fmt.Errorf("%s is bigger than %s", path, ConvertBytesToSizeString(maxDiffSize))
+	}
+	if !isText(content) {
+		return "", fmt.Errorf("%s is a binary file", path)
+	}
+	return string(content), nil
+}
+
+// Split the text in lines ending with their newline, the last one without
+// gets one
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	last := len(lines) - 1
+	if lines[last] == "" {
+		return lines[:last]
+	}
+	lines[last] += "\n"
+	return lines
+}
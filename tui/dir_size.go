@@ -0,0 +1,102 @@
+package tui
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+	"golang.org/x/crypto/ssh"
+)
+
+// Message sent when the size of a directory has been computed
+type dirSizeMsg struct {
+	path string // the directory listed when the size was asked
+	name string
+	size int64
+	err  error
+}
+
+// Compute the size of the marked directories, or the highlighted one, with
+// all their content in the background
+func (m *Model) computeDirSizes() tea.Cmd {
+	var cmds []tea.Cmd
+	for _, i := range m.targetItems() {
+		if !i.isDir() {
+			continue
+		}
+		i.marked = false
+		cmds = append(cmds, m.dirSize(i.rawValue.Name()))
+	}
+	if len(cmds) == 0 {
+		return m.setStatus("No directory to compute the size of")
+	}
+	status := fmt.Sprintf("Computing the size of %d directories", len(cmds))
+	return tea.Batch(append(cmds, m.setStatus(status))...)
+}
+
+// Compute the size of the directory of the current one
+func (m *Model) dirSize(name string) tea.Cmd {
+	sshClient, remote, currentDir := m.sshClient, m.remote, m.currentDir
+	return func() tea.Msg {
+		// The slash follows the symlinks to directories
+		size, err := remoteDirSize(sshClient, remote, remote.Join(currentDir, name)+"/")
+		return dirSizeMsg{path: currentDir, name: name, size: size, err: err}
+	}
+}
+
+// Get the size of the directory computed on the server with du. When the
+// command can't run the directory is walked.
+func remoteDirSize(sshClient *ssh.Client, remote remotefs.FileSystem, dirPath string) (int64, error) {
+	if session, err := newSession(sshClient); err == nil {
+		command := "du -sb -- " + shellQuote(dirPath)
+		slog.Info("remote command", "command", command)
+		output, err := session.Output(command)
+		session.Close()
+		if fields := strings.Fields(string(output)); err == nil && len(fields) > 0 {
+			if size, err := strconv.ParseInt(fields[0], 10, 64); err == nil {
+				return size, nil
+			}
+		}
+	}
+
+	var size int64
+	walker := remotefs.Walk(remote, dirPath)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return 0, err
+		}
+		size += walker.Stat().Size()
+	}
+	return size, nil
+}
+
+// Show the size of the directory next to it, the list is sorted again when
+// ordered by size
+func (m *Model) setDirSize(msg dirSizeMsg) tea.Cmd {
+	if msg.err != nil {
+		m.setError(fmt.Errorf("computing the size of %s failed: %v", msg.name, msg.err))
+		return nil
+	}
+	status := m.setStatus(fmt.Sprintf("%s is %s", msg.name, ConvertBytesToSizeString(msg.size)))
+	if msg.path != m.currentDir {
+		return status
+	}
+	for _, listItem := range m.dirItems {
+		if i := listItem.(*item); i.rawValue.Name() == msg.name {
+			i.dirSize, i.sized = msg.size, true
+		}
+	}
+	if m.sortMode.field != sortBySize {
+		return status
+	}
+	var selected string
+	if selectedItem, ok := m.List.SelectedItem().(*item); ok {
+		selected = selectedItem.rawValue.Name()
+	}
+	cmd := m.setDirItems(m.dirItems)
+	m.selectItem(selected)
+	return tea.Batch(cmd, status)
+}
@@ -0,0 +1,145 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/localpath"
+	"github.com/guglielmobartelloni/sftp-tui/mirror"
+)
+
+// How the directories are transferred
+type dirTransfer int
+
+const (
+	dirFileByFile dirTransfer = iota
+	dirTarStream
+	dirZip   // downloaded into a local .zip
+	dirTarGz // downloaded into a local .tar.gz
+)
+
+// Ask whether to transfer the directories as a tar stream, file by file or
+// to skip them, transfer runs the choice. The downloads can also go into a
+// local archive.
+func (m *Model) askDirTransfer(question string, download bool, transfer func(m *Model, mode dirTransfer) tea.Cmd, skip func(m *Model) tea.Cmd) {
+	var choices []choice
+	// The tar stream runs tar on the server, through ssh
+	if m.sshClient != nil {
+		choices = append(choices, choice{key: "t", label: "tar stream", action: func(m *Model) tea.Cmd {
+			return transfer(m, dirTarStream)
+		}})
+	}
+	choices = append(choices, choice{key: "f", label: "file by file", action: func(m *Model) tea.Cmd {
+		return transfer(m, dirFileByFile)
+	}})
+	if download {
+		choices = append(choices,
+			choice{key: "z", label: "into a .zip", action: func(m *Model) tea.Cmd {
+				return transfer(m, dirZip)
+			}},
+			choice{key: "g", label: "into a .tar.gz", action: func(m *Model) tea.Cmd {
+				return transfer(m, dirTarGz)
+			}},
+		)
+	}
+	m.askChoice(question, append(choices, choice{key: "s", label: "skip them", action: skip})...)
+}
+
+// Queue the download of the remote directories into the local one
+func (m *Model) downloadDirs(dirs []*item, localDir string, mode dirTransfer) tea.Cmd {
+	sshClient, remote := m.sshClient, m.remote
+	cmds := []tea.Cmd{m.setStatus(fmt.Sprintf("Downloading %d directories", len(dirs)))}
+	for _, i := range dirs {
+		name := i.rawValue.Name()
+		remoteDir := m.remote.Join(m.currentDir, name)
+		// The size of the streams isn't known beforehand
+		switch mode {
+		case dirFileByFile:
+			cmds = append(cmds, m.copyDirFiles(false, filepath.Join(localDir, localpath.Name(name)), remoteDir))
+		case dirTarStream:
+			cmds = append(cmds, m.queue.add(&transfer{
+				name:        name,
+				source:      remoteDir,
+				destination: localDir,
+				copyFunc: func(counter io.Writer) error {
+					return tarDownload(sshClient, remoteDir, localDir, counter)
+				},
+			}))
+		default:
+			archiveName := name + ".tar.gz"
+			if mode == dirZip {
+				archiveName = name + ".zip"
+			}
+			archivePath := filepath.Join(localDir, localpath.Name(archiveName))
+			cmds = append(cmds, m.queue.add(&transfer{
+				name:        archiveName,
+				source:      remoteDir,
+				destination: archivePath,
+				copyFunc: func(counter io.Writer) error {
+					return archiveDownload(remote, remoteDir, archivePath, mode == dirZip, counter)
+				},
+			}))
+		}
+	}
+	return tea.Batch(cmds...)
+}
+
+// Queue the upload of the local directories into the current one
+func (m *Model) uploadDirs(dirs []string, tarStream bool) tea.Cmd {
+	sshClient := m.sshClient
+	currentDir := m.currentDir
+	cmds := []tea.Cmd{m.setStatus(fmt.Sprintf("Uploading %d directories", len(dirs)))}
+	for _, localDir := range dirs {
+		localDir := localDir
+		name := filepath.Base(localDir)
+		if !tarStream {
+			cmds = append(cmds, m.copyDirFiles(true, localDir, m.remote.Join(currentDir, name)))
+			continue
+		}
+		cmds = append(cmds, m.queue.add(&transfer{
+			name:        name,
+			source:      localDir,
+			destination: currentDir,
+			upload:      true,
+			total:       localSize(localDir),
+			copyFunc: func(counter io.Writer) error {
+				return tarUpload(sshClient, localDir, currentDir, counter)
+			},
+		}))
+	}
+	return tea.Batch(cmds...)
+}
+
+// Copy the files of the directory one at a time with sftp, like a sync that
+// doesn't delete anything
+func (m *Model) copyDirFiles(push bool, localDir, remoteDir string) tea.Cmd {
+	remote := m.remote
+	return func() tea.Msg {
+		var actions []mirror.Action
+		var err error
+		if push {
+			actions, err = mirror.PlanPush(remote, localDir, remoteDir, false)
+		} else {
+			actions, err = mirror.PlanPull(remote, remoteDir, localDir, false)
+		}
+		if err != nil {
+			return errorMsg{err: fmt.Errorf("listing %s failed: %v", filepath.Base(localDir), err)}
+		}
+		return m.runSync(push, actions, false)()
+	}
+}
+
+// Sum the sizes of the regular files under the local directory
+func localSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(_ string, fileInfo os.FileInfo, err error) error {
+		if err == nil && fileInfo.Mode().IsRegular() {
+			size += fileInfo.Size()
+		}
+		return nil
+	})
+	return size
+}
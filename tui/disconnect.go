@@ -0,0 +1,237 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/guglielmobartelloni/sftp-tui/ssh"
+	"github.com/pkg/sftp"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// How long a request to the server goes before it can be cancelled
+const slowRequest = 3 * time.Second
+
+// Tracks the requests to the server the user waits for, it's shared by the
+// copies of the model and used by the commands running in the background
+type serverRequests struct {
+	mu      sync.Mutex
+	pending map[int]time.Time // when each request started
+	nextID  int
+	slow    chan string   // what is taking long, one notice at a time
+	closed  chan struct{} // closed with the connection of the tab
+}
+
+func newServerRequests() *serverRequests {
+	return &serverRequests{
+		pending: make(map[int]time.Time),
+		slow:    make(chan string, 1),
+		closed:  make(chan struct{}),
+	}
+}
+
+// Track the request, the returned function is called once it's done
+func (r *serverRequests) start(what string) func() {
+	r.mu.Lock()
+	r.nextID++
+	id := r.nextID
+	r.pending[id] = time.Now()
+	r.mu.Unlock()
+
+	timer := time.AfterFunc(slowRequest, func() {
+		select {
+		case r.slow <- what:
+		default:
+			// A notice is already waiting
+		}
+	})
+	return func() {
+		timer.Stop()
+		r.mu.Lock()
+		delete(r.pending, id)
+		r.mu.Unlock()
+	}
+}
+
+// Tell if a request has been waiting for the server longer than slowRequest
+func (r *serverRequests) isSlow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, started := range r.pending {
+		if time.Since(started) >= slowRequest {
+			return true
+		}
+	}
+	return false
+}
+
+// Forget the pending requests, they fail once the connection is closed
+func (r *serverRequests) clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending = make(map[int]time.Time)
+}
+
+// Message sent when a request is taking long
+type serverSlowMsg struct {
+	what string
+}
+
+// Wait for the next request taking long
+func (r *serverRequests) waitSlow() tea.Cmd {
+	return func() tea.Msg {
+		select {
+		case what := <-r.slow:
+			return serverSlowMsg{what: what}
+		case <-r.closed:
+			return nil
+		}
+	}
+}
+
+// Tell the request is taking long and how to give up on it
+func (m *Model) serverSlow(msg serverSlowMsg) tea.Cmd {
+	cmd := m.requests.waitSlow()
+	if !m.requests.isSlow() {
+		return cmd
+	}
+	status := fmt.Sprintf("%s is taking long, %s isn't answering", msg.what, m.host)
+	// Only the ssh connection can be dropped, the ftp servers have one per
+	// request
+	if m.sshClient != nil {
+		status += ": esc to disconnect"
+	}
+	return tea.Batch(cmd, m.setStatus(status))
+}
+
+// Close the connection to the server that doesn't answer, the requests
+// waiting for it fail and the user can reconnect
+func (m *Model) disconnect() {
+	slog.Info("disconnected", "host", m.host)
+	m.requests.clear()
+	m.sshClient.Close()
+	m.disconnected = true
+	m.connectErr = nil
+	m.loading = nil
+	m.List.StopSpinner()
+}
+
+// Tell if reconnecting failed because the server asks questions, which
+// aren't asked in the background
+func needsAnswers(err error) bool {
+	return errors.Is(err, ssh.ErrNeedsAnswers)
+}
+
+// Dial the connection again in the background, esc cancels it. When the
+// server asks questions the terminal is released to answer them.
+func (m *Model) reconnect() tea.Cmd {
+	if needsAnswers(m.connectErr) {
+		m.connectErr = nil
+		connection := &reconnection{host: m.host, connect: m.connect}
+		return tea.Exec(connection, func(err error) tea.Msg {
+			return reconnectedMsg{sshClient: connection.sshClient, sftpClient: connection.sftpClient, err: err, byUser: true}
+		})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelConnect = cancel
+	m.connectErr = nil
+	connect := m.connect
+	return func() tea.Msg {
+		defer cancel()
+		sshClient, sftpClient, err := connect(ctx, true)
+		if err != nil {
+			return reconnectedMsg{err: err, byUser: true}
+		}
+		return reconnectedMsg{sshClient: sshClient, sftpClient: sftpClient, byUser: true}
+	}
+}
+
+// Reconnects with the terminal released, like a new tab, so the questions
+// of the server can be answered
+type reconnection struct {
+	host       string
+	connect    connector
+	sshClient  *gossh.Client
+	sftpClient *sftp.Client
+	stderr     io.Writer
+}
+
+func (c *reconnection) Run() error {
+	fmt.Fprintf(c.stderr, "Reconnecting to %s... (ctrl+c to cancel)\n", c.host)
+	// The terminal is released, ctrl+c sends the interrupt signal
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	var err error
+	c.sshClient, c.sftpClient, err = c.connect(ctx, false)
+	return err
+}
+
+func (c *reconnection) SetStdin(io.Reader) {}
+
+func (c *reconnection) SetStdout(io.Writer) {}
+
+func (c *reconnection) SetStderr(w io.Writer) {
+	c.stderr = w
+}
+
+// Handle the key presses while disconnected from the server
+func (m Model) updateDisconnected(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		if m.cancelConnect != nil {
+			m.cancelConnect()
+		}
+		return m, tea.Quit
+	case "esc":
+		if m.cancelConnect != nil {
+			m.cancelConnect()
+		}
+	case "r", "enter":
+		if m.cancelConnect == nil {
+			return m, m.reconnect()
+		}
+	}
+	return m, nil
+}
+
+// Render the choices of the user when disconnected in the middle of the screen
+func (m Model) disconnectedView() string {
+	lines := []string{fmt.Sprintf("Disconnected from %s", m.host)}
+	switch {
+	case needsAnswers(m.connectErr):
+		lines = append(lines, "", brokenLinkStyle(ssh.ErrNeedsAnswers.Error()))
+	case m.connectErr != nil:
+		lines = append(lines, "", brokenLinkStyle(m.connectErr.Error()))
+	}
+	if m.cancelConnect != nil {
+		lines = append(lines, "", statusMessageStyle("Reconnecting… esc to cancel"))
+	} else {
+		help := []string{"[r] reconnect", "[q] quit"}
+		if needsAnswers(m.connectErr) {
+			help[0] = "[r] reconnect and answer"
+		}
+		if keys.NewTab.Enabled() {
+			help = append(help, fmt.Sprintf("[%s] connect to another host or profile", strings.Join(keys.NewTab.Keys(), "/")))
+		}
+		lines = append(lines, "", statusMessageStyle(strings.Join(help, " · ")))
+	}
+
+	height := m.height
+	footer := ""
+	if m.promptAction != noPrompt {
+		footer = m.prompt.View()
+		height--
+	}
+	modal := lipgloss.Place(m.width, height, lipgloss.Center, lipgloss.Center, modalStyle.Render(strings.Join(lines, "\n")))
+	return lipgloss.JoinVertical(lipgloss.Left, modal, footer)
+}
@@ -0,0 +1,327 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/chunked"
+	"github.com/guglielmobartelloni/sftp-tui/conflict"
+	"github.com/guglielmobartelloni/sftp-tui/localpath"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+)
+
+// A remote file to download and where to save it
+type download struct {
+	remotePath string
+	localPath  string
+	size       int64
+	modTime    time.Time // of the remote file, zero when unknown
+	open       bool      // whether to open the file once downloaded
+	resume     bool      // whether the local file is continued
+}
+
+// Queue the download of the files among the items into the local directory,
+// unmarking them. Broken symlinks are skipped, for the other ones the user
+// chooses between downloading the targets and skipping them. The user also
+// chooses how to download the directories.
+func (m *Model) downloadFiles(items []*item, localDir string) tea.Cmd {
+	var dirs, files []*item
+	for _, i := range items {
+		if i.isDir() && !i.isSymlink() {
+			i.marked = false
+			dirs = append(dirs, i)
+		} else {
+			files = append(files, i)
+		}
+	}
+	if len(dirs) > 0 {
+		question := fmt.Sprintf("%s is a directory", dirs[0].rawValue.Name())
+		if len(dirs) > 1 {
+			question = fmt.Sprintf("%d of the items are directories", len(dirs))
+		}
+		m.askDirTransfer(
+			question,
+			true,
+			func(m *Model, mode dirTransfer) tea.Cmd {
+				cmd := m.downloadDirs(dirs, localDir, mode)
+				if len(files) == 0 {
+					return cmd
+				}
+				return tea.Batch(cmd, m.downloadFiles(files, localDir))
+			},
+			func(m *Model) tea.Cmd {
+				return m.downloadFiles(files, localDir)
+			},
+		)
+		return nil
+	}
+
+	var downloads, links []download
+	broken := 0
+	names := localNames{}
+	for _, i := range items {
+		i.marked = false
+		switch {
+		case i.isDir():
+			continue
+		case i.isBroken():
+			broken++
+			continue
+		}
+		d := download{
+			remotePath: m.remote.Join(m.currentDir, i.rawValue.Name()),
+			localPath:  names.claim(filepath.Join(localDir, localpath.Name(i.rawValue.Name()))),
+			size:       i.size(),
+			modTime:    i.modTime(),
+		}
+		if i.isSymlink() {
+			links = append(links, d)
+		} else {
+			downloads = append(downloads, d)
+		}
+	}
+
+	if len(links) == 0 {
+		return m.startDownloads(downloads, broken)
+	}
+	question := fmt.Sprintf("%s is a symlink to %s", filepath.Base(links[0].localPath), items[0].linkTarget)
+	if len(links) > 1 || len(items) > 1 {
+		question = fmt.Sprintf("%d of the files are symlinks", len(links))
+	}
+	m.askChoice(
+		question,
+		choice{key: "d", label: "download the targets", action: func(m *Model) tea.Cmd {
+			return m.startDownloads(append(downloads, links...), broken)
+		}},
+		choice{key: "s", label: "skip the links", action: func(m *Model) tea.Cmd {
+			return m.startDownloads(downloads, broken)
+		}},
+	)
+	return nil
+}
+
+// Queue the downloads telling how many broken symlinks were skipped
+func (m *Model) startDownloads(downloads []download, broken int) tea.Cmd {
+	status := fmt.Sprintf("Queued %d downloads", len(downloads))
+	if len(downloads) == 0 {
+		status = "No files to download"
+	}
+	if broken > 0 {
+		status += fmt.Sprintf(", skipped %d broken symlinks", broken)
+	}
+	return tea.Batch(
+		m.setStatus(status),
+		m.queueDownloads(downloads),
+	)
+}
+
+// Ask where to download the highlighted file, or the marked ones
+func (m *Model) openDownloadPrompt() tea.Cmd {
+	items := m.targetItems()
+	switch {
+	case len(items) == 0:
+		return nil
+	case len(items) == 1 && !items[0].marked:
+		return m.openPrompt(downloadPrompt, "Download to: ", filepath.Join(m.localDir, localpath.Name(items[0].rawValue.Name())))
+	default:
+		return m.openPrompt(downloadPrompt, fmt.Sprintf("Download %d items to: ", len(items)), m.localDir)
+	}
+}
+
+// Download the items to the destination: a directory, or the new name of
+// the file when there's a single one
+func (m *Model) downloadTo(items []*item, destination string) tea.Cmd {
+	destination = expandLocalPath(destination, m.localDir)
+	if destInfo, err := os.Stat(destination); err == nil && destInfo.IsDir() {
+		return m.downloadFiles(items, destination)
+	}
+	if len(items) != 1 || items[0].isDir() {
+		return reportError(fmt.Errorf("%s is not a directory", destination))
+	}
+
+	i := items[0]
+	i.marked = false
+	if i.isBroken() {
+		return reportError(fmt.Errorf("%s is a broken symlink", i.rawValue.Name()))
+	}
+	return m.queueDownloads([]download{{
+		remotePath: m.remote.Join(m.currentDir, i.rawValue.Name()),
+		localPath:  destination,
+		size:       i.size(),
+		modTime:    i.modTime(),
+	}})
+}
+
+// Change the local directory of the downloads and uploads
+func (m *Model) setLocalDir(dirPath string) tea.Cmd {
+	dirPath = expandLocalPath(dirPath, m.localDir)
+	if dirInfo, err := os.Stat(dirPath); err != nil || !dirInfo.IsDir() {
+		return reportError(fmt.Errorf("%s is not a directory", dirPath))
+	}
+	m.localDir = dirPath
+	return m.setStatus(fmt.Sprintf("Local directory %s", dirPath))
+}
+
+// Queue the downloads, the ones whose local file already exists follow the
+// conflict policy
+func (m *Model) queueDownloads(downloads []download) tea.Cmd {
+	return m.queueDownloadsWith(downloads, m.onConflict)
+}
+
+// Queue the downloads resolving the conflicts with the policy. Asking stops
+// at the first conflict, the next files are queued once answered.
+func (m *Model) queueDownloadsWith(downloads []download, policy conflict.Policy) tea.Cmd {
+	var cmds []tea.Cmd
+	skipped := 0
+	for i, d := range downloads {
+		localInfo, err := os.Stat(d.localPath)
+		if err != nil {
+			// The download interrupted before goes on
+			d.resume = partLeft(d)
+			cmds = append(cmds, m.downloadFile(d))
+			continue
+		}
+		if policy == conflict.Ask {
+			d, rest := d, downloads[i+1:]
+			m.askConflict(d.localPath, len(rest) > 0, func(m *Model, picked conflict.Policy, all bool) tea.Cmd {
+				return tea.Batch(m.resolveDownload(d, localInfo, picked), m.queueDownloadsWith(rest, nextPolicy(picked, all)))
+			})
+			break
+		}
+		cmd := m.resolveDownload(d, localInfo, policy)
+		if cmd == nil {
+			skipped++
+		}
+		cmds = append(cmds, cmd)
+	}
+	return tea.Batch(append(cmds, m.skippedStatus(skipped))...)
+}
+
+// Tell if an interrupted download of the file left a partial one to resume
+func partLeft(d download) bool {
+	partInfo, err := os.Stat(chunked.PartPath(d.localPath))
+	return err == nil && partInfo.Mode().IsRegular() && partInfo.Size() <= d.size
+}
+
+// Download the file whose local file exists as the policy says, nil when
+// it's skipped
+func (m *Model) resolveDownload(d download, localInfo fs.FileInfo, policy conflict.Policy) tea.Cmd {
+	switch policy.Decide(d.size, d.modTime, localInfo) {
+	case conflict.SkipFile:
+		return nil
+	case conflict.RenameFile:
+		d.localPath = uniqueLocalPath(d.localPath)
+	case conflict.ResumeFile:
+		d.resume = true
+	}
+	return m.downloadFile(d)
+}
+
+// Donwload a file based on the path provided. It's written next to it with
+// the .part extension and renamed once complete, and verified.
+func (m *Model) downloadFile(d download) tea.Cmd {
+	sshClient, verify, open := m.sshClient, m.verify, d.open || m.openDownloads
+	partPath := chunked.PartPath(d.localPath)
+	// Copy from the start, or resume the copy already there
+	copyWith := func(remote remotefs.FileSystem, resume bool) func(counter io.Writer) error {
+		copyFunc := func(counter io.Writer) error {
+			if resume {
+				// The local file chosen to be resumed is continued as the
+				// partial one, a retry continues the partial one
+				if _, err := os.Stat(partPath); d.resume && os.IsNotExist(err) {
+					if err := os.Rename(d.localPath, partPath); err != nil {
+						return err
+					}
+				}
+				return resumeDownload(remote, d.remotePath, partPath, counter)
+			}
+			srcFile, err := remote.Open(d.remotePath)
+			if err != nil {
+				return err
+			}
+			defer srcFile.Close()
+
+			destFile, err := os.Create(partPath)
+			if err != nil {
+				return err
+			}
+			defer destFile.Close()
+
+			// Instrument with our counter.
+			return chunked.Copy(srcFile, destFile, 0, counter)
+		}
+		if verify {
+			copyFunc = verifiedCopy(sshClient, remote, partPath, d.remotePath, copyFunc)
+		}
+		copyPart := copyFunc
+		copyFunc = func(counter io.Writer) error {
+			if err := copyPart(counter); err != nil {
+				return err
+			}
+			return os.Rename(partPath, d.localPath)
+		}
+		if open {
+			copyFile := copyFunc
+			copyFunc = func(counter io.Writer) error {
+				if err := copyFile(counter); err != nil {
+					return err
+				}
+				return openWithSystem(d.localPath)
+			}
+		}
+		return copyFunc
+	}
+	return m.queue.add(&transfer{
+		name:        filepath.Base(d.localPath),
+		source:      d.remotePath,
+		destination: d.localPath,
+		file:        true,
+		resumed:     d.resume,
+		total:       d.size,
+		copyFunc:    copyWith(m.remote, d.resume),
+		resumeFunc: func(remote remotefs.FileSystem, counter io.Writer) error {
+			return copyWith(remote, true)(counter)
+		},
+	})
+}
+
+// The local paths taken by the files of a batch: two remote names differing
+// only by case, on Windows and macOS, or by the characters Windows doesn't
+// allow, would go to the same file
+type localNames map[string]bool
+
+// Find a name like "file (1).txt" that isn't used in the directory of the path
+func uniqueLocalPath(localPath string) string {
+	dir := filepath.Dir(localPath)
+	return filepath.Join(dir, conflict.UniqueName(filepath.Base(localPath), func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return !os.IsNotExist(err)
+	}))
+}
+
+// Get the local path of the file of the batch, renamed like "file (1).txt"
+// when another file of the batch took it
+func (n localNames) claim(localPath string) string {
+	if n[localpath.Key(localPath)] {
+		dir := filepath.Dir(localPath)
+		localPath = filepath.Join(dir, conflict.UniqueName(filepath.Base(localPath), func(name string) bool {
+			return n[localpath.Key(filepath.Join(dir, name))]
+		}))
+	}
+	n[localpath.Key(localPath)] = true
+	return localPath
+}
+
+// Expand the leading ~ and make the path absolute using the directory
+func expandLocalPath(localPath, dir string) string {
+	localPath = localpath.ExpandHome(localPath)
+	if !filepath.IsAbs(localPath) {
+		localPath = filepath.Join(dir, localPath)
+	}
+	return localPath
+}
@@ -0,0 +1,60 @@
+package tui
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Changes listed in the report of the dry run, the others are only logged
+const maxDryRunLines = 20
+
+// Message with the changes an operation would have made in a dry run
+type dryRunMsg struct {
+	lines []string
+}
+
+// Report the changes in a modal, and in the log pane, instead of making
+// them; the ones reported while it's open are added to it
+func (m *Model) reportDryRun(lines ...string) {
+	if m.info == "" {
+		m.dryRunLines = nil
+	}
+	for _, line := range lines {
+		slog.Info("dry run", "change", line)
+		m.log.add("Dry run: "+line, false)
+	}
+	m.dryRunLines = append(m.dryRunLines, lines...)
+
+	report := []string{"Dry run, nothing has been changed", ""}
+	shown := m.dryRunLines
+	if len(shown) > maxDryRunLines {
+		shown = shown[:maxDryRunLines]
+	}
+	report = append(report, shown...)
+	if hidden := len(m.dryRunLines) - len(shown); hidden > 0 {
+		report = append(report, linkTargetStyle(fmt.Sprintf("… and %d more, all in the log", hidden)))
+	}
+	m.info = strings.Join(report, "\n")
+}
+
+// Get the command reporting the changes in a dry run
+func dryRun(lines ...string) tea.Cmd {
+	return func() tea.Msg {
+		return dryRunMsg{lines: lines}
+	}
+}
+
+// Describe the copy made by the transfer
+func (t *transfer) describe() string {
+	verb := "download"
+	if t.upload {
+		verb = "upload"
+	}
+	if t.file {
+		return fmt.Sprintf("%s %s to %s (%s)", verb, t.source, t.destination, ConvertBytesToSizeString(t.total))
+	}
+	return fmt.Sprintf("%s %s to %s", verb, t.source, t.destination)
+}
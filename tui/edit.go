@@ -0,0 +1,129 @@
+package tui
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/localpath"
+)
+
+// Message sent when the file to edit has been downloaded
+type editFileMsg struct {
+	remotePath string
+	localPath  string
+	checksum   []byte // checksum of the file before editing
+}
+
+// Message sent when the editor is closed
+type editorClosedMsg struct {
+	editFileMsg
+	err error
+}
+
+// Download the remote file into a temporary directory to edit it
+func (m *Model) editFile(fileInfo fs.FileInfo) tea.Cmd {
+	remote := m.remote
+	remotePath := remote.Join(m.currentDir, fileInfo.Name())
+	return func() tea.Msg {
+		tempDir, err := os.MkdirTemp("", "sftp-tui-edit-")
+		if err != nil {
+			return errorMsg{err: err}
+		}
+		// Keep the name so the editor recognizes the file type
+		localPath := filepath.Join(tempDir, localpath.Name(fileInfo.Name()))
+
+		srcFile, err := remote.Open(remotePath)
+		if err != nil {
+			os.RemoveAll(tempDir)
+			return errorMsg{err: fmt.Errorf("opening %s failed: %v", fileInfo.Name(), err)}
+		}
+		defer srcFile.Close()
+		destFile, err := os.Create(localPath)
+		if err != nil {
+			os.RemoveAll(tempDir)
+			return errorMsg{err: err}
+		}
+		defer destFile.Close()
+		if _, err := io.Copy(destFile, srcFile); err != nil {
+			os.RemoveAll(tempDir)
+			return errorMsg{err: fmt.Errorf("downloading %s failed: %v", fileInfo.Name(), err)}
+		}
+
+		checksum, err := fileChecksum(localPath)
+		if err != nil {
+			os.RemoveAll(tempDir)
+			return errorMsg{err: err}
+		}
+		return editFileMsg{remotePath: remotePath, localPath: localPath, checksum: checksum}
+	}
+}
+
+// Suspend the tui and open the downloaded file with the editor of the user
+func openEditor(msg editFileMsg) tea.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// The editor may come with its own arguments, like "code --wait"
+	args := append(strings.Fields(editor), msg.localPath)
+
+	return tea.ExecProcess(exec.Command(args[0], args[1:]...), func(err error) tea.Msg {
+		return editorClosedMsg{editFileMsg: msg, err: err}
+	})
+}
+
+// Upload the edited file if it changed, then remove the temporary copy
+func (m *Model) saveEditedFile(msg editorClosedMsg) tea.Cmd {
+	remote := m.remote
+	refresh := m.changeDir(m.currentDir, fmt.Sprintf("Saved %s", path.Base(msg.remotePath)))
+	return func() tea.Msg {
+		tempDir := filepath.Dir(msg.localPath)
+		if msg.err != nil {
+			os.RemoveAll(tempDir)
+			return errorMsg{err: fmt.Errorf("running the editor failed: %v", msg.err)}
+		}
+		checksum, err := fileChecksum(msg.localPath)
+		if err != nil {
+			os.RemoveAll(tempDir)
+			return errorMsg{err: err}
+		}
+		if bytes.Equal(checksum, msg.checksum) {
+			os.RemoveAll(tempDir)
+			return statusMsg(fmt.Sprintf("%s not changed", path.Base(msg.remotePath)))
+		}
+
+		if err := uploadFile(remote, msg.localPath, msg.remotePath, io.Discard); err != nil {
+			// Keep the edited copy so the changes aren't lost
+			return errorMsg{err: fmt.Errorf("saving %s failed, the edited copy is in %s: %v", path.Base(msg.remotePath), msg.localPath, err)}
+		}
+		os.RemoveAll(tempDir)
+		return refresh()
+	}
+}
+
+// Compute the sha256 of the local file
+func fileChecksum(localPath string) ([]byte, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
@@ -0,0 +1,21 @@
+package tui
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// Message reporting the failure of an operation. The error is shown in the
+// footer until the next key press, the tui keeps running.
+type errorMsg struct {
+	err error
+}
+
+// Command that reports the error to the tui
+func reportError(err error) tea.Cmd {
+	return func() tea.Msg {
+		return errorMsg{err: err}
+	}
+}
+
+// Render the error shown in the footer
+func (m Model) errorView() string {
+	return errorMessageStyle("Error: " + m.err.Error())
+}
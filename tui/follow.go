@@ -0,0 +1,239 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+)
+
+const (
+	// Bytes of the end of the file shown when it starts being followed
+	followTail = 16 * 1024
+	// Bytes read from the followed file at a time
+	followRead = 1024 * 1024
+	// Lines kept by the follow view, the oldest ones are dropped
+	followLines = 5000
+	// How often the followed file is checked for new lines
+	followInterval = time.Second
+)
+
+// A remote file whose new lines are shown as they are written, like tail -f
+type follower struct {
+	remotePath string
+	offset     int64 // bytes read so far, -1 before the first read
+	lines      []string
+	partial    string // the last line, until it ends
+	paused     bool   // whether the file isn't read
+	waiting    bool   // whether a read is scheduled
+	term       string // the searched text
+	match      int    // the line of the last match, -1 when none
+}
+
+// Message sent when it's time to read the followed file again
+type followTickMsg struct {
+	follower *follower
+}
+
+// Message with the bytes added to the followed file
+type followMsg struct {
+	follower  *follower
+	start     int64 // where the bytes have been read, 0 when truncated
+	data      []byte
+	skipFirst bool // whether the first line has been cut, it's left out
+	err       error
+}
+
+// Show the highlighted file in the preview pane, reading its new lines as
+// they are written
+func (m *Model) followFile(fileInfo fs.FileInfo) tea.Cmd {
+	m.openPreview(previewMsg{name: fileInfo.Name()})
+	m.follow = &follower{remotePath: m.remote.Join(m.currentDir, fileInfo.Name()), offset: -1, match: -1, waiting: true}
+	return readFollowed(m.remote, m.follow, m.follow.offset)
+}
+
+// Read the bytes added to the file after the offset in the background. A
+// file shorter than the offset has been truncated and is read from the start.
+func readFollowed(remote remotefs.FileSystem, f *follower, offset int64) tea.Cmd {
+	remotePath := f.remotePath
+	return func() tea.Msg {
+		file, err := remote.Open(remotePath)
+		if err != nil {
+			return followMsg{follower: f, start: offset, err: err}
+		}
+		defer file.Close()
+		fileInfo, err := file.Stat()
+		if err != nil {
+			return followMsg{follower: f, start: offset, err: err}
+		}
+
+		msg := followMsg{follower: f, start: offset}
+		switch {
+		case offset < 0:
+			msg.start = 0
+			if fileInfo.Size() > followTail {
+				msg.start, msg.skipFirst = fileInfo.Size()-followTail, true
+			}
+		case fileInfo.Size() < offset:
+			msg.start = 0
+		}
+		if _, err := file.Seek(msg.start, io.SeekStart); err != nil {
+			return followMsg{follower: f, start: offset, err: err}
+		}
+		msg.data, msg.err = io.ReadAll(io.LimitReader(file, followRead))
+		return msg
+	}
+}
+
+// Wait before reading the followed file again
+func followTick(f *follower) tea.Cmd {
+	return tea.Tick(followInterval, func(time.Time) tea.Msg {
+		return followTickMsg{follower: f}
+	})
+}
+
+// Read the followed file again, unless it has been closed or paused
+func (m *Model) followTick(msg followTickMsg) tea.Cmd {
+	f := msg.follower
+	if f != m.follow || f.paused {
+		f.waiting = false
+		return nil
+	}
+	return readFollowed(m.remote, f, f.offset)
+}
+
+// Add the lines read to the follow view, it keeps scrolling while it shows
+// the end of the file
+func (m *Model) addFollowed(msg followMsg) tea.Cmd {
+	f := msg.follower
+	if f != m.follow {
+		return nil
+	}
+	if msg.err != nil {
+		m.setError(fmt.Errorf("following %s failed: %v", m.previewName, msg.err))
+		return followTick(f)
+	}
+
+	if f.offset >= 0 && msg.start < f.offset {
+		f.lines = append(f.lines, markedItemStyle("… the file has been truncated"))
+		f.partial = ""
+	}
+	f.offset = msg.start + int64(len(msg.data))
+	data := msg.data
+	if msg.skipFirst {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+	lines := strings.Split(f.partial+strings.ReplaceAll(string(data), "\t", "    "), "\n")
+	for _, line := range lines[:len(lines)-1] {
+		f.lines = append(f.lines, displayName(strings.TrimSuffix(line, "\r")))
+	}
+	f.partial = lines[len(lines)-1]
+	if len(f.lines) > followLines {
+		f.lines = f.lines[len(f.lines)-followLines:]
+		f.match = -1
+	}
+
+	atBottom := m.preview.AtBottom()
+	m.showFollowed()
+	if atBottom {
+		m.preview.GotoBottom()
+	}
+	// Catch up without waiting when there's more to read
+	if len(msg.data) == followRead {
+		return readFollowed(m.remote, f, f.offset)
+	}
+	return followTick(f)
+}
+
+// Put the followed lines in the preview pane, highlighting the searched text
+func (m *Model) showFollowed() {
+	f := m.follow
+	lines := make([]string, 0, len(f.lines)+1)
+	for _, line := range append(f.lines[:len(f.lines):len(f.lines)], displayName(f.partial)) {
+		if f.term != "" {
+			line = strings.ReplaceAll(line, f.term, matchStyle(f.term))
+		}
+		lines = append(lines, line)
+	}
+	m.previewContent = strings.Join(lines, "\n")
+	yOffset := m.preview.YOffset
+	m.resize()
+	m.preview.SetYOffset(yOffset)
+}
+
+// Handle the key presses of the follow view, the others scroll the preview
+func (m *Model) updateFollow(msg tea.KeyMsg) (tea.Cmd, bool) {
+	f := m.follow
+	switch msg.String() {
+	case " ":
+		f.paused = !f.paused
+		if !f.paused && !f.waiting {
+			f.waiting = true
+			return readFollowed(m.remote, f, f.offset), true
+		}
+		return nil, true
+	case "/":
+		return m.openPrompt(followSearchPrompt, "Search: ", f.term), true
+	case "n":
+		m.findFollowed(1)
+		return nil, true
+	case "N":
+		m.findFollowed(-1)
+		return nil, true
+	}
+	return nil, false
+}
+
+// Highlight the text in the followed lines and show the last line with it
+func (m *Model) searchFollowed(term string) {
+	m.follow.term = term
+	m.follow.match = len(m.follow.lines)
+	m.showFollowed()
+	if !m.findFollowed(-1) {
+		m.follow.match = -1
+	}
+}
+
+// Scroll to the next line with the searched text in the direction, 1 down
+// and -1 up. Tell if there's one.
+func (m *Model) findFollowed(direction int) bool {
+	f := m.follow
+	if f.term == "" {
+		return false
+	}
+	for i := f.match + direction; i >= 0 && i < len(f.lines); i += direction {
+		if !strings.Contains(f.lines[i], f.term) {
+			continue
+		}
+		f.match = i
+		// The long lines are wrapped, the line starts below all the previous ones
+		wrap := lipgloss.NewStyle().Width(m.preview.Width)
+		yOffset := 0
+		for _, line := range f.lines[:i] {
+			yOffset += lipgloss.Height(wrap.Render(line))
+		}
+		m.preview.SetYOffset(yOffset)
+		return true
+	}
+	return false
+}
+
+// Describe the state of the follow view for the title of the preview
+func (f *follower) String() string {
+	state := "following"
+	if f.paused {
+		state = "paused"
+	}
+	if f.term != "" && f.match < 0 {
+		state += fmt.Sprintf(" · %q not found", f.term)
+	}
+	return state
+}
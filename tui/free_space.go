@@ -0,0 +1,51 @@
+package tui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Message sent when the free space for an upload has been checked
+type freeSpaceMsg struct {
+	size   int64                  // bytes to upload
+	free   uint64                 // bytes available on the server
+	fits   bool                   // whether the upload fits, or the free space is unknown
+	upload func(m *Model) tea.Cmd // starts the upload
+}
+
+// Check in the background that the bytes fit in the filesystem of the
+// current directory before uploading them, a full filesystem would leave
+// half written files. Without the statvfs extension the upload just starts.
+func (m *Model) checkFreeSpace(size int64, upload func(m *Model) tea.Cmd) tea.Cmd {
+	sftpClient, dirPath := m.SftpClient, m.currentDir
+	if sftpClient == nil {
+		return upload(m)
+	}
+	if _, ok := sftpClient.HasExtension("statvfs@openssh.com"); !ok {
+		return upload(m)
+	}
+	return func() tea.Msg {
+		stat, err := sftpClient.StatVFS(dirPath)
+		if err != nil {
+			return freeSpaceMsg{size: size, fits: true, upload: upload}
+		}
+		free := stat.Bavail * stat.Frsize
+		return freeSpaceMsg{size: size, free: free, fits: uint64(size) <= free, upload: upload}
+	}
+}
+
+// Start the upload when it fits, otherwise ask whether to upload anyway
+func (m *Model) handleFreeSpace(msg freeSpaceMsg) tea.Cmd {
+	if msg.fits {
+		return msg.upload(m)
+	}
+	m.askChoice(
+		fmt.Sprintf("The upload needs %s but only %s are free on the server",
+			ConvertBytesToSizeString(msg.size),
+			ConvertBytesToSizeString(int64(msg.free))),
+		choice{key: "u", label: "upload anyway", action: msg.upload},
+		choice{key: "c", label: "cancel", action: func(*Model) tea.Cmd { return nil }},
+	)
+	return nil
+}
@@ -0,0 +1,50 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+	"github.com/guglielmobartelloni/sftp-tui/throttle"
+)
+
+// Get the scheme of the urls of the files of the ftp server
+func ftpScheme(security remotefs.FTPSecurity) string {
+	switch security {
+	case remotefs.FTPImplicitTLS:
+		return "ftps"
+	case remotefs.FTPExplicitTLS:
+		return "ftpes"
+	}
+	return "ftp"
+}
+
+// Create the model browsing the files of the ftp server, the commands
+// needing a shell report there's none
+func newFTPModel(ctx context.Context, options remotefs.FTPOptions, settings Settings, limiter *throttle.Limiter) (Model, error) {
+	remote, err := remotefs.DialFTP(ctx, options)
+	if err != nil {
+		return Model{}, err
+	}
+	currentDir, items, err := openStartDir(remote, remoteStartDir(settings.RemoteDir))
+	if err != nil {
+		remote.Close()
+		return Model{}, err
+	}
+	m, err := newTabModel(remote, currentDir, items, settings, limiter)
+	if err != nil {
+		remote.Close()
+		return Model{}, err
+	}
+	m.host = options.Host
+	m.port = strconv.Itoa(options.Port)
+	m.user = options.User
+	m.scheme = ftpScheme(options.Security)
+	m.uploads = settings.Upload
+	m.banner = fmt.Sprintf("Connected to %s with %s", options.Host, m.scheme)
+	if options.Security == remotefs.FTPPlain {
+		m.banner += ", without encryption"
+	}
+	return m, nil
+}
@@ -0,0 +1,74 @@
+package tui
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Message with the completed value of the go to prompt
+type completionMsg struct {
+	value      string   // the completed value
+	candidates []string // the directories matching, when more than one
+}
+
+// Jump to the remote directory, absolute or relative to the current one.
+// A leading ~ stands for the home directory.
+func (m *Model) goTo(dirPath string) tea.Cmd {
+	return m.changeDir(m.resolvePath(dirPath), fmt.Sprintf("Entered %s", dirPath))
+}
+
+// Make the remote path absolute, or relative to the home directory when it
+// starts with ~, the sftp session starts there
+func (m *Model) resolvePath(remotePath string) string {
+	switch {
+	case remotePath == "~" || strings.HasPrefix(remotePath, "~/"):
+		return "." + remotePath[1:]
+	case path.IsAbs(remotePath):
+		return remotePath
+	default:
+		return m.remote.Join(m.currentDir, remotePath)
+	}
+}
+
+// Complete the last element of the path with the remote directories
+func (m *Model) completePath(value string) tea.Cmd {
+	remote := m.remote
+	dir, _ := path.Split(value)
+	remoteDir := m.resolvePath(dir)
+	return func() tea.Msg {
+		dir, prefix := path.Split(value)
+		entries, err := remote.ReadDir(remoteDir)
+		if err != nil {
+			return errorMsg{err: err}
+		}
+
+		var candidates []string
+		for _, entry := range entries {
+			if entry.IsDir() && strings.HasPrefix(entry.Name(), prefix) {
+				candidates = append(candidates, entry.Name())
+			}
+		}
+		switch len(candidates) {
+		case 0:
+			return nil
+		case 1:
+			return completionMsg{value: dir + candidates[0] + "/"}
+		default:
+			return completionMsg{value: dir + commonPrefix(candidates), candidates: candidates}
+		}
+	}
+}
+
+// Find the longest prefix shared by the strings
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
@@ -0,0 +1,192 @@
+package tui
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+	"golang.org/x/crypto/ssh"
+)
+
+// Characters of the matching line shown in the results
+const grepLineWidth = 200
+
+// A line of a file matching the searched pattern
+type grepMatch struct {
+	remotePath string
+	name       string // the path relative to the searched directory
+	line       int
+	text       string
+}
+
+func (g grepMatch) Title() string {
+	return fileItemStyle(displayName(g.name)) + linkTargetStyle(fmt.Sprintf(":%d", g.line))
+}
+
+func (g grepMatch) Description() string { return displayName(g.text) }
+
+func (g grepMatch) FilterValue() string { return g.name + ":" + g.text }
+
+// Look for the regular expression in the content of the files under the
+// current directory, with grep on the server or reading the files when it
+// can't run. The matching lines are streamed into the search list.
+func (m *Model) startGrep(pattern string) tea.Cmd {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return reportError(fmt.Errorf("searching %q failed: %v", pattern, err))
+	}
+	ctx, id := m.newSearch(pattern, true)
+	sshClient, remote, root := m.sshClient, m.remote, m.currentDir
+	return func() tea.Msg {
+		updates := make(chan tea.Msg)
+		go func() {
+			defer close(updates)
+			sender := newSearchSender(ctx, id, updates)
+			err := remoteGrep(ctx, sshClient, root, pattern, sender)
+			if errors.Is(err, errNoCommand) {
+				err = scanGrep(ctx, remote, root, re, sender)
+			}
+			if ctx.Err() == nil {
+				sender.send(true, err)
+			}
+		}()
+		return <-updates
+	}
+}
+
+// Error of a command missing on the server
+var errNoCommand = errors.New("command not found")
+
+// Run grep on the server, its matches are added to the sender
+func remoteGrep(ctx context.Context, sshClient *ssh.Client, root, pattern string, sender *searchSender) error {
+	session, err := newSession(sshClient)
+	if err != nil {
+		return errNoCommand
+	}
+	defer session.Close()
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	// The names are followed by a NUL, they may contain colons. The
+	// unreadable files are skipped silently.
+	command := fmt.Sprintf("cd %s && grep -rnsIE --null -e %s .", shellQuote(root), shellQuote(pattern))
+	slog.Info("remote command", "command", command)
+	if err := session.Start(command); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Closing the session stops grep
+			session.Close()
+		case <-done:
+		}
+	}()
+
+	// The name is read up to its NUL first, it may contain newlines too
+	reader := bufio.NewReader(stdout)
+	for {
+		name, err := reader.ReadString('\x00')
+		if err != nil {
+			break
+		}
+		rest, err := reader.ReadString('\n')
+		number, text, _ := strings.Cut(strings.TrimSuffix(rest, "\n"), ":")
+		lineNumber, _ := strconv.Atoi(number)
+		name = strings.TrimPrefix(strings.TrimSuffix(name, "\x00"), "./")
+		if !sender.add(newGrepMatch(path.Join(root, name), name, lineNumber, text)) {
+			return nil
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	err = session.Wait()
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		switch {
+		case exitErr.ExitStatus() == 1:
+			// No match
+			return nil
+		case exitErr.ExitStatus() == 2 && stderr.Len() == 0:
+			// Some files couldn't be read
+			return nil
+		case exitErr.ExitStatus() == 127:
+			return errNoCommand
+		}
+	}
+	return commandError(err, &stderr)
+}
+
+// Read the files looking for the regular expression when grep can't run,
+// the binary files are skipped
+func scanGrep(ctx context.Context, remote remotefs.FileSystem, root string, re *regexp.Regexp, sender *searchSender) error {
+	walker := remotefs.Walk(remote, root)
+	for walker.Step() {
+		if ctx.Err() != nil {
+			return nil
+		}
+		// Skip the unreadable directories
+		if walker.Err() != nil || !walker.Stat().Mode().IsRegular() {
+			continue
+		}
+		file, err := remote.Open(walker.Path())
+		if err != nil {
+			continue
+		}
+		name := strings.TrimPrefix(walker.Path(), strings.TrimSuffix(root, "/")+"/")
+		ok := scanFile(file, re, func(lineNumber int, text string) bool {
+			return sender.add(newGrepMatch(walker.Path(), name, lineNumber, text))
+		})
+		file.Close()
+		if !ok {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Call found with the lines matching the regular expression until it returns
+// false. Tell if the scan went on to the end of the file.
+func scanFile(r io.Reader, re *regexp.Regexp, found func(lineNumber int, text string) bool) bool {
+	reader := bufio.NewReader(r)
+	if start, _ := reader.Peek(512); bytes.IndexByte(start, 0) != -1 {
+		return true
+	}
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadString('\n')
+		if text := strings.TrimSuffix(line, "\n"); re.MatchString(text) {
+			if !found(lineNumber, text) {
+				return false
+			}
+		}
+		if err != nil {
+			return true
+		}
+	}
+}
+
+// Create the match, the line is trimmed to fit in the results
+func newGrepMatch(remotePath, name string, line int, text string) grepMatch {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\t", "    "))
+	if runes := []rune(text); len(runes) > grepLineWidth {
+		text = string(runes[:grepLineWidth]) + "…"
+	}
+	return grepMatch{remotePath: remotePath, name: name, line: line, text: text}
+}
@@ -0,0 +1,177 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Message sent when the free space of the remote filesystem has been read
+type diskSpaceMsg struct {
+	path  string // the directory on the filesystem
+	free  uint64 // bytes available to the user
+	total uint64 // size of the filesystem
+}
+
+// Read the free space of the filesystem of the current directory in the
+// background, when the server supports the statvfs extension
+func (m Model) readDiskSpace() tea.Cmd {
+	sftpClient, dirPath := m.SftpClient, m.currentDir
+	// The local files and the ftp servers have no sftp session
+	if sftpClient == nil {
+		return nil
+	}
+	if _, ok := sftpClient.HasExtension("statvfs@openssh.com"); !ok {
+		return nil
+	}
+	return func() tea.Msg {
+		stat, err := sftpClient.StatVFS(dirPath)
+		if err != nil {
+			// The header just goes without it
+			return nil
+		}
+		return diskSpaceMsg{path: dirPath, free: stat.Bavail * stat.Frsize, total: stat.TotalSpace()}
+	}
+}
+
+// Get the paths of the directories leading to the current one, the root first
+func (m Model) crumbs() []string {
+	crumbs := []string{"/"}
+	for _, name := range strings.Split(m.currentDir, "/") {
+		if name != "" {
+			crumbs = append(crumbs, m.remote.Join(crumbs[len(crumbs)-1], name))
+		}
+	}
+	return crumbs
+}
+
+// Select the directories of the path in the header, enter goes to one
+func (m *Model) focusCrumbs() {
+	m.crumb = len(m.crumbs()) - 1
+	m.crumbsFocused = true
+}
+
+// Handle the key presses while a directory of the path is being selected
+func (m Model) updateCrumbs(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	crumbs := m.crumbs()
+	switch msg.String() {
+	case "left", "h":
+		if m.crumb > 0 {
+			m.crumb--
+		}
+	case "right", "l":
+		if m.crumb < len(crumbs)-1 {
+			m.crumb++
+		}
+	case "home":
+		m.crumb = 0
+	case "end":
+		m.crumb = len(crumbs) - 1
+	case "enter":
+		m.crumbsFocused = false
+		return m, m.goTo(crumbs[m.crumb])
+	case "ctrl+c":
+		return m, tea.Quit
+	default:
+		m.crumbsFocused = false
+	}
+	return m, nil
+}
+
+// Render the line above the list: the user, the host, the current path and
+// the free space
+func (m Model) headerView() string {
+	h, _ := docStyle.GetFrameSize()
+	connection, crumbs, first, space := m.headerParts()
+	path := m.crumbsView(crumbs, first)
+
+	gap := m.width - h - lipgloss.Width(connection+path+space)
+	if gap < 0 {
+		gap = 0
+	}
+	return connection + path + strings.Repeat(" ", gap) + space
+}
+
+// Get the pieces of the header: the connection, the directories of the path
+// with the first one shown, and the space on the right
+func (m Model) headerParts() (string, []string, int, string) {
+	h, _ := docStyle.GetFrameSize()
+	width := m.width - h
+
+	connection := statusMessageStyle(fmt.Sprintf("%s@%s", m.user, m.host)) + " "
+	// The local files and the buckets have no user
+	if m.user == "" {
+		connection = statusMessageStyle(m.host) + " "
+	}
+	var space string
+	if m.dryRun {
+		space = linkTargetStyle(" (dry run)")
+	}
+	if m.cached {
+		// Shown until the directory has been read again
+		space += linkTargetStyle(" (cached)")
+	}
+	if m.diskTotal > 0 {
+		space += linkTargetStyle(fmt.Sprintf(" %s free of %s",
+			ConvertBytesToSizeString(int64(m.diskFree)),
+			ConvertBytesToSizeString(int64(m.diskTotal))))
+	}
+
+	// The directories closer to the root make room when the path is too long
+	crumbs := m.crumbs()
+	first := 0
+	for first < len(crumbs)-1 && lipgloss.Width(connection+m.crumbsView(crumbs, first)+space) > width {
+		first++
+	}
+	return connection, crumbs, first, space
+}
+
+// Find the directory of the path in the header at the column, false when
+// the column is out of the path
+func (m Model) crumbAt(x int) (int, bool) {
+	connection, crumbs, first, _ := m.headerParts()
+	separator := lipgloss.Width(crumbSeparator)
+	x -= lipgloss.Width(connection)
+	if first > 0 {
+		x -= lipgloss.Width("…") + separator
+	}
+	for i := first; i < len(crumbs); i++ {
+		width := lipgloss.Width(crumbName(crumbs, i))
+		if x >= 0 && x < width {
+			return i, true
+		}
+		x -= width + separator
+	}
+	return 0, false
+}
+
+// Separator of the directories of the path in the header
+const crumbSeparator = " › "
+
+// Get the name shown for the directory of the path
+func crumbName(crumbs []string, i int) string {
+	if i == 0 {
+		return "/"
+	}
+	return displayName(strings.TrimPrefix(crumbs[i][strings.LastIndex(crumbs[i], "/"):], "/"))
+}
+
+// Render the directories of the path starting from the first one, the
+// selected one is highlighted
+func (m Model) crumbsView(crumbs []string, first int) string {
+	var names []string
+	if first > 0 {
+		names = append(names, "…")
+	}
+	for i := first; i < len(crumbs); i++ {
+		name := crumbName(crumbs, i)
+		if m.crumbsFocused && i == m.crumb {
+			names = append(names, selectedBitStyle(name))
+		} else {
+			names = append(names, dirItemStyle(name))
+		}
+	}
+	return strings.Join(names, linkTargetStyle(crumbSeparator))
+}
@@ -0,0 +1,28 @@
+package tui
+
+import (
+	"math"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Render all the key bindings
+func (m Model) helpView() string {
+	// The columns that don't fit the width go on the next rows
+	helpModel := help.New()
+	helpModel.Width = math.MaxInt32
+	h, _ := docStyle.GetFrameSize()
+	rows := []string{previewTitleStyle.Render("Keys"), ""}
+	var row [][]key.Binding
+	for _, column := range keys.FullHelp() {
+		if len(row) > 0 && lipgloss.Width(helpModel.FullHelpView(append(row, column))) > m.width-h {
+			rows = append(rows, helpModel.FullHelpView(row), "")
+			row = nil
+		}
+		row = append(row, column)
+	}
+	rows = append(rows, helpModel.FullHelpView(row), "", "Press any key to close the help")
+	return docStyle.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
+}
@@ -0,0 +1,108 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// A remote file dumped in hex in the preview pane a page at a time, only
+// the page shown is read
+type hexView struct {
+	remotePath string
+	info       fs.FileInfo
+	offset     int64 // where the page shown starts
+	page       int64 // bytes per page
+}
+
+// Show the highlighted file dumped in hex, from its beginning
+func (m *Model) hexFile(fileInfo fs.FileInfo) tea.Cmd {
+	return m.readHexPage(hexView{
+		remotePath: m.remote.Join(m.currentDir, fileInfo.Name()),
+		info:       fileInfo,
+		page:       m.previewSize,
+	})
+}
+
+// Read the page of the file in the background
+func (m *Model) readHexPage(view hexView) tea.Cmd {
+	remote := m.remote
+	return func() tea.Msg {
+		file, err := remote.Open(view.remotePath)
+		if err != nil {
+			return errorMsg{err: fmt.Errorf("reading %s failed: %v", view.info.Name(), err)}
+		}
+		defer file.Close()
+
+		data := make([]byte, view.page)
+		n, err := file.ReadAt(data, view.offset)
+		if err != nil && err != io.EOF {
+			return errorMsg{err: fmt.Errorf("reading %s failed: %v", view.info.Name(), err)}
+		}
+		return view.message(data[:n])
+	}
+}
+
+// Create the message showing the page read
+func (v hexView) message(data []byte) previewMsg {
+	return previewMsg{
+		name:    v.info.Name(),
+		content: fileMetadata(v.info),
+		binary:  data,
+		hex:     &v,
+	}
+}
+
+// Handle the keys paging the file, tells if the key has been handled
+func (m *Model) updateHexView(msg tea.KeyMsg) (tea.Cmd, bool) {
+	view := *m.hex
+	switch msg.String() {
+	case "]":
+		if view.offset+view.page >= view.info.Size() {
+			return nil, true
+		}
+		view.offset += view.page
+		return m.readHexPage(view), true
+	case "[":
+		if view.offset == 0 {
+			return nil, true
+		}
+		view.offset -= view.page
+		if view.offset < 0 {
+			view.offset = 0
+		}
+		return m.readHexPage(view), true
+	case "g":
+		return m.openPrompt(hexOffsetPrompt, "Offset (0x1f00 or 7936): ", ""), true
+	}
+	return nil, false
+}
+
+// Show the page starting at the offset, in hex with 0x or decimal
+func (m *Model) gotoHexOffset(value string) tea.Cmd {
+	offset, err := strconv.ParseInt(strings.TrimSpace(value), 0, 64)
+	if err != nil || offset < 0 {
+		m.setError(fmt.Errorf("invalid offset %q", value))
+		return nil
+	}
+	view := *m.hex
+	if offset >= view.info.Size() {
+		m.setError(fmt.Errorf("the offset %#x is past the end of %s", offset, view.info.Name()))
+		return nil
+	}
+	view.offset = offset
+	return m.readHexPage(view)
+}
+
+// Describe the page shown for the title of the preview
+func (v hexView) String() string {
+	end := v.offset + v.page
+	if end > v.info.Size() {
+		end = v.info.Size()
+	}
+	return fmt.Sprintf("%#x-%#x of %s · [ ] pages, g offset", v.offset, end, ConvertBytesToSizeString(v.info.Size()))
+}
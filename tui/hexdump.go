@@ -0,0 +1,51 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dump the bytes in hex and ASCII like hexdump -C, with as many bytes per
+// line as fit in the width: 16, 8 or 4. The offset is the one of the first
+// byte in the file.
+func hexDump(data []byte, offset int64, width int) string {
+	perLine := 16
+	for perLine > 4 && hexLineWidth(perLine) > width {
+		perLine /= 2
+	}
+
+	var dump strings.Builder
+	for start := 0; start < len(data); start += perLine {
+		end := start + perLine
+		if end > len(data) {
+			end = len(data)
+		}
+		line := data[start:end]
+		fmt.Fprintf(&dump, "%08x ", offset+int64(start))
+		for i := 0; i < perLine; i++ {
+			if i%8 == 0 {
+				dump.WriteByte(' ')
+			}
+			if i < len(line) {
+				fmt.Fprintf(&dump, "%02x ", line[i])
+			} else {
+				dump.WriteString("   ")
+			}
+		}
+		dump.WriteString(" |")
+		for _, b := range line {
+			if b < 32 || b > 126 {
+				b = '.'
+			}
+			dump.WriteByte(b)
+		}
+		dump.WriteString("|\n")
+	}
+	return dump.String()
+}
+
+// Width of a line of the dump with the bytes per line
+func hexLineWidth(perLine int) int {
+	// offset, the bytes with a space between each group of 8, the ASCII
+	return 9 + perLine*3 + (perLine+7)/8 + 2 + perLine + 1
+}
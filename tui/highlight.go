@@ -0,0 +1,60 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Colors of the highlighted previews, set with the theme
+var syntaxStyle = "monokai"
+
+// Get the colors of the highlighting matching the theme
+func syntaxStyleOf(theme string) string {
+	switch {
+	case theme == "light":
+		return "github"
+	case theme == "solarized":
+		return "solarized-dark"
+	case theme == "default" && !lipgloss.HasDarkBackground():
+		return "github"
+	default:
+		return "monokai"
+	}
+}
+
+// Color the text with the syntax of the language of the file, told by its
+// name. The text is left as it is when the language isn't known or the
+// terminal has no colors.
+func highlight(name, text string) string {
+	lexer := lexers.Match(name)
+	if lexer == nil {
+		return text
+	}
+	var formatter string
+	switch lipgloss.ColorProfile() {
+	case termenv.TrueColor:
+		formatter = "terminal16m"
+	case termenv.ANSI256:
+		formatter = "terminal256"
+	case termenv.ANSI:
+		formatter = "terminal16"
+	default:
+		return text
+	}
+
+	tokens, err := chroma.Coalesce(lexer).Tokenise(nil, text)
+	if err != nil {
+		return text
+	}
+	var highlighted strings.Builder
+	if err := formatters.Get(formatter).Format(&highlighted, styles.Get(syntaxStyle), tokens); err != nil {
+		return text
+	}
+	return highlighted.String()
+}
@@ -0,0 +1,199 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/config"
+)
+
+// Rapresents a past transfer as an item of the history list
+type historyItem struct {
+	config.Transfer
+}
+
+func (i historyItem) Title() string {
+	direction := "↓"
+	if i.Upload {
+		direction = "↑"
+	}
+	return fmt.Sprintf("%s %s → %s", direction, fileItemStyle(displayName(i.Source)), displayName(i.Destination))
+}
+
+func (i historyItem) Description() string {
+	description := fmt.Sprintf("%s · %s · %s in %s · ",
+		i.Time.Format("2006-01-02 15:04:05"), i.Host, ConvertBytesToSizeString(i.Size), i.Duration.Round(time.Second))
+	if i.Error != "" {
+		return description + brokenLinkStyle("failed: "+i.Error)
+	}
+	return description + "done"
+}
+
+func (i historyItem) FilterValue() string { return i.Source + " " + i.Destination }
+
+// Message sent when the history has been read
+type historyMsg struct {
+	transfers []config.Transfer
+}
+
+// Save the finished transfer in the history
+func (m *Model) recordTransfer(t *transfer) tea.Cmd {
+	entry := config.Transfer{
+		Host:        m.host,
+		Upload:      t.upload,
+		Source:      t.source,
+		Destination: t.destination,
+		Size:        t.total,
+		Duration:    time.Since(t.started),
+		Time:        time.Now(),
+		File:        t.file,
+	}
+	// The size of the streamed transfers isn't known
+	if t.transferred > entry.Size {
+		entry.Size = t.transferred
+	}
+	if t.err != nil {
+		entry.Error = t.err.Error()
+	}
+	return func() tea.Msg {
+		if err := config.AddHistory(entry); err != nil {
+			return errorMsg{err: fmt.Errorf("saving the transfer history failed: %v", err)}
+		}
+		return nil
+	}
+}
+
+// Read the history in the background and show it
+func (m *Model) openHistory() tea.Cmd {
+	return func() tea.Msg {
+		transfers, err := config.History()
+		if err != nil {
+			return errorMsg{err: fmt.Errorf("reading the transfer history failed: %v", err)}
+		}
+		return historyMsg{transfers: transfers}
+	}
+}
+
+// Show the past transfers, the last one first
+func (m *Model) showHistory(transfers []config.Transfer) tea.Cmd {
+	if len(transfers) == 0 {
+		return m.setStatus("No transfers yet")
+	}
+	items := make([]list.Item, len(transfers))
+	for i, transfer := range transfers {
+		items[len(transfers)-1-i] = historyItem{transfer}
+	}
+	history := list.New(items, newDelegate(), 0, 0)
+	history.Title = "Transfer history"
+	history.SetStatusBarItemName("transfer", "transfers")
+	m.history = &history
+	m.resize()
+	return nil
+}
+
+// Handle the key presses while the history is shown
+func (m Model) updateHistory(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.history.FilterState() == list.Filtering {
+		var cmd tea.Cmd
+		*m.history, cmd = m.history.Update(msg)
+		return m, cmd
+	}
+
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q", "H":
+		// Clear the filter first
+		if m.history.FilterState() != list.Unfiltered {
+			break
+		}
+		m.history = nil
+		return m, nil
+	case "i":
+		if selected, ok := m.history.SelectedItem().(historyItem); ok {
+			m.info = selected.details()
+		}
+		return m, nil
+	case "enter", "r":
+		selected, ok := m.history.SelectedItem().(historyItem)
+		if !ok {
+			return m, nil
+		}
+		m.history = nil
+		return m, m.rerunTransfers([]config.Transfer{selected.Transfer})
+	case "R":
+		// Run again the failed transfers of this host, the oldest first
+		var failed []config.Transfer
+		items := m.history.Items()
+		for i := len(items) - 1; i >= 0; i-- {
+			entry := items[i].(historyItem).Transfer
+			if entry.Error != "" && entry.File && entry.Host == m.host {
+				failed = append(failed, entry)
+			}
+		}
+		if len(failed) == 0 {
+			return m, m.history.NewStatusMessage(statusMessageStyle("No failed transfer to run again on " + m.host))
+		}
+		m.history = nil
+		status := m.setStatus(fmt.Sprintf("Running %d transfers again", len(failed)))
+		return m, tea.Batch(m.rerunTransfers(failed), status)
+	}
+
+	var cmd tea.Cmd
+	*m.history, cmd = m.history.Update(msg)
+	return m, cmd
+}
+
+// Queue the transfers of the history again. Only the copies of single files
+// can run again, from a tab connected to the same host.
+func (m *Model) rerunTransfers(entries []config.Transfer) tea.Cmd {
+	var cmds []tea.Cmd
+	var downloads []download
+	for _, entry := range entries {
+		switch {
+		case !entry.File:
+			cmds = append(cmds, m.setStatus("Only the transfers of single files can run again"))
+		case entry.Host != m.host:
+			cmds = append(cmds, m.setStatus(fmt.Sprintf("The transfer was on %s, run it again from a tab connected to it", entry.Host)))
+		case entry.Upload:
+			fileInfo, err := os.Stat(entry.Source)
+			if err != nil {
+				m.setError(fmt.Errorf("uploading %s failed: %v", entry.Source, err))
+				continue
+			}
+			cmds = append(cmds, m.queueUpload(entry.Source, entry.Destination, fileInfo.Size(), false))
+		default:
+			downloads = append(downloads, download{remotePath: entry.Source, localPath: entry.Destination, size: entry.Size})
+		}
+	}
+	// Downloaded together, they ask one at a time what to do with the
+	// existing files
+	return tea.Batch(append(cmds, m.queueDownloads(downloads))...)
+}
+
+// Describe the transfer with all its fields
+func (i historyItem) details() string {
+	direction := "download"
+	if i.Upload {
+		direction = "upload"
+	}
+	result := "done"
+	if i.Error != "" {
+		result = "failed: " + i.Error
+	}
+	lines := []string{
+		infoLine("Host", i.Host),
+		infoLine("Type", direction),
+		infoLine("From", i.Source),
+		infoLine("To", i.Destination),
+		infoLine("Size", fmt.Sprintf("%s (%d bytes)", ConvertBytesToSizeString(i.Size), i.Size)),
+		infoLine("Duration", i.Duration.Round(time.Millisecond).String()),
+		infoLine("Finished", i.Time.Format(time.RFC1123)),
+		infoLine("Result", result),
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,130 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+const (
+	// Images bigger than this are described, not downloaded to be shown
+	maxImageSize = 32 * 1024 * 1024
+	// Pixels of the decoded image, a bigger one would take too much memory
+	maxImagePixels = 50 * 1000 * 1000
+	// Side of the thumbnail kept for the preview, enough for any pane
+	thumbnailSize = 400
+)
+
+// Tell if the preview shows the file as an image
+func isImage(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".png", ".jpg", ".jpeg", ".gif":
+		return true
+	}
+	return false
+}
+
+// Read the image and shrink it into a thumbnail. The header is read first,
+// to tell the size without downloading an image too big, and the decoding
+// stops at the end of the image data.
+func readImage(file io.Reader, fileInfo fs.FileInfo) (previewMsg, error) {
+	var header bytes.Buffer
+	config, format, err := image.DecodeConfig(io.TeeReader(file, &header))
+	if err != nil {
+		return previewMsg{}, err
+	}
+	description := fmt.Sprintf("%s image, %d×%d", strings.ToUpper(format), config.Width, config.Height)
+	if fileInfo.Size() > maxImageSize || config.Width*config.Height > maxImagePixels {
+		return previewMsg{
+			name:    fileInfo.Name(),
+			content: fileMetadata(fileInfo) + "\n\n" + description + ", too big to be previewed",
+		}, nil
+	}
+	img, _, err := image.Decode(io.MultiReader(&header, file))
+	if err != nil {
+		return previewMsg{}, err
+	}
+	return previewMsg{
+		name:    fileInfo.Name(),
+		content: description,
+		image:   scaleImage(img, thumbnailSize, thumbnailSize),
+	}, nil
+}
+
+// Shrink the image to fit in the size keeping its proportions, each pixel
+// is the average of the ones it covers. A smaller image is kept as it is.
+func scaleImage(img image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxWidth && height <= maxHeight || width == 0 || height == 0 {
+		return img
+	}
+	scale := float64(maxWidth) / float64(width)
+	if s := float64(maxHeight) / float64(height); s < scale {
+		scale = s
+	}
+	scaledWidth, scaledHeight := int(float64(width)*scale), int(float64(height)*scale)
+	if scaledWidth < 1 {
+		scaledWidth = 1
+	}
+	if scaledHeight < 1 {
+		scaledHeight = 1
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, scaledWidth, scaledHeight))
+	for y := 0; y < scaledHeight; y++ {
+		y0, y1 := y*height/scaledHeight, (y+1)*height/scaledHeight
+		for x := 0; x < scaledWidth; x++ {
+			x0, x1 := x*width/scaledWidth, (x+1)*width/scaledWidth
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(bounds.Min.X+sx, bounds.Min.Y+sy).RGBA()
+					r, g, b, a, n = r+pr, g+pg, b+pb, a+pa, n+1
+				}
+			}
+			scaled.Set(x, y, color.RGBA64{uint16(r / n), uint16(g / n), uint16(b / n), uint16(a / n)})
+		}
+	}
+	return scaled
+}
+
+// Draw the image with unicode half blocks, two pixels per character: the
+// upper one is the color of the block, the lower one the background. The
+// transparent pixels are black.
+func renderImage(img image.Image, width, height int) string {
+	profile := lipgloss.ColorProfile()
+	if profile == termenv.Ascii {
+		return "The terminal has no colors to show the image"
+	}
+	img = scaleImage(img, width, height*2)
+	bounds := img.Bounds()
+	hex := func(c color.Color) termenv.Color {
+		r, g, b, _ := c.RGBA()
+		return profile.Color(fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8))
+	}
+
+	var view strings.Builder
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += 2 {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			block := termenv.String("▀").Foreground(hex(img.At(x, y)))
+			if y+1 < bounds.Max.Y {
+				block = block.Background(hex(img.At(x, y+1)))
+			}
+			view.WriteString(block.String())
+		}
+		view.WriteString("\n")
+	}
+	return view.String()
+}
@@ -0,0 +1,94 @@
+package tui
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/pkg/sftp"
+)
+
+// Message with the details of a remote file
+type infoMsg struct {
+	content string
+}
+
+// Read the details of the remote file in the background, following the
+// symlink to describe its target
+func (m *Model) fileInfo(fileInfo fs.FileInfo) tea.Cmd {
+	remote, owners := m.remote, m.owners
+	remotePath := remote.Join(m.currentDir, fileInfo.Name())
+	return func() tea.Msg {
+		stat, err := remote.Lstat(remotePath)
+		if err != nil {
+			return errorMsg{err: fmt.Errorf("reading the info of %s failed: %v", fileInfo.Name(), err)}
+		}
+
+		lines := []string{
+			infoLine("Path", remotePath),
+			infoLine("Type", fileType(stat)),
+			infoLine("Size", fmt.Sprintf("%s (%d bytes)", ConvertBytesToSizeString(stat.Size()), stat.Size())),
+			infoLine("Mode", fmt.Sprintf("%s (%04o)", stat.Mode(), uint32(stat.Mode().Perm()))),
+		}
+		if fileStat, ok := stat.Sys().(*sftp.FileStat); ok {
+			lines = append(lines,
+				infoLine("Owner", owners.describe(fileStat)),
+				infoLine("Accessed", time.Unix(int64(fileStat.Atime), 0).Format(time.RFC1123)),
+			)
+		}
+		lines = append(lines, infoLine("Modified", stat.ModTime().Format(time.RFC1123)))
+
+		if stat.Mode()&fs.ModeSymlink != 0 {
+			target, err := remote.ReadLink(remotePath)
+			if err != nil {
+				target = "unreadable: " + err.Error()
+			} else if targetInfo, err := remote.Stat(remotePath); err != nil {
+				target += " (broken)"
+			} else {
+				target += fmt.Sprintf(" (%s)", fileType(targetInfo))
+			}
+			lines = append(lines, infoLine("Target", target))
+		}
+		return infoMsg{content: strings.Join(lines, "\n")}
+	}
+}
+
+// Align the label of the detail
+func infoLine(label, value string) string {
+	return fmt.Sprintf("%-9s %s", label+":", value)
+}
+
+// Describe the kind of file, with the extension for the regular ones
+func fileType(fileInfo fs.FileInfo) string {
+	switch mode := fileInfo.Mode(); {
+	case mode.IsDir():
+		return "directory"
+	case mode&fs.ModeSymlink != 0:
+		return "symlink"
+	case mode&fs.ModeNamedPipe != 0:
+		return "named pipe"
+	case mode&fs.ModeSocket != 0:
+		return "socket"
+	case mode&fs.ModeDevice != 0:
+		return "device"
+	}
+	if ext := filepath.Ext(fileInfo.Name()); ext != "" {
+		return fmt.Sprintf("file (%s)", ext)
+	}
+	return "file"
+}
+
+// Render the details as a modal in the middle of the screen
+func (m Model) infoView() string {
+	return lipgloss.Place(
+		m.width,
+		m.height,
+		lipgloss.Center,
+		lipgloss.Center,
+		modalStyle.Render(m.info+"\n\n"+statusMessageStyle("press any key to close")),
+	)
+}
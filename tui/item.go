@@ -1,10 +1,50 @@
 package tui
 
-import "io/fs"
+import (
+	"io/fs"
+	"time"
+)
 
-// Rapresents an a file as an item of the list of the tui client 
+// Rapresents an a file as an item of the list of the tui client
 type item struct {
-	rawValue fs.FileInfo // File properties
+	rawValue   fs.FileInfo // File properties
+	marked     bool        // Selected for the batch operations
+	linkTarget string      // Where the symlink points to
+	target     fs.FileInfo // Properties of the symlink target, nil if broken
+	dirSize    int64       // Size of the directory with its content
+	sized      bool        // Whether the size of the directory has been computed
+	owner      string      // owner:group of the file, empty when unknown
+}
+
+// Tell if the item is a symlink
+func (i item) isSymlink() bool {
+	return i.rawValue.Mode()&fs.ModeSymlink != 0
+}
+
+// Tell if the item is a symlink pointing to nothing
+func (i item) isBroken() bool {
+	return i.isSymlink() && i.target == nil
+}
+
+// Tell if the item is a directory, or a symlink to a directory
+func (i item) isDir() bool {
+	return i.rawValue.IsDir() || (i.target != nil && i.target.IsDir())
+}
+
+// Get the size of the file, or of the symlink target
+func (i item) size() int64 {
+	if i.target != nil {
+		return i.target.Size()
+	}
+	return i.rawValue.Size()
+}
+
+// Get the modification time of the file, or of the symlink target
+func (i item) modTime() time.Time {
+	if i.target != nil {
+		return i.target.ModTime()
+	}
+	return i.rawValue.ModTime()
 }
 
 // Get the stiled title for the file item
@@ -14,12 +54,36 @@ func (i item) Title() string {
 	}
 
 	var title string
-	if i.rawValue.IsDir() {
-		title = dirItemStyle(i.rawValue.Name())
-	} else {
-		title = fileItemStyle(i.rawValue.Name())
+	switch {
+	case i.isDir() && !showIcons:
+		// Without the icon the directories are told by the slash
+		title = dirItemStyle(displayName(i.rawValue.Name()) + "/")
+	case i.isDir():
+		title = dirItemStyle(displayName(i.rawValue.Name()))
+	default:
+		title = fileItemStyle(displayName(i.rawValue.Name()))
+	}
+	if showIcons {
+		title = getFileIcon(i.rawValue) + " " + title
+	}
+	switch {
+	case i.isBroken():
+		title += brokenLinkStyle(" → " + displayName(i.linkTarget))
+	case i.isSymlink():
+		title += linkTargetStyle(" → " + displayName(i.linkTarget))
+	}
+	if i.marked {
+		title = markedItemStyle("● ") + title
+	}
+	return title
+}
+
+// Get the size the list is sorted by, the one computed for directories
+func (i item) listedSize() int64 {
+	if i.sized {
+		return i.dirSize
 	}
-	return getFileIcon(i.rawValue) + " " + title
+	return i.rawValue.Size()
 }
 
 // Get fancy description for the file item
@@ -27,7 +91,17 @@ func (i item) Description() string {
 	if i.rawValue.Name() == ".." {
 		return ""
 	}
-	return getFileDescription(i.rawValue)
+	description := getFileDescription(i.rawValue)
+	if i.target != nil {
+		description = getFileDescription(i.target)
+	}
+	if i.owner != "" {
+		description += " " + i.owner
+	}
+	if i.sized {
+		description += linkTargetStyle(" · " + ConvertBytesToSizeString(i.dirSize) + " in all")
+	}
+	return description
 }
 
 // The value to filter when searching
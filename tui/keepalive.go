@@ -0,0 +1,114 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	keepAliveInterval = 30 * time.Second // how often the connection is checked
+	keepAliveTimeout  = 15 * time.Second // how long to wait for the server answer
+)
+
+// Dials a new ssh connection to the server and starts the sftp session,
+// cancelling the context stops waiting for the server. Unattended, while
+// the program owns the terminal, the questions of the server aren't asked.
+type connector func(ctx context.Context, unattended bool) (*ssh.Client, *sftp.Client, error)
+
+// Message sent when it's time to check the connection
+type keepAliveMsg struct{}
+
+// Message sent when the server answered the keep alive
+type connectionAliveMsg struct{}
+
+// Message sent when the connection has been dialed again
+type reconnectedMsg struct {
+	sshClient  *ssh.Client
+	sftpClient *sftp.Client
+	err        error // why reconnecting failed
+	// whether the user asked for it, otherwise it's a keep alive check and
+	// the next one is scheduled
+	byUser bool
+}
+
+// Wait for the next connection check
+func keepAlive() tea.Cmd {
+	return tea.Tick(keepAliveInterval, func(time.Time) tea.Msg {
+		return keepAliveMsg{}
+	})
+}
+
+// Send a keep alive request, reconnecting when the server doesn't answer
+func (m *Model) checkConnection() tea.Cmd {
+	sshClient, connect := m.sshClient, m.connect
+	// The reconnection can be cancelled from the disconnected screen
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelConnect = cancel
+	return func() tea.Msg {
+		defer cancel()
+		answered := make(chan error, 1)
+		go func() {
+			_, _, err := sshClient.SendRequest("keepalive@openssh.com", true, nil)
+			answered <- err
+		}()
+
+		select {
+		case err := <-answered:
+			if err == nil {
+				return connectionAliveMsg{}
+			}
+		case <-time.After(keepAliveTimeout):
+		case <-ctx.Done():
+			return reconnectedMsg{err: ctx.Err()}
+		}
+
+		sshClient.Close()
+		newSSHClient, newSftpClient, err := connect(ctx, true)
+		if err != nil {
+			return reconnectedMsg{err: err}
+		}
+		return reconnectedMsg{sshClient: newSSHClient, sftpClient: newSftpClient}
+	}
+}
+
+// Use the new connection, restoring the current directory
+func (m *Model) reconnected(msg reconnectedMsg) tea.Cmd {
+	m.cancelConnect = nil
+	var next tea.Cmd
+	if !msg.byUser {
+		next = keepAlive()
+	}
+	if msg.err != nil {
+		slog.Error("reconnecting failed", "host", m.host, "err", msg.err)
+		if needsAnswers(msg.err) {
+			// Retrying won't do, the user has to answer
+			if !m.disconnected {
+				m.disconnect()
+			}
+			m.connectErr = msg.err
+			return next
+		}
+		if m.disconnected {
+			// Reconnecting is up to the user
+			m.connectErr = msg.err
+			return next
+		}
+		// Try again at the next check
+		m.setError(fmt.Errorf("connection lost, reconnecting failed: %v", msg.err))
+		return next
+	}
+	slog.Info("reconnected", "host", m.host)
+	m.disconnected = false
+	m.SftpClient.Close()
+	m.sshClient, m.SftpClient = msg.sshClient, msg.sftpClient
+	m.remote = remotefs.NewSFTP(m.SftpClient)
+	m.queue.remote.Store(m.remote)
+	return tea.Batch(m.changeDir(m.currentDir, "Reconnected"), next)
+}
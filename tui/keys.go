@@ -0,0 +1,226 @@
+package tui
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// The key bindings of the file browser, the help is generated from them.
+// In the config file the actions are named after the fields in lower case.
+type keyMap struct {
+	// Moving in the list
+	Up       key.Binding
+	Down     key.Binding
+	PrevPage key.Binding
+	NextPage key.Binding
+	Start    key.Binding
+	End      key.Binding
+	Filter   key.Binding
+	Pattern  key.Binding
+
+	// Navigation
+	Enter     key.Binding
+	Back      key.Binding
+	Refresh   key.Binding
+	GoTo      key.Binding
+	Search    key.Binding
+	Grep      key.Binding
+	Bookmark  key.Binding
+	Bookmarks key.Binding
+	Crumbs    key.Binding
+
+	// Files
+	Mark        key.Binding
+	Download    key.Binding
+	DownloadTo  key.Binding
+	Upload      key.Binding
+	Rename      key.Binding
+	Copy        key.Binding
+	Paste       key.Binding
+	Mkdir       key.Binding
+	Delete      key.Binding
+	Undo        key.Binding
+	Trash       key.Binding
+	Permissions key.Binding
+	Info        key.Binding
+	DirSize     key.Binding
+	Preview     key.Binding
+	Follow      key.Binding
+	Hex         key.Binding
+	Diff        key.Binding
+	Extract     key.Binding
+	Compress    key.Binding
+	Edit        key.Binding
+	Open        key.Binding
+	CopyPath    key.Binding
+	CopyURL     key.Binding
+	Command     key.Binding
+
+	// Transfers
+	LocalDir   key.Binding
+	Push       key.Binding
+	Pull       key.Binding
+	Watch      key.Binding
+	Queue      key.Binding
+	Log        key.Binding
+	RateLimit  key.Binding
+	Verify     key.Binding
+	CopyToNext key.Binding
+	History    key.Binding
+
+	// View
+	Sort      key.Binding
+	Reverse   key.Binding
+	DirsFirst key.Binding
+	Hidden    key.Binding
+	Columns   key.Binding
+	Help      key.Binding
+	Quit      key.Binding
+	NewTab    key.Binding
+	NextTab   key.Binding
+	PrevTab   key.Binding
+	CloseTab  key.Binding
+}
+
+var keys = keyMap{
+	Up:       key.NewBinding(key.WithKeys("up", "k"), key.WithHelp("↑/k", "up")),
+	Down:     key.NewBinding(key.WithKeys("down", "j"), key.WithHelp("↓/j", "down")),
+	PrevPage: key.NewBinding(key.WithKeys("left", "h", "pgup"), key.WithHelp("←/h/pgup", "prev page")),
+	NextPage: key.NewBinding(key.WithKeys("right", "l", "pgdown", "f"), key.WithHelp("→/l/pgdn", "next page")),
+	Start:    key.NewBinding(key.WithKeys("home", "g"), key.WithHelp("g/home", "go to start")),
+	End:      key.NewBinding(key.WithKeys("end", "G"), key.WithHelp("G/end", "go to end")),
+	Filter:   key.NewBinding(key.WithKeys("/"), key.WithHelp("/", "filter")),
+	Pattern:  key.NewBinding(key.WithKeys("*"), key.WithHelp("*", "pattern filter")),
+
+	Enter:     key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "open/download")),
+	Back:      key.NewBinding(key.WithKeys("backspace"), key.WithHelp("backspace", "parent dir")),
+	Refresh:   key.NewBinding(key.WithKeys("R"), key.WithHelp("R", "refresh")),
+	GoTo:      key.NewBinding(key.WithKeys(":"), key.WithHelp(":", "go to path")),
+	Search:    key.NewBinding(key.WithKeys("ctrl+f"), key.WithHelp("ctrl+f", "search names")),
+	Grep:      key.NewBinding(key.WithKeys("ctrl+g"), key.WithHelp("ctrl+g", "search content")),
+	Bookmark:  key.NewBinding(key.WithKeys("b"), key.WithHelp("b", "bookmark dir")),
+	Bookmarks: key.NewBinding(key.WithKeys("B"), key.WithHelp("B", "bookmarks")),
+	Crumbs:    key.NewBinding(key.WithKeys("ctrl+b"), key.WithHelp("ctrl+b", "parent dirs")),
+
+	Mark:        key.NewBinding(key.WithKeys(" "), key.WithHelp("space", "mark")),
+	Download:    key.NewBinding(key.WithKeys("d"), key.WithHelp("d", "download")),
+	DownloadTo:  key.NewBinding(key.WithKeys("D"), key.WithHelp("D", "download to")),
+	Upload:      key.NewBinding(key.WithKeys("u"), key.WithHelp("u", "upload")),
+	Rename:      key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "rename/move")),
+	Copy:        key.NewBinding(key.WithKeys("c"), key.WithHelp("c", "copy")),
+	Paste:       key.NewBinding(key.WithKeys("v"), key.WithHelp("v", "paste")),
+	Mkdir:       key.NewBinding(key.WithKeys("m"), key.WithHelp("m", "new dir")),
+	Delete:      key.NewBinding(key.WithKeys("x", "delete"), key.WithHelp("x", "delete")),
+	Undo:        key.NewBinding(key.WithKeys("z"), key.WithHelp("z", "undo delete")),
+	Trash:       key.NewBinding(key.WithKeys("T"), key.WithHelp("T", "trash")),
+	Permissions: key.NewBinding(key.WithKeys("P"), key.WithHelp("P", "permissions")),
+	Info:        key.NewBinding(key.WithKeys("i"), key.WithHelp("i", "details")),
+	DirSize:     key.NewBinding(key.WithKeys("Z"), key.WithHelp("Z", "dir size")),
+	Preview:     key.NewBinding(key.WithKeys("p", "tab"), key.WithHelp("p", "preview")),
+	Follow:      key.NewBinding(key.WithKeys("F"), key.WithHelp("F", "follow")),
+	Hex:         key.NewBinding(key.WithKeys("ctrl+x"), key.WithHelp("ctrl+x", "hex view")),
+	Diff:        key.NewBinding(key.WithKeys("="), key.WithHelp("=", "diff with local")),
+	Extract:     key.NewBinding(key.WithKeys("X"), key.WithHelp("X", "extract")),
+	Compress:    key.NewBinding(key.WithKeys("A"), key.WithHelp("A", "compress and download")),
+	Edit:        key.NewBinding(key.WithKeys("e"), key.WithHelp("e", "edit")),
+	Open:        key.NewBinding(key.WithKeys("o"), key.WithHelp("o", "open locally")),
+	CopyPath:    key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "copy path")),
+	CopyURL:     key.NewBinding(key.WithKeys("Y"), key.WithHelp("Y", "copy url")),
+	Command:     key.NewBinding(key.WithKeys("!"), key.WithHelp("!", "run command")),
+
+	LocalDir:   key.NewBinding(key.WithKeys("L"), key.WithHelp("L", "local dir")),
+	Push:       key.NewBinding(key.WithKeys(">"), key.WithHelp(">", "sync push")),
+	Pull:       key.NewBinding(key.WithKeys("<"), key.WithHelp("<", "sync pull")),
+	Watch:      key.NewBinding(key.WithKeys("W"), key.WithHelp("W", "watch and upload")),
+	Queue:      key.NewBinding(key.WithKeys("t"), key.WithHelp("t", "transfer queue")),
+	Log:        key.NewBinding(key.WithKeys("O"), key.WithHelp("O", "operation log")),
+	RateLimit:  key.NewBinding(key.WithKeys("ctrl+l"), key.WithHelp("ctrl+l", "rate limit")),
+	Verify:     key.NewBinding(key.WithKeys("V"), key.WithHelp("V", "verify checksums")),
+	CopyToNext: key.NewBinding(key.WithKeys("C"), key.WithHelp("C", "copy to next tab")),
+	History:    key.NewBinding(key.WithKeys("H"), key.WithHelp("H", "transfer history")),
+
+	Sort:      key.NewBinding(key.WithKeys("s"), key.WithHelp("s", "sort by")),
+	Reverse:   key.NewBinding(key.WithKeys("S"), key.WithHelp("S", "reverse sort")),
+	DirsFirst: key.NewBinding(key.WithKeys("ctrl+s"), key.WithHelp("ctrl+s", "dirs first")),
+	Hidden:    key.NewBinding(key.WithKeys("."), key.WithHelp(".", "dotfiles")),
+	Columns:   key.NewBinding(key.WithKeys("w"), key.WithHelp("w", "column view")),
+	Help:      key.NewBinding(key.WithKeys("?"), key.WithHelp("?", "help")),
+	Quit:      key.NewBinding(key.WithKeys("ctrl+c"), key.WithHelp("ctrl+c", "quit")),
+	NewTab:    key.NewBinding(key.WithKeys("ctrl+t"), key.WithHelp("ctrl+t", "new tab")),
+	NextTab:   key.NewBinding(key.WithKeys("ctrl+right"), key.WithHelp("ctrl+→", "next tab")),
+	PrevTab:   key.NewBinding(key.WithKeys("ctrl+left"), key.WithHelp("ctrl+←", "previous tab")),
+	CloseTab:  key.NewBinding(key.WithKeys("ctrl+w"), key.WithHelp("ctrl+w", "close tab")),
+}
+
+// The bindings shown below the list, next to the ones of the list
+func (k keyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Enter, k.Mark, k.Download, k.Upload, k.Delete}
+}
+
+// The bindings shown by the help, one column per group
+func (k keyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Up, k.Down, k.PrevPage, k.NextPage, k.Start, k.End, k.Filter, k.Pattern},
+		{k.Enter, k.Back, k.Refresh, k.GoTo, k.Search, k.Grep, k.Bookmark, k.Bookmarks, k.Crumbs},
+		{k.Mark, k.Download, k.DownloadTo, k.Upload, k.Rename, k.Copy, k.Paste, k.Mkdir, k.Delete, k.Undo, k.Trash, k.Permissions},
+		{k.Info, k.DirSize, k.Preview, k.Follow, k.Hex, k.Diff, k.Extract, k.Compress, k.Edit, k.Open, k.CopyPath, k.CopyURL, k.Command},
+		{k.LocalDir, k.Push, k.Pull, k.Watch, k.Queue, k.Log, k.RateLimit, k.Verify, k.CopyToNext, k.History},
+		{k.Sort, k.Reverse, k.DirsFirst, k.Hidden, k.Columns, k.Help, k.Quit},
+		{k.NewTab, k.NextTab, k.PrevTab, k.CloseTab},
+	}
+}
+
+// Replace the keys of the actions with the ones of the config file, the
+// actions not listed keep their keys. A key bound to two actions is an error.
+func (k *keyMap) remap(bindings map[string][]string) error {
+	actions := k.actions()
+	for name, keyNames := range bindings {
+		binding, ok := actions[strings.ToLower(name)]
+		if !ok {
+			return fmt.Errorf("unknown action %q in the keys", name)
+		}
+		if len(keyNames) == 0 {
+			return fmt.Errorf("no keys for the action %q", name)
+		}
+		var pressed []string
+		for _, keyName := range keyNames {
+			// The space bar is named " " by bubbletea
+			if keyName == "space" {
+				keyName = " "
+			}
+			pressed = append(pressed, keyName)
+		}
+		binding.SetKeys(pressed...)
+		binding.SetHelp(strings.Join(keyNames, "/"), binding.Help().Desc)
+	}
+
+	bound := make(map[string]string)
+	names := make([]string, 0, len(actions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, keyName := range actions[name].Keys() {
+			if other, ok := bound[keyName]; ok {
+				return fmt.Errorf("the key %q is bound to both %s and %s", keyName, other, name)
+			}
+			bound[keyName] = name
+		}
+	}
+	return nil
+}
+
+// Get the bindings by the name of their action
+func (k *keyMap) actions() map[string]*key.Binding {
+	actions := make(map[string]*key.Binding)
+	value := reflect.ValueOf(k).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		actions[strings.ToLower(value.Type().Field(i).Name)] = value.Field(i).Addr().Interface().(*key.Binding)
+	}
+	return actions
+}
@@ -0,0 +1,88 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+)
+
+// Entries resolved and added to the list at a time, a bigger directory is
+// shown while it loads
+const loadBatch = 500
+
+// A directory being loaded in batches
+type dirLoad struct {
+	path   string
+	status string // status message to show once loaded
+	total  int    // number of entries
+}
+
+// Message with a batch of the entries of the directory being loaded
+type dirBatchMsg struct {
+	load  *dirLoad
+	items []list.Item
+	first bool    // whether it's the first batch, the listing is replaced
+	next  tea.Cmd // loads the next batch, nil for the last one
+}
+
+// Resolve the entries a batch at a time, each batch is sent as soon as it's
+// ready
+func loadBatches(remote remotefs.FileSystem, load *dirLoad, files []os.FileInfo, first bool) tea.Cmd {
+	return func() tea.Msg {
+		n := loadBatch
+		if n > len(files) {
+			n = len(files)
+		}
+		msg := dirBatchMsg{load: load, items: newItems(remote, load.path, files[:n]), first: first}
+		if first {
+			msg.items = append([]list.Item{&item{rawValue: &PreviousDir{}}}, msg.items...)
+		}
+		if n < len(files) {
+			msg.next = loadBatches(remote, load, files[n:], false)
+		}
+		return msg
+	}
+}
+
+// Add the batch to the listing and load the next one, the batches of a
+// directory left meanwhile are dropped
+func (m *Model) addBatch(msg dirBatchMsg) tea.Cmd {
+	var selected string
+	if msg.first {
+		m.logDirChange(msg.load.path)
+		m.currentDir = msg.load.path
+		m.loading = msg.load
+		m.cached = false
+		m.dirItems = nil
+	} else if msg.load != m.loading {
+		return nil
+	} else if selectedItem, ok := m.List.SelectedItem().(*item); ok {
+		// The new entries are sorted in, keep the highlighted one
+		selected = selectedItem.rawValue.Name()
+	}
+	cmds := []tea.Cmd{m.setDirItems(append(m.dirItems, msg.items...))}
+	if selected != "" {
+		m.selectItem(selected)
+	}
+
+	if msg.next != nil {
+		status := fmt.Sprintf("Loading %d of %d items…", len(m.dirItems)-1, msg.load.total)
+		cmds = append(cmds, m.List.NewStatusMessage(statusMessageStyle(status)), msg.next)
+		if msg.first {
+			cmds = append(cmds, m.List.StartSpinner())
+		}
+		return tea.Batch(cmds...)
+	}
+
+	m.loading = nil
+	m.List.StopSpinner()
+	if m.selectName != "" {
+		m.selectItem(m.selectName)
+		m.selectName = ""
+	}
+	m.cache.put(msg.load.path, m.dirItems)
+	return tea.Batch(append(cmds, m.setStatus(msg.load.status), m.readDiskSpace())...)
+}
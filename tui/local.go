@@ -0,0 +1,36 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+	"github.com/guglielmobartelloni/sftp-tui/throttle"
+)
+
+// Name of the tab browsing the local files, in place of the host
+const localHost = "local"
+
+// Create the model browsing the local directory like the files of a
+// server, the commands needing one report there's none
+func newLocalModel(dir string, settings Settings, limiter *throttle.Limiter) (Model, error) {
+	local, err := remotefs.NewLocalHome()
+	if err != nil {
+		return Model{}, err
+	}
+	startDir, err := local.Path(dir)
+	if err != nil {
+		return Model{}, err
+	}
+	currentDir, items, err := openStartDir(local, startDir)
+	if err != nil {
+		return Model{}, err
+	}
+	m, err := newTabModel(local, currentDir, items, settings, limiter)
+	if err != nil {
+		return Model{}, err
+	}
+	m.local = true
+	m.host = localHost
+	m.banner = fmt.Sprintf("Browsing the local files, downloads go to %s", m.localDir)
+	return m, nil
+}
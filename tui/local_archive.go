@@ -0,0 +1,150 @@
+package tui
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+)
+
+// Writes the entries of an archive, a .zip or a .tar.gz
+type archiveWriter interface {
+	// Add the entry named after the path, the content is read for the
+	// regular files
+	add(name string, fileInfo os.FileInfo, link string, content io.Reader) error
+	Close() error
+}
+
+// Download the remote directory into a local archive, its files are streamed
+// into it one at a time. The entries are named after the base name of the
+// directory. The bytes of the files are written to the counter.
+func archiveDownload(remote remotefs.FileSystem, remoteDir, archivePath string, zipped bool, counter io.Writer) (err error) {
+	file, err := os.OpenFile(archivePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		// Don't leave half an archive
+		if err != nil {
+			os.Remove(archivePath)
+		}
+	}()
+
+	var archive archiveWriter
+	if zipped {
+		archive = &zipArchive{zip.NewWriter(file)}
+	} else {
+		gzipWriter := gzip.NewWriter(file)
+		archive = &tarArchive{gzip: gzipWriter, tar: tar.NewWriter(gzipWriter)}
+	}
+
+	parent := path.Dir(remoteDir)
+	walker := remotefs.Walk(remote, remoteDir)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return err
+		}
+		fileInfo := walker.Stat()
+		name := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), parent), "/")
+		var link string
+		switch {
+		case fileInfo.Mode()&os.ModeSymlink != 0:
+			if link, err = remote.ReadLink(walker.Path()); err != nil {
+				return err
+			}
+		case fileInfo.Mode().IsRegular():
+			remoteFile, err := remote.Open(walker.Path())
+			if err != nil {
+				return err
+			}
+			err = archive.add(name, fileInfo, "", io.TeeReader(remoteFile, counter))
+			remoteFile.Close()
+			if err != nil {
+				return err
+			}
+			continue
+		case !fileInfo.IsDir():
+			// Sockets, pipes and devices can't be archived
+			continue
+		}
+		if err := archive.add(name, fileInfo, link, nil); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// Writes a .zip
+type zipArchive struct {
+	zip *zip.Writer
+}
+
+func (a *zipArchive) add(name string, fileInfo os.FileInfo, link string, content io.Reader) error {
+	header, err := zip.FileInfoHeader(fileInfo)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if fileInfo.IsDir() {
+		header.Name += "/"
+	} else {
+		header.Method = zip.Deflate
+	}
+	w, err := a.zip.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	// The target of a symlink is its content
+	if link != "" {
+		content = strings.NewReader(link)
+	}
+	if content == nil {
+		return nil
+	}
+	_, err = io.Copy(w, content)
+	return err
+}
+
+func (a *zipArchive) Close() error {
+	return a.zip.Close()
+}
+
+// Writes a .tar.gz
+type tarArchive struct {
+	gzip *gzip.Writer
+	tar  *tar.Writer
+}
+
+func (a *tarArchive) add(name string, fileInfo os.FileInfo, link string, content io.Reader) error {
+	header, err := tar.FileInfoHeader(fileInfo, link)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if fileInfo.IsDir() {
+		header.Name += "/"
+	}
+	if err := a.tar.WriteHeader(header); err != nil {
+		return err
+	}
+	if content == nil {
+		return nil
+	}
+	_, err = io.Copy(a.tar, content)
+	return err
+}
+
+func (a *tarArchive) Close() error {
+	if err := a.tar.Close(); err != nil {
+		return err
+	}
+	return a.gzip.Close()
+}
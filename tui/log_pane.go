@@ -0,0 +1,84 @@
+package tui
+
+import (
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muesli/reflow/truncate"
+)
+
+const (
+	// Lines taken by the log pane, header included
+	logPaneHeight = 8
+	// Entries kept in the log, the oldest are dropped
+	maxLogEntries = 1000
+)
+
+// Something that happened in the tab, shown in the log pane
+type logEntry struct {
+	at   time.Time
+	text string
+	err  bool
+}
+
+// The actions and the errors of the tab, the last one at the end. It's
+// shared between the copies of the model and changed only in Update.
+type actionLog struct {
+	entries []logEntry
+}
+
+// Record the action, the empty ones are left out
+func (l *actionLog) add(text string, err bool) {
+	if text == "" {
+		return
+	}
+	l.entries = append(l.entries, logEntry{at: time.Now(), text: text, err: err})
+	if len(l.entries) > maxLogEntries {
+		l.entries = l.entries[len(l.entries)-maxLogEntries:]
+	}
+}
+
+// Show the status message below the list and record it in the log
+func (m *Model) setStatus(status string) tea.Cmd {
+	status = displayName(status)
+	m.log.add(status, false)
+	return m.List.NewStatusMessage(statusMessageStyle(status))
+}
+
+// Show the error in the footer until the next key press and record it in
+// the log
+func (m *Model) setError(err error) {
+	m.err = err
+	m.log.add("Error: "+err.Error(), true)
+}
+
+// Record that the tab moved to the directory
+func (m *Model) logDirChange(dirPath string) {
+	if dirPath != m.currentDir {
+		m.log.add("Entered "+dirPath, false)
+	}
+}
+
+// Render the last entries of the log that fit in the pane, one per line
+func (l *actionLog) View(width int) string {
+	lines := []string{queueHeaderStyle("Log")}
+
+	entries := l.entries
+	if len(entries) > logPaneHeight-1 {
+		entries = entries[len(entries)-logPaneHeight+1:]
+	}
+	for _, entry := range entries {
+		// The entries are on a line, the long ones are cut
+		text := displayName(strings.ReplaceAll(entry.text, "\n", " "))
+		line := truncate.StringWithTail(entry.at.Format("15:04:05")+" "+text, uint(width), "…")
+		if entry.err {
+			line = errorMessageStyle(line)
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) < logPaneHeight {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
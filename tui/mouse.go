@@ -0,0 +1,86 @@
+package tui
+
+import (
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Longest wait between the clicks of a double click
+const doubleClickTime = 500 * time.Millisecond
+
+// Click on an entry of the list, to tell a double click
+type click struct {
+	index int
+	at    time.Time
+}
+
+// Handle the mouse over the file list: a click selects an entry, a double
+// click opens it like enter, the wheel moves the selection and a click on
+// a directory of the path in the header goes there. The other views are
+// left to the keys.
+func (m Model) updateMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	if m.confirmation != nil || m.permissions != nil || m.disconnected || m.showHelp || m.info != "" ||
+		m.promptAction != noPrompt || m.previewName != "" || m.search != nil || m.bookmarks != nil ||
+		m.trashList != nil || m.history != nil || m.List.FilterState() == list.Filtering {
+		return m, nil
+	}
+
+	switch msg.Type {
+	case tea.MouseWheelUp:
+		m.List.CursorUp()
+	case tea.MouseWheelDown:
+		m.List.CursorDown()
+	case tea.MouseLeft:
+		m.err = nil
+		m.crumbsFocused = false
+		x, y := msg.X-docStyle.GetMarginLeft(), msg.Y-docStyle.GetMarginTop()
+		if y == 0 {
+			if crumb, ok := m.crumbAt(x); ok {
+				return m, m.goTo(m.crumbs()[crumb])
+			}
+			return m, nil
+		}
+		// The list is below the header
+		index, ok := m.itemAt(y - 1)
+		if !ok {
+			return m, nil
+		}
+		m.List.Select(index)
+		if index == m.lastClick.index && time.Since(m.lastClick.at) < doubleClickTime {
+			m.lastClick = click{}
+			return m, m.enterItem(m.List.SelectedItem().(*item))
+		}
+		m.lastClick = click{index: index, at: time.Now()}
+	}
+	return m, nil
+}
+
+// Find the entry of the list on the line, counted from the top of the list,
+// false when there's none
+func (m Model) itemAt(line int) (int, bool) {
+	if m.List.ShowTitle() || (m.List.ShowFilter() && m.List.FilteringEnabled()) {
+		line -= lipgloss.Height(m.List.Styles.TitleBar.Render(""))
+	}
+	if m.List.ShowStatusBar() {
+		line -= lipgloss.Height(m.List.Styles.StatusBar.Render(""))
+	}
+
+	var delegate list.ItemDelegate = newDelegate()
+	if m.columns {
+		delegate = columnDelegate{}
+	}
+	height := delegate.Height() + delegate.Spacing()
+	// The lines between the entries don't belong to any
+	if line < 0 || line%height >= delegate.Height() {
+		return 0, false
+	}
+	start, end := m.List.Paginator.GetSliceBounds(len(m.List.VisibleItems()))
+	index := start + line/height
+	if index >= end {
+		return 0, false
+	}
+	return index, true
+}
@@ -0,0 +1,48 @@
+package tui
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/localpath"
+)
+
+// Download the highlighted file into a temporary directory and open it with
+// the default application
+func (m *Model) openFile(i *item) tea.Cmd {
+	if i.isDir() || i.isBroken() {
+		return nil
+	}
+	dir := filepath.Join(os.TempDir(), "sftp-tui")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return reportError(err)
+	}
+	return m.downloadFile(download{
+		remotePath: m.remote.Join(m.currentDir, i.rawValue.Name()),
+		localPath:  filepath.Join(dir, localpath.Name(i.rawValue.Name())),
+		size:       i.size(),
+		open:       true,
+	})
+}
+
+// Open the local file with the default application of the system, without
+// waiting for it
+func openWithSystem(localPath string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", localPath)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", localPath)
+	default:
+		cmd = exec.Command("xdg-open", localPath)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
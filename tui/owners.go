@@ -0,0 +1,116 @@
+package tui
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// The names of the users and the groups of the server by id, read once per
+// connection
+type ownerNames struct {
+	users  map[uint32]string
+	groups map[uint32]string
+}
+
+// Message with the names of the users and the groups of the server
+type ownerNamesMsg struct {
+	names ownerNames
+}
+
+// Read the names of the users and the groups in the background, with getent
+// on the server so the ones of LDAP are included, or from /etc/passwd and
+// /etc/group when the command can't run
+func loadOwnerNames(sshClient *ssh.Client, remote remotefs.FileSystem) tea.Cmd {
+	return func() tea.Msg {
+		return ownerNamesMsg{names: ownerNames{
+			users:  readIDNames(sshClient, remote, "passwd"),
+			groups: readIDNames(sshClient, remote, "group"),
+		}}
+	}
+}
+
+// Read the names by id of the database, passwd or group, whose lines are
+// like name:x:id:...
+func readIDNames(sshClient *ssh.Client, remote remotefs.FileSystem, database string) map[uint32]string {
+	var data []byte
+	if sshClient != nil {
+		if session, err := newSession(sshClient); err == nil {
+			command := "getent " + database
+			slog.Info("remote command", "command", command)
+			data, _ = session.Output(command)
+			session.Close()
+		}
+	}
+	if len(data) == 0 {
+		if file, err := remote.Open("/etc/" + database); err == nil {
+			data, _ = io.ReadAll(file)
+			file.Close()
+		}
+	}
+
+	names := make(map[uint32]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 4)
+		if len(fields) < 3 {
+			continue
+		}
+		id, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			continue
+		}
+		// The first entry of an id wins, like for ls
+		if _, ok := names[uint32(id)]; !ok {
+			names[uint32(id)] = fields[0]
+		}
+	}
+	return names
+}
+
+// Get the owner and the group of the file as owner:group, by id when their
+// name isn't known
+func (n ownerNames) owner(fileInfo fs.FileInfo) string {
+	fileStat, ok := fileInfo.Sys().(*sftp.FileStat)
+	if !ok {
+		return ""
+	}
+	user, group := strconv.Itoa(int(fileStat.UID)), strconv.Itoa(int(fileStat.GID))
+	if name, ok := n.users[fileStat.UID]; ok {
+		user = name
+	}
+	if name, ok := n.groups[fileStat.GID]; ok {
+		group = name
+	}
+	return user + ":" + group
+}
+
+// Describe the owner and the group of the file for the details, with their
+// ids
+func (n ownerNames) describe(fileStat *sftp.FileStat) string {
+	user := fmt.Sprintf("uid %d", fileStat.UID)
+	if name, ok := n.users[fileStat.UID]; ok {
+		user = fmt.Sprintf("%s (uid %d)", name, fileStat.UID)
+	}
+	group := fmt.Sprintf("gid %d", fileStat.GID)
+	if name, ok := n.groups[fileStat.GID]; ok {
+		group = fmt.Sprintf("%s (gid %d)", name, fileStat.GID)
+	}
+	return user + ", " + group
+}
+
+// Show the owners of the listed items, by id until their names are read
+func (m *Model) setOwnerNames(names ownerNames) tea.Cmd {
+	m.owners = names
+	return m.setDirItems(m.dirItems)
+}
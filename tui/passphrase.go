@@ -0,0 +1,66 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Holds the state of the passphrase prompt
+type passphrasePrompt struct {
+	input     textinput.Model
+	wrong     bool // the previous passphrase didn't decrypt the key
+	submitted bool
+}
+
+func (m passphrasePrompt) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m passphrasePrompt) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			return m, tea.Quit
+		case "enter":
+			m.submitted = true
+			return m, tea.Quit
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m passphrasePrompt) View() string {
+	if m.submitted {
+		return ""
+	}
+	view := m.input.View() + "\n"
+	if m.wrong {
+		view = errorMessageStyle("Wrong passphrase, try again") + "\n" + view
+	}
+	return view
+}
+
+// Ask the passphrase of the private key with a masked input, returns false
+// if the user gave up
+func AskPassphrase(privateKeyPath string, wrong bool) (string, bool) {
+	input := textinput.New()
+	input.Prompt = fmt.Sprintf("Passphrase for %s: ", privateKeyPath)
+	input.EchoMode = textinput.EchoPassword
+	input.EchoCharacter = '•'
+	input.Focus()
+
+	finalModel, err := tea.NewProgram(passphrasePrompt{input: input, wrong: wrong}).StartReturningModel()
+	if err != nil {
+		return "", false
+	}
+	prompt := finalModel.(passphrasePrompt)
+	if !prompt.submitted {
+		return "", false
+	}
+	return prompt.input.Value(), true
+}
@@ -0,0 +1,183 @@
+package tui
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+	"golang.org/x/crypto/ssh"
+)
+
+// Keep the marked items, or the highlighted one, to paste them in another
+// directory of the server
+func (m *Model) copyItems() tea.Cmd {
+	m.copied = nil
+	for _, i := range m.targetItems() {
+		if i.rawValue.Name() == ".." {
+			continue
+		}
+		i.marked = false
+		source := copySource{
+			remotePath: m.remote.Join(m.currentDir, i.rawValue.Name()),
+			name:       i.rawValue.Name(),
+		}
+		// The size of a directory isn't known without walking it
+		if !i.rawValue.IsDir() {
+			source.size = i.size()
+		}
+		m.copied = append(m.copied, source)
+	}
+	if len(m.copied) == 0 {
+		return nil
+	}
+	return m.setStatus(fmt.Sprintf("Copied %d items, %s pastes them", len(m.copied), keys.Paste.Help().Key))
+}
+
+// Queue the copies of the copied items into the current directory, an item
+// already there is copied under another name
+func (m *Model) pasteItems() tea.Cmd {
+	if len(m.copied) == 0 {
+		return m.setStatus(fmt.Sprintf("Nothing to paste, %s copies the items", keys.Copy.Help().Key))
+	}
+	sshClient, remote, currentDir := m.sshClient, m.remote, m.currentDir
+	var cmds []tea.Cmd
+	for _, source := range m.copied {
+		source := source
+		cmds = append(cmds, m.queue.add(&transfer{
+			name:        source.name,
+			source:      source.remotePath,
+			destination: currentDir,
+			upload:      true,
+			total:       source.size,
+			copyFunc: func(counter io.Writer) error {
+				return remoteCopy(sshClient, remote, source.remotePath, currentDir, counter)
+			},
+		}))
+	}
+	return tea.Batch(cmds...)
+}
+
+// Copy the remote item into the directory with cp on the server, when it
+// can't run the data streams through the client
+func remoteCopy(sshClient *ssh.Client, remote remotefs.FileSystem, source, dir string, counter io.Writer) error {
+	fileInfo, err := remote.Lstat(source)
+	if err != nil {
+		return err
+	}
+	destination := copyName(remote, dir, path.Base(source), fileInfo.IsDir())
+	if fileInfo.IsDir() && strings.HasPrefix(destination, source+"/") {
+		return fmt.Errorf("can't copy %s inside itself", source)
+	}
+
+	if session, err := newSession(sshClient); err == nil {
+		var stderr bytes.Buffer
+		session.Stderr = &stderr
+		command := fmt.Sprintf("cp -Rp -- %s %s", shellQuote(source), shellQuote(destination))
+		slog.Info("remote command", "command", command)
+		err := session.Run(command)
+		session.Close()
+		var exitErr *ssh.ExitError
+		switch {
+		// 127 is the exit status of a command not found
+		case errors.As(err, &exitErr) && exitErr.ExitStatus() == 127:
+		case err != nil:
+			return commandError(err, &stderr)
+		default:
+			// A server allowing only sftp may run no command at all
+			if _, err := remote.Lstat(destination); err == nil {
+				return nil
+			}
+		}
+	}
+	return streamCopy(remote, source, destination, counter)
+}
+
+// Get a name for the copy not taken in the directory: the name itself, then
+// "name copy.ext", "name copy 2.ext" and so on
+func copyName(remote remotefs.FileSystem, dir, name string, isDir bool) string {
+	ext := path.Ext(name)
+	if isDir || ext == name {
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for n := 1; ; n++ {
+		if _, err := remote.Lstat(remote.Join(dir, candidate)); err != nil {
+			return remote.Join(dir, candidate)
+		}
+		if n == 1 {
+			candidate = base + " copy" + ext
+		} else {
+			candidate = fmt.Sprintf("%s copy %d%s", base, n, ext)
+		}
+	}
+}
+
+// Copy the remote item reading and writing it through the client, the
+// directories with all their content. The bytes copied are written to the
+// counter.
+func streamCopy(remote remotefs.FileSystem, source, destination string, counter io.Writer) error {
+	fileInfo, err := remote.Lstat(source)
+	if err != nil {
+		return err
+	}
+	switch {
+	case fileInfo.Mode()&os.ModeSymlink != 0:
+		target, err := remote.ReadLink(source)
+		if err != nil {
+			return err
+		}
+		return remote.Symlink(target, destination)
+
+	case fileInfo.IsDir():
+		if err := remote.Mkdir(destination); err != nil {
+			return err
+		}
+		children, err := remote.ReadDir(source)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			if err := streamCopy(remote, remote.Join(source, child.Name()), remote.Join(destination, child.Name()), counter); err != nil {
+				return err
+			}
+		}
+		return copyMode(remote, destination, fileInfo.Mode())
+
+	case fileInfo.Mode().IsRegular():
+		srcFile, err := remote.Open(source)
+		if err != nil {
+			return err
+		}
+		defer srcFile.Close()
+		destFile, err := remote.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(destFile, io.TeeReader(srcFile, counter)); err != nil {
+			destFile.Close()
+			return err
+		}
+		if err := destFile.Close(); err != nil {
+			return err
+		}
+		return copyMode(remote, destination, fileInfo.Mode())
+	}
+	// Sockets, pipes and devices can't be copied
+	return nil
+}
+
+// Give the copy the permissions of the source, when the server has them
+func copyMode(remote remotefs.FileSystem, destination string, mode os.FileMode) error {
+	if err := remote.Chmod(destination, mode.Perm()); !errors.Is(err, errors.ErrUnsupported) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package tui
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/throttle"
+)
+
+// A condition on the entries of a pattern filter
+type patternTerm func(info fs.FileInfo) bool
+
+// Restricts the listing to the entries matching all its terms, like
+// `*.log size>10M mtime<7d`
+type patternFilter struct {
+	expr  string
+	terms []patternTerm
+}
+
+// Parse the terms of the filter separated by spaces:
+//
+//	*.log or log    glob of the name, or text in the name ignoring the case
+//	type:dir        dir, file or link
+//	size>10M        size compared with >, >=, <, <= or =, K, M and G are powers of 1024
+//	mtime<7d        modified less than 7 days ago, s, m, h, d and w are the units
+//	!term           the entries not matching the term
+func parsePatternFilter(expr string) (*patternFilter, error) {
+	filter := &patternFilter{expr: strings.TrimSpace(expr)}
+	for _, word := range strings.Fields(expr) {
+		term, err := parsePatternTerm(strings.TrimPrefix(word, "!"))
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(word, "!") {
+			matches := term
+			term = func(info fs.FileInfo) bool { return !matches(info) }
+		}
+		filter.terms = append(filter.terms, term)
+	}
+	return filter, nil
+}
+
+func parsePatternTerm(word string) (patternTerm, error) {
+	switch {
+	case strings.HasPrefix(word, "type:"):
+		switch kind := strings.TrimPrefix(word, "type:"); kind {
+		case "dir", "d":
+			return func(info fs.FileInfo) bool { return info.IsDir() }, nil
+		case "file", "f":
+			return func(info fs.FileInfo) bool { return info.Mode().IsRegular() }, nil
+		case "link", "l":
+			return func(info fs.FileInfo) bool { return info.Mode()&fs.ModeSymlink != 0 }, nil
+		default:
+			return nil, fmt.Errorf("unknown type %q, use dir, file or link", kind)
+		}
+	case isComparison(word, "size"):
+		op, value, err := splitComparison(word, "size")
+		if err != nil {
+			return nil, err
+		}
+		size, err := throttle.ParseRate(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size %q", value)
+		}
+		return func(info fs.FileInfo) bool { return compare(info.Size(), op, size) }, nil
+	case isComparison(word, "mtime"):
+		op, value, err := splitComparison(word, "mtime")
+		if err != nil {
+			return nil, err
+		}
+		age, err := parseAge(value)
+		if err != nil {
+			return nil, err
+		}
+		// Younger files have a smaller age
+		return func(info fs.FileInfo) bool {
+			return compare(int64(time.Since(info.ModTime())), op, int64(age))
+		}, nil
+	default:
+		return func(info fs.FileInfo) bool { return matchName(word, info.Name()) }, nil
+	}
+}
+
+// Tell if the term compares the field, like size>10M; a name like sizes.txt
+// is matched as it is
+func isComparison(word, field string) bool {
+	return strings.HasPrefix(word, field) && len(word) > len(field) && strings.ContainsRune("<>=", rune(word[len(field)]))
+}
+
+// Split a term like size>=10M in its operator and value
+func splitComparison(word, field string) (string, string, error) {
+	rest := strings.TrimPrefix(word, field)
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(rest, op) {
+			return op, rest[len(op):], nil
+		}
+	}
+	return "", "", fmt.Errorf("invalid term %q, use %s>, %s< or %s= followed by a value", word, field, field, field)
+}
+
+func compare(a int64, op string, b int64) bool {
+	switch op {
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	default:
+		return a == b
+	}
+}
+
+// Parse an age like 30m, 12h, 7d or 2w
+func parseAge(value string) (time.Duration, error) {
+	units := map[byte]time.Duration{
+		's': time.Second,
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}
+	if value == "" {
+		return 0, fmt.Errorf("missing age, like 7d")
+	}
+	unit, ok := units[value[len(value)-1]]
+	if !ok {
+		return 0, fmt.Errorf("invalid age %q, use s, m, h, d or w like 7d", value)
+	}
+	n, err := strconv.ParseFloat(value[:len(value)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q, use s, m, h, d or w like 7d", value)
+	}
+	return time.Duration(n * float64(unit)), nil
+}
+
+// Tell if the entry matches all the terms
+func (f *patternFilter) match(info fs.FileInfo) bool {
+	for _, term := range f.terms {
+		if !term(info) {
+			return false
+		}
+	}
+	return true
+}
+
+// Restrict the listing to the entries matching the filter, an empty one
+// shows them all again
+func (m *Model) setPatternFilter(expr string) tea.Cmd {
+	if strings.TrimSpace(expr) == "" {
+		m.pattern = nil
+	} else {
+		filter, err := parsePatternFilter(expr)
+		if err != nil {
+			m.setError(fmt.Errorf("filtering failed: %v", err))
+			return nil
+		}
+		m.pattern = filter
+	}
+	m.updateTitle()
+	return m.setDirItems(m.dirItems)
+}
+
+// Keep the items matching the pattern filter, the parent directory always
+// stays
+func (m *Model) filterPattern(items []list.Item) ([]list.Item, int) {
+	if m.pattern == nil {
+		return items, 0
+	}
+	var kept []list.Item
+	for _, listItem := range items {
+		info := listItem.(*item).rawValue
+		if info.Name() == ".." || m.pattern.match(info) {
+			kept = append(kept, listItem)
+		}
+	}
+	return kept, len(items) - len(kept)
+}
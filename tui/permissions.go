@@ -0,0 +1,213 @@
+package tui
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/pkg/sftp"
+)
+
+// Positions of the cursor in the permissions form after the 9 mode bits
+const (
+	uidField = 9 + iota
+	gidField
+	permissionsFields
+)
+
+var selectedBitStyle = lipgloss.NewStyle().Reverse(true).Render
+
+// Holds the state of the form changing the permissions of the items
+type permissionsForm struct {
+	items            []*item     // the items to change
+	mode             fs.FileMode // the permission bits
+	cursor           int         // the bit, or the field, being edited
+	uid, gid         textinput.Model
+	origUID, origGID string // the owner before editing, to change it only when edited
+}
+
+// Open the permissions form for the marked items, or the highlighted one,
+// starting from the mode and owner of the first
+func (m *Model) openPermissions() tea.Cmd {
+	items := m.targetItems()
+	if len(items) == 0 {
+		return nil
+	}
+
+	form := &permissionsForm{items: items, mode: items[0].rawValue.Mode().Perm()}
+	form.uid, form.gid = textinput.New(), textinput.New()
+	form.uid.Prompt, form.gid.Prompt = "uid: ", "gid: "
+	form.uid.CharLimit, form.gid.CharLimit = 10, 10
+	if stat, ok := items[0].rawValue.Sys().(*sftp.FileStat); ok {
+		form.origUID = strconv.FormatUint(uint64(stat.UID), 10)
+		form.origGID = strconv.FormatUint(uint64(stat.GID), 10)
+	}
+	form.uid.SetValue(form.origUID)
+	form.gid.SetValue(form.origGID)
+	m.permissions = form
+	return form.moveTo(0)
+}
+
+// Get the mode bit under the position of the cursor, from owner read to
+// others execute
+func permissionBit(position int) fs.FileMode {
+	return 1 << (8 - position)
+}
+
+// Move the cursor to the position, focusing the owner inputs
+func (f *permissionsForm) moveTo(position int) tea.Cmd {
+	f.cursor = (position + permissionsFields) % permissionsFields
+	f.uid.Blur()
+	f.gid.Blur()
+	switch f.cursor {
+	case uidField:
+		return f.uid.Focus()
+	case gidField:
+		return f.gid.Focus()
+	}
+	return nil
+}
+
+// Handle the key presses while the permissions form is open
+func (m Model) updatePermissions(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	form := m.permissions
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.permissions = nil
+		return m, nil
+	case "enter":
+		m.permissions = nil
+		return m, m.applyPermissions(form)
+	case "tab", "down":
+		if form.cursor < uidField && msg.String() == "down" {
+			return m, form.moveTo(form.cursor + 3)
+		}
+		return m, form.moveTo(form.cursor + 1)
+	case "shift+tab", "up":
+		if form.cursor < uidField && msg.String() == "up" {
+			return m, form.moveTo(form.cursor - 3)
+		}
+		return m, form.moveTo(form.cursor - 1)
+	}
+
+	switch form.cursor {
+	case uidField:
+		var cmd tea.Cmd
+		form.uid, cmd = form.uid.Update(msg)
+		return m, cmd
+	case gidField:
+		var cmd tea.Cmd
+		form.gid, cmd = form.gid.Update(msg)
+		return m, cmd
+	}
+
+	switch msg.String() {
+	case "left", "h":
+		return m, form.moveTo(form.cursor - 1)
+	case "right", "l":
+		return m, form.moveTo(form.cursor + 1)
+	case "k":
+		return m, form.moveTo(form.cursor - 3)
+	case "j":
+		return m, form.moveTo(form.cursor + 3)
+	case " ":
+		form.mode ^= permissionBit(form.cursor)
+	case "r", "w", "x":
+		// Toggle the bit in the row of the cursor
+		column := strings.Index("rwx", msg.String())
+		form.mode ^= permissionBit(form.cursor/3*3 + column)
+	}
+	return m, nil
+}
+
+// Change the mode, and the owner if edited, of the items
+func (m *Model) applyPermissions(form *permissionsForm) tea.Cmd {
+	chown := form.uid.Value() != form.origUID || form.gid.Value() != form.origGID
+	uid, err := strconv.ParseUint(form.uid.Value(), 10, 32)
+	if chown && err != nil {
+		return reportError(fmt.Errorf("invalid uid %q", form.uid.Value()))
+	}
+	gid, err := strconv.ParseUint(form.gid.Value(), 10, 32)
+	if chown && err != nil {
+		return reportError(fmt.Errorf("invalid gid %q", form.gid.Value()))
+	}
+
+	remote := m.remote
+	currentDir := m.currentDir
+	items := form.items
+	if m.dryRun {
+		var lines []string
+		for _, i := range items {
+			i.marked = false
+			remotePath := remote.Join(currentDir, i.rawValue.Name())
+			lines = append(lines, fmt.Sprintf("chmod %s %s", form.mode, remotePath))
+			if chown {
+				lines = append(lines, fmt.Sprintf("chown %d:%d %s", uid, gid, remotePath))
+			}
+		}
+		return dryRun(lines...)
+	}
+	return func() tea.Msg {
+		for _, i := range items {
+			i.marked = false
+			remotePath := remote.Join(currentDir, i.rawValue.Name())
+			if err := remote.Chmod(remotePath, form.mode); err != nil {
+				return tea.Batch(reportError(fmt.Errorf("chmod of %s failed: %v", i.rawValue.Name(), err)), m.changeDir(currentDir, ""))()
+			}
+			if chown {
+				if err := remote.Chown(remotePath, int(uid), int(gid)); err != nil {
+					return tea.Batch(reportError(fmt.Errorf("chown of %s failed: %v", i.rawValue.Name(), err)), m.changeDir(currentDir, ""))()
+				}
+			}
+		}
+		return m.changeDir(currentDir, fmt.Sprintf("Changed the permissions to %s", form.mode))()
+	}
+}
+
+// Render the permissions form as a modal in the middle of the screen
+func (m Model) permissionsView() string {
+	form := m.permissions
+	title := form.items[0].rawValue.Name()
+	if len(form.items) > 1 {
+		title = fmt.Sprintf("%d items", len(form.items))
+	}
+
+	lines := []string{"Permissions of " + title, "", "        read  write exec"}
+	for row, who := range []string{"owner ", "group ", "others"} {
+		line := who + "  "
+		for column := 0; column < 3; column++ {
+			position := row*3 + column
+			box := "[ ]"
+			if form.mode&permissionBit(position) != 0 {
+				box = "[" + string("rwx"[column]) + "]"
+			}
+			if position == form.cursor {
+				box = selectedBitStyle(box)
+			}
+			line += box + "   "
+		}
+		lines = append(lines, strings.TrimRight(line, " "))
+	}
+	lines = append(lines,
+		"",
+		fmt.Sprintf("mode: %s (%04o)", form.mode, uint32(form.mode)),
+		form.uid.View(),
+		form.gid.View(),
+		"",
+		"space toggle • tab next field • enter apply • esc cancel",
+	)
+
+	return lipgloss.Place(
+		m.width,
+		m.height,
+		lipgloss.Center,
+		lipgloss.Center,
+		modalStyle.Render(strings.Join(lines, "\n")),
+	)
+}
@@ -0,0 +1,169 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Bytes read from the remote file for the preview, when not set
+const defaultPreviewSize = 64 * 1024
+
+// Message sent when the beginning of the previewed file has been read
+type previewMsg struct {
+	name    string
+	content string
+	image   image.Image // thumbnail of the previewed image, nil for the other files
+	binary  []byte      // the bytes of the previewed binary file, dumped in hex
+	hex     *hexView    // the page of the file the bytes are, nil for the other files
+}
+
+// Read the beginning of the remote file in the background
+func (m *Model) previewFile(fileInfo fs.FileInfo) tea.Cmd {
+	remote := m.remote
+	remotePath := remote.Join(m.currentDir, fileInfo.Name())
+	previewSize := m.previewSize
+	return func() tea.Msg {
+		file, err := remote.Open(remotePath)
+		if err != nil {
+			return errorMsg{err: fmt.Errorf("previewing %s failed: %v", fileInfo.Name(), err)}
+		}
+		defer file.Close()
+
+		if isImage(fileInfo.Name()) {
+			msg, err := readImage(file, fileInfo)
+			if err == nil {
+				return msg
+			}
+			// Not an image after all, it's previewed like the other files
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return errorMsg{err: fmt.Errorf("previewing %s failed: %v", fileInfo.Name(), err)}
+			}
+		}
+
+		content, err := io.ReadAll(io.LimitReader(file, previewSize))
+		if err != nil {
+			return errorMsg{err: fmt.Errorf("previewing %s failed: %v", fileInfo.Name(), err)}
+		}
+
+		if !isText(content) {
+			// The next pages are read from the hex view
+			view := hexView{remotePath: remotePath, info: fileInfo, page: previewSize}
+			return view.message(content)
+		}
+		text := highlight(fileInfo.Name(), displayText(strings.ReplaceAll(string(content), "\t", "    ")))
+		if fileInfo.Size() > previewSize {
+			text += fmt.Sprintf("\n\n… showing the first %s of %s", ConvertBytesToSizeString(previewSize), ConvertBytesToSizeString(fileInfo.Size()))
+		}
+		return previewMsg{name: fileInfo.Name(), content: text}
+	}
+}
+
+// Guess if the content is text: valid UTF-8 without NUL bytes.
+// The last rune may have been cut by the preview size.
+func isText(content []byte) bool {
+	if bytes.IndexByte(content, 0) != -1 {
+		return false
+	}
+	for len(content) > 0 {
+		r, size := utf8.DecodeRune(content)
+		if r == utf8.RuneError && size == 1 && len(content) > utf8.UTFMax {
+			return false
+		}
+		content = content[size:]
+	}
+	return true
+}
+
+// Describe the file properties, one per line
+func fileMetadata(fileInfo fs.FileInfo) string {
+	return fmt.Sprintf(
+		"Name:     %s\nSize:     %s (%d bytes)\nMode:     %s\nModified: %s",
+		fileInfo.Name(),
+		ConvertBytesToSizeString(fileInfo.Size()),
+		fileInfo.Size(),
+		fileInfo.Mode(),
+		fileInfo.ModTime().Format("2006-01-02 15:04:05"),
+	)
+}
+
+// Show the preview pane with the content
+func (m *Model) openPreview(msg previewMsg) {
+	m.previewName = msg.name
+	m.previewContent = msg.content
+	m.previewImage = msg.image
+	m.previewBinary = msg.binary
+	m.hex = msg.hex
+	m.preview = viewport.New(0, 0)
+	m.follow = nil
+	m.resize()
+}
+
+// Handle the key presses while the preview is shown
+func (m Model) updatePreview(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q", "p":
+		m.previewName = ""
+		m.follow = nil
+		m.hex = nil
+		return m, nil
+	}
+	if m.hex != nil {
+		if cmd, ok := m.updateHexView(msg); ok {
+			return m, cmd
+		}
+	}
+	if m.follow != nil {
+		if cmd, ok := m.updateFollow(msg); ok {
+			return m, cmd
+		}
+	}
+
+	var cmd tea.Cmd
+	m.preview, cmd = m.preview.Update(msg)
+	return m, cmd
+}
+
+// Fit the preview in the space of the list
+func (m *Model) resizePreview(width, height int) {
+	// Leave a line for the title
+	m.preview.Width, m.preview.Height = width, height-1
+	if m.previewImage != nil {
+		// The image fills the pane below its description
+		m.preview.SetContent(m.previewContent + "\n" + renderImage(m.previewImage, width, height-2))
+		return
+	}
+	if m.previewBinary != nil {
+		// As many bytes per line as fit in the pane
+		var offset int64
+		if m.hex != nil {
+			offset = m.hex.offset
+		}
+		m.preview.SetContent(m.previewContent + "\n\n" + hexDump(m.previewBinary, offset, width))
+		return
+	}
+	m.preview.SetContent(lipgloss.NewStyle().Width(width).Render(m.previewContent))
+}
+
+// Render the title and the visible part of the preview
+func (m Model) previewView() string {
+	name := displayName(m.previewName)
+	if m.follow != nil {
+		name += " · " + m.follow.String()
+	}
+	if m.hex != nil {
+		name += " · " + m.hex.String()
+	}
+	title := previewTitleStyle.Render(fmt.Sprintf("%s %3.0f%%", name, m.preview.ScrollPercent()*100))
+	return lipgloss.JoinVertical(lipgloss.Left, title, m.preview.View())
+}
@@ -0,0 +1,94 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/config"
+)
+
+// Rapresents a saved profile as an item of the picker list
+type profileItem struct {
+	profile config.Profile
+}
+
+func (i profileItem) Title() string { return i.profile.Name }
+
+// Show where the profile connects to
+func (i profileItem) Description() string {
+	description := i.profile.Host
+	if i.profile.Username != "" {
+		description = i.profile.Username + "@" + description
+	}
+	if i.profile.Port != "" {
+		description += ":" + i.profile.Port
+	}
+	return description
+}
+
+func (i profileItem) FilterValue() string { return i.profile.Name }
+
+// Holds the state of the profile picker
+type profilePicker struct {
+	List   list.Model
+	chosen *config.Profile
+}
+
+func (m profilePicker) Init() tea.Cmd {
+	return nil
+}
+
+func (m profilePicker) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if m.List.FilterState() == list.Filtering {
+			break
+		}
+		switch msg.String() {
+		case "ctrl+c":
+			return m, tea.Quit
+		case "enter":
+			if selectedItem, ok := m.List.SelectedItem().(profileItem); ok {
+				m.chosen = &selectedItem.profile
+			}
+			return m, tea.Quit
+		}
+
+	case tea.WindowSizeMsg:
+		h, v := docStyle.GetFrameSize()
+		m.List.SetSize(msg.Width-h, msg.Height-v)
+	}
+
+	var cmd tea.Cmd
+	m.List, cmd = m.List.Update(msg)
+	return m, cmd
+}
+
+func (m profilePicker) View() string {
+	return docStyle.Render(m.List.View())
+}
+
+// Let the user pick one of the profiles, returns false if none was chosen
+func PickProfile(profiles []config.Profile) (config.Profile, bool) {
+	items := make([]list.Item, len(profiles))
+	for i, profile := range profiles {
+		items[i] = profileItem{profile: profile}
+	}
+
+	m := profilePicker{
+		List: list.New(items, newDelegate(), 0, 0),
+	}
+	m.List.Title = "Profiles"
+
+	finalModel, err := tea.NewProgram(m, tea.WithAltScreen()).StartReturningModel()
+	if err != nil {
+		fmt.Println("Error running program:", err)
+		return config.Profile{}, false
+	}
+	chosen := finalModel.(profilePicker).chosen
+	if chosen == nil {
+		return config.Profile{}, false
+	}
+	return *chosen, true
+}
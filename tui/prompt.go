@@ -0,0 +1,121 @@
+package tui
+
+import (
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// The action to run when the prompt is submitted
+type promptAction int
+
+const (
+	noPrompt promptAction = iota
+	uploadPrompt
+	renamePrompt
+	mkdirPrompt
+	downloadPrompt
+	localDirPrompt
+	gotoPrompt
+	searchPrompt
+	grepPrompt
+	syncPushPrompt
+	syncPullPrompt
+	rateLimitPrompt
+	commandPrompt
+	watchPrompt
+	followSearchPrompt
+	patternPrompt
+	hexOffsetPrompt
+	diffPrompt
+)
+
+// Create the text input used by the prompts
+func newPrompt() textinput.Model {
+	prompt := textinput.New()
+	prompt.CharLimit = 4096
+	return prompt
+}
+
+// Open the prompt for the given action
+func (m *Model) openPrompt(action promptAction, label, value string) tea.Cmd {
+	m.promptAction = action
+	m.prompt.Prompt = label
+	m.prompt.SetValue(value)
+	m.prompt.CursorEnd()
+	return m.prompt.Focus()
+}
+
+// Close the prompt discarding its value
+func (m *Model) closePrompt() {
+	m.promptAction = noPrompt
+	m.prompt.Blur()
+	m.prompt.Reset()
+}
+
+// Handle the key presses while the prompt is open
+func (m Model) updatePrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.closePrompt()
+		return m, nil
+	case "tab":
+		if m.promptAction == gotoPrompt {
+			return m, m.completePath(m.prompt.Value())
+		}
+	case "enter":
+		action, value := m.promptAction, m.prompt.Value()
+		m.closePrompt()
+		// Emptying the filter shows all the entries again
+		if action == patternPrompt {
+			return m, m.setPatternFilter(value)
+		}
+		if value == "" {
+			return m, nil
+		}
+		switch action {
+		case uploadPrompt:
+			return m, tea.Batch(
+				m.setStatus("Uploading "+value),
+				m.uploadFiles(value),
+			)
+		case renamePrompt:
+			return m, m.renameItems(m.targetItems(), value)
+		case mkdirPrompt:
+			return m, m.makeDir(value)
+		case downloadPrompt:
+			return m, m.downloadTo(m.targetItems(), value)
+		case localDirPrompt:
+			return m, m.setLocalDir(value)
+		case gotoPrompt:
+			return m, m.goTo(value)
+		case grepPrompt:
+			return m, m.startGrep(value)
+		case searchPrompt:
+			return m, m.startSearch(value)
+		case syncPushPrompt:
+			return m, m.planSync(value, true)
+		case syncPullPrompt:
+			return m, m.planSync(value, false)
+		case rateLimitPrompt:
+			return m, m.setRateLimit(value)
+		case commandPrompt:
+			return m, m.runCommand(value)
+		case followSearchPrompt:
+			m.searchFollowed(value)
+			return m, nil
+		case hexOffsetPrompt:
+			return m, m.gotoHexOffset(value)
+		case diffPrompt:
+			return m, m.diffFile(value)
+		case watchPrompt:
+			return m, m.startWatch(value)
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.prompt, cmd = m.prompt.Update(msg)
+	return m, cmd
+}
@@ -0,0 +1,246 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+	"github.com/guglielmobartelloni/sftp-tui/throttle"
+)
+
+// Lines taken by the queue pane, header included
+const queuePaneHeight = 8
+
+var queueHeaderStyle = lipgloss.NewStyle().Bold(true).Render
+
+// Holds the transfers and runs up to concurrency of them at the same time.
+// It's shared between the copies of the model and changed only in Update.
+type transferQueue struct {
+	transfers   []*transfer
+	concurrency int
+	nextID      int
+	throughput  throughput        // speed of the transfers since the queue got busy
+	limiter     *throttle.Limiter // caps the speed of all the transfers together
+	retryPolicy retryPolicy       // how the failed transfers are tried again
+	dryRun      bool              // whether the transfers are only reported
+	// closed when quitting, the running copies stop and no other starts
+	stop chan struct{}
+	// whether the files left by the canceled copies are kept to resume them
+	keepPartial bool
+	cleaning    int // partial files being removed
+	// the remotefs.FileSystem of the current connection, the retries resume
+	// with it after a reconnection
+	remote atomic.Value
+}
+
+func newTransferQueue(concurrency int, retry retryPolicy, limiter *throttle.Limiter) *transferQueue {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &transferQueue{concurrency: concurrency, retryPolicy: retry, limiter: limiter, stop: make(chan struct{})}
+}
+
+// Get the filesystem of the current connection
+func (q *transferQueue) currentRemote() remotefs.FileSystem {
+	return q.remote.Load().(remotefs.FileSystem)
+}
+
+// Enqueue a transfer and start it if a worker is free. In a dry run it's
+// only reported.
+func (q *transferQueue) add(t *transfer) tea.Cmd {
+	if q.dryRun {
+		return dryRun(t.describe())
+	}
+	q.nextID++
+	t.id, t.state = q.nextID, transferPending
+	q.transfers = append(q.transfers, t)
+	if q.stopped() {
+		t.state, t.err = transferCanceled, errTransferCanceled
+		return nil
+	}
+	return q.schedule()
+}
+
+// Enqueue the transfer again, as a new one
+func (q *transferQueue) retry(t *transfer) tea.Cmd {
+	return q.add(&transfer{
+		name:        t.name,
+		source:      t.source,
+		destination: t.destination,
+		file:        t.file,
+		upload:      t.upload,
+		resumed:     t.resumed,
+		total:       t.total,
+		copyFunc:    t.copyFunc,
+		resumeFunc:  t.resumeFunc,
+	})
+}
+
+// Start the pending transfers while there are free workers
+func (q *transferQueue) schedule() tea.Cmd {
+	var cmds []tea.Cmd
+	pending, active := q.counts()
+	for _, t := range q.transfers {
+		if pending == 0 || active >= q.concurrency {
+			break
+		}
+		if t.state == transferPending {
+			q.throughput.start(time.Now())
+			slog.Info("transfer started", "name", t.name, "upload", t.upload, "size", t.total)
+			t.state, t.started = transferActive, time.Now()
+			cmds = append(cmds, t.run(q.limiter, q.retryPolicy, q.currentRemote, q.stop))
+			pending--
+			active++
+		}
+	}
+	return tea.Batch(cmds...)
+}
+
+// Record the bytes copied by the transfer so far
+func (q *transferQueue) progress(t *transfer, transferred int64) {
+	// A retry starts counting again, the bytes were measured already
+	if transferred > t.transferred {
+		q.throughput.add(transferred-t.transferred, time.Now())
+	}
+	t.transferred = transferred
+	t.state = transferActive
+}
+
+// Record the failure of the transfer tried again after the wait
+func (q *transferQueue) retrying(t *transfer, msg transferRetryMsg) {
+	slog.Warn("transfer failed, retrying", "name", t.name, "attempt", msg.attempt, "wait", msg.wait, "err", msg.err)
+	t.state, t.err = transferRetrying, msg.err
+	t.attempt, t.retryWait = msg.attempt, msg.wait
+}
+
+// Mark the transfer as finished, the speed is measured again once all the
+// transfers are over
+func (q *transferQueue) finish(t *transfer, err error) {
+	// The copies failing once stopped have been cut off
+	if err != nil && q.stopped() {
+		err = errTransferCanceled
+	}
+	switch {
+	case errors.Is(err, errTransferCanceled):
+		slog.Info("transfer canceled", "name", t.name, "upload", t.upload, "bytes", t.transferred)
+		t.state, t.err = transferCanceled, err
+	case err != nil:
+		slog.Error("transfer failed", "name", t.name, "upload", t.upload, "err", err)
+		t.state, t.err = transferFailed, err
+	default:
+		slog.Info("transfer done", "name", t.name, "upload", t.upload, "bytes", t.transferred)
+		t.err = nil
+		// The size of the streamed transfers isn't known
+		if t.transferred < t.total {
+			q.progress(t, t.total)
+		}
+		t.state = transferDone
+	}
+	if pending, active := q.counts(); pending == 0 && active == 0 {
+		q.throughput = throughput{}
+	}
+}
+
+// Stop the transfers to quit: the pending ones are canceled, the running
+// ones stop at their next write
+func (q *transferQueue) cancel(keepPartial bool) {
+	if q.stopped() {
+		return
+	}
+	q.keepPartial = keepPartial
+	close(q.stop)
+	for _, t := range q.transfers {
+		if t.state == transferPending {
+			t.state, t.err = transferCanceled, errTransferCanceled
+		}
+	}
+}
+
+// Tell if the transfers have been stopped
+func (q *transferQueue) stopped() bool {
+	select {
+	case <-q.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// Find the transfer with the given id
+func (q *transferQueue) get(id int) *transfer {
+	for _, t := range q.transfers {
+		if t.id == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// Count the transfers waiting and running
+func (q *transferQueue) counts() (pending, active int) {
+	for _, t := range q.transfers {
+		switch t.state {
+		case transferPending:
+			pending++
+		case transferActive, transferRetrying:
+			active++
+		}
+	}
+	return pending, active
+}
+
+// Count the bytes copied and to copy by the unfinished transfers of known
+// size
+func (q *transferQueue) bytes() (transferred, total int64) {
+	for _, t := range q.transfers {
+		if (t.state == transferPending || t.state == transferActive || t.state == transferRetrying) && t.total > 0 {
+			transferred += t.transferred
+			total += t.total
+		}
+	}
+	return transferred, total
+}
+
+// Percentage of the bytes copied by the unfinished transfers
+func (q *transferQueue) percent() float64 {
+	transferred, total := q.bytes()
+	if total == 0 {
+		return 0
+	}
+	return float64(transferred) / float64(total)
+}
+
+// Render the last transfers of the queue, one per line
+func (q *transferQueue) View() string {
+	lines := []string{queueHeaderStyle("Transfers")}
+
+	// Show the most recent transfers that fit in the pane
+	transfers := q.transfers
+	if len(transfers) > queuePaneHeight-1 {
+		transfers = transfers[len(transfers)-queuePaneHeight+1:]
+	}
+	for _, t := range transfers {
+		direction := "↓"
+		if t.upload {
+			direction = "↑"
+		}
+		line := fmt.Sprintf("%s %-8s %3.0f%% %s", direction, t.state, t.percent()*100, displayName(t.name))
+		if t.state == transferRetrying {
+			line += fmt.Sprintf(": attempt %d of %d in %s", t.attempt+1, q.retryPolicy.retries+1, t.retryWait)
+		}
+		if t.err != nil {
+			line += ": " + t.err.Error()
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) < queuePaneHeight {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
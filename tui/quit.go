@@ -0,0 +1,145 @@
+package tui
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/chunked"
+)
+
+// Transfers listed when asking to quit, the others are counted
+const maxQuitTransfers = 8
+
+// Type of the message sent by tea.Quit, it's unexported
+var quitMsgType = reflect.TypeOf(tea.Quit())
+
+// Message sent when the user confirmed to quit stopping the transfers
+type quitTransfersMsg struct {
+	keepPartial bool // whether the files left by the stopped copies are kept
+}
+
+// Message asking a tab to stop its transfers to quit
+type cancelTransfersMsg struct {
+	keepPartial bool
+}
+
+// Message sent when the file left by a canceled transfer has been removed
+type partialRemovedMsg struct{}
+
+// Quit, asking first when transfers are running in any tab. Quitting again
+// while they stop doesn't wait for them.
+func (t tabs) quit() (tea.Model, tea.Cmd) {
+	transfers := t.runningTransfers()
+	if t.quitting || len(transfers) == 0 {
+		return t, tea.Quit
+	}
+
+	question := fmt.Sprintf("%d transfers are running, quitting stops them", len(transfers))
+	if len(transfers) == 1 {
+		question = "A transfer is running, quitting stops it"
+	}
+	lines := []string{question, ""}
+	if len(transfers) > maxQuitTransfers {
+		lines = append(lines, transfers[:maxQuitTransfers]...)
+		lines = append(lines, fmt.Sprintf("… and %d more", len(transfers)-maxQuitTransfers))
+	} else {
+		lines = append(lines, transfers...)
+	}
+	lines = append(lines, "", "The partial files can be kept to resume them.")
+
+	quit := func(keepPartial bool) func(*Model) tea.Cmd {
+		return func(*Model) tea.Cmd {
+			return func() tea.Msg { return quitTransfersMsg{keepPartial: keepPartial} }
+		}
+	}
+	t.tabs[t.active].model.askChoice(
+		strings.Join(lines, "\n"),
+		choice{key: "r", label: "quit removing the partial files", action: quit(false)},
+		choice{key: "k", label: "quit keeping them", action: quit(true)},
+		choice{key: "c", label: "cancel", action: func(*Model) tea.Cmd { return nil }},
+	)
+	return t, nil
+}
+
+// Describe the transfers waiting and running in all the tabs, one per line
+func (t tabs) runningTransfers() []string {
+	var lines []string
+	for _, tab := range t.tabs {
+		for _, transfer := range tab.model.queue.transfers {
+			if transfer.state != transferPending && transfer.state != transferActive && transfer.state != transferRetrying {
+				continue
+			}
+			direction := "↓"
+			if transfer.upload {
+				direction = "↑"
+			}
+			line := fmt.Sprintf("%s %-8s %3.0f%% %s", direction, transfer.state, transfer.percent()*100, displayName(transfer.name))
+			if len(t.tabs) > 1 {
+				line += " on " + tab.model.host
+			}
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// Stop the transfers of all the tabs, the program quits once they are over
+func (t tabs) stopTransfers(keepPartial bool) (tea.Model, tea.Cmd) {
+	t.quitting = true
+	var cmds []tea.Cmd
+	for _, tab := range t.tabs {
+		cmds = append(cmds, t.updateTab(tab, cancelTransfersMsg{keepPartial: keepPartial}))
+	}
+	if t.stopped() {
+		return t, tea.Quit
+	}
+	return t, tea.Batch(cmds...)
+}
+
+// Tell if the transfers of all the tabs are over, and their partial files
+// removed
+func (t tabs) stopped() bool {
+	for _, tab := range t.tabs {
+		if pending, active := tab.model.queue.counts(); pending+active > 0 || tab.model.queue.cleaning > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Stop the transfers of the tab to quit
+func (m *Model) cancelTransfers(keepPartial bool) tea.Cmd {
+	_, active := m.queue.counts()
+	m.queue.cancel(keepPartial)
+	if active == 0 {
+		return nil
+	}
+	return m.setStatus(fmt.Sprintf("Stopping %d transfers…", active))
+}
+
+// Remove the file left by the canceled transfer, unless it's kept to resume
+// it: the .part one of a download, the destination of an upload. Only the
+// copies of single files creating their destination leave one.
+func (m *Model) removePartial(t *transfer) tea.Cmd {
+	if m.queue.keepPartial || !t.file || t.resumed {
+		return nil
+	}
+	m.queue.cleaning++
+	remote, destination, upload := m.remote, t.destination, t.upload
+	return func() tea.Msg {
+		var err error
+		if upload {
+			err = remote.Remove(destination)
+		} else {
+			err = os.Remove(chunked.PartPath(destination))
+		}
+		if err != nil && !os.IsNotExist(err) {
+			slog.Warn("removing the partial file failed", "path", destination, "err", err)
+		}
+		return partialRemovedMsg{}
+	}
+}
@@ -0,0 +1,36 @@
+package tui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/throttle"
+)
+
+// Turn the rate limit of the transfers off, or back on. When no limit was
+// ever set the user is asked for one.
+func (m *Model) toggleRateLimit() tea.Cmd {
+	switch {
+	case m.queue.limiter.Rate() > 0:
+		m.queue.limiter.SetRate(0)
+		return m.setStatus("Rate limit off")
+	case m.limitRate > 0:
+		return m.setRateLimit(fmt.Sprint(m.limitRate))
+	default:
+		return m.openPrompt(rateLimitPrompt, "Limit the transfers to (like 500K or 2M): ", "")
+	}
+}
+
+// Cap the speed of all the transfers, the running ones included
+func (m *Model) setRateLimit(value string) tea.Cmd {
+	rate, err := throttle.ParseRate(value)
+	if err != nil {
+		return reportError(err)
+	}
+	m.queue.limiter.SetRate(rate)
+	if rate == 0 {
+		return m.setStatus("Rate limit off")
+	}
+	m.limitRate = rate
+	return m.setStatus(fmt.Sprintf("Rate limited to %s/s", ConvertBytesToSizeString(rate)))
+}
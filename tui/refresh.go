@@ -0,0 +1,103 @@
+package tui
+
+import (
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Message sent when it's time to check the current directory for changes
+type refreshTickMsg struct{}
+
+// Message with the listing of the directory read by the periodic check
+type refreshedMsg struct {
+	path     string
+	items    []list.Item // nil when the directory couldn't be read
+	periodic bool        // whether it's the periodic check, the next one is scheduled
+}
+
+// Wait for the next check of the current directory, never when the interval
+// is 0
+func (m Model) refreshTick() tea.Cmd {
+	if m.refreshInterval <= 0 {
+		return nil
+	}
+	return tea.Tick(m.refreshInterval, func(time.Time) tea.Msg {
+		return refreshTickMsg{}
+	})
+}
+
+// Read the current directory in the background to look for changes
+func (m *Model) checkDirChanges(periodic bool) tea.Cmd {
+	remote, dirPath := m.remote, m.currentDir
+	return func() tea.Msg {
+		items, err := CreateItemListModel(dirPath, remote)
+		if err != nil {
+			// Tried again at the next check
+			return refreshedMsg{path: dirPath, periodic: periodic}
+		}
+		return refreshedMsg{path: dirPath, items: items, periodic: periodic}
+	}
+}
+
+// Show the listing when the directory changed, keeping the highlighted item
+// and the marks
+func (m *Model) applyRefresh(msg refreshedMsg) tea.Cmd {
+	var cmds []tea.Cmd
+	if msg.periodic {
+		cmds = append(cmds, m.refreshTick())
+	}
+	if msg.items == nil || msg.path != m.currentDir || m.loading != nil {
+		return tea.Batch(cmds...)
+	}
+	m.cached = false
+	m.cache.put(msg.path, msg.items)
+	if m.List.FilterState() == list.Filtering || sameListing(m.dirItems, msg.items) {
+		return tea.Batch(cmds...)
+	}
+
+	marked := make(map[string]bool)
+	for _, listItem := range m.dirItems {
+		if i := listItem.(*item); i.marked {
+			marked[i.rawValue.Name()] = true
+		}
+	}
+	for _, listItem := range msg.items {
+		i := listItem.(*item)
+		i.marked = marked[i.rawValue.Name()]
+	}
+	var selected string
+	if selectedItem, ok := m.List.SelectedItem().(*item); ok {
+		selected = selectedItem.rawValue.Name()
+	}
+	cmds = append(cmds, m.setDirItems(msg.items))
+	m.selectItem(selected)
+	cmds = append(cmds, m.setStatus("↻ Updated, the directory changed on the server"))
+	return tea.Batch(cmds...)
+}
+
+// Tell if the two listings have the same entries, with the same size,
+// mode and modification time
+func sameListing(old, new []list.Item) bool {
+	if len(old) != len(new) {
+		return false
+	}
+	entries := make(map[string]*item, len(old))
+	for _, listItem := range old {
+		i := listItem.(*item)
+		entries[i.rawValue.Name()] = i
+	}
+	for _, listItem := range new {
+		i := listItem.(*item)
+		o, ok := entries[i.rawValue.Name()]
+		if !ok ||
+			o.rawValue.Size() != i.rawValue.Size() ||
+			o.rawValue.Mode() != i.rawValue.Mode() ||
+			!o.rawValue.ModTime().Equal(i.rawValue.ModTime()) ||
+			o.linkTarget != i.linkTarget {
+			return false
+		}
+	}
+	return true
+}
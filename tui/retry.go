@@ -0,0 +1,136 @@
+package tui
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/chunked"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+)
+
+// Longest wait between two attempts of a transfer
+const maxBackoff = time.Minute
+
+// How the failed transfers are tried again
+type retryPolicy struct {
+	retries int           // attempts after the first one, 0 never retries
+	backoff time.Duration // wait before the first retry, doubled at each one
+}
+
+// Wait before the attempt, counting the retries from 1
+func (p retryPolicy) wait(attempt int) time.Duration {
+	wait := p.backoff
+	for i := 1; i < attempt && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+// Tell if the transfer may succeed when tried again, the missing files and
+// the denied permissions don't go away, the canceled transfers stay so
+func retryable(err error) bool {
+	var mismatch *checksumMismatchError
+	return !errors.As(err, &mismatch) &&
+		!errors.Is(err, errTransferCanceled) &&
+		!errors.Is(err, fs.ErrNotExist) &&
+		!errors.Is(err, fs.ErrPermission) &&
+		!errors.Is(err, fs.ErrExist)
+}
+
+// Message sent when a transfer failed and is tried again after the wait
+type transferRetryMsg struct {
+	id      int
+	attempt int
+	err     error
+	wait    time.Duration
+	updates <-chan tea.Msg // where to wait for the next update
+}
+
+// Download the remote file continuing from the size of the local one, the
+// bytes already there aren't copied again
+func resumeDownload(remote remotefs.FileSystem, remotePath, localPath string, counter io.Writer) error {
+	srcFile, err := remote.Open(remotePath)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+	srcInfo, err := srcFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	destFile, err := os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer destFile.Close()
+	offset, err := resumeOffset(destFile, srcInfo.Size())
+	if err != nil {
+		return err
+	}
+	restartCounter(counter, offset)
+	return chunked.Copy(srcFile, destFile, offset, counter)
+}
+
+// Upload the local file continuing from the size of the remote one, the
+// bytes already there aren't copied again
+func resumeUpload(remote remotefs.FileSystem, localPath, remotePath string, counter io.Writer) error {
+	srcFile, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+	srcInfo, err := srcFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	destFile, err := remote.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE)
+	if err != nil {
+		return err
+	}
+	defer destFile.Close()
+	offset, err := resumeOffset(destFile, srcInfo.Size())
+	if err != nil {
+		return err
+	}
+	if _, err := srcFile.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	restartCounter(counter, offset)
+	_, err = io.Copy(destFile, io.TeeReader(srcFile, counter))
+	return err
+}
+
+// A destination file of a resumed transfer
+type resumedFile interface {
+	io.Seeker
+	Truncate(size int64) error
+}
+
+// Move to the end of the destination, where the copy continues. When it's
+// bigger than the source it's not a part of it, the copy starts over.
+func resumeOffset(destFile resumedFile, size int64) (int64, error) {
+	offset, err := destFile.Seek(0, io.SeekEnd)
+	if err != nil || offset <= size {
+		return offset, err
+	}
+	if err := destFile.Truncate(0); err != nil {
+		return 0, err
+	}
+	return destFile.Seek(0, io.SeekStart)
+}
+
+// Count the bytes copied by the previous attempts as copied
+func restartCounter(counter io.Writer, offset int64) {
+	if wc, ok := counter.(*writeProgressCounter); ok {
+		wc.restart(offset)
+	}
+}
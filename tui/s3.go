@@ -0,0 +1,33 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+	"github.com/guglielmobartelloni/sftp-tui/throttle"
+)
+
+// Create the model browsing the objects of the bucket like files, the
+// commands needing a shell report there's none
+func newS3Model(ctx context.Context, options remotefs.S3Options, settings Settings, limiter *throttle.Limiter) (Model, error) {
+	remote, err := remotefs.DialS3(ctx, options)
+	if err != nil {
+		return Model{}, err
+	}
+	currentDir, items, err := openStartDir(remote, remoteStartDir(settings.RemoteDir))
+	if err != nil {
+		remote.Close()
+		return Model{}, err
+	}
+	m, err := newTabModel(remote, currentDir, items, settings, limiter)
+	if err != nil {
+		remote.Close()
+		return Model{}, err
+	}
+	m.host = options.Bucket
+	m.scheme = "s3"
+	m.uploads = settings.Upload
+	m.banner = fmt.Sprintf("Opened the bucket %s on %s", options.Bucket, options.Endpoint)
+	return m, nil
+}
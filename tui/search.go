@@ -0,0 +1,260 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/localpath"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+)
+
+// How many matches are sent to the tui at once
+const searchBatchSize = 50
+
+// A file found by the search
+type searchMatch struct {
+	remotePath string
+	rawValue   fs.FileInfo
+}
+
+func (s searchMatch) Title() string {
+	return item{rawValue: s.rawValue}.Title()
+}
+
+func (s searchMatch) Description() string { return displayName(s.remotePath) }
+
+func (s searchMatch) FilterValue() string { return s.remotePath }
+
+// A recursive search of file names, or of the content of the files, under a
+// directory
+type search struct {
+	id       int
+	pattern  string
+	root     string
+	contents bool // whether the content of the files is searched
+	results  list.Model
+	cancel   context.CancelFunc // stops the walk
+	done     bool
+}
+
+// Message delivering the matches found by a running search
+type searchResultsMsg struct {
+	id      int
+	matches []list.Item
+	done    bool
+	err     error
+	updates <-chan tea.Msg // where to wait for the next results
+}
+
+// Walk the remote tree from the current directory looking for the pattern,
+// the results are streamed into the search list
+func (m *Model) startSearch(pattern string) tea.Cmd {
+	filter, err := parsePatternFilter(pattern)
+	if err != nil {
+		m.setError(fmt.Errorf("searching %q failed: %v", pattern, err))
+		return nil
+	}
+	ctx, id := m.newSearch(pattern, false)
+	remote := m.remote
+	root := m.currentDir
+	return func() tea.Msg {
+		updates := make(chan tea.Msg)
+		go walkSearch(ctx, remote, root, filter, id, updates)
+		return <-updates
+	}
+}
+
+// Replace the running search with one of the pattern in the current
+// directory, the context is cancelled when the search is closed
+func (m *Model) newSearch(pattern string, contents bool) (context.Context, int) {
+	if m.search != nil {
+		m.search.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	id := 1
+	if m.search != nil {
+		id = m.search.id + 1
+	}
+	m.search = &search{
+		id:       id,
+		pattern:  pattern,
+		root:     m.currentDir,
+		contents: contents,
+		results:  list.New(nil, newDelegate(), 0, 0),
+		cancel:   cancel,
+	}
+	m.search.results.SetShowStatusBar(true)
+	m.updateSearchTitle()
+	m.resize()
+	return ctx, id
+}
+
+// Sends the matches of a search to the tui in batches
+type searchSender struct {
+	ctx      context.Context
+	id       int
+	updates  chan tea.Msg
+	matches  []list.Item
+	lastSent time.Time
+}
+
+func newSearchSender(ctx context.Context, id int, updates chan tea.Msg) *searchSender {
+	return &searchSender{ctx: ctx, id: id, updates: updates, lastSent: time.Now()}
+}
+
+// Add the match, the batch is sent when full or after a while. Tell if the
+// search goes on, false once cancelled.
+func (s *searchSender) add(match list.Item) bool {
+	s.matches = append(s.matches, match)
+	if len(s.matches) >= searchBatchSize || time.Since(s.lastSent) > progressInterval {
+		return s.send(false, nil)
+	}
+	return s.ctx.Err() == nil
+}
+
+// Send the matches found since the last batch
+func (s *searchSender) send(done bool, err error) bool {
+	select {
+	case s.updates <- searchResultsMsg{id: s.id, matches: s.matches, done: done, err: err, updates: s.updates}:
+		s.matches = nil
+		s.lastSent = time.Now()
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// Walk the tree sending the matches of the filter in batches until done or
+// cancelled, the entries are checked as they are read
+func walkSearch(ctx context.Context, remote remotefs.FileSystem, root string, filter *patternFilter, id int, updates chan tea.Msg) {
+	defer close(updates)
+	sender := newSearchSender(ctx, id, updates)
+
+	walker := remotefs.Walk(remote, root)
+	for walker.Step() {
+		if ctx.Err() != nil {
+			return
+		}
+		// Skip the unreadable directories
+		if walker.Err() != nil || walker.Path() == root {
+			continue
+		}
+		if filter.match(walker.Stat()) {
+			if !sender.add(searchMatch{remotePath: walker.Path(), rawValue: walker.Stat()}) {
+				return
+			}
+		}
+	}
+	sender.send(true, nil)
+}
+
+// Match the name with the glob pattern, or look for the pattern in the name
+// ignoring the case when it isn't a glob
+func matchName(pattern, name string) bool {
+	if strings.ContainsAny(pattern, "*?[") {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	}
+	return strings.Contains(strings.ToLower(name), strings.ToLower(pattern))
+}
+
+// Add the matches to the results list
+func (m *Model) addSearchResults(msg searchResultsMsg) tea.Cmd {
+	if m.search == nil || m.search.id != msg.id {
+		// The search has been closed or replaced
+		return nil
+	}
+	m.search.done = msg.done
+	if msg.err != nil {
+		m.setError(fmt.Errorf("searching %q failed: %v", m.search.pattern, msg.err))
+	}
+	cmd := m.search.results.SetItems(append(m.search.results.Items(), msg.matches...))
+	m.updateSearchTitle()
+	if msg.done {
+		return cmd
+	}
+	return tea.Batch(cmd, waitForSearch(msg.updates))
+}
+
+// Wait for the next results of a running search
+func waitForSearch(updates <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-updates
+	}
+}
+
+// Stop the search and go back to the file list
+func (m *Model) closeSearch() {
+	m.search.cancel()
+	m.search = nil
+}
+
+// Show the pattern and the state of the search in the title bar
+func (m *Model) updateSearchTitle() {
+	state := "searching…"
+	if m.search.done {
+		state = "done"
+	}
+	kind := "Search"
+	if m.search.contents {
+		kind = "Grep"
+	}
+	m.search.results.Title = fmt.Sprintf("%s %q in %s · %s", kind, m.search.pattern, m.search.root, state)
+}
+
+// Handle the key presses while the search results are shown
+func (m Model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.search.results.FilterState() == list.Filtering {
+		var cmd tea.Cmd
+		m.search.results, cmd = m.search.results.Update(msg)
+		return m, cmd
+	}
+
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q":
+		// Clear the filter of the results first
+		if m.search.results.FilterState() != list.Unfiltered {
+			break
+		}
+		m.closeSearch()
+		return m, nil
+	case "enter":
+		// Jump to the directory of the match and highlight it
+		var remotePath string
+		switch match := m.search.results.SelectedItem().(type) {
+		case searchMatch:
+			remotePath = match.remotePath
+		case grepMatch:
+			remotePath = match.remotePath
+		default:
+			return m, nil
+		}
+		m.closeSearch()
+		m.selectName = path.Base(remotePath)
+		return m, m.changeDir(path.Dir(remotePath), fmt.Sprintf("Entered %s", path.Dir(remotePath)))
+	case "d":
+		match, ok := m.search.results.SelectedItem().(searchMatch)
+		if !ok || match.rawValue.IsDir() {
+			return m, nil
+		}
+		return m, m.queueDownloads([]download{{
+			remotePath: match.remotePath,
+			localPath:  filepath.Join(m.localDir, localpath.Name(match.rawValue.Name())),
+			size:       match.rawValue.Size(),
+			modTime:    match.rawValue.ModTime(),
+		}})
+	}
+
+	var cmd tea.Cmd
+	m.search.results, cmd = m.search.results.Update(msg)
+	return m, cmd
+}
@@ -0,0 +1,80 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+)
+
+// Message asking to copy remote files into the current directory of the
+// next tab
+type copyToTabMsg struct {
+	remote remotefs.FileSystem // the files of the source tab
+	host   string
+	files  []copySource
+}
+
+// A remote file to copy to another server, or to paste in another directory
+type copySource struct {
+	remotePath string
+	name       string
+	size       int64
+}
+
+// Ask the tabs to copy the marked files, or the highlighted one, to the
+// server of the next tab, unmarking them
+func (m *Model) copyToNextTab() tea.Cmd {
+	msg := copyToTabMsg{remote: m.remote, host: m.host}
+	for _, i := range m.targetItems() {
+		i.marked = false
+		if i.isDir() || i.isBroken() {
+			continue
+		}
+		msg.files = append(msg.files, copySource{
+			remotePath: m.remote.Join(m.currentDir, i.rawValue.Name()),
+			name:       i.rawValue.Name(),
+			size:       i.size(),
+		})
+	}
+	if len(msg.files) == 0 {
+		return reportError(fmt.Errorf("no files to copy, the directories are skipped"))
+	}
+	return func() tea.Msg { return msg }
+}
+
+// Queue the copies of the files of another server into the current
+// directory, the data streams through the client
+func (m *Model) queueServerCopies(msg copyToTabMsg) tea.Cmd {
+	remote := m.remote
+	var cmds []tea.Cmd
+	for _, f := range msg.files {
+		f := f
+		remotePath := remote.Join(m.currentDir, f.name)
+		cmds = append(cmds, m.queue.add(&transfer{
+			name:        f.name,
+			source:      f.remotePath,
+			destination: remotePath,
+			upload:      true,
+			total:       f.size,
+			copyFunc: func(counter io.Writer) error {
+				srcFile, err := msg.remote.Open(f.remotePath)
+				if err != nil {
+					return err
+				}
+				defer srcFile.Close()
+
+				destFile, err := remote.Create(remotePath)
+				if err != nil {
+					return err
+				}
+				defer destFile.Close()
+
+				_, err = io.Copy(destFile, io.TeeReader(srcFile, counter))
+				return err
+			},
+		}))
+	}
+	return tea.Batch(cmds...)
+}
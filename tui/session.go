@@ -0,0 +1,68 @@
+package tui
+
+import (
+	"log/slog"
+
+	"github.com/guglielmobartelloni/sftp-tui/config"
+	"github.com/guglielmobartelloni/sftp-tui/ssh"
+)
+
+// Get the sort field from its name, by name when unknown
+func parseSortField(name string) sortField {
+	for field := sortByName; field <= sortByExtension; field++ {
+		if field.String() == name {
+			return field
+		}
+	}
+	return sortByName
+}
+
+// Restore the view of the last session
+func (m *Model) restoreSession(session *config.Session) {
+	m.sortMode = sortMode{
+		field:     parseSortField(session.Sort),
+		reverse:   session.Reverse,
+		dirsFirst: session.DirsFirst,
+	}
+	m.showQueue = session.ShowQueue
+	m.showLog = session.ShowLog
+}
+
+// Get where the user is in the tab, to restore it with --resume
+func (m Model) session(options ssh.Options) config.Session {
+	return config.Session{
+		Connection: config.Profile{
+			Host:            options.Host,
+			Port:            options.Port,
+			Username:        options.Username,
+			PrivateKeyPath:  options.PrivateKeyPath,
+			CertificatePath: options.CertificatePath,
+			KnownHostsPath:  options.KnownHostsPath,
+			ProxyJump:       options.ProxyJump,
+			Proxy:           options.Proxy,
+			Legacy:          options.Legacy,
+		},
+		RemoteDir: m.currentDir,
+		LocalDir:  m.localDir,
+		Sort:      m.sortMode.field.String(),
+		Reverse:   m.sortMode.reverse,
+		DirsFirst: m.sortMode.dirsFirst,
+		ShowQueue: m.showQueue,
+		ShowLog:   m.showLog,
+	}
+}
+
+// Save the session of the active tab, the one restored by --resume
+func (t tabs) saveSession() {
+	if len(t.tabs) == 0 {
+		return
+	}
+	active := t.tabs[t.active]
+	// The local files and the ftp servers aren't an ssh connection to resume
+	if active.model.sshClient == nil {
+		return
+	}
+	if err := config.SaveSession(active.model.session(active.options)); err != nil {
+		slog.Error("saving the session failed", "err", err)
+	}
+}
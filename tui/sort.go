@@ -0,0 +1,101 @@
+package tui
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+// The file property the list is ordered by
+type sortField int
+
+const (
+	sortByName sortField = iota
+	sortBySize
+	sortByModTime
+	sortByExtension
+)
+
+func (f sortField) String() string {
+	switch f {
+	case sortBySize:
+		return "size"
+	case sortByModTime:
+		return "modified"
+	case sortByExtension:
+		return "extension"
+	default:
+		return "name"
+	}
+}
+
+// How the items of the list are ordered
+type sortMode struct {
+	field     sortField
+	reverse   bool // descending order
+	dirsFirst bool // directories before the files
+}
+
+// Switch to the next sort field
+func (s sortMode) nextField() sortMode {
+	s.field = (s.field + 1) % (sortByExtension + 1)
+	return s
+}
+
+// Describe the sort mode for the title bar
+func (s sortMode) String() string {
+	description := s.field.String()
+	if s.reverse {
+		description += " ↓"
+	} else {
+		description += " ↑"
+	}
+	if s.dirsFirst {
+		description += ", dirs first"
+	}
+	return description
+}
+
+// Order the items in place, the ".." item stays on top
+func (s sortMode) sort(items []list.Item) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i].(*item), items[j].(*item)
+		if a.rawValue.Name() == ".." || b.rawValue.Name() == ".." {
+			return a.rawValue.Name() == ".."
+		}
+		if s.dirsFirst && a.rawValue.IsDir() != b.rawValue.IsDir() {
+			return a.rawValue.IsDir()
+		}
+
+		less, greater := s.compare(a, b)
+		if s.reverse {
+			return greater
+		}
+		return less
+	})
+}
+
+// Compare two files by the sort field, ties are broken by name. The
+// directories whose size has been computed are sorted by it.
+func (s sortMode) compare(i, j *item) (less, greater bool) {
+	a, b := i.rawValue, j.rawValue
+	switch s.field {
+	case sortBySize:
+		if sizeA, sizeB := i.listedSize(), j.listedSize(); sizeA != sizeB {
+			return sizeA < sizeB, sizeA > sizeB
+		}
+	case sortByModTime:
+		if !a.ModTime().Equal(b.ModTime()) {
+			return a.ModTime().Before(b.ModTime()), a.ModTime().After(b.ModTime())
+		}
+	case sortByExtension:
+		extA, extB := strings.ToLower(filepath.Ext(a.Name())), strings.ToLower(filepath.Ext(b.Name()))
+		if extA != extB {
+			return extA < extB, extA > extB
+		}
+	}
+	nameA, nameB := strings.ToLower(a.Name()), strings.ToLower(b.Name())
+	return nameA < nameB, nameA > nameB
+}
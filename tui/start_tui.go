@@ -1,14 +1,25 @@
 package tui
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/progress"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/config"
+	"github.com/guglielmobartelloni/sftp-tui/conflict"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
 	"github.com/guglielmobartelloni/sftp-tui/ssh"
+	"github.com/guglielmobartelloni/sftp-tui/throttle"
 	"github.com/pkg/sftp"
+	gossh "golang.org/x/crypto/ssh"
 )
 
 //const (
@@ -20,35 +31,263 @@ import (
 //	knownHostsPath = "/Users/samurai/.ssh/known_hosts"
 //)
 
-func StartProgram(username, privateKeyPath, password, host, port, knownHostsPath string) {
-	sshClient := ssh.ConnectSSH(
-		username,
-		privateKeyPath,
-		password,
-		host,
-		port,
-		knownHostsPath,
-	)
-	SftpClient, err := sftp.NewClient(sshClient)
-	handleError(err)
-	//Close open connnections
-	defer SftpClient.Close()
-	defer sshClient.Close()
+// Settings of the tui that don't concern the connection
+type Settings struct {
+	Concurrency   int                 // transfers running at the same time
+	LocalDir      string              // local directory of the downloads and uploads
+	ShowHidden    bool                // whether the dotfiles are listed
+	Columns       bool                // whether the entries are shown in columns like ls -l
+	LimitRate     int64               // cap of the transfer speed in bytes per second, 0 for no limit
+	Verify        bool                // whether the checksums are compared after the transfers
+	OnConflict    string              // what's done with the transfers whose destination exists, empty to ask
+	OpenDownloads bool                // whether the downloaded files are opened with the default application
+	Keys          map[string][]string // keys of the actions replacing the default ones
+	Theme         string              // name of the built-in theme
+	Colors        map[string]string   // colors replacing the ones of the theme
+	NoIcons       bool                // whether the icons are left out, for the terminals without a nerd font
+	NoMouse       bool                // whether the mouse is left to the terminal
+	PreviewSize   int64               // bytes of the file read for the preview, 0 for the default
+	Trash         string              // directory where the deleted items are moved, empty to delete them right away
+	DryRun        bool                // whether the changes are only reported, not made
+	// how often the current directory is checked for changes, 0 never
+	RefreshInterval time.Duration
+	// how long the listings of the directories visited are reused, 0 never
+	CacheTTL time.Duration
+	// times a failed transfer is tried again, and the wait before the first
+	// retry, doubled at each one
+	Retries      int
+	RetryBackoff time.Duration
+	// directory where the sessions start, relative to the home, which is the
+	// default
+	RemoteDir string
+	// local files and directories uploaded to the start directory once
+	// connected
+	Upload []string
+	// the view of the last session restored in the first tab, nil for a new
+	// session
+	Session *config.Session
+	// gets the connection settings of the host, or profile, of a new tab
+	ResolveHost func(host string) (ssh.Options, error)
+	// local directory browsed in the first tab in place of a server, empty
+	// to connect to one
+	Browse string
+	// ftp server browsed in the first tab in place of an sftp one, nil to
+	// connect with ssh
+	FTP *remotefs.FTPOptions
+	// s3 bucket browsed in the first tab in place of a server, nil to
+	// connect with ssh
+	S3 *remotefs.S3Options
+}
 
-	m := Model{
-		List: list.New(
-			CreateItemListModel(".", SftpClient),
-			list.NewDefaultDelegate(), 0, 0),
-		SftpClient: SftpClient,
-		progress:   progress.New(),
+// Run the tui connecting to the server, or browsing the local files, until
+// the user quits
+func StartProgram(options ssh.Options, settings Settings) error {
+	if err := keys.remap(settings.Keys); err != nil {
+		return fmt.Errorf("loading the keys failed %v", err)
+	}
+	if err := setTheme(settings.Theme, settings.Colors); err != nil {
+		return fmt.Errorf("loading the theme failed %v", err)
+	}
+	if _, err := conflict.ParsePolicy(settings.OnConflict); err != nil {
+		return fmt.Errorf("loading the conflict policy failed %v", err)
+	}
+	showIcons = !settings.NoIcons
+	// The rate limit is shared by the transfers of all the tabs
+	limiter := throttle.NewLimiter(settings.LimitRate)
+	// The connection is opened from the form, right away when the host is
+	// known
+	programOptions := []tea.ProgramOption{tea.WithAltScreen()}
+	if !settings.NoMouse {
+		programOptions = append(programOptions, tea.WithMouseCellMotion())
 	}
-	m.List.Title = "File List"
+	var model tea.Model = newTabs(options, settings, limiter)
+	switch {
+	case settings.Browse != "":
+		local, err := newLocalModel(settings.Browse, settings, limiter)
+		if err != nil {
+			return fmt.Errorf("browsing %s failed %v", settings.Browse, err)
+		}
+		model = newTabsWith(local, settings, limiter)
+	case settings.FTP != nil:
+		ftpModel, err := connectFirst(settings.FTP.Host, func(ctx context.Context) (Model, error) {
+			return newFTPModel(ctx, *settings.FTP, settings, limiter)
+		})
+		if err != nil {
+			return fmt.Errorf("connecting to %s failed %v", settings.FTP.Host, err)
+		}
+		model = newTabsWith(ftpModel, settings, limiter)
+	case settings.S3 != nil:
+		s3Model, err := connectFirst(settings.S3.Endpoint, func(ctx context.Context) (Model, error) {
+			return newS3Model(ctx, *settings.S3, settings, limiter)
+		})
+		if err != nil {
+			return fmt.Errorf("opening the bucket %s failed %v", settings.S3.Bucket, err)
+		}
+		model = newTabsWith(s3Model, settings, limiter)
+	}
+	p := tea.NewProgram(model, programOptions...)
 
-	p := tea.NewProgram(m, tea.WithAltScreen())
+	finalModel, err := p.StartReturningModel()
+	//Close open connnections, they may have been replaced by a reconnection
+	if finalModel, ok := finalModel.(tabs); ok {
+		finalModel.saveSession()
+		finalModel.close()
+	}
+	if err != nil {
+		return fmt.Errorf("running the program failed %v", err)
+	}
+	return nil
+}
 
-	if err := p.Start(); err != nil {
-		fmt.Println("Error running program:", err)
-		os.Exit(1)
+// Connect to the server before starting the program, when there's no form
+// to go back to: ctrl+c cancels it
+func connectFirst(host string, newModel func(ctx context.Context) (Model, error)) (Model, error) {
+	fmt.Fprintf(os.Stderr, "Connecting to %s... (ctrl+c to cancel)\n", host)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return newModel(ctx)
+}
+
+// Connect to the server and create the model browsing its home directory,
+// cancelling the context stops waiting for the server
+func newModel(ctx context.Context, options ssh.Options, settings Settings, limiter *throttle.Limiter) (Model, error) {
+	// Tell which server was reached, the key is checked again on reconnection
+	var banner string
+	options.OnHostKey = func(key gossh.PublicKey) {
+		banner = fmt.Sprintf("Connected to %s, %s key %s", options.Host, key.Type(), gossh.FingerprintSHA256(key))
+	}
+	connect := func(ctx context.Context, unattended bool) (*gossh.Client, *sftp.Client, error) {
+		options := options
+		options.Unattended = unattended
+		sshClient, err := ssh.ConnectSSHContext(ctx, options)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Closing the connection stops waiting for the session
+		stop := context.AfterFunc(ctx, func() { sshClient.Close() })
+		sftpClient, err := ssh.NewSFTP(sshClient, options)
+		if !stop() {
+			if err == nil {
+				sftpClient.Close()
+			}
+			return nil, nil, fmt.Errorf("connecting to %s cancelled: %w", options.Host, ctx.Err())
+		}
+		if err != nil {
+			sshClient.Close()
+			return nil, nil, fmt.Errorf("starting the sftp session failed %v", err)
+		}
+		return sshClient, sftpClient, nil
+	}
+	sshClient, SftpClient, err := connect(ctx, false)
+	if err != nil {
+		return Model{}, err
+	}
+	closeAll := func() {
+		SftpClient.Close()
+		sshClient.Close()
+	}
+
+	startDir := remoteStartDir(settings.RemoteDir)
+	remote := remotefs.NewSFTP(SftpClient)
+	currentDir, items, err := openStartDir(remote, startDir)
+	if err != nil && settings.Session != nil {
+		// The directory of the last session may be gone
+		slog.Warn("restoring the directory failed", "dir", startDir, "err", err)
+		currentDir, items, err = openStartDir(remote, ".")
+	}
+	if err != nil {
+		closeAll()
+		return Model{}, err
 	}
 
+	m, err := newTabModel(remote, currentDir, items, settings, limiter)
+	if err != nil {
+		closeAll()
+		return Model{}, err
+	}
+	m.SftpClient = SftpClient
+	m.sshClient = sshClient
+	m.connect = connect
+	m.host = options.Host
+	m.port = options.Port
+	m.user = options.Username
+	m.banner = banner
+	return m, nil
+}
+
+// Get where the session starts: the home directory, or the remote directory
+// relative to it
+func remoteStartDir(remoteDir string) string {
+	switch {
+	case remoteDir == "~" || strings.HasPrefix(remoteDir, "~/"):
+		return "." + remoteDir[1:]
+	case remoteDir != "":
+		return remoteDir
+	}
+	return "."
+}
+
+// Create the model browsing the files listed, the connection is up to the
+// caller
+func newTabModel(remote remotefs.FileSystem, currentDir string, items []list.Item, settings Settings, limiter *throttle.Limiter) (Model, error) {
+	localDir, err := filepath.Abs(settings.LocalDir)
+	if err != nil {
+		return Model{}, err
+	}
+
+	m := Model{
+		List:            list.New(nil, newDelegate(), 0, 0),
+		remote:          remote,
+		currentDir:      currentDir,
+		localDir:        localDir,
+		progress:        progress.New(),
+		queue:           newTransferQueue(settings.Concurrency, retryPolicy{settings.Retries, settings.RetryBackoff}, limiter),
+		limitRate:       settings.LimitRate,
+		verify:          settings.Verify,
+		openDownloads:   settings.OpenDownloads,
+		prompt:          newPrompt(),
+		showHidden:      settings.ShowHidden,
+		columns:         settings.Columns,
+		previewSize:     settings.PreviewSize,
+		trash:           settings.Trash,
+		dryRun:          settings.DryRun,
+		refreshInterval: settings.RefreshInterval,
+		cache:           newListingCache(settings.CacheTTL),
+		requests:        newServerRequests(),
+		log:             &actionLog{},
+	}
+	// The policy has been checked when starting
+	m.onConflict, _ = conflict.ParsePolicy(settings.OnConflict)
+	if m.previewSize <= 0 {
+		m.previewSize = defaultPreviewSize
+	}
+	if settings.Session != nil {
+		m.restoreSession(settings.Session)
+	}
+	m.queue.remote.Store(remote)
+	m.queue.dryRun = settings.DryRun
+	m.List.KeyMap.CursorUp = keys.Up
+	m.List.KeyMap.CursorDown = keys.Down
+	m.List.KeyMap.PrevPage = keys.PrevPage
+	m.List.KeyMap.NextPage = keys.NextPage
+	m.List.KeyMap.GoToStart = keys.Start
+	m.List.KeyMap.GoToEnd = keys.End
+	m.List.KeyMap.Filter = keys.Filter
+	m.List.AdditionalShortHelpKeys = keys.ShortHelp
+	m.setDirItems(items)
+	m.updateTitle()
+	return m, nil
+}
+
+// List the directory where the session starts
+func openStartDir(client remotefs.FileSystem, startDir string) (string, []list.Item, error) {
+	currentDir, err := client.RealPath(startDir)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading the directory %s failed %v", startDir, err)
+	}
+	items, err := CreateItemListModel(currentDir, client)
+	if err != nil {
+		return "", nil, err
+	}
+	return currentDir, items, nil
 }
@@ -0,0 +1,187 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/mirror"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+)
+
+// Message with the changes needed to mirror a directory
+type syncPlanMsg struct {
+	push      bool // whether the local directory is mirrored to the remote
+	localDir  string
+	remoteDir string
+	actions   []mirror.Action
+}
+
+// Message with the copies left once the directories have been created and
+// the extras deleted
+type syncCopiesMsg struct {
+	push   bool
+	copies []mirror.Action
+}
+
+// Compare the local directory with the current remote one in the background,
+// push mirrors the local one to the remote, otherwise the remote one is
+// mirrored locally
+func (m *Model) planSync(localDir string, push bool) tea.Cmd {
+	localDir = expandLocalPath(localDir, m.localDir)
+	remote := m.remote
+	remoteDir := m.currentDir
+	return func() tea.Msg {
+		// Plan the deletes too, the user chooses whether to run them
+		var actions []mirror.Action
+		var err error
+		if push {
+			actions, err = mirror.PlanPush(remote, localDir, remoteDir, true)
+		} else {
+			actions, err = mirror.PlanPull(remote, remoteDir, localDir, true)
+		}
+		if err != nil {
+			return errorMsg{err: fmt.Errorf("comparing %s with %s failed: %v", localDir, remoteDir, err)}
+		}
+		return syncPlanMsg{push: push, localDir: localDir, remoteDir: remoteDir, actions: actions}
+	}
+}
+
+// Tell what the sync would change and ask whether to run it
+func (m *Model) confirmSync(msg syncPlanMsg) tea.Cmd {
+	var dirs, copies, deletes int
+	var size int64
+	for _, action := range msg.actions {
+		switch action.Kind {
+		case mirror.MakeDir:
+			dirs++
+		case mirror.Copy:
+			copies++
+			size += action.Size
+		case mirror.Delete:
+			deletes++
+		}
+	}
+
+	title, destination, copyVerb, extras := fmt.Sprintf("Push %s to %s", msg.localDir, msg.remoteDir), msg.remoteDir, "upload", "remote items missing locally"
+	if !msg.push {
+		title, destination, copyVerb, extras = fmt.Sprintf("Pull %s to %s", msg.remoteDir, msg.localDir), msg.localDir, "download", "local items missing on the remote"
+	}
+	if len(msg.actions) == 0 {
+		return m.setStatus(fmt.Sprintf("%s is up to date", destination))
+	}
+
+	summary := []string{
+		title,
+		"",
+		fmt.Sprintf("%d files to %s (%s)", copies, copyVerb, ConvertBytesToSizeString(size)),
+		fmt.Sprintf("%d directories to create", dirs),
+	}
+	if deletes > 0 {
+		summary = append(summary, fmt.Sprintf("%d %s", deletes, extras))
+	}
+
+	label := "push"
+	if !msg.push {
+		label = "pull"
+	}
+	choices := []choice{{key: label[:1], label: label, action: func(m *Model) tea.Cmd {
+		return m.runSync(msg.push, msg.actions, false)
+	}}}
+	if deletes > 0 {
+		choices = append(choices, choice{key: "d", label: "delete the extras too", action: func(m *Model) tea.Cmd {
+			return m.runSync(msg.push, msg.actions, true)
+		}})
+	}
+	choices = append(choices, choice{key: "c", label: "cancel", action: func(m *Model) tea.Cmd { return nil }})
+	m.askChoice(strings.Join(summary, "\n"), choices...)
+	return nil
+}
+
+// Get the function running the actions of the sync
+func syncApply(push bool) func(remotefs.FileSystem, mirror.Action, io.Writer) error {
+	if push {
+		return mirror.ApplyPush
+	}
+	return mirror.ApplyPull
+}
+
+// Create the directories and delete the extras, if asked, then give the
+// copies to the transfer queue. In a dry run all the actions are reported.
+func (m *Model) runSync(push bool, actions []mirror.Action, deleteExtras bool) tea.Cmd {
+	remote := m.remote
+	currentDir := m.currentDir
+	apply := syncApply(push)
+	dryRun := m.dryRun
+	return func() tea.Msg {
+		var copies []mirror.Action
+		var changes []string
+		for _, action := range actions {
+			switch {
+			case action.Kind == mirror.Delete && !deleteExtras:
+				continue
+			case dryRun:
+				changes = append(changes, describeSyncAction(push, action))
+			case action.Kind == mirror.Copy:
+				copies = append(copies, action)
+			default:
+				if err := apply(remote, action, io.Discard); err != nil {
+					return tea.Batch(
+						reportError(fmt.Errorf("%s of %s failed: %v", action.Kind, action.Path, err)),
+						m.changeDir(currentDir, ""),
+					)()
+				}
+			}
+		}
+		if dryRun {
+			return dryRunMsg{lines: changes}
+		}
+		return syncCopiesMsg{push: push, copies: copies}
+	}
+}
+
+// Describe the change made by the action of the sync
+func describeSyncAction(push bool, action mirror.Action) string {
+	switch {
+	case action.Kind != mirror.Copy:
+		return fmt.Sprintf("%s %s", action.Kind, action.Destination)
+	case push:
+		return fmt.Sprintf("upload %s to %s (%s)", action.Source, action.Destination, ConvertBytesToSizeString(action.Size))
+	default:
+		return fmt.Sprintf("download %s to %s (%s)", action.Source, action.Destination, ConvertBytesToSizeString(action.Size))
+	}
+}
+
+// Queue the transfers of the sync
+func (m *Model) queueSyncCopies(msg syncCopiesMsg) tea.Cmd {
+	if len(msg.copies) == 0 {
+		if !msg.push {
+			return m.setStatus("Pulled")
+		}
+		return m.changeDir(m.currentDir, "Pushed")
+	}
+
+	remote := m.remote
+	apply := syncApply(msg.push)
+	status := fmt.Sprintf("Queued %d uploads", len(msg.copies))
+	if !msg.push {
+		status = fmt.Sprintf("Queued %d downloads", len(msg.copies))
+	}
+	cmds := []tea.Cmd{m.setStatus(status)}
+	for _, action := range msg.copies {
+		action := action
+		cmds = append(cmds, m.queue.add(&transfer{
+			name:        action.Path,
+			source:      action.Source,
+			destination: action.Destination,
+			file:        true,
+			upload:      msg.push,
+			total:       action.Size,
+			copyFunc: func(counter io.Writer) error {
+				return apply(remote, action, counter)
+			},
+		}))
+	}
+	return tea.Batch(cmds...)
+}
@@ -0,0 +1,360 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/guglielmobartelloni/sftp-tui/ssh"
+	"github.com/guglielmobartelloni/sftp-tui/throttle"
+)
+
+// Message sent by a command of a tab, it's delivered to that tab
+type tabMsg struct {
+	id  int
+	msg tea.Msg
+}
+
+// Message sent when the connection of a new tab is done
+type tabOpenedMsg struct {
+	model   Model
+	options ssh.Options // the settings of the connection, to correct them when it failed
+	err     error
+}
+
+// A connection with its own directory, list and transfer queue
+type tab struct {
+	id      int
+	model   Model
+	options ssh.Options // the settings of the connection
+}
+
+// Holds the tabs, one per connection, the keys go to the active one
+type tabs struct {
+	tabs     []*tab
+	active   int
+	nextID   int
+	settings Settings
+	limiter  *throttle.Limiter // shared by the transfers of all the tabs
+	form     *connectForm      // the connection form, nil when not shown
+	quitting bool              // whether the transfers are being stopped to quit
+	width    int
+	height   int
+}
+
+// Create the tabs showing the connection form filled with the settings, the
+// first tab is opened once connected
+func newTabs(options ssh.Options, settings Settings, limiter *throttle.Limiter) tabs {
+	return tabs{settings: settings, limiter: limiter, form: newConnectForm(options, nil)}
+}
+
+// Create the tabs showing the model, already connected, in the first one
+func newTabsWith(model Model, settings Settings, limiter *throttle.Limiter) tabs {
+	return tabs{
+		tabs:     []*tab{{id: 1, model: model}},
+		nextID:   1,
+		settings: settings,
+		limiter:  limiter,
+	}
+}
+
+// Connect right away when the host is known, otherwise the form is filled
+// first
+func (t tabs) Init() tea.Cmd {
+	// The local files and the ftp servers are browsed right away
+	if t.form == nil {
+		return t.wrap(t.tabs[0].id, t.tabs[0].model.Init())
+	}
+	if t.form.orig.Host == "" {
+		return textinput.Blink
+	}
+	return t.connect(t.form.orig)
+}
+
+func (t tabs) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tabMsg:
+		// The commands of a batch are run separately, they are tagged too
+		if cmds, ok := batchCmds(msg.msg); ok {
+			for i := range cmds {
+				cmds[i] = t.wrap(msg.id, cmds[i])
+			}
+			return t, tea.Batch(cmds...)
+		}
+		// Quitting, running the editor and so on are up to the program, the
+		// running transfers are stopped before quitting
+		if reflect.TypeOf(msg.msg) == quitMsgType {
+			return t.quit()
+		}
+		if reflect.TypeOf(msg.msg).PkgPath() == reflect.TypeOf(tea.KeyMsg{}).PkgPath() {
+			inner := msg.msg
+			return t, func() tea.Msg { return inner }
+		}
+		switch inner := msg.msg.(type) {
+		case copyToTabMsg:
+			return t, t.copyToNextTab(msg.id, inner)
+		case quitTransfersMsg:
+			return t.stopTransfers(inner.keepPartial)
+		}
+		for _, tab := range t.tabs {
+			if tab.id == msg.id {
+				cmd := t.updateTab(tab, msg.msg)
+				if t.quitting && t.stopped() {
+					return t, tea.Quit
+				}
+				return t, cmd
+			}
+		}
+		// The tab has been closed
+		return t, nil
+
+	case tabOpenedMsg:
+		if msg.err != nil {
+			// Let the user correct the settings
+			t.form = newConnectForm(msg.options, msg.err)
+			return t, textinput.Blink
+		}
+		t.form = nil
+		// The files given on the command line go to the first server
+		if t.nextID == 0 {
+			msg.model.uploads = t.settings.Upload
+		}
+		t.nextID++
+		t.tabs = append(t.tabs, &tab{id: t.nextID, model: msg.model, options: msg.options})
+		t.active = len(t.tabs) - 1
+		return t, tea.Batch(t.wrap(t.nextID, msg.model.Init()), t.resize())
+
+	case tea.WindowSizeMsg:
+		t.width, t.height = msg.Width, msg.Height
+		return t, t.resize()
+
+	case tea.KeyMsg:
+		if t.form != nil {
+			return t.updateForm(msg)
+		}
+		switch {
+		case key.Matches(msg, keys.NewTab):
+			t.form = newConnectForm(ssh.Options{}, nil)
+			return t, textinput.Blink
+		case key.Matches(msg, keys.NextTab):
+			t.active = (t.active + 1) % len(t.tabs)
+			return t, nil
+		case key.Matches(msg, keys.PrevTab):
+			t.active = (t.active + len(t.tabs) - 1) % len(t.tabs)
+			return t, nil
+		case key.Matches(msg, keys.CloseTab):
+			return t.closeTab()
+		}
+
+	case tea.MouseMsg:
+		if t.form != nil {
+			return t, nil
+		}
+		// The tab sees the lines below the tab bar
+		if len(t.tabs) > 1 {
+			msg.Y--
+		}
+		if len(t.tabs) > 0 {
+			return t, t.updateTab(t.tabs[t.active], msg)
+		}
+	}
+
+	// The keys and the messages not sent by a tab go to the active one
+	if len(t.tabs) == 0 {
+		return t, nil
+	}
+	return t, t.updateTab(t.tabs[t.active], msg)
+}
+
+// Update the model of the tab, tagging its commands
+func (t tabs) updateTab(tab *tab, msg tea.Msg) tea.Cmd {
+	model, cmd := tab.model.Update(msg)
+	if err := model.(Model).err; err != nil && err != tab.model.err {
+		slog.Error("error", "host", tab.model.host, "err", err)
+	}
+	tab.model = model.(Model)
+	return t.wrap(tab.id, cmd)
+}
+
+// Tag the message of the command with the tab
+func (t tabs) wrap(id int, cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		msg := cmd()
+		if msg == nil {
+			return nil
+		}
+		return tabMsg{id: id, msg: msg}
+	}
+}
+
+// Get the commands of the message returned by tea.Batch, it's unexported
+func batchCmds(msg tea.Msg) ([]tea.Cmd, bool) {
+	value := reflect.ValueOf(msg)
+	if value.Kind() != reflect.Slice || value.Type().Elem() != reflect.TypeOf(tea.Cmd(nil)) {
+		return nil, false
+	}
+	cmds := make([]tea.Cmd, value.Len())
+	for i := range cmds {
+		cmds[i] = value.Index(i).Interface().(tea.Cmd)
+	}
+	return cmds, true
+}
+
+// Copy the files of the source tab into the current directory of the next
+// tab, the copies are queued in the destination tab
+func (t tabs) copyToNextTab(sourceID int, msg copyToTabMsg) tea.Cmd {
+	var source int
+	for i, tab := range t.tabs {
+		if tab.id == sourceID {
+			source = i
+		}
+	}
+	if len(t.tabs) == 1 {
+		return t.updateTab(t.tabs[source], errorMsg{err: fmt.Errorf("no other server to copy to, open one with ctrl+t")})
+	}
+
+	destination := t.tabs[(source+1)%len(t.tabs)]
+	status := statusMsg(fmt.Sprintf("Copying %d files to %s:%s", len(msg.files), destination.model.host, destination.model.currentDir))
+	return tea.Batch(
+		t.wrap(destination.id, destination.model.queueServerCopies(msg)),
+		t.updateTab(t.tabs[source], status),
+	)
+}
+
+// Resize all the tabs, the tab bar takes a line when there are more tabs
+func (t tabs) resize() tea.Cmd {
+	height := t.height
+	if len(t.tabs) > 1 {
+		height--
+	}
+	var cmds []tea.Cmd
+	for _, tab := range t.tabs {
+		cmds = append(cmds, t.updateTab(tab, tea.WindowSizeMsg{Width: t.width, Height: height}))
+	}
+	return tea.Batch(cmds...)
+}
+
+// Connect in a new tab. The terminal is released meanwhile, so the host key
+// and the passphrase can be asked.
+func (t tabs) connect(options ssh.Options) tea.Cmd {
+	settings := t.settings
+	// The last session is restored in the first tab
+	if t.nextID > 0 {
+		settings.Session = nil
+	}
+	connection := &tabConnection{options: options, settings: settings, limiter: t.limiter}
+	return tea.Exec(connection, func(err error) tea.Msg {
+		return tabOpenedMsg{model: connection.model, options: options, err: err}
+	})
+}
+
+// Browse the local files, from the current directory, in a new tab
+func (t tabs) openLocal() tea.Cmd {
+	settings, limiter := t.settings, t.limiter
+	// The last session is of an ssh connection
+	settings.Session = nil
+	return func() tea.Msg {
+		model, err := newLocalModel(".", settings, limiter)
+		return tabOpenedMsg{model: model, err: err}
+	}
+}
+
+// Close the active tab and its connection, closing the last one quits like
+// ctrl+c
+func (t tabs) closeTab() (tea.Model, tea.Cmd) {
+	if len(t.tabs) == 1 {
+		return t.quit()
+	}
+	t.tabs[t.active].model.closeConnection()
+	t.tabs = append(t.tabs[:t.active:t.active], t.tabs[t.active+1:]...)
+	if t.active == len(t.tabs) {
+		t.active--
+	}
+	return t, t.resize()
+}
+
+// Close the connections of all the tabs
+func (t tabs) close() {
+	for _, tab := range t.tabs {
+		tab.model.closeConnection()
+	}
+}
+
+func (t tabs) View() string {
+	if t.form != nil {
+		return t.formView()
+	}
+	view := t.tabs[t.active].model.View()
+	if len(t.tabs) == 1 {
+		return view
+	}
+	var names []string
+	for i, tab := range t.tabs {
+		name := fmt.Sprintf("%d %s", i+1, tab.model.host)
+		// Show the progress of the transfers running in the background
+		if pending, active := tab.model.queue.counts(); pending+active > 0 {
+			name += fmt.Sprintf(" %.0f%%", tab.model.queue.percent()*100)
+		}
+		if i == t.active {
+			names = append(names, activeTabStyle.Render(name))
+		} else {
+			names = append(names, tabStyle.Render(name))
+		}
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, strings.Join(names, " "), view)
+}
+
+// Close the connection of the tab, closing the ssh one first doesn't wait for
+// the sftp session to end
+func (m Model) closeConnection() {
+	if m.watcher != nil {
+		m.watcher.close()
+	}
+	if m.cancelConnect != nil {
+		m.cancelConnect()
+	}
+	close(m.requests.closed)
+	if m.sshClient != nil {
+		m.sshClient.Close()
+	}
+	m.remote.Close()
+}
+
+// Connects a new tab while the program has released the terminal
+type tabConnection struct {
+	options  ssh.Options
+	settings Settings
+	limiter  *throttle.Limiter
+	model    Model
+	stderr   io.Writer
+}
+
+func (c *tabConnection) Run() error {
+	fmt.Fprintf(c.stderr, "Connecting to %s... (ctrl+c to cancel)\n", c.options.Host)
+	// The terminal is released, ctrl+c sends the interrupt signal
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	var err error
+	c.model, err = newModel(ctx, c.options, c.settings, c.limiter)
+	return err
+}
+
+func (c *tabConnection) SetStdin(io.Reader) {}
+
+func (c *tabConnection) SetStdout(io.Writer) {}
+
+func (c *tabConnection) SetStderr(w io.Writer) {
+	c.stderr = w
+}
@@ -0,0 +1,237 @@
+package tui
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/guglielmobartelloni/sftp-tui/localpath"
+	"golang.org/x/crypto/ssh"
+)
+
+// Download the remote directory into the local one as a gzipped tar stream
+// created on the server, a single round trip instead of a few per file.
+// The bytes of the extracted files are written to the counter.
+func tarDownload(sshClient *ssh.Client, remoteDir, localDir string, counter io.Writer) error {
+	session, err := newSession(sshClient)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	// The ./ keeps a name starting with - from being taken as an option
+	command := fmt.Sprintf("tar czf - -C %s %s", shellQuote(path.Dir(remoteDir)), shellQuote("./"+path.Base(remoteDir)))
+	slog.Info("remote command", "command", command)
+	if err := session.Start(command); err != nil {
+		return err
+	}
+	if err := extractTar(stdout, localDir, counter); err != nil {
+		return commandError(err, &stderr)
+	}
+	return commandError(session.Wait(), &stderr)
+}
+
+// Upload the local directory into the remote one as a gzipped tar stream
+// extracted on the server. The bytes of the archived files are written to
+// the counter.
+func tarUpload(sshClient *ssh.Client, localDir, remoteDir string, counter io.Writer) error {
+	session, err := newSession(sshClient)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	command := "tar xzf - --no-same-owner -C " + shellQuote(remoteDir)
+	slog.Info("remote command", "command", command)
+	if err := session.Start(command); err != nil {
+		return err
+	}
+	err = writeTar(stdin, localDir, counter)
+	stdin.Close()
+	if err != nil {
+		return commandError(err, &stderr)
+	}
+	return commandError(session.Wait(), &stderr)
+}
+
+// Add what the remote command printed on stderr to its error
+func commandError(err error, stderr *bytes.Buffer) error {
+	if err == nil || stderr.Len() == 0 {
+		return err
+	}
+	return fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+}
+
+// Extract the gzipped tar stream into the directory, the entries escaping
+// it are refused: the ones named outside of it, the symlinks pointing
+// outside of it and the ones written through a symlink
+func extractTar(r io.Reader, dir string, counter io.Writer) error {
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tarReader := tar.NewReader(gzipReader)
+	dir = filepath.Clean(dir)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, localpath.FromSlash(header.Name))
+		if !insideDir(dir, target) {
+			return fmt.Errorf("%s is outside of %s", header.Name, dir)
+		}
+		if err := checkParents(dir, target); err != nil {
+			return fmt.Errorf("%s: %w", header.Name, err)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, header.FileInfo().Mode().Perm()|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := extractFile(tarReader, target, header, counter); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			link := localpath.FromSlash(header.Linkname)
+			if path.IsAbs(header.Linkname) || filepath.IsAbs(link) || !insideDir(dir, filepath.Join(filepath.Dir(target), link)) {
+				return fmt.Errorf("%s points outside of %s", header.Name, dir)
+			}
+			os.Remove(target)
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Tell whether the path, cleaned, is the directory or inside of it
+func insideDir(dir, target string) bool {
+	return target == dir || strings.HasPrefix(target, dir+string(filepath.Separator))
+}
+
+// Refuse the path when one of the directories between dir and it is a
+// symlink: what's written there would end up where the symlink points.
+// The missing ones are fine, they're created as directories.
+func checkParents(dir, target string) error {
+	rel, err := filepath.Rel(dir, filepath.Dir(target))
+	if err != nil || rel == "." {
+		return err
+	}
+	current := dir
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, name)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink", current)
+		}
+	}
+	return nil
+}
+
+// Write the file of the tar entry keeping its mode and modification time
+func extractFile(r io.Reader, target string, header *tar.Header, counter io.Writer) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	// Replace a symlink instead of writing where it points
+	os.Remove(target)
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, header.FileInfo().Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, io.TeeReader(r, counter)); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(target, header.ModTime, header.ModTime)
+}
+
+// Write the directory as a gzipped tar stream, its entries are named after
+// the base name of the directory
+func writeTar(w io.Writer, dir string, counter io.Writer) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+	parent := filepath.Dir(dir)
+	err := filepath.Walk(dir, func(filePath string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Sockets, pipes and devices can't be archived
+		if fileInfo.Mode()&(os.ModeSocket|os.ModeNamedPipe|os.ModeDevice) != 0 {
+			return nil
+		}
+		var link string
+		if fileInfo.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(filePath); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(fileInfo, link)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(parent, filePath)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if fileInfo.IsDir() {
+			header.Name += "/"
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if !fileInfo.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tarWriter, io.TeeReader(file, counter))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
@@ -0,0 +1,107 @@
+package tui
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Build a gzipped tar stream of the headers, the regular files hold their
+// name
+func tarStream(t *testing.T, headers ...*tar.Header) io.Reader {
+	t.Helper()
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, header := range headers {
+		if header.Typeflag == tar.TypeReg {
+			header.Size = int64(len(header.Name))
+		}
+		if header.Mode == 0 {
+			header.Mode = 0644
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			tarWriter.Write([]byte(header.Name))
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buffer
+}
+
+func TestExtractTar(t *testing.T) {
+	dir := t.TempDir()
+	// Windows allows them only to the administrators and in developer mode
+	if err := os.Symlink("target", filepath.Join(dir, "check")); err != nil {
+		t.Skip("no symlinks:", err)
+	}
+	os.Remove(filepath.Join(dir, "check"))
+	stream := tarStream(t,
+		&tar.Header{Name: "a/", Typeflag: tar.TypeDir, Mode: 0755},
+		&tar.Header{Name: "a/file", Typeflag: tar.TypeReg},
+		&tar.Header{Name: "a/link", Typeflag: tar.TypeSymlink, Linkname: "file"},
+		&tar.Header{Name: "a/up", Typeflag: tar.TypeSymlink, Linkname: "../a/file"},
+	)
+	if err := extractTar(stream, dir, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "a", "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "a/file" {
+		t.Errorf("content through the link is %q", content)
+	}
+}
+
+func TestExtractTarRefused(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []*tar.Header
+	}{
+		{"outside", []*tar.Header{
+			{Name: "../file", Typeflag: tar.TypeReg},
+		}},
+		{"absolute link", []*tar.Header{
+			{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "/etc"},
+		}},
+		{"relative link", []*tar.Header{
+			{Name: "a/", Typeflag: tar.TypeDir},
+			{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: "../../etc"},
+		}},
+		{"through a link", []*tar.Header{
+			{Name: "a/", Typeflag: tar.TypeDir},
+			{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "a"},
+			{Name: "link/file", Typeflag: tar.TypeReg},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parent := t.TempDir()
+			dir := filepath.Join(parent, "dir")
+			if err := os.Mkdir(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := extractTar(tarStream(t, test.headers...), dir, io.Discard); err == nil {
+				t.Fatal("the archive was extracted")
+			}
+			if _, err := os.Lstat(filepath.Join(dir, "a", "file")); err == nil {
+				t.Error("a file was written through the link")
+			}
+			if _, err := os.Lstat(filepath.Join(parent, "file")); err == nil {
+				t.Error("a file was written outside of the directory")
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package tui
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// The colors of the tui. In the config file they are named after the fields
+// in lower case.
+type Theme struct {
+	Dir      lipgloss.TerminalColor // directory names
+	File     lipgloss.TerminalColor // file names
+	Link     lipgloss.TerminalColor // symlink targets and the other tabs
+	Status   lipgloss.TerminalColor // status messages
+	Marked   lipgloss.TerminalColor // marks of the items
+	Selected lipgloss.TerminalColor // the highlighted item
+	Error    lipgloss.TerminalColor // errors and broken symlinks
+	Accent   lipgloss.TerminalColor // titles and the active tab
+	Border   lipgloss.TerminalColor // border of the dialogs
+}
+
+// The built-in themes, the default one adapts to the terminal background
+var themes = map[string]Theme{
+	"default": {
+		Dir:      lipgloss.Color("#64CDEF"),
+		File:     lipgloss.Color("#ffffff"),
+		Link:     lipgloss.AdaptiveColor{Light: "#A49FA5", Dark: "#777777"},
+		Status:   lipgloss.Color("#04B575"),
+		Marked:   lipgloss.Color("#EE6FF8"),
+		Selected: lipgloss.AdaptiveColor{Light: "#F793FF", Dark: "#AD58B4"},
+		Error:    lipgloss.AdaptiveColor{Light: "#FF4672", Dark: "#ED567A"},
+		Accent:   lipgloss.Color("#25A065"),
+		Border:   lipgloss.Color("#F25D94"),
+	},
+	"dark": {
+		Dir:      lipgloss.Color("#64CDEF"),
+		File:     lipgloss.Color("#ffffff"),
+		Link:     lipgloss.Color("#777777"),
+		Status:   lipgloss.Color("#04B575"),
+		Marked:   lipgloss.Color("#EE6FF8"),
+		Selected: lipgloss.Color("#AD58B4"),
+		Error:    lipgloss.Color("#ED567A"),
+		Accent:   lipgloss.Color("#25A065"),
+		Border:   lipgloss.Color("#F25D94"),
+	},
+	"light": {
+		Dir:      lipgloss.Color("#0969DA"),
+		File:     lipgloss.Color("#1A1A1A"),
+		Link:     lipgloss.Color("#A49FA5"),
+		Status:   lipgloss.Color("#1A7F37"),
+		Marked:   lipgloss.Color("#BF3989"),
+		Selected: lipgloss.Color("#F793FF"),
+		Error:    lipgloss.Color("#FF4672"),
+		Accent:   lipgloss.Color("#25A065"),
+		Border:   lipgloss.Color("#F25D94"),
+	},
+	"solarized": {
+		Dir:      lipgloss.Color("#268BD2"),
+		File:     lipgloss.Color("#839496"),
+		Link:     lipgloss.Color("#586E75"),
+		Status:   lipgloss.Color("#859900"),
+		Marked:   lipgloss.Color("#D33682"),
+		Selected: lipgloss.Color("#B58900"),
+		Error:    lipgloss.Color("#DC322F"),
+		Accent:   lipgloss.Color("#2AA198"),
+		Border:   lipgloss.Color("#6C71C4"),
+	},
+}
+
+// A color of the config file, #rrggbb or an ANSI color number
+var colorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{6}|[0-9]{1,3})$`)
+
+// The styles of the theme, set by applyTheme
+var (
+	statusMessageStyle func(string) string
+	errorMessageStyle  func(string) string
+	fileItemStyle      func(string) string
+	dirItemStyle       func(string) string
+	markedItemStyle    func(string) string
+	linkTargetStyle    func(string) string
+	brokenLinkStyle    func(string) string
+	matchStyle         func(string) string
+	previewTitleStyle  lipgloss.Style
+	activeTabStyle     lipgloss.Style
+	tabStyle           lipgloss.Style
+	modalStyle         lipgloss.Style
+	selectedColor      lipgloss.TerminalColor
+)
+
+// Whether the names are preceded by their icon, it needs a nerd font
+var showIcons = true
+
+func init() {
+	applyTheme(themes["default"])
+}
+
+// Set the theme with the name, the colors replace the ones of the theme
+func setTheme(name string, colors map[string]string) error {
+	if name == "" {
+		name = "default"
+	}
+	theme, ok := themes[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(themes))
+		for name := range themes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown theme %q, the themes are %s", name, strings.Join(names, ", "))
+	}
+
+	value := reflect.ValueOf(&theme).Elem()
+	for name, color := range colors {
+		field := value.FieldByNameFunc(func(field string) bool {
+			return strings.EqualFold(field, name)
+		})
+		if !field.IsValid() {
+			return fmt.Errorf("unknown color %q in the theme", name)
+		}
+		if !colorPattern.MatchString(color) {
+			return fmt.Errorf("the color %s of %s is neither #rrggbb nor an ANSI color number", color, name)
+		}
+		field.Set(reflect.ValueOf(lipgloss.Color(color)))
+	}
+	applyTheme(theme)
+	syntaxStyle = syntaxStyleOf(strings.ToLower(name))
+	return nil
+}
+
+// Create the styles with the colors of the theme
+func applyTheme(theme Theme) {
+	statusMessageStyle = lipgloss.NewStyle().Foreground(theme.Status).Render
+	errorMessageStyle = lipgloss.NewStyle().Foreground(theme.Error).Render
+	fileItemStyle = lipgloss.NewStyle().Foreground(theme.File).Render
+	dirItemStyle = lipgloss.NewStyle().Foreground(theme.Dir).Render
+	markedItemStyle = lipgloss.NewStyle().Foreground(theme.Marked).Render
+	linkTargetStyle = lipgloss.NewStyle().Foreground(theme.Link).Render
+	brokenLinkStyle = lipgloss.NewStyle().Foreground(theme.Error).Render
+	matchStyle = lipgloss.NewStyle().Foreground(theme.Marked).Reverse(true).Render
+	previewTitleStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#FFFDF5")).
+		Background(theme.Accent).
+		Padding(0, 1)
+	activeTabStyle = previewTitleStyle.Copy()
+	tabStyle = lipgloss.NewStyle().
+		Foreground(theme.Link).
+		Padding(0, 1)
+	modalStyle = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(theme.Border).
+		Padding(1, 2)
+	selectedColor = theme.Selected
+}
+
+// Create the delegate of the lists, the highlighted item has the color of
+// the theme
+func newDelegate() list.DefaultDelegate {
+	delegate := list.NewDefaultDelegate()
+	delegate.Styles.SelectedTitle = delegate.Styles.SelectedTitle.Copy().BorderForeground(selectedColor)
+	delegate.Styles.SelectedDesc = delegate.Styles.SelectedDesc.Copy().
+		BorderForeground(selectedColor).
+		Foreground(selectedColor)
+	return delegate
+}
@@ -0,0 +1,83 @@
+package tui
+
+import (
+	"fmt"
+	"time"
+)
+
+// Weight of the last measure in the current speed, the lower the smoother
+const speedSmoothing = 0.3
+
+// Measures the speed of the transfers running since the queue got busy
+type throughput struct {
+	started    time.Time // when the first bytes were copied
+	lastUpdate time.Time // when the current speed was last measured
+	copied     int64     // bytes copied since started
+	measured   int64     // bytes copied since the last measure
+	speed      float64   // current speed in bytes per second
+}
+
+// Start measuring when the first transfer starts
+func (tp *throughput) start(now time.Time) {
+	if tp.started.IsZero() {
+		tp.started, tp.lastUpdate = now, now
+	}
+}
+
+// Account the bytes just copied
+func (tp *throughput) add(bytes int64, now time.Time) {
+	tp.start(now)
+	tp.copied += bytes
+	tp.measured += bytes
+
+	// Measure over a long enough time for a meaningful speed
+	elapsed := now.Sub(tp.lastUpdate)
+	if elapsed < progressInterval {
+		return
+	}
+	speed := float64(tp.measured) / elapsed.Seconds()
+	tp.measured = 0
+	if tp.speed == 0 {
+		tp.speed = speed
+	} else {
+		tp.speed = speedSmoothing*speed + (1-speedSmoothing)*tp.speed
+	}
+	tp.lastUpdate = now
+}
+
+// Average speed in bytes per second since the transfers started
+func (tp *throughput) average(now time.Time) float64 {
+	elapsed := now.Sub(tp.started).Seconds()
+	if tp.started.IsZero() || elapsed <= 0 {
+		return 0
+	}
+	return float64(tp.copied) / elapsed
+}
+
+// Estimate the time needed to copy the remaining bytes at the current speed
+func (tp *throughput) eta(remaining int64) (time.Duration, bool) {
+	if tp.speed <= 0 {
+		return 0, false
+	}
+	return time.Duration(float64(remaining) / tp.speed * float64(time.Second)).Round(time.Second), true
+}
+
+// Describe the speed, the bytes copied and the time left
+func (q *transferQueue) statsView() string {
+	transferred, total := q.bytes()
+	stats := fmt.Sprintf("%s/%s", ConvertBytesToSizeString(transferred), ConvertBytesToSizeString(total))
+	if q.throughput.speed > 0 {
+		stats = fmt.Sprintf("%s/s (avg %s/s) · %s",
+			ConvertBytesToSizeString(int64(q.throughput.speed)),
+			ConvertBytesToSizeString(int64(q.throughput.average(time.Now()))),
+			stats,
+		)
+	}
+	if eta, ok := q.throughput.eta(total - transferred); ok {
+		stats += fmt.Sprintf(" · ETA %s", eta)
+	}
+	if rate := q.limiter.Rate(); rate > 0 {
+		stats += fmt.Sprintf(" · limit %s/s", ConvertBytesToSizeString(rate))
+	}
+	return stats
+}
@@ -0,0 +1,147 @@
+package tui
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+	"github.com/guglielmobartelloni/sftp-tui/throttle"
+)
+
+// The state of a transfer in the queue
+type transferState int
+
+const (
+	transferPending transferState = iota
+	transferActive
+	transferRetrying
+	transferDone
+	transferFailed
+	transferCanceled
+)
+
+// Error of the copies stopped by quitting
+var errTransferCanceled = errors.New("transfer canceled")
+
+func (s transferState) String() string {
+	switch s {
+	case transferPending:
+		return "pending"
+	case transferActive:
+		return "active"
+	case transferRetrying:
+		return "retrying"
+	case transferDone:
+		return "done"
+	case transferCanceled:
+		return "canceled"
+	default:
+		return "failed"
+	}
+}
+
+// A download or an upload of the queue
+type transfer struct {
+	id          int
+	name        string
+	source      string // what's copied, a remote path for the downloads
+	destination string // where it's copied, a local path for the downloads
+	file        bool   // whether a single file is copied from source to destination
+	upload      bool
+	resumed     bool  // whether the copy continues a file already there, it's kept when canceled
+	total       int64 // bytes to copy
+	transferred int64 // bytes copied so far
+	state       transferState
+	err         error
+	started     time.Time
+	attempt     int           // retries so far
+	retryWait   time.Duration // wait before the next attempt
+	// copies the file, it must tee the copied bytes into the counter
+	copyFunc func(counter io.Writer) error
+	// continues the copy where the failed attempt stopped, with the client
+	// of the current connection; without it the retries start over
+	resumeFunc func(remote remotefs.FileSystem, counter io.Writer) error
+}
+
+// Percentage of the transfer completed between 0 and 1
+func (t *transfer) percent() float64 {
+	if t.total == 0 {
+		if t.state == transferDone {
+			return 1
+		}
+		return 0
+	}
+	return float64(t.transferred) / float64(t.total)
+}
+
+// Time the transfer took since it started, rounded for the status
+func (t *transfer) elapsed() time.Duration {
+	elapsed := time.Since(t.started)
+	if elapsed < time.Second {
+		return elapsed.Round(time.Millisecond)
+	}
+	return elapsed.Round(100 * time.Millisecond)
+}
+
+// Message reporting the bytes copied by a running transfer
+type transferProgressMsg struct {
+	id          int
+	transferred int64
+	updates     <-chan tea.Msg // where to wait for the next update
+}
+
+// Message sent when a transfer is finished
+type transferDoneMsg struct {
+	id  int
+	err error
+}
+
+// Run the copy in the background, throttled by the limiter. The failed copies
+// are tried again following the policy, resuming with the filesystem of the
+// current connection when they can. Closing stop ends the copy at its next
+// write. The returned command delivers the progress of the transfer until
+// it's done.
+func (t *transfer) run(limiter *throttle.Limiter, retry retryPolicy, remote func() remotefs.FileSystem, stop <-chan struct{}) tea.Cmd {
+	id, total, copyFunc, resumeFunc := t.id, t.total, t.copyFunc, t.resumeFunc
+	return func() tea.Msg {
+		updates := make(chan tea.Msg)
+		counter := &writeProgressCounter{
+			TotalFileSize: total,
+			id:            id,
+			updates:       updates,
+			limiter:       limiter,
+			stop:          stop,
+		}
+		go func() {
+			err := copyFunc(counter)
+			for attempt := 1; err != nil && attempt <= retry.retries && retryable(err); attempt++ {
+				wait := retry.wait(attempt)
+				updates <- transferRetryMsg{id: id, attempt: attempt, err: err, wait: wait, updates: updates}
+				select {
+				case <-time.After(wait):
+				case <-stop:
+					err = errTransferCanceled
+					continue
+				}
+				if resumeFunc != nil {
+					err = resumeFunc(remote(), counter)
+				} else {
+					counter.restart(0)
+					err = copyFunc(counter)
+				}
+			}
+			updates <- transferDoneMsg{id: id, err: err}
+			close(updates)
+		}()
+		return <-updates
+	}
+}
+
+// Wait for the next update of a running transfer
+func waitForTransfer(updates <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-updates
+	}
+}
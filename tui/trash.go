@@ -0,0 +1,321 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+)
+
+// Extension of the file next to a trash entry holding where the item was
+const originExt = ".origin"
+
+// A deleted item kept in the trash. Each entry is a directory of the trash
+// holding the item, the path it had is in a file next to it.
+type trashEntry struct {
+	dir     string    // directory of the entry in the trash
+	origin  string    // where the item was
+	deleted time.Time // when the item was moved to the trash
+	isDir   bool      // whether the item is a directory
+}
+
+func (e trashEntry) Title() string {
+	if e.isDir {
+		return dirItemStyle(displayName(e.origin))
+	}
+	return fileItemStyle(displayName(e.origin))
+}
+
+func (e trashEntry) Description() string {
+	return "deleted " + e.deleted.Format("2006-01-02 15:04:05")
+}
+
+func (e trashEntry) FilterValue() string { return e.origin }
+
+// Message sent when items have been moved to the trash
+type trashedMsg struct {
+	entries []trashEntry // the items moved, undo puts them back
+	err     error        // why the others couldn't be moved
+}
+
+// Message sent when the content of the trash has been read
+type trashListMsg struct {
+	entries []trashEntry
+}
+
+// Message sent when an entry has been taken out of the trash, by restoring
+// or purging it
+type trashRemovedMsg struct {
+	entry  trashEntry
+	status string
+}
+
+// Move the items into the trash in the background
+func (m *Model) trashItems(remotePaths []string) tea.Cmd {
+	remote, trashDir := m.remote, m.resolvePath(m.trash)
+	return func() tea.Msg {
+		var entries []trashEntry
+		for _, remotePath := range remotePaths {
+			entry, err := moveToTrash(remote, trashDir, remotePath)
+			if err != nil {
+				return trashedMsg{entries: entries, err: fmt.Errorf("moving %s to the trash failed: %v", path.Base(remotePath), err)}
+			}
+			entries = append(entries, entry)
+		}
+		return trashedMsg{entries: entries}
+	}
+}
+
+// Move the item into a new entry of the trash
+func moveToTrash(remote remotefs.FileSystem, trashDir, remotePath string) (trashEntry, error) {
+	fileInfo, err := remote.Lstat(remotePath)
+	if err != nil {
+		return trashEntry{}, err
+	}
+	now := time.Now()
+	entryDir := remote.Join(trashDir, fmt.Sprintf("%d", now.UnixNano()))
+	if err := remote.MkdirAll(entryDir); err != nil {
+		return trashEntry{}, err
+	}
+	if err := writeRemoteFile(remote, entryDir+originExt, remotePath); err != nil {
+		remote.RemoveDirectory(entryDir)
+		return trashEntry{}, err
+	}
+	if err := remote.Rename(remotePath, remote.Join(entryDir, path.Base(remotePath))); err != nil {
+		remote.RemoveDirectory(entryDir)
+		remote.Remove(entryDir + originExt)
+		return trashEntry{}, err
+	}
+	return trashEntry{dir: entryDir, origin: remotePath, deleted: now, isDir: fileInfo.IsDir()}, nil
+}
+
+// Replace the content of the remote file with the text
+func writeRemoteFile(remote remotefs.FileSystem, remotePath, text string) error {
+	file, err := remote.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write([]byte(text)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Put the item of the entry back where it was, an item there isn't replaced
+func restoreEntry(remote remotefs.FileSystem, entry trashEntry) error {
+	if _, err := remote.Lstat(entry.origin); err == nil {
+		return fmt.Errorf("%s already exists", entry.origin)
+	}
+	if err := remote.MkdirAll(path.Dir(entry.origin)); err != nil {
+		return err
+	}
+	if err := remote.Rename(remote.Join(entry.dir, path.Base(entry.origin)), entry.origin); err != nil {
+		return err
+	}
+	remote.Remove(entry.dir + originExt)
+	return remote.RemoveDirectory(entry.dir)
+}
+
+// Delete the entry from the trash for good
+func purgeEntry(remote remotefs.FileSystem, entry trashEntry) error {
+	if err := remotefs.RemoveAll(remote, entry.dir); err != nil {
+		return err
+	}
+	return remote.Remove(entry.dir + originExt)
+}
+
+// Read the entries of the trash, the last deleted first
+func readTrash(remote remotefs.FileSystem, trashDir string) ([]trashEntry, error) {
+	fileInfos, err := remote.ReadDir(trashDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []trashEntry
+	for _, fileInfo := range fileInfos {
+		if !fileInfo.IsDir() {
+			continue
+		}
+		entryDir := remote.Join(trashDir, fileInfo.Name())
+		origin, err := readRemoteFile(remote, entryDir+originExt)
+		if err != nil {
+			// Not an entry of the trash
+			continue
+		}
+		entry := trashEntry{dir: entryDir, origin: origin, deleted: fileInfo.ModTime()}
+		if itemInfo, err := remote.Lstat(remote.Join(entryDir, path.Base(origin))); err == nil {
+			entry.isDir = itemInfo.IsDir()
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].deleted.After(entries[j].deleted)
+	})
+	return entries, nil
+}
+
+// Read the whole remote file as text
+func readRemoteFile(remote remotefs.FileSystem, remotePath string) (string, error) {
+	file, err := remote.Open(remotePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	return strings.TrimSpace(string(content)), err
+}
+
+// Keep the items moved to the trash so that undo can restore them
+func (m *Model) handleTrashed(msg trashedMsg) tea.Cmd {
+	if len(msg.entries) > 0 {
+		m.undo = append(m.undo, msg.entries)
+	}
+	if msg.err != nil {
+		return tea.Batch(reportError(msg.err), m.changeDir(m.currentDir, ""))
+	}
+	return m.changeDir(m.currentDir, fmt.Sprintf("Moved %d items to the trash, %s restores them", len(msg.entries), keys.Undo.Help().Key))
+}
+
+// Restore the items of the last deletion
+func (m *Model) undoDelete() tea.Cmd {
+	if len(m.undo) == 0 {
+		return m.setStatus("Nothing to undo")
+	}
+	entries := m.undo[len(m.undo)-1]
+	m.undo = m.undo[:len(m.undo)-1]
+	remote, currentDir := m.remote, m.currentDir
+	return func() tea.Msg {
+		for _, entry := range entries {
+			if err := restoreEntry(remote, entry); err != nil {
+				err = fmt.Errorf("restoring %s failed: %v", entry.origin, err)
+				return tea.Batch(reportError(err), m.changeDir(currentDir, ""))()
+			}
+		}
+		return m.changeDir(currentDir, fmt.Sprintf("Restored %d items", len(entries)))()
+	}
+}
+
+// Read the trash in the background and show it
+func (m *Model) openTrash() tea.Cmd {
+	if m.trash == "" {
+		return m.setStatus("The trash is turned off, the items are deleted right away")
+	}
+	remote, trashDir := m.remote, m.resolvePath(m.trash)
+	return func() tea.Msg {
+		entries, err := readTrash(remote, trashDir)
+		if err != nil {
+			return errorMsg{err: fmt.Errorf("reading the trash failed: %v", err)}
+		}
+		return trashListMsg{entries: entries}
+	}
+}
+
+// Show the entries of the trash
+func (m *Model) showTrash(entries []trashEntry) tea.Cmd {
+	if len(entries) == 0 {
+		return m.setStatus("The trash is empty")
+	}
+	items := make([]list.Item, len(entries))
+	for i, entry := range entries {
+		items[i] = entry
+	}
+	trash := list.New(items, newDelegate(), 0, 0)
+	trash.Title = "Trash"
+	trash.SetStatusBarItemName("item", "items")
+	m.trashList = &trash
+	m.resize()
+	return nil
+}
+
+// Handle the key presses while the trash is shown
+func (m Model) updateTrash(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.trashList.FilterState() == list.Filtering {
+		var cmd tea.Cmd
+		*m.trashList, cmd = m.trashList.Update(msg)
+		return m, cmd
+	}
+
+	remote := m.remote
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc", "q", "T":
+		// Clear the filter first
+		if m.trashList.FilterState() != list.Unfiltered {
+			break
+		}
+		m.trashList = nil
+		return m, nil
+	case "enter", "r":
+		entry, ok := m.trashList.SelectedItem().(trashEntry)
+		if !ok {
+			return m, nil
+		}
+		return m, func() tea.Msg {
+			if err := restoreEntry(remote, entry); err != nil {
+				return errorMsg{err: fmt.Errorf("restoring %s failed: %v", entry.origin, err)}
+			}
+			return trashRemovedMsg{entry: entry, status: fmt.Sprintf("Restored %s", entry.origin)}
+		}
+	case "x", "delete":
+		entry, ok := m.trashList.SelectedItem().(trashEntry)
+		if !ok {
+			return m, nil
+		}
+		m.askConfirmation(fmt.Sprintf("Delete %s for good?", entry.origin), func() tea.Msg {
+			if err := purgeEntry(remote, entry); err != nil {
+				return errorMsg{err: fmt.Errorf("purging %s failed: %v", entry.origin, err)}
+			}
+			return trashRemovedMsg{entry: entry, status: fmt.Sprintf("Deleted %s", entry.origin)}
+		})
+		return m, nil
+	case "X":
+		var entries []trashEntry
+		for _, listItem := range m.trashList.Items() {
+			entries = append(entries, listItem.(trashEntry))
+		}
+		m.askConfirmation(fmt.Sprintf("Empty the trash, deleting %d items for good?", len(entries)), func() tea.Msg {
+			for _, entry := range entries {
+				if err := purgeEntry(remote, entry); err != nil {
+					return errorMsg{err: fmt.Errorf("purging %s failed: %v", entry.origin, err)}
+				}
+			}
+			return trashListMsg{}
+		})
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	*m.trashList, cmd = m.trashList.Update(msg)
+	return m, cmd
+}
+
+// Take the entry out of the trash list, once it's empty the list is closed.
+// The current directory is listed again, the item may have come back there.
+func (m *Model) removeTrashEntry(msg trashRemovedMsg) tea.Cmd {
+	if m.trashList != nil {
+		for i, listItem := range m.trashList.Items() {
+			if listItem.(trashEntry).dir == msg.entry.dir {
+				m.trashList.RemoveItem(i)
+				break
+			}
+		}
+		if m.trashList.Index() >= len(m.trashList.Items()) {
+			m.trashList.CursorUp()
+		}
+		if len(m.trashList.Items()) == 0 {
+			m.trashList = nil
+		}
+	}
+	return m.changeDir(m.currentDir, msg.status)
+}
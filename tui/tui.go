@@ -1,47 +1,133 @@
 package tui
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"image"
 	"io"
 	"io/fs"
+	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/guglielmobartelloni/sftp-tui/config"
+	"github.com/guglielmobartelloni/sftp-tui/conflict"
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
 	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
 )
 
-var (
-	docStyle           = lipgloss.NewStyle().Margin(2, 2)
-	statusMessageStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.AdaptiveColor{Light: "#04B575", Dark: "#04B575"}).
-				Render
-
-	fileItemStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.AdaptiveColor{Light: "#ffffff", Dark: "#ffffff"}).
-			Render
-	dirItemStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.AdaptiveColor{Light: "#64CDEF", Dark: "#64CDEF"}).
-			Render
-)
+var docStyle = lipgloss.NewStyle().Margin(2, 2)
+
+// Message that shows a status message in the list
+type statusMsg string
 
-// Struct that keeps the progress bar percantage
-type barPercentage float64
+// Message sent when the listing of a directory has been read
+type dirListingMsg struct {
+	path   string      // real path of the directory
+	items  []list.Item // the directory entries
+	status string      // status message to show
+	cached bool        // whether the entries come from the cache
+}
 
 // Holds the state of the tui
 type Model struct {
-	List       list.Model   // the list of items
-	SftpClient *sftp.Client // the sftp client
-	currentDir string       // current directory
-	progress   progress.Model
+	List            list.Model          // the list of items
+	SftpClient      *sftp.Client        // the sftp client
+	remote          remotefs.FileSystem // the files of the server, through the sftp client
+	local           bool                // whether the files browsed are the local ones, without a server
+	sshClient       *ssh.Client         // the connection of the sftp client
+	connect         connector           // dials the connection again when it's lost
+	currentDir      string              // current directory
+	localDir        string              // local directory of the downloads and uploads
+	progress        progress.Model
+	queue           *transferQueue     // the downloads and uploads
+	showQueue       bool               // whether the queue pane is visible
+	prompt          textinput.Model    // the text input shown at the bottom
+	promptAction    promptAction       // what to do when the prompt is submitted
+	confirmation    *confirmation      // the question waiting for an answer
+	err             error              // the last error, shown until a key is pressed
+	preview         viewport.Model     // the pane showing the previewed file
+	previewName     string             // name of the previewed file, empty when not previewing
+	previewContent  string             // what the preview shows
+	previewImage    image.Image        // the previewed image, nil when the preview shows text
+	previewBinary   []byte             // the bytes of the previewed binary file, nil when the preview shows text
+	hex             *hexView           // the page of the file dumped in the preview, nil when not dumping
+	previewSize     int64              // bytes of the file read for the preview
+	follow          *follower          // the file whose new lines are shown in the preview, nil when not following
+	sortMode        sortMode           // how the list is ordered
+	dirItems        []list.Item        // all the entries of the current directory
+	showHidden      bool               // whether the dotfiles are listed
+	pattern         *patternFilter     // restricts the listing, nil when not filtering
+	owners          ownerNames         // the names of the users and the groups of the server
+	columns         bool               // whether the entries are shown in columns like ls -l
+	search          *search            // the running search, nil when not searching
+	bookmarks       *list.Model        // the bookmark list, nil when not shown
+	history         *list.Model        // the past transfers, nil when not shown
+	permissions     *permissionsForm   // the permissions being edited, nil when not editing
+	info            string             // the details of a file shown in a modal, empty when not shown
+	log             *actionLog         // the actions and the errors of the tab
+	showLog         bool               // whether the log pane is visible
+	dryRun          bool               // whether the changes are only reported
+	dryRunLines     []string           // the changes reported in the modal of the dry run
+	host            string             // host of the connection, the bookmarks are saved per host
+	port            string             // port of the connection
+	user            string             // user of the connection
+	scheme          string             // scheme of the urls of the files, sftp when empty
+	selectName      string             // entry to highlight once the directory is listed
+	limitRate       int64              // the rate limit turned back on by the toggle, bytes per second
+	verify          bool               // whether the checksums are compared after the transfers
+	onConflict      conflict.Policy    // what's done with the transfers whose destination exists
+	openDownloads   bool               // whether the downloaded files are opened with the default application
+	banner          string             // the host key of the server, shown once connected
+	uploads         []string           // local paths uploaded once connected, given on the command line
+	showHelp        bool               // whether the help with all the keys is shown
+	crumbsFocused   bool               // whether a directory of the path in the header is being selected
+	crumb           int                // the directory of the path selected in the header
+	lastClick       click              // the last click on the list, to tell a double click
+	diskFree        uint64             // bytes available on the remote filesystem
+	diskTotal       uint64             // size of the remote filesystem, 0 when unknown
+	trash           string             // directory where the deleted items are moved, empty to delete them right away
+	trashList       *list.Model        // the entries of the trash, nil when not shown
+	undo            [][]trashEntry     // the items moved to the trash by each deletion
+	copied          []copySource       // the items to paste in another directory
+	watcher         *watcher           // the local directory whose changes are uploaded, nil when not watching
+	refreshInterval time.Duration      // how often the current directory is checked for changes, 0 never
+	cache           *listingCache      // the listings of the directories visited
+	cached          bool               // whether the listing comes from the cache, until it's read again
+	loading         *dirLoad           // the directory being loaded in batches, nil when loaded
+	requests        *serverRequests    // the requests to the server the user waits for
+	disconnected    bool               // whether the user gave up on the server, until reconnected
+	connectErr      error              // why reconnecting from the disconnected screen failed
+	cancelConnect   context.CancelFunc // stops the reconnection running, nil when none is
+	width           int                // width of the terminal
+	height          int                // height of the terminal
 }
 
 func (m Model) Init() tea.Cmd {
-	return nil
+	banner, uploads := m.banner, m.uploads
+	cmds := []tea.Cmd{func() tea.Msg { return statusMsg(banner) }, m.readDiskSpace(), m.refreshTick(), m.requests.waitSlow(),
+		loadOwnerNames(m.sshClient, m.remote)}
+	// The local files have no connection to keep alive, the ftp servers get
+	// a new one for the next request when it's dropped
+	if m.sshClient != nil {
+		cmds = append(cmds, keepAlive())
+	}
+	if len(uploads) > 0 {
+		cmds = append(cmds, func() tea.Msg { return uploadPathsMsg{paths: uploads} })
+	}
+	return tea.Batch(cmds...)
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -49,37 +135,403 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c":
+		m.err = nil
+		// Give up on a server that doesn't answer
+		if (msg.String() == "esc" || msg.String() == "ctrl+c") && m.sshClient != nil && !m.disconnected && m.requests.isSlow() {
+			m.disconnect()
+			return m, nil
+		}
+		if m.confirmation != nil {
+			return m.updateConfirmation(msg)
+		}
+		if m.permissions != nil {
+			return m.updatePermissions(msg)
+		}
+		if m.showHelp {
+			// Any key closes the help
+			m.showHelp = false
+			if key.Matches(msg, keys.Quit) {
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+		if m.info != "" {
+			// Any key closes the details
+			m.info = ""
+			if key.Matches(msg, keys.Quit) {
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+		if m.promptAction != noPrompt {
+			return m.updatePrompt(msg)
+		}
+		if m.disconnected {
+			return m.updateDisconnected(msg)
+		}
+		if m.previewName != "" {
+			return m.updatePreview(msg)
+		}
+		if m.search != nil {
+			return m.updateSearch(msg)
+		}
+		if m.bookmarks != nil {
+			return m.updateBookmarks(msg)
+		}
+		if m.trashList != nil {
+			return m.updateTrash(msg)
+		}
+		if m.history != nil {
+			return m.updateHistory(msg)
+		}
+		if m.crumbsFocused {
+			return m.updateCrumbs(msg)
+		}
+		// Let the list handle the keys while typing the filter
+		if m.List.FilterState() == list.Filtering {
+			break
+		}
+		switch {
+		case key.Matches(msg, keys.Quit):
 			return m, tea.Quit
-		case "backspace":
-			cmds = moveDir(&m, "..", cmds)
-			return m, tea.Batch(cmds...)
-		case "enter":
-			var cmd tea.Cmd
-			selectedItem := m.List.SelectedItem().(*item).rawValue
-
-			selectedItemName := selectedItem.Name()
-			if selectedItem.IsDir() {
-				cmds = moveDir(&m, selectedItemName, cmds)
-			} else {
-				cmd = m.List.NewStatusMessage(statusMessageStyle(fmt.Sprintf("Downloading %s", selectedItemName)))
-				cmds = append(cmds, cmd)
-				cmds = append(cmds, m.downloadFile(selectedItem))
-			}
-
-			return m, tea.Batch(cmds...)
-		}
-
-	case *barPercentage:
-		cmd := m.progress.SetPercent(float64(*msg) / 100.0)
-		//fmt.Println(int(*msg))
-		if int(*msg) != 100 {
-			return m, tea.Batch(cmd, tea.Tick(time.Millisecond*500, func(t time.Time) tea.Msg {
-				return msg
-			}))
+		case key.Matches(msg, keys.Help):
+			m.showHelp = true
+			return m, nil
+		case key.Matches(msg, keys.Upload):
+			return m, m.openPrompt(uploadPrompt, "Upload: ", "")
+		case key.Matches(msg, keys.Mark):
+			return m, m.toggleMark()
+		case key.Matches(msg, keys.Download):
+			return m, m.downloadFiles(m.targetItems(), m.localDir)
+		case key.Matches(msg, keys.DownloadTo):
+			return m, m.openDownloadPrompt()
+		case key.Matches(msg, keys.LocalDir):
+			return m, m.openPrompt(localDirPrompt, "Local directory: ", m.localDir)
+		case key.Matches(msg, keys.Rename):
+			return m, m.openRenamePrompt()
+		case key.Matches(msg, keys.GoTo):
+			return m, m.openPrompt(gotoPrompt, "Go to: ", "")
+		case key.Matches(msg, keys.Info):
+			if selectedItem, ok := m.List.SelectedItem().(*item); ok && selectedItem.rawValue.Name() != ".." {
+				return m, m.fileInfo(selectedItem.rawValue)
+			}
+			return m, nil
+		case key.Matches(msg, keys.Permissions):
+			return m, m.openPermissions()
+		case key.Matches(msg, keys.Bookmark):
+			return m, m.addBookmark()
+		case key.Matches(msg, keys.Bookmarks):
+			return m, m.openBookmarks()
+		case key.Matches(msg, keys.Crumbs):
+			m.focusCrumbs()
+			return m, nil
+		case key.Matches(msg, keys.Watch):
+			return m, m.toggleWatch()
+		case key.Matches(msg, keys.Push):
+			return m, m.openPrompt(syncPushPrompt, fmt.Sprintf("Push to %s the local directory: ", m.currentDir), m.localDir)
+		case key.Matches(msg, keys.Pull):
+			return m, m.openPrompt(syncPullPrompt, fmt.Sprintf("Pull %s to the local directory: ", m.currentDir), m.localDir)
+		case key.Matches(msg, keys.Pattern):
+			value := ""
+			if m.pattern != nil {
+				value = m.pattern.expr
+			}
+			return m, m.openPrompt(patternPrompt, "Filter (*.log type:dir size>10M mtime<7d): ", value)
+		case key.Matches(msg, keys.Search):
+			return m, m.openPrompt(searchPrompt, "Search (name, *.log type:file size>10M): ", "")
+		case key.Matches(msg, keys.Grep):
+			return m, m.openPrompt(grepPrompt, "Search the content (regexp): ", "")
+		case key.Matches(msg, keys.RateLimit):
+			return m, m.toggleRateLimit()
+		case key.Matches(msg, keys.Verify):
+			return m, m.toggleVerify()
+		case key.Matches(msg, keys.Open):
+			if selectedItem, ok := m.List.SelectedItem().(*item); ok {
+				return m, m.openFile(selectedItem)
+			}
+			return m, nil
+		case key.Matches(msg, keys.CopyPath):
+			return m, m.copyPath(false)
+		case key.Matches(msg, keys.CopyURL):
+			return m, m.copyPath(true)
+		case key.Matches(msg, keys.CopyToNext):
+			return m, m.copyToNextTab()
+		case key.Matches(msg, keys.History):
+			return m, m.openHistory()
+		case key.Matches(msg, keys.Command):
+			return m, m.openPrompt(commandPrompt, "$ ", "")
+		case key.Matches(msg, keys.Mkdir):
+			return m, m.openPrompt(mkdirPrompt, "New directory: ", "")
+		case key.Matches(msg, keys.Delete):
+			m.deleteItems(m.targetItems())
+			return m, nil
+		case key.Matches(msg, keys.Copy):
+			return m, m.copyItems()
+		case key.Matches(msg, keys.Paste):
+			return m, m.pasteItems()
+		case key.Matches(msg, keys.DirSize):
+			return m, m.computeDirSizes()
+		case key.Matches(msg, keys.Undo):
+			return m, m.undoDelete()
+		case key.Matches(msg, keys.Trash):
+			return m, m.openTrash()
+		case key.Matches(msg, keys.Preview):
+			if selectedItem, ok := m.List.SelectedItem().(*item); ok && !selectedItem.isDir() {
+				return m, m.previewFile(selectedItem.rawValue)
+			}
+			return m, nil
+		case key.Matches(msg, keys.Extract):
+			if selectedItem, ok := m.List.SelectedItem().(*item); ok && !selectedItem.isDir() {
+				return m, m.extractArchive(selectedItem)
+			}
+			return m, nil
+		case key.Matches(msg, keys.Compress):
+			if selectedItem, ok := m.List.SelectedItem().(*item); ok && selectedItem.isDir() && selectedItem.rawValue.Name() != ".." {
+				return m, m.compressDir(selectedItem)
+			}
+			return m, nil
+		case key.Matches(msg, keys.Follow):
+			if selectedItem, ok := m.List.SelectedItem().(*item); ok && !selectedItem.isDir() {
+				return m, m.followFile(selectedItem.rawValue)
+			}
+			return m, nil
+		case key.Matches(msg, keys.Diff):
+			// Two marked files are compared with each other
+			if marked := m.markedItems(); len(marked) == 2 {
+				return m, m.diffMarked(marked[0], marked[1])
+			}
+			if selectedItem, ok := m.List.SelectedItem().(*item); ok && !selectedItem.isDir() {
+				return m, m.askDiff(selectedItem.rawValue)
+			}
+			return m, nil
+		case key.Matches(msg, keys.Hex):
+			if selectedItem, ok := m.List.SelectedItem().(*item); ok && !selectedItem.isDir() {
+				return m, m.hexFile(selectedItem.rawValue)
+			}
+			return m, nil
+		case key.Matches(msg, keys.Edit):
+			if selectedItem, ok := m.List.SelectedItem().(*item); ok && !selectedItem.isDir() {
+				return m, m.editFile(selectedItem.rawValue)
+			}
+			return m, nil
+		case key.Matches(msg, keys.Sort):
+			return m, m.setSortMode(m.sortMode.nextField())
+		case key.Matches(msg, keys.Reverse):
+			mode := m.sortMode
+			mode.reverse = !mode.reverse
+			return m, m.setSortMode(mode)
+		case key.Matches(msg, keys.DirsFirst):
+			mode := m.sortMode
+			mode.dirsFirst = !mode.dirsFirst
+			return m, m.setSortMode(mode)
+		case key.Matches(msg, keys.Hidden):
+			return m, m.toggleHidden()
+		case key.Matches(msg, keys.Columns):
+			return m, m.toggleColumns()
+		case key.Matches(msg, keys.Queue):
+			m.showQueue = !m.showQueue
+			m.resize()
+			return m, nil
+		case key.Matches(msg, keys.Log):
+			m.showLog = !m.showLog
+			m.resize()
+			return m, nil
+		case key.Matches(msg, keys.Refresh):
+			return m, m.changeDir(m.currentDir, "Refreshed")
+		case key.Matches(msg, keys.Back):
+			return m, m.moveDir("..")
+		case key.Matches(msg, keys.Enter):
+			return m, m.enterItem(m.List.SelectedItem().(*item))
+		}
+
+	case tea.MouseMsg:
+		return m.updateMouse(msg)
+
+	case transferProgressMsg:
+		if t := m.queue.get(msg.id); t != nil {
+			m.queue.progress(t, msg.transferred)
+		}
+		return m, tea.Batch(m.progress.SetPercent(m.queue.percent()), waitForTransfer(msg.updates))
+
+	case uploadPathsMsg:
+		return m, m.uploadPaths(msg.paths)
+
+	case transferRetryMsg:
+		if t := m.queue.get(msg.id); t != nil {
+			m.queue.retrying(t, msg)
+		}
+		return m, waitForTransfer(msg.updates)
+
+	case transferDoneMsg:
+		t := m.queue.get(msg.id)
+		if t == nil {
+			return m, nil
+		}
+		m.queue.finish(t, msg.err)
+		if t.state == transferCanceled {
+			// Quitting, the history isn't written anymore
+			return m, m.removePartial(t)
+		}
+		cmds = append(cmds, m.recordTransfer(t))
+
+		// Give the free worker to the next transfer
+		cmds = append(cmds, m.queue.schedule())
+		if pending, active := m.queue.counts(); pending == 0 && active == 0 {
+			cmds = append(cmds, m.progress.SetPercent(0))
+		}
+		var mismatch *checksumMismatchError
+		switch {
+		case errors.As(msg.err, &mismatch):
+			m.askRetransfer(t, msg.err)
+		case msg.err != nil:
+			m.setError(fmt.Errorf("transfer of %s failed: %v", t.name, msg.err))
+		case t.upload:
+			cmds = append(cmds, m.changeDir(m.currentDir, fmt.Sprintf("Uploaded %s in %s", t.name, t.elapsed())))
+		default:
+			cmds = append(cmds, m.setStatus(fmt.Sprintf("Downloaded %s in %s", t.name, t.elapsed())))
+		}
+		return m, tea.Batch(cmds...)
+
+	case dirListingMsg:
+		m.logDirChange(msg.path)
+		m.currentDir = msg.path
+		m.cached = msg.cached
+		m.loading = nil
+		m.List.StopSpinner()
+		cmd := m.setDirItems(msg.items)
+		if m.selectName != "" {
+			m.selectItem(m.selectName)
+			m.selectName = ""
+		}
+		cmds = append(cmds, cmd, m.setStatus(msg.status), m.readDiskSpace())
+		if msg.cached {
+			// Read it again, the changes are shown once read
+			cmds = append(cmds, m.checkDirChanges(false))
 		} else {
+			m.cache.put(msg.path, msg.items)
+		}
+		return m, tea.Batch(cmds...)
+
+	case dirBatchMsg:
+		return m, m.addBatch(msg)
+
+	case archivedMsg:
+		status := fmt.Sprintf("Compressed into %s", path.Base(msg.download.remotePath))
+		return m, tea.Batch(m.changeDir(m.currentDir, status), m.queueDownloads([]download{msg.download}))
+
+	case dirSizeMsg:
+		return m, m.setDirSize(msg)
+
+	case trashedMsg:
+		return m, m.handleTrashed(msg)
+
+	case trashListMsg:
+		m.trashList = nil
+		return m, m.showTrash(msg.entries)
+
+	case historyMsg:
+		return m, m.showHistory(msg.transfers)
+
+	case trashRemovedMsg:
+		return m, m.removeTrashEntry(msg)
+
+	case watchChangesMsg:
+		return m, m.uploadChanges(msg)
+
+	case freeSpaceMsg:
+		return m, m.handleFreeSpace(msg)
+
+	case ownerNamesMsg:
+		return m, m.setOwnerNames(msg.names)
+
+	case diskSpaceMsg:
+		if msg.path == m.currentDir {
+			m.diskFree, m.diskTotal = msg.free, msg.total
+		}
+		return m, nil
+
+	case searchResultsMsg:
+		return m, m.addSearchResults(msg)
+
+	case completionMsg:
+		if m.promptAction == gotoPrompt {
+			m.prompt.SetValue(msg.value)
+			m.prompt.CursorEnd()
 		}
+		if len(msg.candidates) > 0 {
+			return m, m.setStatus(strings.Join(msg.candidates, " "))
+		}
+		return m, nil
+
+	case syncPlanMsg:
+		return m, m.confirmSync(msg)
+
+	case syncCopiesMsg:
+		return m, m.queueSyncCopies(msg)
+
+	case infoMsg:
+		m.info = msg.content
+		return m, nil
+
+	case dryRunMsg:
+		m.reportDryRun(msg.lines...)
+		return m, nil
+
+	case cancelTransfersMsg:
+		return m, m.cancelTransfers(msg.keepPartial)
+
+	case partialRemovedMsg:
+		m.queue.cleaning--
+		return m, nil
+
+	case previewMsg:
+		m.openPreview(msg)
+		return m, nil
+
+	case followTickMsg:
+		return m, m.followTick(msg)
+
+	case followMsg:
+		return m, m.addFollowed(msg)
+
+	case editFileMsg:
+		return m, openEditor(msg)
+
+	case editorClosedMsg:
+		return m, m.saveEditedFile(msg)
+
+	case refreshTickMsg:
+		return m, m.checkDirChanges(true)
+
+	case refreshedMsg:
+		return m, m.applyRefresh(msg)
+
+	case keepAliveMsg:
+		if m.disconnected {
+			// Reconnecting is up to the user
+			return m, keepAlive()
+		}
+		return m, m.checkConnection()
+
+	case connectionAliveMsg:
+		m.cancelConnect = nil
+		return m, keepAlive()
+
+	case serverSlowMsg:
+		return m, m.serverSlow(msg)
+
+	case reconnectedMsg:
+		return m, m.reconnected(msg)
+
+	case errorMsg:
+		// The requests cut off by the disconnection fail
+		if !m.disconnected {
+			m.setError(msg.err)
+		}
+		return m, nil
+
+	case statusMsg:
+		return m, m.setStatus(string(msg))
 
 	case progress.FrameMsg:
 		progressModel, cmd := m.progress.Update(msg)
@@ -87,8 +539,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, cmd
 
 	case tea.WindowSizeMsg:
-		h, v := docStyle.GetFrameSize()
-		m.List.SetSize(msg.Width-h, msg.Height-v)
+		m.width, m.height = msg.Width, msg.Height
+		m.resize()
 
 	}
 
@@ -97,67 +549,601 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
-func moveDir(m *Model, selectedItemName string, cmds []tea.Cmd) []tea.Cmd {
-	currentWd, err := m.SftpClient.RealPath(m.SftpClient.Join(m.currentDir, selectedItemName))
-	handleError(err)
-	m.currentDir = currentWd
+// Order the list with the sort mode
+func (m *Model) setSortMode(mode sortMode) tea.Cmd {
+	m.sortMode = mode
+	m.updateTitle()
+	return m.setDirItems(m.dirItems)
+}
 
-	cmd := m.List.SetItems(CreateItemListModel(currentWd, m.SftpClient))
-	cmds = append(cmds, cmd)
-	cmd = m.List.NewStatusMessage(statusMessageStyle(fmt.Sprintf("Entered %s", selectedItemName)))
-	cmds = append(cmds, cmd)
-	return cmds
+// Show or hide the dotfiles, saving the choice in the config file
+func (m *Model) toggleHidden() tea.Cmd {
+	m.showHidden = !m.showHidden
+	showHidden := m.showHidden
+	return tea.Batch(m.setDirItems(m.dirItems), func() tea.Msg {
+		if err := config.Set("ShowHidden", showHidden); err != nil {
+			return errorMsg{err: fmt.Errorf("saving the config failed: %v", err)}
+		}
+		return nil
+	})
 }
 
-// Donwload a file based on the path provided
-func (m *Model) downloadFile(fileItem fs.FileInfo) tea.Cmd {
+// Show the entries of the current directory, sorted and without the
+// dotfiles if they are hidden
+func (m *Model) setDirItems(items []list.Item) tea.Cmd {
+	m.dirItems = items
+	m.sortMode.sort(items)
+	for _, listItem := range items {
+		if fileItem := listItem.(*item); fileItem.rawValue.Name() != ".." {
+			fileItem.owner = m.owners.owner(fileItem.rawValue)
+		}
+	}
+
+	visible := items
+	hidden := 0
+	if !m.showHidden {
+		visible = nil
+		for _, listItem := range items {
+			if name := listItem.(*item).rawValue.Name(); name != ".." && strings.HasPrefix(name, ".") {
+				hidden++
+				continue
+			}
+			visible = append(visible, listItem)
+		}
+	}
+
+	visible, filtered := m.filterPattern(visible)
+	if m.columns {
+		// The owner column is as wide as the widest listed
+		m.List.SetDelegate(newColumnDelegate(visible))
+	}
+
+	// Count the hidden files next to the items in the status bar
+	var notShown []string
+	if hidden > 0 {
+		notShown = append(notShown, fmt.Sprintf("%d hidden", hidden))
+	}
+	if filtered > 0 {
+		notShown = append(notShown, fmt.Sprintf("%d filtered out", filtered))
+	}
+	if len(notShown) > 0 {
+		suffix := ", " + strings.Join(notShown, ", ")
+		m.List.SetStatusBarItemName("item"+suffix, "items"+suffix)
+	} else {
+		m.List.SetStatusBarItemName("item", "items")
+	}
+	return m.List.SetItems(visible)
+}
+
+// Show the state of the browser in the title bar
+func (m *Model) updateTitle() {
+	m.List.Title = fmt.Sprintf("File List · %s", m.sortMode)
+	if m.pattern != nil {
+		m.List.Title += fmt.Sprintf(" · %s", m.pattern.expr)
+	}
+	if m.watcher != nil {
+		m.List.Title += fmt.Sprintf(" · watching %s", filepath.Base(m.watcher.localDir))
+	}
+}
+
+// Fit the list in the space left by the panes
+func (m *Model) resize() {
+	h, v := docStyle.GetFrameSize()
+	// Leave a line for the header and one for the footer
+	listHeight := m.height - v - 2
+	if m.showQueue {
+		listHeight -= queuePaneHeight
+	}
+	if m.showLog {
+		listHeight -= logPaneHeight
+	}
+	m.List.SetSize(m.width-h, listHeight)
+	// Leave room for the transfer stats next to the progress bar
+	m.progress.Width = (m.width - h) / 3
+	if m.progress.Width > 40 {
+		m.progress.Width = 40
+	}
+	if m.search != nil {
+		m.search.results.SetSize(m.width-h, listHeight)
+	}
+	if m.bookmarks != nil {
+		m.bookmarks.SetSize(m.width-h, listHeight)
+	}
+	if m.trashList != nil {
+		m.trashList.SetSize(m.width-h, listHeight)
+	}
+	if m.history != nil {
+		m.history.SetSize(m.width-h, listHeight)
+	}
+	if m.previewName != "" {
+		m.resizePreview(m.width-h, listHeight)
+	}
+}
+
+// Highlight the entry with the given name, if it's listed
+func (m *Model) selectItem(name string) {
+	for i, listItem := range m.List.Items() {
+		if listItem.(*item).rawValue.Name() == name {
+			m.List.Select(i)
+			return
+		}
+	}
+}
+
+// Mark or unmark the highlighted item and move to the next one
+func (m *Model) toggleMark() tea.Cmd {
+	selectedItem, ok := m.List.SelectedItem().(*item)
+	if !ok || selectedItem.rawValue.Name() == ".." {
+		return nil
+	}
+	selectedItem.marked = !selectedItem.marked
+	m.List.CursorDown()
+	return m.List.NewStatusMessage(statusMessageStyle(fmt.Sprintf("%d marked", len(m.markedItems()))))
+}
+
+// Get the marked items of the current directory
+func (m *Model) markedItems() []*item {
+	var marked []*item
+	for _, listItem := range m.List.Items() {
+		if i, ok := listItem.(*item); ok && i.marked {
+			marked = append(marked, i)
+		}
+	}
+	return marked
+}
+
+// Get the items the batch operations act on: the marked ones or, when
+// nothing is marked, the highlighted one
+func (m *Model) targetItems() []*item {
+	if marked := m.markedItems(); len(marked) > 0 {
+		return marked
+	}
+	if selectedItem, ok := m.List.SelectedItem().(*item); ok && selectedItem.rawValue.Name() != ".." {
+		return []*item{selectedItem}
+	}
+	return nil
+}
+
+// Enter the directory, following the symlinks, or download the file
+func (m *Model) enterItem(selectedItem *item) tea.Cmd {
+	if selectedItem.isSymlink() && selectedItem.isDir() {
+		return m.followLink(selectedItem)
+	}
+	if selectedItem.isDir() {
+		return m.moveDir(selectedItem.rawValue.Name())
+	}
+	return m.downloadFiles([]*item{selectedItem}, m.localDir)
+}
+
+// Enter the directory relative to the current one
+func (m *Model) moveDir(name string) tea.Cmd {
+	return m.changeDir(m.remote.Join(m.currentDir, name), fmt.Sprintf("Entered %s", name))
+}
+
+// Enter the directory the symlink points to
+func (m *Model) followLink(link *item) tea.Cmd {
+	target := link.linkTarget
+	if !path.IsAbs(target) {
+		target = m.remote.Join(m.currentDir, target)
+	}
+	return m.changeDir(target, fmt.Sprintf("Entered %s → %s", link.rawValue.Name(), link.linkTarget))
+}
+
+// Read the directory in the background and show it once loaded, a big one is
+// shown while it loads. Another directory already visited is shown right away
+// from the cache.
+func (m *Model) changeDir(dirPath, status string) tea.Cmd {
+	if dirPath != m.currentDir {
+		if items, ok := m.cache.get(dirPath); ok {
+			return func() tea.Msg {
+				return dirListingMsg{path: dirPath, items: items, status: status, cached: true}
+			}
+		}
+	}
+	remote, requests := m.remote, m.requests
 	return func() tea.Msg {
-		var srcFile io.Reader
-		srcFile, err := m.SftpClient.Open(m.SftpClient.Join(m.currentDir, fileItem.Name()))
-		handleError(err)
-		// Instrument with our counter.
-		barPercentage := barPercentage(0)
-		counter := &writeProgressCounter{
-			TotalFileSize: fileItem.Size(),
-			percentage:    &barPercentage,
+		done := requests.start("Listing " + dirPath)
+		defer done()
+		realPath, err := remote.RealPath(dirPath)
+		if err != nil {
+			return errorMsg{err: err}
+		}
+		fileList, err := remote.ReadDir(realPath)
+		if err != nil {
+			return errorMsg{err: err}
+		}
+		slog.Debug("listed", "path", realPath, "entries", len(fileList))
+		if len(fileList) > loadBatch {
+			load := &dirLoad{path: realPath, status: status, total: len(fileList)}
+			return loadBatches(remote, load, fileList, true)()
+		}
+		return dirListingMsg{
+			path:   realPath,
+			items:  append([]list.Item{&item{rawValue: &PreviousDir{}}}, newItems(remote, realPath, fileList)...),
+			status: status,
+		}
+	}
+}
+
+// Ask to delete the items, directories are deleted with all their content.
+// With the trash turned on the items are moved there instead.
+func (m *Model) deleteItems(items []*item) {
+	if len(items) == 0 {
+		return
+	}
+
+	var remotePaths []string
+	hasDirs := false
+	for _, i := range items {
+		remotePaths = append(remotePaths, m.remote.Join(m.currentDir, i.rawValue.Name()))
+		hasDirs = hasDirs || i.rawValue.IsDir()
+	}
+	if m.dryRun {
+		var lines []string
+		for n, remotePath := range remotePaths {
+			switch {
+			case m.trash != "":
+				lines = append(lines, fmt.Sprintf("move %s to the trash", remotePath))
+			case items[n].rawValue.IsDir():
+				lines = append(lines, fmt.Sprintf("delete %s with all its content", remotePath))
+			default:
+				lines = append(lines, fmt.Sprintf("delete %s", remotePath))
+			}
+		}
+		m.reportDryRun(lines...)
+		return
+	}
+	if m.trash != "" {
+		question := fmt.Sprintf("Move %d items to the trash?", len(items))
+		if len(items) == 1 {
+			question = fmt.Sprintf("Move %s to the trash?", items[0].rawValue.Name())
 		}
-		srcFile = io.TeeReader(srcFile, counter)
+		m.askConfirmation(question, m.trashItems(remotePaths))
+		return
+	}
 
-		destFile, err := os.Create(filepath.Join(".", fileItem.Name()))
-		handleError(err)
-		go func() {
-			defer destFile.Close()
-			_, err = io.Copy(destFile, srcFile)
-			handleError(err)
-		}()
-		return &barPercentage
+	question := fmt.Sprintf("Delete %d items?", len(items))
+	if len(items) == 1 {
+		question = fmt.Sprintf("Delete %s?", items[0].rawValue.Name())
 	}
+	if hasDirs {
+		question += "\nDirectories are deleted with all their content."
+	}
+
+	remote := m.remote
+	currentDir := m.currentDir
+	m.askConfirmation(question, func() tea.Msg {
+		for _, remotePath := range remotePaths {
+			if err := remotefs.RemoveAll(remote, remotePath); err != nil {
+				err = fmt.Errorf("deleting %s failed: %v", path.Base(remotePath), err)
+				return tea.Batch(reportError(err), m.changeDir(currentDir, ""))()
+			}
+		}
+		return m.changeDir(currentDir, fmt.Sprintf("Deleted %d items", len(remotePaths)))()
+	})
+}
+
+// Ask the new name of the highlighted item, or the directory where the
+// marked items are moved
+func (m *Model) openRenamePrompt() tea.Cmd {
+	items := m.targetItems()
+	switch {
+	case len(items) == 0:
+		return nil
+	case len(items) == 1 && !items[0].marked:
+		return m.openPrompt(renamePrompt, "Rename to: ", items[0].rawValue.Name())
+	default:
+		return m.openPrompt(renamePrompt, fmt.Sprintf("Move %d items to: ", len(items)), m.currentDir)
+	}
+}
+
+// Rename the items to the destination, relative to the current directory.
+// When the destination is a directory the items are moved inside it.
+func (m *Model) renameItems(items []*item, destination string) tea.Cmd {
+	remote := m.remote
+	currentDir := m.currentDir
+	dryRun := m.dryRun
+	destination = m.resolvePath(destination)
+	return func() tea.Msg {
+		destInfo, err := remote.Stat(destination)
+		isDir := err == nil && destInfo.IsDir()
+		if len(items) > 1 && !isDir {
+			return errorMsg{err: fmt.Errorf("%s is not a directory", destination)}
+		}
+
+		status := fmt.Sprintf("Moved %d items to %s", len(items), destination)
+		var renames []string
+		for _, i := range items {
+			newPath := destination
+			if isDir {
+				newPath = remote.Join(destination, i.rawValue.Name())
+			}
+			if dryRun {
+				renames = append(renames, fmt.Sprintf("rename %s to %s", remote.Join(currentDir, i.rawValue.Name()), newPath))
+				continue
+			}
+			if err := remote.Rename(remote.Join(currentDir, i.rawValue.Name()), newPath); err != nil {
+				err = fmt.Errorf("renaming %s failed: %v", i.rawValue.Name(), err)
+				return tea.Batch(reportError(err), m.changeDir(currentDir, ""))()
+			}
+			if len(items) == 1 {
+				status = fmt.Sprintf("Renamed %s to %s", i.rawValue.Name(), newPath)
+			}
+		}
+		if dryRun {
+			return dryRunMsg{lines: renames}
+		}
+		return m.changeDir(currentDir, status)()
+	}
+}
+
+// Create the directory, and its missing parents, relative to the current one
+func (m *Model) makeDir(name string) tea.Cmd {
+	remote := m.remote
+	currentDir := m.currentDir
+	dirPath := m.resolvePath(name)
+	return func() tea.Msg {
+		if err := remote.MkdirAll(dirPath); err != nil {
+			return errorMsg{err: fmt.Errorf("creating %s failed: %v", name, err)}
+		}
+		return m.changeDir(currentDir, fmt.Sprintf("Created %s", name))()
+	}
+}
+
+// Upload the local files matching the pattern into the current directory
+func (m *Model) uploadFiles(pattern string) tea.Cmd {
+	pattern = expandLocalPath(pattern, m.localDir)
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return reportError(err)
+	}
+	if len(matches) == 0 {
+		return reportError(fmt.Errorf("no files matching %s", pattern))
+	}
+	return m.uploadPaths(matches)
+}
+
+// Message asking to upload the local paths into the current directory
+type uploadPathsMsg struct {
+	paths []string
+}
+
+// Upload the local files and directories into the current directory, once
+// checked there's room for them
+func (m *Model) uploadPaths(paths []string) tea.Cmd {
+	var files, dirs []string
+	var size int64
+	for _, localPath := range paths {
+		fileInfo, err := os.Stat(localPath)
+		switch {
+		case err != nil:
+			continue
+		case fileInfo.IsDir():
+			dirs = append(dirs, localPath)
+			size += localSize(localPath)
+		default:
+			files = append(files, localPath)
+			size += fileInfo.Size()
+		}
+	}
+	if len(files) == 0 && len(dirs) == 0 {
+		return reportError(fmt.Errorf("none of %s can be read", strings.Join(paths, ", ")))
+	}
+	return m.checkFreeSpace(size, func(m *Model) tea.Cmd {
+		return m.uploadMatches(files, dirs)
+	})
+}
+
+// Upload the files and, asking how, the directories into the current one
+func (m *Model) uploadMatches(files, dirs []string) tea.Cmd {
+	if len(dirs) == 0 {
+		return m.queueUploads(files)
+	}
+
+	question := fmt.Sprintf("%s is a directory", filepath.Base(dirs[0]))
+	if len(dirs) > 1 {
+		question = fmt.Sprintf("%d of the matches are directories", len(dirs))
+	}
+	m.askDirTransfer(
+		question,
+		false,
+		func(m *Model, mode dirTransfer) tea.Cmd {
+			return tea.Batch(m.uploadDirs(dirs, mode == dirTarStream), m.queueUploads(files))
+		},
+		func(m *Model) tea.Cmd {
+			return m.queueUploads(files)
+		},
+	)
+	return nil
+}
+
+// Queue the upload of the local files into the current directory, the ones
+// whose remote file already exists follow the conflict policy
+func (m *Model) queueUploads(localPaths []string) tea.Cmd {
+	return m.queueUploadsWith(localPaths, m.onConflict)
+}
+
+// Queue the uploads resolving the conflicts with the policy, the remote
+// files are the ones listed in the current directory. Asking stops at the
+// first conflict, the next files are queued once answered.
+func (m *Model) queueUploadsWith(localPaths []string, policy conflict.Policy) tea.Cmd {
+	var cmds []tea.Cmd
+	skipped := 0
+	for i, localPath := range localPaths {
+		fileInfo, err := os.Stat(localPath)
+		if err != nil {
+			continue
+		}
+		remoteInfo, exists := m.listedEntry(fileInfo.Name())
+		if !exists {
+			cmds = append(cmds, m.queueUpload(localPath, m.remote.Join(m.currentDir, fileInfo.Name()), fileInfo.Size(), false))
+			continue
+		}
+		if policy == conflict.Ask {
+			localPath, rest := localPath, localPaths[i+1:]
+			m.askConflict(m.remote.Join(m.currentDir, fileInfo.Name()), len(rest) > 0, func(m *Model, picked conflict.Policy, all bool) tea.Cmd {
+				return tea.Batch(m.resolveUpload(localPath, fileInfo, remoteInfo, picked), m.queueUploadsWith(rest, nextPolicy(picked, all)))
+			})
+			break
+		}
+		cmd := m.resolveUpload(localPath, fileInfo, remoteInfo, policy)
+		if cmd == nil {
+			skipped++
+		}
+		cmds = append(cmds, cmd)
+	}
+	return tea.Batch(append(cmds, m.skippedStatus(skipped))...)
+}
+
+// Upload the file whose remote file exists as the policy says, nil when
+// it's skipped
+func (m *Model) resolveUpload(localPath string, localInfo, remoteInfo fs.FileInfo, policy conflict.Policy) tea.Cmd {
+	name, resume := localInfo.Name(), false
+	switch policy.Decide(localInfo.Size(), localInfo.ModTime(), remoteInfo) {
+	case conflict.SkipFile:
+		return nil
+	case conflict.RenameFile:
+		name = conflict.UniqueName(name, func(name string) bool {
+			_, exists := m.listedEntry(name)
+			return exists
+		})
+	case conflict.ResumeFile:
+		resume = true
+	}
+	return m.queueUpload(localPath, m.remote.Join(m.currentDir, name), localInfo.Size(), resume)
+}
+
+// Get the entry of the current directory with the name, hidden or not
+func (m *Model) listedEntry(name string) (fs.FileInfo, bool) {
+	for _, listItem := range m.dirItems {
+		if fileInfo := listItem.(*item).rawValue; fileInfo.Name() == name {
+			return fileInfo, true
+		}
+	}
+	return nil, false
+}
+
+// Queue the upload of the local file to the remote path, continuing the
+// remote file when resuming
+func (m *Model) queueUpload(localPath, remotePath string, size int64, resume bool) tea.Cmd {
+	sshClient, verify := m.sshClient, m.verify
+	// Copy from the start, or resume the copy already there
+	copyWith := func(remote remotefs.FileSystem, resume bool) func(counter io.Writer) error {
+		copyFunc := func(counter io.Writer) error {
+			if resume {
+				return resumeUpload(remote, localPath, remotePath, counter)
+			}
+			return uploadFile(remote, localPath, remotePath, counter)
+		}
+		if verify {
+			copyFunc = verifiedCopy(sshClient, remote, localPath, remotePath, copyFunc)
+		}
+		return copyFunc
+	}
+	return m.queue.add(&transfer{
+		name:        path.Base(remotePath),
+		source:      localPath,
+		destination: remotePath,
+		file:        true,
+		upload:      true,
+		resumed:     resume,
+		total:       size,
+		copyFunc:    copyWith(m.remote, resume),
+		resumeFunc: func(remote remotefs.FileSystem, counter io.Writer) error {
+			return copyWith(remote, true)(counter)
+		},
+	})
+}
+
+// Copy the local file to the remote path
+func uploadFile(remote remotefs.FileSystem, localPath, remotePath string, counter io.Writer) error {
+	srcFile, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	destFile, err := remote.Create(remotePath)
+	if err != nil {
+		return err
+	}
+	defer destFile.Close()
+
+	// Instrument with our counter.
+	_, err = io.Copy(destFile, io.TeeReader(srcFile, counter))
+	return err
 }
 
 func (m Model) View() string {
-	f, err := tea.LogToFile("debug.log", "debug")
-	handleError(err)
-	f.WriteString(fmt.Sprintf("Percentuale: %f", m.progress.Percent()))
-	// Don't render the progress bar if theres no donwload or if the download is finished
-	if m.progress.Percent() != 0 && m.progress.Percent() != 1 {
-		// Renders the progress bar TODO needs some work
-		return docStyle.Render(
-			lipgloss.JoinHorizontal(
+	if m.confirmation != nil {
+		return m.confirmationView()
+	}
+	if m.permissions != nil {
+		return m.permissionsView()
+	}
+	if m.disconnected {
+		return m.disconnectedView()
+	}
+	if m.showHelp {
+		return m.helpView()
+	}
+	if m.info != "" {
+		return m.infoView()
+	}
+	if m.previewName != "" {
+		return docStyle.Render(lipgloss.JoinVertical(lipgloss.Left, m.headerView(), m.previewView(), m.footerView()))
+	}
+	if m.search != nil {
+		return docStyle.Render(lipgloss.JoinVertical(lipgloss.Left, m.headerView(), m.search.results.View(), m.footerView()))
+	}
+	if m.bookmarks != nil {
+		return docStyle.Render(lipgloss.JoinVertical(lipgloss.Left, m.headerView(), m.bookmarks.View(), m.footerView()))
+	}
+	if m.trashList != nil {
+		return docStyle.Render(lipgloss.JoinVertical(lipgloss.Left, m.headerView(), m.trashList.View(), m.footerView()))
+	}
+	if m.history != nil {
+		return docStyle.Render(lipgloss.JoinVertical(lipgloss.Left, m.headerView(), m.history.View(), m.footerView()))
+	}
+	return docStyle.Render(lipgloss.JoinVertical(lipgloss.Left, m.headerView(), m.List.View(), m.footerView()))
+}
+
+// Render the line below the list
+func (m Model) footerView() string {
+	var footer string
+	switch {
+	case m.promptAction != noPrompt:
+		footer = m.prompt.View()
+	case m.err != nil:
+		footer = m.errorView()
+	default:
+		if pending, active := m.queue.counts(); pending+active > 0 {
+			// Show the running transfers without blocking the navigation
+			footer = lipgloss.JoinHorizontal(
 				lipgloss.Center,
 				m.progress.View(),
-				// lipgloss.NewStyle().Render("Banana"),
-			),
-		)
-	} else {
-		// Renders the file list
-		return docStyle.Render(m.List.View())
+				" ",
+				statusMessageStyle(fmt.Sprintf("%s · %d active, %d pending", m.queue.statsView(), active, pending)),
+			)
+		}
+	}
+	if m.showQueue {
+		footer = lipgloss.JoinVertical(lipgloss.Left, m.queue.View(), footer)
 	}
+	if m.showLog {
+		// The log stays in view while the prompt or the error are shown
+		h, _ := docStyle.GetFrameSize()
+		footer = lipgloss.JoinVertical(lipgloss.Left, m.log.View(m.width-h), footer)
+	}
+	return footer
 }
 
 // Create the list of item by fetching the server
-func CreateItemListModel(dirPath string, sftpClient *sftp.Client) []list.Item {
-	fileList, err := sftpClient.ReadDir(dirPath)
-	handleError(err)
+func CreateItemListModel(dirPath string, remote remotefs.FileSystem) ([]list.Item, error) {
+	fileList, err := remote.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
 
 	previousDir := PreviousDir{}
 	// Insert the .. dir
@@ -167,8 +1153,21 @@ func CreateItemListModel(dirPath string, sftpClient *sftp.Client) []list.Item {
 		},
 	}
 
+	return append(items, newItems(remote, dirPath, fileList)...), nil
+}
+
+// Create the items of the entries of the directory
+func newItems(remote remotefs.FileSystem, dirPath string, fileList []os.FileInfo) []list.Item {
+	items := make([]list.Item, 0, len(fileList))
 	for _, file := range fileList {
-		items = append(items, &item{rawValue: file})
+		fileItem := &item{rawValue: file}
+		if fileItem.isSymlink() {
+			// Resolve the link to show where it points and follow it
+			linkPath := remote.Join(dirPath, file.Name())
+			fileItem.linkTarget, _ = remote.ReadLink(linkPath)
+			fileItem.target, _ = remote.Stat(linkPath)
+		}
+		items = append(items, fileItem)
 	}
 	return items
 }
@@ -0,0 +1,190 @@
+package tui
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"testing"
+
+	"github.com/guglielmobartelloni/sftp-tui/remotefs"
+)
+
+// Create a memory filesystem holding the files, with their name as
+// content, and the directories, the paths ending with a slash. The missing
+// parents are created.
+func memoryServer(t *testing.T, paths ...string) *remotefs.Memory {
+	t.Helper()
+	remote := remotefs.NewMemory()
+	for _, p := range paths {
+		if p[len(p)-1] == '/' {
+			if err := remote.MkdirAll(p); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := remote.MkdirAll(path.Dir(p)); err != nil {
+			t.Fatal(err)
+		}
+		file, err := remote.Create(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(file, p)
+		file.Close()
+	}
+	return remote
+}
+
+// Create the model browsing the directory of the memory filesystem
+func memoryModel(t *testing.T, remote remotefs.FileSystem, dir string, settings Settings) Model {
+	t.Helper()
+	settings.LocalDir = t.TempDir()
+	currentDir, items, err := openStartDir(remote, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := newTabModel(remote, currentDir, items, settings, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// Get the items of the model with the names
+func namedItems(t *testing.T, m Model, names ...string) []*item {
+	t.Helper()
+	var found []*item
+	for _, name := range names {
+		for _, listItem := range m.List.Items() {
+			if i := listItem.(*item); i.rawValue.Name() == name {
+				found = append(found, i)
+			}
+		}
+	}
+	if len(found) != len(names) {
+		t.Fatalf("found %d of the items %v", len(found), names)
+	}
+	return found
+}
+
+func exists(remote remotefs.FileSystem, name string) bool {
+	_, err := remote.Lstat(name)
+	return !errors.Is(err, fs.ErrNotExist)
+}
+
+func TestCreateItemListModel(t *testing.T) {
+	remote := memoryServer(t, "/home/dir/", "/home/file")
+	remote.Symlink("file", "/home/link")
+	remote.Symlink("missing", "/home/broken")
+
+	items, err := CreateItemListModel("/home", remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, listItem := range items {
+		names = append(names, listItem.(*item).rawValue.Name())
+	}
+	want := []string{"..", "broken", "dir", "file", "link"}
+	if len(names) != len(want) {
+		t.Fatalf("listed %v, want %v", names, want)
+	}
+	for n := range want {
+		if names[n] != want[n] {
+			t.Fatalf("listed %v, want %v", names, want)
+		}
+	}
+
+	link := items[4].(*item)
+	if !link.isSymlink() || link.linkTarget != "file" || link.target == nil || link.target.IsDir() {
+		t.Errorf("the link is %+v", link)
+	}
+	if broken := items[1].(*item); broken.target != nil {
+		t.Errorf("the broken link points to %v", broken.target)
+	}
+	if _, err := CreateItemListModel("/missing", remote); err == nil {
+		t.Error("a missing directory was listed")
+	}
+}
+
+func TestRenameItems(t *testing.T) {
+	remote := memoryServer(t, "/home/a", "/home/b", "/home/c", "/home/dir/")
+	m := memoryModel(t, remote, "/home", Settings{})
+
+	msg := m.renameItems(namedItems(t, m, "a"), "renamed")()
+	if listing, ok := msg.(dirListingMsg); !ok || listing.path != "/home" {
+		t.Fatalf("the rename ended with %#v", msg)
+	}
+	if exists(remote, "/home/a") || !exists(remote, "/home/renamed") {
+		t.Error("the file wasn't renamed")
+	}
+	updated, _ := m.Update(msg)
+	m = updated.(Model)
+
+	// Moved inside the directory
+	m.renameItems(namedItems(t, m, "b", "c"), "dir")()
+	for _, name := range []string{"/home/dir/b", "/home/dir/c"} {
+		if !exists(remote, name) {
+			t.Errorf("%s wasn't moved", name)
+		}
+	}
+
+	msg = m.renameItems(namedItems(t, m, "renamed", "dir"), "renamed")()
+	if _, ok := msg.(errorMsg); !ok {
+		t.Errorf("moving into a file ended with %#v", msg)
+	}
+}
+
+func TestRenameItemsDryRun(t *testing.T) {
+	remote := memoryServer(t, "/home/a")
+	m := memoryModel(t, remote, "/home", Settings{DryRun: true})
+
+	msg := m.renameItems(namedItems(t, m, "a"), "b")()
+	if _, ok := msg.(dryRunMsg); !ok {
+		t.Errorf("the dry run ended with %#v", msg)
+	}
+	if !exists(remote, "/home/a") {
+		t.Error("the file was renamed in a dry run")
+	}
+}
+
+func TestDeleteItems(t *testing.T) {
+	remote := memoryServer(t, "/home/dir/sub/", "/home/dir/sub/file", "/home/file", "/home/kept")
+	m := memoryModel(t, remote, "/home", Settings{})
+
+	m.deleteItems(namedItems(t, m, "dir", "file"))
+	if m.confirmation == nil {
+		t.Fatal("the deletion wasn't confirmed")
+	}
+	if !exists(remote, "/home/dir") {
+		t.Fatal("deleted before the confirmation")
+	}
+	yes := m.confirmation.choices[0]
+	msg := yes.action(&m)()
+	if listing, ok := msg.(dirListingMsg); !ok || listing.status != "Deleted 2 items" {
+		t.Fatalf("the deletion ended with %#v", msg)
+	}
+	if exists(remote, "/home/dir") || exists(remote, "/home/file") || !exists(remote, "/home/kept") {
+		t.Error("the wrong items were deleted")
+	}
+}
+
+func TestDeleteItemsToTrash(t *testing.T) {
+	remote := memoryServer(t, "/home/file")
+	m := memoryModel(t, remote, "/home", Settings{Trash: "/trash"})
+
+	m.deleteItems(namedItems(t, m, "file"))
+	msg := m.confirmation.choices[0].action(&m)()
+	trashed, ok := msg.(trashedMsg)
+	if !ok || trashed.err != nil || len(trashed.entries) != 1 {
+		t.Fatalf("the deletion ended with %#v", msg)
+	}
+	if exists(remote, "/home/file") {
+		t.Error("the file is still there")
+	}
+	entries, err := remote.ReadDir("/trash")
+	if err != nil || len(entries) == 0 {
+		t.Errorf("the trash holds %v, %v", entries, err)
+	}
+}
@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"io/fs"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/knipferrc/teacup/icons"
 )
@@ -63,9 +67,32 @@ func getFileIcon(value fs.FileInfo) string {
 	return icon
 }
 
-// Utility function to handle errors
-func handleError(err error) {
-	if err != nil {
-		panic(err)
+// Make the name safe to show on a line: the control characters, like a
+// newline or an escape, the ones reversing the text and the bytes that
+// aren't UTF-8 are shown escaped like in Go strings
+func displayName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); {
+		r, size := utf8.DecodeRuneInString(name[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&b, `\x%02x`, name[i])
+		case unicode.IsControl(r) || unicode.Is(unicode.Bidi_Control, r):
+			quoted := strconv.QuoteRuneToASCII(r)
+			b.WriteString(quoted[1 : len(quoted)-1])
+		default:
+			b.WriteRune(r)
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// Make the text safe to show like the names, keeping its lines
+func displayText(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = displayName(line)
 	}
+	return strings.Join(lines, "\n")
 }
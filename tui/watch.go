@@ -0,0 +1,223 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fsnotify/fsnotify"
+	"github.com/guglielmobartelloni/sftp-tui/mirror"
+)
+
+// How long the changes are collected before uploading them, editors write a
+// file in a few steps
+const watchDelay = 500 * time.Millisecond
+
+// Message with the local files changed in the watched directory
+type watchChangesMsg struct {
+	id    int      // the watcher, a stopped one is ignored
+	paths []string // the files and directories created or written
+}
+
+// Watches a local directory, its changes are uploaded to the remote one
+type watcher struct {
+	id        int
+	localDir  string
+	remoteDir string
+	fsw       *fsnotify.Watcher
+	changes   chan []string
+	done      chan struct{}
+}
+
+// Start watching the local directory and its subdirectories
+func startWatcher(id int, localDir, remoteDir string) (*watcher, error) {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &watcher{
+		id:        id,
+		localDir:  localDir,
+		remoteDir: remoteDir,
+		fsw:       fsw,
+		changes:   make(chan []string),
+		done:      make(chan struct{}),
+	}
+	if err := w.addDirs(localDir); err != nil {
+		fsw.Close()
+		return nil, err
+	}
+	go w.run()
+	return w, nil
+}
+
+// Watch the directory and the ones inside it, fsnotify isn't recursive
+func (w *watcher) addDirs(dir string) error {
+	return filepath.Walk(dir, func(filePath string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fileInfo.IsDir() {
+			return w.fsw.Add(filePath)
+		}
+		return nil
+	})
+}
+
+// Collect the changes and send them once no more come for a while
+func (w *watcher) run() {
+	defer close(w.changes)
+	pending := make(map[string]bool)
+	var timer <-chan time.Time
+	for {
+		select {
+		case event, ok := <-w.fsw.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Create|fsnotify.Write) == 0 || isTempFile(event.Name) {
+				continue
+			}
+			if fileInfo, err := os.Stat(event.Name); err == nil && fileInfo.IsDir() && event.Op&fsnotify.Create != 0 {
+				w.addDirs(event.Name)
+			}
+			pending[event.Name] = true
+			timer = time.After(watchDelay)
+		case _, ok := <-w.fsw.Errors:
+			if !ok {
+				return
+			}
+		case <-timer:
+			paths := make([]string, 0, len(pending))
+			for changed := range pending {
+				paths = append(paths, changed)
+			}
+			sort.Strings(paths)
+			pending = make(map[string]bool)
+			timer = nil
+			select {
+			case w.changes <- paths:
+			case <-w.done:
+				return
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Tell if the file is one of the temporary ones written by the editors
+func isTempFile(filePath string) bool {
+	name := filepath.Base(filePath)
+	return strings.HasSuffix(name, "~") ||
+		strings.HasSuffix(name, ".swp") ||
+		strings.HasSuffix(name, ".swx") ||
+		strings.HasPrefix(name, ".#") ||
+		name == "4913"
+}
+
+// Wait for the next changes of the watched directory
+func (w *watcher) wait() tea.Cmd {
+	return func() tea.Msg {
+		paths, ok := <-w.changes
+		if !ok {
+			return nil
+		}
+		return watchChangesMsg{id: w.id, paths: paths}
+	}
+}
+
+// Stop watching the directory
+func (w *watcher) close() {
+	close(w.done)
+	w.fsw.Close()
+}
+
+// Start watching the local directory, its new and changed files are uploaded
+// to the current remote directory. Watching again stops.
+func (m *Model) toggleWatch() tea.Cmd {
+	if m.watcher != nil {
+		m.watcher.close()
+		m.watcher = nil
+		m.updateTitle()
+		return m.setStatus("Stopped watching")
+	}
+	return m.openPrompt(watchPrompt, fmt.Sprintf("Upload to %s the changes of the local directory: ", m.currentDir), m.localDir)
+}
+
+// Watch the local directory, mapped to the current remote one
+func (m *Model) startWatch(localDir string) tea.Cmd {
+	localDir = expandLocalPath(localDir, m.localDir)
+	id := 1
+	if m.watcher != nil {
+		id = m.watcher.id + 1
+		m.watcher.close()
+	}
+	w, err := startWatcher(id, localDir, m.currentDir)
+	if err != nil {
+		return reportError(fmt.Errorf("watching %s failed: %v", localDir, err))
+	}
+	m.watcher = w
+	m.updateTitle()
+	return tea.Batch(
+		m.setStatus(fmt.Sprintf("Watching %s, the changes are uploaded to %s", localDir, m.currentDir)),
+		w.wait(),
+	)
+}
+
+// Upload the changed files to the remote directory, the new directories
+// are pushed with all their content
+func (m *Model) uploadChanges(msg watchChangesMsg) tea.Cmd {
+	w := m.watcher
+	if w == nil || w.id != msg.id {
+		return nil
+	}
+	remote := m.remote
+	cmds := []tea.Cmd{w.wait()}
+	for _, localPath := range msg.paths {
+		rel, err := filepath.Rel(w.localDir, localPath)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		fileInfo, err := os.Stat(localPath)
+		if err != nil {
+			// Gone already
+			continue
+		}
+		localPath, remotePath := localPath, path.Join(w.remoteDir, filepath.ToSlash(rel))
+		if fileInfo.IsDir() {
+			cmds = append(cmds, func() tea.Msg {
+				actions, err := mirror.PlanPush(remote, localPath, remotePath, false)
+				if err != nil {
+					return errorMsg{err: fmt.Errorf("comparing %s with %s failed: %v", localPath, remotePath, err)}
+				}
+				return m.runSync(true, actions, false)()
+			})
+			continue
+		}
+		if !fileInfo.Mode().IsRegular() {
+			continue
+		}
+		cmds = append(cmds, m.queue.add(&transfer{
+			name:        filepath.ToSlash(rel),
+			source:      localPath,
+			destination: remotePath,
+			file:        true,
+			upload:      true,
+			total:       fileInfo.Size(),
+			copyFunc: func(counter io.Writer) error {
+				if err := remote.MkdirAll(path.Dir(remotePath)); err != nil {
+					return err
+				}
+				return uploadFile(remote, localPath, remotePath, counter)
+			},
+		}))
+	}
+	return tea.Batch(cmds...)
+}
@@ -1,19 +1,55 @@
 package tui
 
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/guglielmobartelloni/sftp-tui/throttle"
+)
+
+// How often the progress of a transfer is reported
+const progressInterval = 100 * time.Millisecond
+
 // writeProgressCounter counts the number of bytes written to it.
 type writeProgressCounter struct {
-	BytesWritten  int64 // Total # of bytes written
-	TotalFileSize int64 // Total file size
-	percentage    *barPercentage // Write percentage calculated
+	BytesWritten  int64             // Total # of bytes written
+	TotalFileSize int64             // Total file size
+	id            int               // Id of the transfer
+	updates       chan tea.Msg      // Where the progress is reported
+	lastUpdate    time.Time         // When the progress was last reported
+	limiter       *throttle.Limiter // Slows down the copy to the rate limit
+	stop          <-chan struct{}   // Closed when the copy has to stop
 }
 
 // Write implements the io.Writer interface.
 //
-// Always completes and never returns an error.
+// Fails only once the copy has to stop, the copy tee'd into it stops too.
 func (wc *writeProgressCounter) Write(p []byte) (int, error) {
+	select {
+	case <-wc.stop:
+		return 0, errTransferCanceled
+	default:
+	}
 	n := len(p)
+	// Blocking here holds the copy, the counter is tee'd into it
+	wc.limiter.Wait(n)
 	wc.BytesWritten += int64(n)
-	*wc.percentage = barPercentage(100 * wc.BytesWritten / wc.TotalFileSize)
+
+	if time.Since(wc.lastUpdate) >= progressInterval {
+		wc.lastUpdate = time.Now()
+		wc.updates <- transferProgressMsg{
+			id:          wc.id,
+			transferred: wc.BytesWritten,
+			updates:     wc.updates,
+		}
+	}
 
 	return n, nil
 }
+
+// Start counting again from the offset, for the next attempt of the copy
+func (wc *writeProgressCounter) restart(offset int64) {
+	wc.BytesWritten = offset
+	// Report it with the next write
+	wc.lastUpdate = time.Time{}
+}